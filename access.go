@@ -0,0 +1,41 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/elastic/package-registry/internal/auth"
+	"github.com/elastic/package-registry/packages"
+)
+
+// accessMiddleware sets in the context of the requests the access restrictions that apply
+// to the caller, according to the scopes of the authenticated user.
+func accessMiddleware(rules packages.AccessRules) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			restrictions := rules.Restrictions(auth.Scopes(r.Context()))
+			ctx := packages.ContextWithAccessRestrictions(r.Context(), restrictions)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// responseCacheKey returns the key used to cache the response of a request. Responses are
+// cached by the set of restrictions of the caller, so they are not shared between callers
+// with access to different packages.
+func responseCacheKey(r *http.Request) string {
+	restrictions := packages.AccessRestrictionsFromContext(r.Context())
+	return restrictions.Key() + " " + r.URL.String()
+}
+
+// responseCacheKeyURL returns the URL of the request of a key returned by responseCacheKey.
+func responseCacheKeyURL(key string) string {
+	_, u, _ := strings.Cut(key, " ")
+	return u
+}
@@ -0,0 +1,85 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/package-registry/internal/auth"
+	"github.com/elastic/package-registry/internal/util"
+	"github.com/elastic/package-registry/packages"
+)
+
+func TestRouterWithVisibilityRules(t *testing.T) {
+	logger := util.NewTestLogger()
+	config := defaultConfig
+	config.Auth = auth.Config{
+		Tokens: []auth.TokenConfig{
+			{Name: "internal", Token: "internal-secret", Scopes: []string{"internal"}},
+			{Name: "other", Token: "other-secret"},
+		},
+	}
+	indexer := NewCombinedIndexer(packages.NewFileSystemIndexer(packages.FSIndexerOptions{Logger: logger}, "./testdata/package"))
+	defer indexer.Close(t.Context())
+	require.NoError(t, indexer.Init(t.Context()))
+
+	authenticator, err := initAuthenticator(logger, &config)
+	require.NoError(t, err)
+	rules, err := packages.NewAccessRules([]packages.AccessRule{
+		{Packages: []string{"examp*"}, Scopes: []string{"internal"}},
+	})
+	require.NoError(t, err)
+	router, err := getRouter(logger, serverOptions{
+		config:        &config,
+		indexer:       indexer,
+		authenticator: authenticator,
+		accessRules:   rules,
+		searchCache:   expirable.NewLRU[string, *jsonResponse](10, nil, time.Minute),
+	})
+	require.NoError(t, err)
+
+	paths := []string{
+		"/package/example/1.0.0/",
+		"/package/example/1.0.0/docs/README.md",
+		"/epr/example/example-1.0.0.zip",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			recorder := serveWithToken(router, path, "other-secret")
+			assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+			recorder = serveWithToken(router, path, "internal-secret")
+			assert.Equal(t, http.StatusOK, recorder.Code)
+		})
+	}
+
+	t.Run("search", func(t *testing.T) {
+		// Requests are repeated to check that cached responses are not shared.
+		for range 2 {
+			recorder := serveWithToken(router, "/search", "other-secret")
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.NotContains(t, recorder.Body.String(), `"name": "example"`)
+
+			recorder = serveWithToken(router, "/search", "internal-secret")
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), `"name": "example"`)
+		}
+	})
+}
+
+func serveWithToken(router http.Handler, path, token string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodGet, path, nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
@@ -33,7 +33,7 @@ type categoriesHandler struct {
 	indexer   Indexer
 	cacheTime time.Duration
 
-	cache                       *expirable.LRU[string, []byte]
+	cache                       *expirable.LRU[string, *jsonResponse]
 	proxyMode                   *proxymode.ProxyMode
 	allowUnknownQueryParameters bool
 }
@@ -68,7 +68,7 @@ func categoriesWithProxy(pm *proxymode.ProxyMode) categoriesOption {
 	}
 }
 
-func categoriesWithCache(cache *expirable.LRU[string, []byte]) categoriesOption {
+func categoriesWithCache(cache *expirable.LRU[string, *jsonResponse]) categoriesOption {
 	return func(h *categoriesHandler) {
 		h.cache = cache
 	}
@@ -83,10 +83,11 @@ func categoriesWithAllowUnknownQueryParameters(allow bool) categoriesOption {
 func (h *categoriesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	logger := h.logger.With(apmzap.TraceContext(r.Context())...)
 
+	cacheKey := responseCacheKey(r)
 	if h.cache != nil {
-		if response, ok := h.cache.Get(r.URL.String()); ok {
+		if response, ok := h.cache.Get(cacheKey); ok {
 			logger.Debug("using as response cached request", zap.String("cache.url", r.URL.String()), zap.Int("cache.size", h.cache.Len()))
-			serveJSONResponse(r.Context(), w, h.cacheTime, response)
+			serveJSONResponse(w, r, h.cacheTime, response)
 			return
 		}
 	}
@@ -108,6 +109,7 @@ func (h *categoriesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	filter.Restrictions = packages.AccessRestrictionsFromContext(r.Context())
 	opts := packages.GetOptions{
 		Filter: filter,
 	}
@@ -118,12 +120,14 @@ func (h *categoriesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	categories := getCategories(r.Context(), pkgs, includePolicyTemplates)
 
+	// Responses are not cached if any upstream failed, so they are complete again once the
+	// upstreams recover.
+	partialResponse := false
 	if h.proxyMode.Enabled() {
 		proxiedCategories, err := h.proxyMode.Categories(r)
 		if err != nil {
-			logger.Error("proxy mode: categories failed", zap.Error(err))
-			http.Error(w, "internal server error", http.StatusInternalServerError)
-			return
+			logger.Warn("proxy mode: categories failed in some upstreams, response includes only the available ones", zap.Error(err))
+			partialResponse = true
 		}
 
 		for _, category := range proxiedCategories {
@@ -147,10 +151,11 @@ func (h *categoriesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	serveJSONResponse(r.Context(), w, h.cacheTime, data)
+	response := newJSONResponse(data)
+	serveJSONResponse(w, r, h.cacheTime, response)
 
-	if h.cache != nil {
-		val := h.cache.Add(r.URL.String(), data)
+	if h.cache != nil && !partialResponse {
+		val := h.cache.Add(cacheKey, response)
 		logger.Debug("added to cache request", zap.String("cache.url", r.URL.String()), zap.Int("cache.size", h.cache.Len()), zap.Bool("cache.eviction", val))
 	}
 }
@@ -323,11 +328,29 @@ func getCategoriesOutput(ctx context.Context, categories map[string]*packages.Ca
 	return util.MarshalJSONPretty(outputCategories)
 }
 
-func serveJSONResponse(ctx context.Context, w http.ResponseWriter, cacheTime time.Duration, data []byte) {
-	span, _ := apm.StartSpan(ctx, "Serve JSON Response", "app")
+func serveJSONResponse(w http.ResponseWriter, r *http.Request, cacheTime time.Duration, response *jsonResponse) {
+	span, _ := apm.StartSpan(r.Context(), "Serve JSON Response", "app")
 	defer span.End()
 
 	cacheHeaders(w, cacheTime)
+
+	body, etag := response.body, response.etag
+	if len(body) >= util.CompressionMinSize {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if encoding := util.NegotiateEncoding(r.Header.Get("Accept-Encoding")); encoding != "" {
+			// Responses are served uncompressed if compression fails.
+			if encoded, encodedETag, err := response.encode(encoding); err == nil {
+				body, etag = encoded, encodedETag
+				w.Header().Set("Content-Encoding", encoding)
+			}
+		}
+	}
+
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	jsonHeader(w)
-	w.Write(data)
+	w.Write(body)
 }
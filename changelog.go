@@ -0,0 +1,144 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/gorilla/mux"
+	"go.elastic.co/apm/module/apmzap/v2"
+	"go.uber.org/zap"
+
+	"github.com/elastic/package-registry/internal/util"
+	"github.com/elastic/package-registry/packages"
+	"github.com/elastic/package-registry/proxymode"
+)
+
+const (
+	changelogRouterPath = "/package/{packageName:[a-z0-9_]+}/{packageVersion}/changelog"
+
+	// changelogRemoteTimeout is the maximum time to get the changelog of remote packages.
+	changelogRemoteTimeout = 30 * time.Second
+)
+
+var errChangelogNotFound = errors.New("changelog not found")
+
+type changelogHandler struct {
+	logger    *zap.Logger
+	indexer   Indexer
+	cacheTime time.Duration
+
+	// client is used to follow the redirections of the remote resolvers.
+	client    *http.Client
+	proxyMode *proxymode.ProxyMode
+}
+
+type changelogOption func(*changelogHandler)
+
+func newChangelogHandler(logger *zap.Logger, indexer Indexer, cacheTime time.Duration, opts ...changelogOption) (*changelogHandler, error) {
+	if indexer == nil {
+		return nil, errors.New("indexer is required for changelog handler")
+	}
+	if cacheTime <= 0 {
+		return nil, errors.New("cache time must be greater than 0s")
+	}
+
+	h := &changelogHandler{
+		logger:    logger,
+		indexer:   indexer,
+		cacheTime: cacheTime,
+		client:    &http.Client{Timeout: changelogRemoteTimeout},
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h, nil
+}
+
+func changelogWithProxy(pm *proxymode.ProxyMode) changelogOption {
+	return func(h *changelogHandler) {
+		h.proxyMode = pm
+	}
+}
+
+func (h *changelogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.logger.With(apmzap.TraceContext(r.Context())...)
+
+	vars := mux.Vars(r)
+	packageName := vars["packageName"]
+	packageVersion := vars["packageVersion"]
+	if _, err := semver.StrictNewVersion(packageVersion); err != nil {
+		badRequest(w, "invalid package version")
+		return
+	}
+
+	var from *semver.Version
+	if v := r.URL.Query().Get("from"); v != "" {
+		var err error
+		from, err = semver.NewVersion(v)
+		if err != nil {
+			badRequest(w, fmt.Sprintf("invalid 'from' version '%s': %s", v, err))
+			return
+		}
+	}
+
+	opts := packages.NameVersionFilter(packageName, packageVersion)
+	opts.Filter.Restrictions = packages.AccessRestrictionsFromContext(r.Context())
+	opts.SkipPackageData = true
+
+	pkgs, err := h.indexer.Get(r.Context(), &opts)
+	if err != nil {
+		logger.Error("getting package failed", zap.Error(err))
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(pkgs) == 0 && h.proxyMode.Enabled() {
+		proxiedPackage, err := h.proxyMode.Package(r)
+		if err != nil {
+			logger.Error("proxy mode: package failed", zap.Error(err))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if proxiedPackage != nil && opts.Filter.Restrictions.Allows(proxiedPackage) {
+			pkgs = pkgs.Join(packages.Packages{proxiedPackage})
+		}
+	}
+	if len(pkgs) == 0 {
+		notFoundError(w, errPackageRevisionNotFound)
+		return
+	}
+
+	changelog, err := packages.ReadChangelog(r.Context(), h.client, pkgs[0])
+	if errors.Is(err, fs.ErrNotExist) {
+		notFoundError(w, errChangelogNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error("reading package changelog failed",
+			zap.String("package.name", packageName),
+			zap.String("package.version", packageVersion),
+			zap.Error(err))
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if from != nil {
+		changelog = changelog.Since(from)
+	}
+
+	data, err := util.MarshalJSONPretty(changelog)
+	if err != nil {
+		logger.Error("marshaling package changelog failed", zap.Error(err))
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	serveJSONResponse(w, r, h.cacheTime, newJSONResponse(data))
+}
@@ -0,0 +1,89 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/package-registry/archiver"
+	"github.com/elastic/package-registry/packages"
+)
+
+func TestChangelog(t *testing.T) {
+	t.Parallel()
+
+	fsOpts := packages.FSIndexerOptions{
+		Logger: testLogger,
+	}
+	indexer := packages.NewFileSystemIndexer(fsOpts, "./testdata/package")
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+
+	err := indexer.Init(t.Context())
+	require.NoError(t, err)
+
+	changelogHandler, err := newChangelogHandler(testLogger, indexer, testCacheTime)
+	require.NoError(t, err)
+
+	tests := []struct {
+		endpoint string
+		path     string
+		file     string
+		handler  http.Handler
+	}{
+		{"/package/multiversion/1.2.0/changelog", changelogRouterPath, "changelog-multiversion-1.2.0.json", changelogHandler},
+		{"/package/multiversion/1.2.0/changelog?from=1.0.4", changelogRouterPath, "changelog-multiversion-1.2.0-from-1.0.4.json", changelogHandler},
+		{"/package/multiversion/1.2.0/changelog?from=1.2.0", changelogRouterPath, "changelog-multiversion-1.2.0-from-1.2.0.json", changelogHandler},
+		{"/package/multiversion/1.2.0/changelog?from=foo", changelogRouterPath, "changelog-invalid-from.txt", changelogHandler},
+		{"/package/example/1.0.0/changelog", changelogRouterPath, "changelog-not-found.txt", changelogHandler},
+		{"/package/missing/1.0.0/changelog", changelogRouterPath, "changelog-package-not-found.txt", changelogHandler},
+		{"/package/multiversion/a.b.c/changelog", changelogRouterPath, "changelog-invalid-version.txt", changelogHandler},
+	}
+
+	for _, test := range tests {
+		t.Run(test.endpoint, func(t *testing.T) {
+			runEndpoint(t, test.endpoint, test.path, test.file, test.handler)
+		})
+	}
+
+}
+
+func TestChangelogZipPackage(t *testing.T) {
+	t.Parallel()
+
+	zipPath := t.TempDir()
+	f, err := os.Create(filepath.Join(zipPath, "multiversion-1.1.0.zip"))
+	require.NoError(t, err)
+	err = archiver.ArchivePackage(f, archiver.PackageProperties{
+		Name:    "multiversion",
+		Version: "1.1.0",
+		Path:    "./testdata/package/multiversion/1.1.0",
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	indexer := packages.NewZipFileSystemIndexer(packages.FSIndexerOptions{Logger: testLogger}, zipPath)
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+	require.NoError(t, indexer.Init(t.Context()))
+
+	changelogHandler, err := newChangelogHandler(testLogger, indexer, testCacheTime)
+	require.NoError(t, err)
+
+	recorder := recordRequest(t, "/package/multiversion/1.1.0/changelog?from=1.0.3", changelogRouterPath, changelogHandler)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var changelog packages.Changelog
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &changelog))
+	require.Len(t, changelog, 2)
+	assert.Equal(t, "1.1.0", changelog[0].Version)
+	assert.Equal(t, "1.0.4", changelog[1].Version)
+	assert.Equal(t, "Unexpected breaking change had to be introduced. This should not happen in a minor.", changelog[1].Changes[0].Description)
+}
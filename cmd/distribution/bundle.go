@@ -0,0 +1,189 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// bundlePackagesDir is the directory of the bundle with the packages and their signatures.
+	bundlePackagesDir = "packages"
+
+	// bundleConfig is the configuration of the registry included in the bundle.
+	bundleConfig = `# Configuration generated by the distribution tool, paths are relative to the
+# working directory of the registry.
+package_paths:
+  - ./` + bundlePackagesDir + `
+`
+)
+
+// bundleAction writes a tar.gz file with the collected packages found in a directory, their
+// signatures, a manifest listing them, and a registry configuration serving them. Extracted
+// in the working directory of the registry, for example in its container image, it replaces
+// its configuration so it serves the packages of the bundle.
+type bundleAction struct {
+	address string
+
+	Destination string `yaml:"destination"`
+	Output      string `yaml:"output"`
+}
+
+func (a *bundleAction) init(c config) error {
+	if a.Destination == "" {
+		return errors.New("missing destination")
+	}
+	if a.Output == "" {
+		return errors.New("missing output")
+	}
+	a.address = c.Address
+	if a.address == "" {
+		a.address = defaultAddress
+	}
+	return nil
+}
+
+func (a *bundleAction) perform(i packageInfo) error {
+	for _, p := range []string{i.Download, i.SignaturePath} {
+		name := path.Base(p)
+		_, err := os.Stat(filepath.Join(a.Destination, name))
+		if err != nil {
+			return fmt.Errorf("failed to find %s to bundle: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (a *bundleAction) finish(packages []packageInfo) error {
+	if len(packages) == 0 {
+		return errors.New("no packages collected, bundle not created")
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(a.Output), downloadTempPattern)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	err = a.write(f, packages)
+	if err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := f.Chmod(0644); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	err = os.Rename(f.Name(), a.Output)
+	if err != nil {
+		return fmt.Errorf("failed to write bundle to %s: %w", a.Output, err)
+	}
+	fmt.Println("bundle with", len(packages), "packages written to", a.Output)
+	return nil
+}
+
+func (a *bundleAction) write(w io.Writer, packages []packageInfo) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now().UTC()
+
+	manifest := mirrorManifest{
+		Address:  a.address,
+		Updated:  now,
+		Packages: make([]mirrorManifestPackage, len(packages)),
+	}
+	for i, p := range packages {
+		manifest.Packages[i] = mirrorManifestPackage{
+			Name:          p.Name,
+			Version:       p.Version,
+			Path:          path.Join(bundlePackagesDir, path.Base(p.Download)),
+			SignaturePath: path.Join(bundlePackagesDir, path.Base(p.SignaturePath)),
+		}
+	}
+	d, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeTarFile(tw, "config.yml", []byte(bundleConfig), now); err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, mirrorManifestFile, d, now); err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     bundlePackagesDir + "/",
+		Mode:     0755,
+		ModTime:  now,
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range manifest.Packages {
+		for _, name := range []string{p.Path, p.SignaturePath} {
+			err := addTarFile(tw, name, filepath.Join(a.Destination, path.Base(name)))
+			if err != nil {
+				return fmt.Errorf("failed to add %s: %w", name, err)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, d []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(d)),
+		ModTime:  modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(d)
+	return err
+}
+
+func addTarFile(tw *tar.Writer, name, source string) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,110 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBundleAction(t *testing.T) {
+	tempDir := t.TempDir()
+
+	entity, err := openpgp.NewEntity("test", "test", "test@example.com", nil)
+	require.NoError(t, err)
+	packages := []packageInfo{
+		writeTestPackage(t, tempDir, entity, "apache", "2.0.0"),
+		writeTestPackage(t, tempDir, entity, "nginx", "1.0.0"),
+	}
+
+	output := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	action := &bundleAction{Destination: tempDir, Output: output}
+	require.NoError(t, action.init(config{}))
+	for _, info := range packages {
+		require.NoError(t, action.perform(info))
+	}
+	require.NoError(t, action.finish(packages))
+
+	f, err := os.Open(output)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	files := make(map[string][]byte)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+		if header.Typeflag == tar.TypeReg {
+			files[header.Name], err = io.ReadAll(tr)
+			require.NoError(t, err)
+		}
+	}
+	assert.Equal(t, []string{
+		"config.yml",
+		"manifest.json",
+		"packages/",
+		"packages/apache-2.0.0.zip",
+		"packages/apache-2.0.0.zip.sig",
+		"packages/nginx-1.0.0.zip",
+		"packages/nginx-1.0.0.zip.sig",
+	}, names)
+	assert.Equal(t, "content of nginx-1.0.0.zip", string(files["packages/nginx-1.0.0.zip"]))
+
+	var registryConfig struct {
+		PackagePaths []string `yaml:"package_paths"`
+	}
+	require.NoError(t, yaml.Unmarshal(files["config.yml"], &registryConfig))
+	assert.Equal(t, []string{"./packages"}, registryConfig.PackagePaths)
+
+	var manifest mirrorManifest
+	require.NoError(t, json.Unmarshal(files["manifest.json"], &manifest))
+	assert.Equal(t, defaultAddress, manifest.Address)
+	assert.Equal(t, []mirrorManifestPackage{
+		{Name: "apache", Version: "2.0.0", Path: "packages/apache-2.0.0.zip", SignaturePath: "packages/apache-2.0.0.zip.sig"},
+		{Name: "nginx", Version: "1.0.0", Path: "packages/nginx-1.0.0.zip", SignaturePath: "packages/nginx-1.0.0.zip.sig"},
+	}, manifest.Packages)
+
+	t.Run("missing package", func(t *testing.T) {
+		require.NoError(t, os.Remove(filepath.Join(tempDir, "nginx-1.0.0.zip.sig")))
+		assert.Error(t, action.perform(packages[1]))
+	})
+
+	t.Run("no packages", func(t *testing.T) {
+		action := &bundleAction{Destination: tempDir, Output: filepath.Join(t.TempDir(), "bundle.tar.gz")}
+		require.NoError(t, action.init(config{}))
+		assert.Error(t, action.finish(nil))
+		assert.NoFileExists(t, action.Output)
+	})
+}
+
+func TestBundleActionInit(t *testing.T) {
+	action := &bundleAction{}
+	assert.Error(t, action.init(config{}))
+
+	action = &bundleAction{Destination: "packages"}
+	assert.Error(t, action.init(config{}))
+
+	action = &bundleAction{Destination: "packages", Output: "bundle.tar.gz"}
+	require.NoError(t, action.init(config{Address: "https://epr.example.com"}))
+	assert.Equal(t, "https://epr.example.com", action.address)
+}
@@ -0,0 +1,97 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// defaultChecksumFile is the name of the checksums file when no output is configured.
+const defaultChecksumFile = "SHA256SUMS"
+
+// checksumAction writes the SHA-256 checksums of the collected packages and their signatures
+// found in a directory, in the format used by sha256sum, so they can be checked with
+// `sha256sum -c`.
+type checksumAction struct {
+	Destination string `yaml:"destination"`
+	Output      string `yaml:"output"`
+
+	mu        sync.Mutex
+	checksums map[string]string
+}
+
+func (a *checksumAction) init(c config) error {
+	if a.Destination == "" {
+		return errors.New("missing destination")
+	}
+	if a.Output == "" {
+		a.Output = filepath.Join(a.Destination, defaultChecksumFile)
+	}
+	return nil
+}
+
+func (a *checksumAction) perform(i packageInfo) error {
+	for _, p := range []string{i.Download, i.SignaturePath} {
+		name := path.Base(p)
+		sum, err := sha256File(filepath.Join(a.Destination, name))
+		if err != nil {
+			return fmt.Errorf("failed to calculate checksum of %s: %w", name, err)
+		}
+
+		a.mu.Lock()
+		if a.checksums == nil {
+			a.checksums = make(map[string]string)
+		}
+		a.checksums[name] = sum
+		a.mu.Unlock()
+	}
+	return nil
+}
+
+func (a *checksumAction) finish(packages []packageInfo) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, i := range packages {
+		for _, p := range []string{i.Download, i.SignaturePath} {
+			name := path.Base(p)
+			sum, found := a.checksums[name]
+			if !found {
+				return fmt.Errorf("missing checksum of %s", name)
+			}
+			fmt.Fprintf(&buf, "%s  %s\n", sum, name)
+		}
+	}
+
+	err := writeFile(a.Output, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to write checksums to %s: %w", a.Output, err)
+	}
+	return nil
+}
+
+func sha256File(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
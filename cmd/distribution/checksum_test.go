@@ -0,0 +1,64 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumAction(t *testing.T) {
+	tempDir := t.TempDir()
+
+	entity, err := openpgp.NewEntity("test", "test", "test@example.com", nil)
+	require.NoError(t, err)
+	packages := []packageInfo{
+		writeTestPackage(t, tempDir, entity, "apache", "2.0.0"),
+		writeTestPackage(t, tempDir, entity, "nginx", "1.0.0"),
+	}
+
+	action := &checksumAction{Destination: tempDir}
+	require.NoError(t, action.init(config{}))
+	assert.Equal(t, filepath.Join(tempDir, defaultChecksumFile), action.Output)
+
+	// Performed in different order than collected.
+	require.NoError(t, action.perform(packages[1]))
+	require.NoError(t, action.perform(packages[0]))
+	require.NoError(t, action.finish(packages))
+
+	var expected string
+	for _, name := range []string{"apache-2.0.0.zip", "apache-2.0.0.zip.sig", "nginx-1.0.0.zip", "nginx-1.0.0.zip.sig"} {
+		d, err := os.ReadFile(filepath.Join(tempDir, name))
+		require.NoError(t, err)
+		sum := sha256.Sum256(d)
+		expected += fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+	d, err := os.ReadFile(action.Output)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(d))
+
+	t.Run("missing package", func(t *testing.T) {
+		download, signature := config{}.downloadPathForPackage("nginx", "1.2.0")
+		err := action.perform(packageInfo{Name: "nginx", Version: "1.2.0", Download: download, SignaturePath: signature})
+		assert.Error(t, err)
+	})
+}
+
+func TestChecksumActionInit(t *testing.T) {
+	action := &checksumAction{}
+	assert.Error(t, action.init(config{}))
+
+	action = &checksumAction{Destination: "packages", Output: "checksums.txt"}
+	require.NoError(t, action.init(config{}))
+	assert.Equal(t, "checksums.txt", action.Output)
+}
@@ -35,19 +35,9 @@ type config struct {
 
 // searchURLs generates the search searchURLs required for the given configuration.
 func (c config) searchURLs() (iter.Seq[*url.URL], error) {
-	address := defaultAddress
-	if c.Address != "" {
-		address = c.Address
-	}
-	basePath, err := url.JoinPath(address, "search")
+	baseURL, err := c.searchURL()
 	if err != nil {
-		return nil, fmt.Errorf("invalid address: %w", err)
-	}
-	baseURL, err := url.Parse(basePath)
-	if err != nil {
-		// This should not happen because JoinPath already parses the url.
-		fmt.Printf("invalid url (%s): %s", baseURL, err)
-		os.Exit(-1)
+		return nil, err
 	}
 	matrix := c.Matrix
 	if len(matrix) == 0 {
@@ -77,6 +67,38 @@ func (c config) searchURLs() (iter.Seq[*url.URL], error) {
 	}, nil
 }
 
+// searchURL returns the URL of the search endpoint, without query parameters.
+func (c config) searchURL() (*url.URL, error) {
+	address := defaultAddress
+	if c.Address != "" {
+		address = c.Address
+	}
+	basePath, err := url.JoinPath(address, "search")
+	if err != nil {
+		return nil, fmt.Errorf("invalid address: %w", err)
+	}
+	baseURL, err := url.Parse(basePath)
+	if err != nil {
+		// This should not happen because JoinPath already parses the url.
+		fmt.Printf("invalid url (%s): %s", baseURL, err)
+		os.Exit(-1)
+	}
+	return baseURL, nil
+}
+
+// packageURL returns the URL of the metadata of the package with the given name and version.
+func (c config) packageURL(name, version string) (string, error) {
+	address := defaultAddress
+	if c.Address != "" {
+		address = c.Address
+	}
+	u, err := url.JoinPath(address, "package", name, version, "/")
+	if err != nil {
+		return "", fmt.Errorf("invalid address: %w", err)
+	}
+	return u, nil
+}
+
 // downloadPathForPackage returns the paths to download the package with the given name and version and its signature.
 func (c config) downloadPathForPackage(name, version string) (string, string) {
 	path := path.Join("epr", name, fmt.Sprintf("%s-%s.zip", name, version))
@@ -89,18 +111,21 @@ func (c config) collect(client *http.Client) ([]packageInfo, error) {
 		return nil, fmt.Errorf("failed to build URLs: %w", err)
 	}
 
-	type key struct {
-		Name, Version string
-	}
 	var mapLock sync.Mutex
-	packagesMap := make(map[key]packageInfo)
+	packagesMap := make(map[packageKey]packageInfo)
+	// sources are the search parameters of the queries where each package was found.
+	sources := make(map[packageKey]url.Values)
 
-	pinnedPackages, err := c.pinnedPackages()
+	pinnedPackages, err := c.pinnedPackages(client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare pinned packages: %w", err)
 	}
 	for _, p := range pinnedPackages {
-		packagesMap[key{Name: p.Name, Version: p.Version}] = p
+		k := packageKey{Name: p.Name, Version: p.Version}
+		packagesMap[k] = p
+		// Pinned packages are not found by any query, their requirements are looked
+		// for without filters.
+		sources[k] = url.Values{}
 	}
 
 	taskPool := workers.NewTaskPool(runtime.GOMAXPROCS(0))
@@ -124,11 +149,12 @@ func (c config) collect(client *http.Client) ([]packageInfo, error) {
 
 			mapLock.Lock()
 			for _, p := range packages {
-				k := key{Name: p.Name, Version: p.Version}
+				k := packageKey{Name: p.Name, Version: p.Version}
 				if _, found := packagesMap[k]; found {
 					continue
 				}
 				packagesMap[k] = p
+				sources[k] = u.Query()
 			}
 			mapLock.Unlock()
 
@@ -139,6 +165,11 @@ func (c config) collect(client *http.Client) ([]packageInfo, error) {
 		return nil, err
 	}
 
+	err = c.resolveRequirements(client, packagesMap, sources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve package requirements: %w", err)
+	}
+
 	result := make([]packageInfo, 0, len(packagesMap))
 	for _, p := range packagesMap {
 		result = append(result, p)
@@ -169,25 +200,48 @@ func (c config) collect(client *http.Client) ([]packageInfo, error) {
 	return result, nil
 }
 
-func (c config) pinnedPackages() ([]packageInfo, error) {
+// pinnedPackages returns the packages configured by name and version, with the packages
+// they require obtained from their metadata.
+func (c config) pinnedPackages(client *http.Client) ([]packageInfo, error) {
 	packages := make([]packageInfo, len(c.Packages))
+	var errs []error
 	for i, p := range c.Packages {
 		download, signature := c.downloadPathForPackage(p.Name, p.Version)
+		requires, err := c.packageRequirements(client, p.Name, p.Version)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get requirements of package %s %s: %w", p.Name, p.Version, err))
+		}
 		packages[i] = packageInfo{
 			Name:          p.Name,
 			Version:       p.Version,
 			Download:      download,
 			SignaturePath: signature,
+			Requires:      requires,
 		}
 	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
 	return packages, nil
 }
 
+type packageKey struct {
+	Name, Version string
+}
+
 type packageInfo struct {
-	Name          string `json:"name"`
-	Version       string `json:"version"`
-	Download      string `json:"download"`
-	SignaturePath string `json:"signature_path"`
+	Name          string               `json:"name"`
+	Version       string               `json:"version"`
+	Download      string               `json:"download"`
+	SignaturePath string               `json:"signature_path"`
+	Conditions    packageConditions    `json:"conditions"`
+	Requires      *packageRequirements `json:"requires,omitempty"`
+}
+
+type packageConditions struct {
+	Kibana struct {
+		Version string `json:"version"`
+	} `json:"kibana"`
 }
 
 func configActionFactory(name string) (configAction, error) {
@@ -196,6 +250,16 @@ func configActionFactory(name string) (configAction, error) {
 		return &printAction{}, nil
 	case "download":
 		return &downloadAction{}, nil
+	case "mirror":
+		return &mirrorAction{}, nil
+	case "verify":
+		return &verifyAction{}, nil
+	case "checksum":
+		return &checksumAction{}, nil
+	case "report":
+		return &reportAction{}, nil
+	case "bundle":
+		return &bundleAction{}, nil
 	default:
 		return nil, fmt.Errorf("unknown action %s", name)
 	}
@@ -206,6 +270,12 @@ type configAction interface {
 	perform(packageInfo) error
 }
 
+// configActionFinisher is implemented by actions that need to do something after being
+// performed successfully for all the collected packages.
+type configActionFinisher interface {
+	finish([]packageInfo) error
+}
+
 type configQuery struct {
 	Package       string `yaml:"package" url:"package,omitempty"`
 	All           bool   `yaml:"all" url:"all,omitempty"`
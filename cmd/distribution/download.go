@@ -7,8 +7,10 @@ package main
 import (
 	"bytes"
 	_ "embed"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
@@ -18,6 +20,9 @@ import (
 	"github.com/ProtonMail/go-crypto/openpgp"
 )
 
+// downloadTempPattern is the pattern of the names of the files being downloaded.
+const downloadTempPattern = ".download-*.tmp"
+
 type downloadAction struct {
 	client  *http.Client
 	keyRing openpgp.KeyRing
@@ -41,17 +46,27 @@ func (a *downloadAction) init(c config) error {
 	if err != nil {
 		return fmt.Errorf("failed to create desination directory: %w", err)
 	}
-	a.keyRing, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(publicKey))
+	a.keyRing, err = readPublicKey()
+	return err
+}
+
+// readPublicKey reads the key ring with the public key used to verify the signatures.
+func readPublicKey() (openpgp.KeyRing, error) {
+	keyRing, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(publicKey))
 	if err != nil {
-		return fmt.Errorf("failed to initialize public key: %w", err)
+		return nil, fmt.Errorf("failed to initialize public key: %w", err)
 	}
-	return nil
+	return keyRing, nil
 }
 
 func (a *downloadAction) perform(i packageInfo) error {
-	if valid, _ := a.valid(i); valid {
+	valid, err := a.valid(i)
+	if valid {
 		return nil
 	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		fmt.Printf("- %s %s is not valid, downloading it again: %s\n", i.Name, i.Version, err)
+	}
 	if err := a.download(i.Download); err != nil {
 		return fmt.Errorf("failed to download package %s: %w", i.Download, err)
 	}
@@ -81,14 +96,30 @@ func (a *downloadAction) download(urlPath string) error {
 		return fmt.Errorf("failed to get %s (status code %d)", urlPath, resp.StatusCode)
 	}
 
-	f, err := os.Create(a.destinationPath(urlPath))
+	// Download to a temporary file, so registries using the destination as package path
+	// never find incomplete files.
+	f, err := os.CreateTemp(a.Destination, downloadTempPattern)
 	if err != nil {
-		return fmt.Errorf("failed to open %s in %s: %w", path.Base(urlPath), a.Destination, err)
+		return fmt.Errorf("failed to create temporary file in %s: %w", a.Destination, err)
 	}
+	defer os.Remove(f.Name())
 	defer f.Close()
 
 	_, err = io.Copy(f, resp.Body)
-	return err
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", urlPath, err)
+	}
+	if err := f.Chmod(0644); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	err = os.Rename(f.Name(), a.destinationPath(urlPath))
+	if err != nil {
+		return fmt.Errorf("failed to write %s in %s: %w", path.Base(urlPath), a.Destination, err)
+	}
+	return nil
 }
 
 func (a *downloadAction) destinationPath(urlPath string) string {
@@ -96,21 +127,47 @@ func (a *downloadAction) destinationPath(urlPath string) string {
 }
 
 func (a *downloadAction) valid(info packageInfo) (bool, error) {
-	signed, err := os.Open(a.destinationPath(info.Download))
+	err := verifySignature(a.keyRing, a.destinationPath(info.Download), a.destinationPath(info.SignaturePath))
 	if err != nil {
 		return false, err
 	}
+	return true, nil
+}
+
+// verifySignature checks that the file is signed by the signature, with a key in the key ring.
+func verifySignature(keyRing openpgp.KeyRing, signedPath, signaturePath string) error {
+	signed, err := os.Open(signedPath)
+	if err != nil {
+		return err
+	}
 	defer signed.Close()
 
-	signature, err := os.Open(a.destinationPath(info.SignaturePath))
+	signature, err := os.Open(signaturePath)
 	if err != nil {
-		return false, err
+		return err
 	}
 	defer signature.Close()
 
-	_, err = openpgp.CheckArmoredDetachedSignature(a.keyRing, signed, signature, nil)
+	_, err = openpgp.CheckArmoredDetachedSignature(keyRing, signed, signature, nil)
+	return err
+}
+
+// writeFile writes the data to the file in the given path, replacing it only once completely written.
+func writeFile(name string, d []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), downloadTempPattern)
 	if err != nil {
-		return false, err
+		return err
 	}
-	return true, nil
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.Write(d); err != nil {
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
 }
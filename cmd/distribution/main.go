@@ -54,6 +54,15 @@ func main() {
 		fmt.Println(err)
 		os.Exit(-1)
 	}
+	for _, action := range config.Actions {
+		if finisher, ok := action.(configActionFinisher); ok {
+			err := finisher.finish(packages)
+			if err != nil {
+				fmt.Printf("failed to finish action: %s\n", err)
+				os.Exit(-1)
+			}
+		}
+	}
 	fmt.Println(len(packages), "packages total")
 }
 
@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -78,6 +79,31 @@ func TestConfigActionFactory(t *testing.T) {
 			actionName:  "download",
 			expectError: false,
 		},
+		{
+			name:        "mirror action",
+			actionName:  "mirror",
+			expectError: false,
+		},
+		{
+			name:        "verify action",
+			actionName:  "verify",
+			expectError: false,
+		},
+		{
+			name:        "checksum action",
+			actionName:  "checksum",
+			expectError: false,
+		},
+		{
+			name:        "report action",
+			actionName:  "report",
+			expectError: false,
+		},
+		{
+			name:        "bundle action",
+			actionName:  "bundle",
+			expectError: false,
+		},
 		{
 			name:        "unknown action",
 			actionName:  "unknown",
@@ -204,20 +230,41 @@ func TestConfigDownloadPathForPackage(t *testing.T) {
 }
 
 func TestConfigPinnedPackages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/package/nginx/1.0.0/":
+			w.Write([]byte(`{"name":"nginx","version":"1.0.0","requires":{"input":[{"package":"nginx_input","version":"^1.0.0"}]}}`))
+		case "/package/apache/2.0.0/":
+			w.Write([]byte(`{"name":"apache","version":"2.0.0"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
 	cfg := config{
+		Address: server.URL,
 		Packages: []configPackage{
 			{Name: "nginx", Version: "1.0.0"},
 			{Name: "apache", Version: "2.0.0"},
 		},
 	}
 
-	packages, err := cfg.pinnedPackages()
+	packages, err := cfg.pinnedPackages(&http.Client{})
 	require.NoError(t, err)
 	assert.Len(t, packages, 2)
 	assert.Equal(t, "nginx", packages[0].Name)
 	assert.Equal(t, "1.0.0", packages[0].Version)
 	assert.Equal(t, "epr/nginx/nginx-1.0.0.zip", packages[0].Download)
 	assert.Equal(t, "epr/nginx/nginx-1.0.0.zip.sig", packages[0].SignaturePath)
+	assert.Equal(t, &packageRequirements{Input: []packageRequirement{{Package: "nginx_input", Version: "^1.0.0"}}}, packages[0].Requires)
+	assert.Nil(t, packages[1].Requires)
+
+	t.Run("missing package", func(t *testing.T) {
+		cfg.Packages = append(cfg.Packages, configPackage{Name: "missing", Version: "1.0.0"})
+		_, err := cfg.pinnedPackages(&http.Client{})
+		assert.ErrorContains(t, err, "failed to get requirements of package missing 1.0.0")
+	})
 }
 
 func TestConfigCollect(t *testing.T) {
@@ -274,8 +321,13 @@ func TestConfigCollect(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create mock server
 			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				require.Equal(t, "/search", r.URL.Path)
 				w.Header().Set("Content-Type", "application/json")
+				if strings.HasPrefix(r.URL.Path, "/package/") {
+					// Metadata of pinned packages.
+					w.Write([]byte(`{}`))
+					return
+				}
+				require.Equal(t, "/search", r.URL.Path)
 				json.NewEncoder(w).Encode(tt.mockResponse)
 			}))
 			defer server.Close()
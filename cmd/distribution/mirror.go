@@ -0,0 +1,121 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// mirrorManifestFile is the name of the file with the list of mirrored packages.
+const mirrorManifestFile = "manifest.json"
+
+// mirrorAction keeps a directory with the packages collected and their signatures, that
+// can be used as package path of a registry. Packages already mirrored are not downloaded
+// again, and packages not collected anymore are removed.
+type mirrorAction struct {
+	downloadAction `yaml:",inline"`
+}
+
+type mirrorManifest struct {
+	Address  string                  `json:"address"`
+	Updated  time.Time               `json:"updated"`
+	Packages []mirrorManifestPackage `json:"packages"`
+}
+
+type mirrorManifestPackage struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	Path          string `json:"path"`
+	SignaturePath string `json:"signature_path"`
+}
+
+func (a *mirrorAction) init(c config) error {
+	if a.Destination == "" {
+		return errors.New("missing destination")
+	}
+	if a.Address == "" && c.Address == "" {
+		a.Address = defaultAddress
+	}
+	return a.downloadAction.init(c)
+}
+
+func (a *mirrorAction) finish(packages []packageInfo) error {
+	if len(packages) == 0 {
+		// Protect against misconfigured queries removing all the packages.
+		return errors.New("no packages collected, mirror not updated")
+	}
+
+	err := a.prune(packages)
+	if err != nil {
+		return fmt.Errorf("failed to prune mirror: %w", err)
+	}
+
+	err = a.writeManifest(packages)
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// prune removes the packages and signatures that are not in the given list, and the
+// files of interrupted downloads.
+func (a *mirrorAction) prune(packages []packageInfo) error {
+	keep := make(map[string]bool)
+	for _, p := range packages {
+		keep[path.Base(p.Download)] = true
+		keep[path.Base(p.SignaturePath)] = true
+	}
+
+	entries, err := os.ReadDir(a.Destination)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || keep[name] {
+			continue
+		}
+		temporary, _ := filepath.Match(downloadTempPattern, name)
+		if !temporary && !strings.HasSuffix(name, ".zip") && !strings.HasSuffix(name, ".zip.sig") {
+			continue
+		}
+		err := os.Remove(filepath.Join(a.Destination, name))
+		if err != nil {
+			return err
+		}
+		if !temporary {
+			fmt.Println("- removed", name)
+		}
+	}
+	return nil
+}
+
+func (a *mirrorAction) writeManifest(packages []packageInfo) error {
+	manifest := mirrorManifest{
+		Address:  a.Address,
+		Updated:  time.Now().UTC(),
+		Packages: make([]mirrorManifestPackage, len(packages)),
+	}
+	for i, p := range packages {
+		manifest.Packages[i] = mirrorManifestPackage{
+			Name:          p.Name,
+			Version:       p.Version,
+			Path:          path.Base(p.Download),
+			SignaturePath: path.Base(p.SignaturePath),
+		}
+	}
+	d, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(a.Destination, mirrorManifestFile), d)
+}
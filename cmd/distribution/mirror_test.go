@@ -0,0 +1,125 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrorAction(t *testing.T) {
+	tempDir := t.TempDir()
+
+	entity, err := openpgp.NewEntity("test", "test", "test@example.com", nil)
+	require.NoError(t, err)
+
+	var downloads atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		name := filepath.Base(r.URL.Path)
+		content := []byte("content of " + strings.TrimSuffix(name, ".sig"))
+		if strings.HasSuffix(name, ".sig") {
+			var sigBuf bytes.Buffer
+			openpgp.ArmoredDetachSign(&sigBuf, entity, bytes.NewReader(content), nil)
+			content = sigBuf.Bytes()
+		}
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	action := &mirrorAction{downloadAction{
+		Destination: tempDir,
+		Address:     server.URL,
+	}}
+	require.NoError(t, action.init(config{}))
+	action.keyRing = openpgp.EntityList{entity}
+
+	cfg := config{}
+	mirror := func(versions ...string) {
+		var infos []packageInfo
+		for _, version := range versions {
+			download, signature := cfg.downloadPathForPackage("nginx", version)
+			infos = append(infos, packageInfo{Name: "nginx", Version: version, Download: download, SignaturePath: signature})
+		}
+		for _, info := range infos {
+			require.NoError(t, action.perform(info))
+		}
+		require.NoError(t, action.finish(infos))
+	}
+
+	// Leftovers of interrupted downloads and other files.
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ".download-123.tmp"), []byte("partial"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "README"), []byte("keep"), 0644))
+
+	mirror("1.0.0", "1.1.0")
+	assert.EqualValues(t, 4, downloads.Load())
+	assert.FileExists(t, filepath.Join(tempDir, "nginx-1.0.0.zip"))
+	assert.FileExists(t, filepath.Join(tempDir, "nginx-1.1.0.zip.sig"))
+	assert.NoFileExists(t, filepath.Join(tempDir, ".download-123.tmp"))
+	assert.FileExists(t, filepath.Join(tempDir, "README"))
+
+	// Only new versions are downloaded, and versions not collected anymore are removed.
+	mirror("1.1.0", "1.2.0")
+	assert.EqualValues(t, 6, downloads.Load())
+	assert.NoFileExists(t, filepath.Join(tempDir, "nginx-1.0.0.zip"))
+	assert.NoFileExists(t, filepath.Join(tempDir, "nginx-1.0.0.zip.sig"))
+	assert.FileExists(t, filepath.Join(tempDir, "nginx-1.2.0.zip"))
+
+	d, err := os.ReadFile(filepath.Join(tempDir, mirrorManifestFile))
+	require.NoError(t, err)
+	var manifest mirrorManifest
+	require.NoError(t, json.Unmarshal(d, &manifest))
+	assert.Equal(t, server.URL, manifest.Address)
+	assert.False(t, manifest.Updated.IsZero())
+	assert.Equal(t, []mirrorManifestPackage{
+		{Name: "nginx", Version: "1.1.0", Path: "nginx-1.1.0.zip", SignaturePath: "nginx-1.1.0.zip.sig"},
+		{Name: "nginx", Version: "1.2.0", Path: "nginx-1.2.0.zip", SignaturePath: "nginx-1.2.0.zip.sig"},
+	}, manifest.Packages)
+
+	// Nothing is removed if no package is collected.
+	assert.Error(t, action.finish(nil))
+	assert.FileExists(t, filepath.Join(tempDir, "nginx-1.2.0.zip"))
+}
+
+func TestMirrorActionInit(t *testing.T) {
+	action := &mirrorAction{}
+	assert.Error(t, action.init(config{}))
+
+	action = &mirrorAction{downloadAction{Destination: t.TempDir()}}
+	require.NoError(t, action.init(config{}))
+	assert.Equal(t, defaultAddress, action.Address)
+}
+
+func TestReadConfigMirrorAction(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(configPath, []byte(`
+queries:
+  - package: nginx
+actions:
+  - mirror:
+      destination: ./mirror
+      address: https://mirror.example.com
+`), 0644)
+	require.NoError(t, err)
+
+	cfg, err := readConfig(configPath)
+	require.NoError(t, err)
+	require.Len(t, cfg.Actions, 1)
+	action, ok := cfg.Actions[0].(*mirrorAction)
+	require.True(t, ok)
+	assert.Equal(t, "./mirror", action.Destination)
+	assert.Equal(t, "https://mirror.example.com", action.Address)
+}
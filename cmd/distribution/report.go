@@ -0,0 +1,127 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+const (
+	reportFormatJSON = "json"
+	reportFormatCSV  = "csv"
+)
+
+// reportAction writes a report with the collected packages, their Kibana constraints and
+// the size of the packages found in a directory.
+type reportAction struct {
+	Destination string `yaml:"destination"`
+	Output      string `yaml:"output"`
+	Format      string `yaml:"format"`
+
+	mu    sync.Mutex
+	sizes map[string]int64
+}
+
+type reportPackage struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	KibanaVersion string `json:"kibana_version"`
+	Path          string `json:"path"`
+	Size          int64  `json:"size"`
+}
+
+func (a *reportAction) init(c config) error {
+	if a.Destination == "" {
+		return errors.New("missing destination")
+	}
+	switch a.Format {
+	case "":
+		a.Format = reportFormatJSON
+	case reportFormatJSON, reportFormatCSV:
+	default:
+		return fmt.Errorf("unknown report format %q, expected %q or %q", a.Format, reportFormatJSON, reportFormatCSV)
+	}
+	return nil
+}
+
+func (a *reportAction) perform(i packageInfo) error {
+	name := path.Base(i.Download)
+	info, err := os.Stat(filepath.Join(a.Destination, name))
+	if err != nil {
+		return fmt.Errorf("failed to get size of %s: %w", name, err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.sizes == nil {
+		a.sizes = make(map[string]int64)
+	}
+	a.sizes[name] = info.Size()
+	return nil
+}
+
+func (a *reportAction) finish(packages []packageInfo) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	report := make([]reportPackage, len(packages))
+	for i, p := range packages {
+		name := path.Base(p.Download)
+		size, found := a.sizes[name]
+		if !found {
+			return fmt.Errorf("missing size of %s", name)
+		}
+		report[i] = reportPackage{
+			Name:          p.Name,
+			Version:       p.Version,
+			KibanaVersion: p.Conditions.Kibana.Version,
+			Path:          name,
+			Size:          size,
+		}
+	}
+
+	var d []byte
+	var err error
+	switch a.Format {
+	case reportFormatCSV:
+		d, err = reportCSV(report)
+	default:
+		d, err = json.MarshalIndent(report, "", "  ")
+		d = append(d, '\n')
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	if a.Output == "" {
+		_, err = os.Stdout.Write(d)
+		return err
+	}
+	err = writeFile(a.Output, d)
+	if err != nil {
+		return fmt.Errorf("failed to write report to %s: %w", a.Output, err)
+	}
+	return nil
+}
+
+func reportCSV(report []reportPackage) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"name", "version", "kibana_version", "path", "size"})
+	for _, p := range report {
+		w.Write([]string{p.Name, p.Version, p.KibanaVersion, p.Path, strconv.FormatInt(p.Size, 10)})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
@@ -0,0 +1,79 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportAction(t *testing.T) {
+	tempDir := t.TempDir()
+
+	entity, err := openpgp.NewEntity("test", "test", "test@example.com", nil)
+	require.NoError(t, err)
+	packages := []packageInfo{
+		writeTestPackage(t, tempDir, entity, "apache", "2.0.0"),
+		writeTestPackage(t, tempDir, entity, "nginx", "1.0.0"),
+	}
+	packages[0].Conditions.Kibana.Version = "^8.0.0"
+
+	t.Run("json", func(t *testing.T) {
+		action := &reportAction{Destination: tempDir, Output: filepath.Join(t.TempDir(), "report.json")}
+		require.NoError(t, action.init(config{}))
+		for _, info := range packages {
+			require.NoError(t, action.perform(info))
+		}
+		require.NoError(t, action.finish(packages))
+
+		d, err := os.ReadFile(action.Output)
+		require.NoError(t, err)
+		var report []reportPackage
+		require.NoError(t, json.Unmarshal(d, &report))
+		assert.Equal(t, []reportPackage{
+			{Name: "apache", Version: "2.0.0", KibanaVersion: "^8.0.0", Path: "apache-2.0.0.zip", Size: 27},
+			{Name: "nginx", Version: "1.0.0", Path: "nginx-1.0.0.zip", Size: 26},
+		}, report)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		action := &reportAction{Destination: tempDir, Output: filepath.Join(t.TempDir(), "report.csv"), Format: "csv"}
+		require.NoError(t, action.init(config{}))
+		for _, info := range packages {
+			require.NoError(t, action.perform(info))
+		}
+		require.NoError(t, action.finish(packages))
+
+		d, err := os.ReadFile(action.Output)
+		require.NoError(t, err)
+		assert.Equal(t, "name,version,kibana_version,path,size\n"+
+			"apache,2.0.0,^8.0.0,apache-2.0.0.zip,27\n"+
+			"nginx,1.0.0,,nginx-1.0.0.zip,26\n", string(d))
+	})
+
+	t.Run("missing package", func(t *testing.T) {
+		action := &reportAction{Destination: t.TempDir()}
+		require.NoError(t, action.init(config{}))
+		assert.Error(t, action.perform(packages[0]))
+	})
+}
+
+func TestReportActionInit(t *testing.T) {
+	action := &reportAction{}
+	assert.Error(t, action.init(config{}))
+
+	action = &reportAction{Destination: "packages"}
+	require.NoError(t, action.init(config{}))
+	assert.Equal(t, reportFormatJSON, action.Format)
+
+	action = &reportAction{Destination: "packages", Format: "xml"}
+	assert.Error(t, action.init(config{}))
+}
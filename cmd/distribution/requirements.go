@@ -0,0 +1,183 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// requirementFilters are the search parameters of the queries that are also used to look for
+// the packages required by the packages found, so they are compatible with the same versions
+// of the stack.
+var requirementFilters = []string{"kibana.version", "spec.min", "spec.max", "prerelease"}
+
+type packageRequirements struct {
+	Input   []packageRequirement `json:"input,omitempty"`
+	Content []packageRequirement `json:"content,omitempty"`
+}
+
+type packageRequirement struct {
+	Package string `json:"package"`
+	Version string `json:"version"`
+}
+
+// resolveRequirements adds to the collected packages the packages they require, and the ones
+// required by these, recursively. Required packages are looked for with the filters of the
+// query where the package requiring them was found, pinned packages have no filters. An error
+// is returned for requirements that cannot be satisfied.
+func (c config) resolveRequirements(client *http.Client, packages map[packageKey]packageInfo, sources map[packageKey]url.Values) error {
+	baseURL, err := c.searchURL()
+	if err != nil {
+		return err
+	}
+
+	// Search responses are reused by the requirements resolved with the same query.
+	responses := make(map[string][]packageInfo)
+	search := func(query url.Values) ([]packageInfo, error) {
+		u := *baseURL
+		u.RawQuery = query.Encode()
+		if response, found := responses[u.String()]; found {
+			return response, nil
+		}
+		resp, err := client.Get(u.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to GET %s: %w", u.String(), err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to GET %s (status code %d)", u.String(), resp.StatusCode)
+		}
+		var response []packageInfo
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse search response: %w", err)
+		}
+		responses[u.String()] = response
+		return response, nil
+	}
+
+	pending := slices.SortedFunc(maps.Keys(sources), func(a, b packageKey) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(a.Version, b.Version))
+	})
+
+	var errs []error
+	for len(pending) > 0 {
+		k := pending[0]
+		pending = pending[1:]
+
+		p := packages[k]
+		if p.Requires == nil {
+			continue
+		}
+		filters := url.Values{}
+		for _, name := range requirementFilters {
+			if v, found := sources[k][name]; found {
+				filters[name] = v
+			}
+		}
+		for _, requirement := range slices.Concat(p.Requires.Input, p.Requires.Content) {
+			constraint, err := semver.NewConstraint(requirement.Version)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("package %s %s requires %s with invalid version %q: %w", p.Name, p.Version, requirement.Package, requirement.Version, err))
+				continue
+			}
+			if satisfied(packages, requirement.Package, constraint) {
+				continue
+			}
+
+			query := url.Values{}
+			for name, v := range filters {
+				query[name] = v
+			}
+			query.Set("package", requirement.Package)
+			query.Set("all", "true")
+			candidates, err := search(query)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("package %s %s requires %s %s: %w", p.Name, p.Version, requirement.Package, requirement.Version, err))
+				continue
+			}
+			required, found := latestSatisfying(candidates, requirement.Package, constraint)
+			if !found {
+				errs = append(errs, fmt.Errorf("package %s %s requires %s %s, no compatible version found", p.Name, p.Version, requirement.Package, requirement.Version))
+				continue
+			}
+
+			fmt.Println("-", p.Name, p.Version, "requires", required.Name, required.Version)
+			requiredKey := packageKey{Name: required.Name, Version: required.Version}
+			packages[requiredKey] = required
+			sources[requiredKey] = filters
+			pending = append(pending, requiredKey)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// packageRequirements obtains the packages required by a package from its metadata.
+func (c config) packageRequirements(client *http.Client, name, version string) (*packageRequirements, error) {
+	u, err := c.packageURL(name, version)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GET %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to GET %s (status code %d)", u, resp.StatusCode)
+	}
+	var metadata struct {
+		Requires *packageRequirements `json:"requires,omitempty"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse package metadata: %w", err)
+	}
+	return metadata.Requires, nil
+}
+
+// satisfied returns true if some of the packages has the given name and a version that
+// satisfies the constraint.
+func satisfied(packages map[packageKey]packageInfo, name string, constraint *semver.Constraints) bool {
+	for k := range packages {
+		if k.Name != name {
+			continue
+		}
+		if v, err := semver.NewVersion(k.Version); err == nil && constraint.Check(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// latestSatisfying returns the package with the given name and the highest version that
+// satisfies the constraint.
+func latestSatisfying(candidates []packageInfo, name string, constraint *semver.Constraints) (packageInfo, bool) {
+	var latest packageInfo
+	var latestVersion *semver.Version
+	for _, p := range candidates {
+		if p.Name != name {
+			continue
+		}
+		v, err := semver.NewVersion(p.Version)
+		if err != nil || !constraint.Check(v) {
+			continue
+		}
+		if latestVersion == nil || v.GreaterThan(latestVersion) {
+			latest, latestVersion = p, v
+		}
+	}
+	return latest, latestVersion != nil
+}
@@ -0,0 +1,106 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigCollectRequirements(t *testing.T) {
+	requires := func(input ...packageRequirement) *packageRequirements {
+		return &packageRequirements{Input: input}
+	}
+	responses := map[string][]packageInfo{
+		"kibana.version=9.1.0": {
+			{Name: "sql", Version: "1.0.0", Requires: requires(packageRequirement{Package: "sql_input", Version: "^0.2.0"})},
+			{Name: "mysql", Version: "2.0.0", Requires: requires(packageRequirement{Package: "sql_input", Version: "^0.2.0"})},
+			{Name: "nginx", Version: "1.0.0"},
+		},
+		"all=true&kibana.version=9.1.0&package=sql_input": {
+			{Name: "sql_input", Version: "0.1.0"},
+			{Name: "sql_input", Version: "0.2.0"},
+			{Name: "sql_input", Version: "0.2.1", Requires: requires(packageRequirement{Package: "base_input", Version: "1.0.0"})},
+			{Name: "sql_input", Version: "0.3.0"},
+		},
+		"all=true&kibana.version=9.1.0&package=base_input": {
+			{Name: "base_input", Version: "1.0.0"},
+		},
+	}
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+		json.NewEncoder(w).Encode(responses[r.URL.RawQuery])
+	}))
+	defer server.Close()
+
+	cfg := config{
+		Address: server.URL,
+		Queries: []configQuery{{KibanaVersion: "9.1.0"}},
+	}
+	packages, err := cfg.collect(&http.Client{})
+	require.NoError(t, err)
+
+	var collected []string
+	for _, p := range packages {
+		collected = append(collected, p.Name+"-"+p.Version)
+	}
+	assert.Equal(t, []string{"base_input-1.0.0", "mysql-2.0.0", "nginx-1.0.0", "sql-1.0.0", "sql_input-0.2.1"}, collected)
+
+	// Requirements are searched once.
+	assert.Equal(t, []string{
+		"kibana.version=9.1.0",
+		"all=true&kibana.version=9.1.0&package=sql_input",
+		"all=true&kibana.version=9.1.0&package=base_input",
+	}, requests)
+
+	t.Run("unresolvable requirements", func(t *testing.T) {
+		responses["kibana.version=9.1.0"] = append(responses["kibana.version=9.1.0"],
+			packageInfo{Name: "oracle", Version: "1.0.0", Requires: requires(packageRequirement{Package: "sql_input", Version: "^1.0.0"})},
+			packageInfo{Name: "redis", Version: "1.0.0", Requires: requires(packageRequirement{Package: "redis_input", Version: "1.0.0"})},
+		)
+		_, err := cfg.collect(&http.Client{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "package oracle 1.0.0 requires sql_input ^1.0.0, no compatible version found")
+		assert.Contains(t, err.Error(), "package redis 1.0.0 requires redis_input 1.0.0, no compatible version found")
+	})
+}
+
+func TestConfigCollectPinnedPackagesRequirements(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.String() {
+		case "/package/sql/1.0.0/":
+			w.Write([]byte(`{"name":"sql","version":"1.0.0","requires":{"input":[{"package":"sql_input","version":"^0.2.0"}]}}`))
+		case "/search?all=true&package=sql_input":
+			json.NewEncoder(w).Encode([]packageInfo{
+				{Name: "sql_input", Version: "0.2.0"},
+				{Name: "sql_input", Version: "0.2.1"},
+				{Name: "sql_input", Version: "0.3.0"},
+			})
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config{
+		Address:  server.URL,
+		Packages: []configPackage{{Name: "sql", Version: "1.0.0"}},
+	}
+	packages, err := cfg.collect(&http.Client{})
+	require.NoError(t, err)
+
+	var collected []string
+	for _, p := range packages {
+		collected = append(collected, p.Name+"-"+p.Version)
+	}
+	assert.Equal(t, []string{"sql-1.0.0", "sql_input-0.2.1"}, collected)
+}
@@ -0,0 +1,63 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// verifyAction checks that the collected packages are present in a directory with valid
+// signatures. Nothing is downloaded, all the failures are reported when finished.
+type verifyAction struct {
+	keyRing openpgp.KeyRing
+
+	Destination string `yaml:"destination"`
+
+	mu       sync.Mutex
+	failures map[string]error
+}
+
+func (a *verifyAction) init(c config) error {
+	if a.Destination == "" {
+		return errors.New("missing destination")
+	}
+	var err error
+	a.keyRing, err = readPublicKey()
+	return err
+}
+
+func (a *verifyAction) perform(i packageInfo) error {
+	err := verifySignature(a.keyRing,
+		filepath.Join(a.Destination, path.Base(i.Download)),
+		filepath.Join(a.Destination, path.Base(i.SignaturePath)),
+	)
+	if err != nil {
+		fmt.Printf("- %s %s verification failed: %s\n", i.Name, i.Version, err)
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.failures == nil {
+			a.failures = make(map[string]error)
+		}
+		a.failures[path.Base(i.Download)] = err
+	}
+	return nil
+}
+
+func (a *verifyAction) finish(packages []packageInfo) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.failures) > 0 {
+		return fmt.Errorf("verification failed for %d of %d packages", len(a.failures), len(packages))
+	}
+	fmt.Println(len(packages), "packages verified")
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestPackage writes a package and its signature in the directory, and returns its info.
+func writeTestPackage(t *testing.T, dir string, entity *openpgp.Entity, name, version string) packageInfo {
+	t.Helper()
+
+	download, signature := config{}.downloadPathForPackage(name, version)
+	content := []byte("content of " + filepath.Base(download))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, filepath.Base(download)), content, 0644))
+
+	var sigBuf bytes.Buffer
+	require.NoError(t, openpgp.ArmoredDetachSign(&sigBuf, entity, bytes.NewReader(content), nil))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, filepath.Base(signature)), sigBuf.Bytes(), 0644))
+
+	return packageInfo{Name: name, Version: version, Download: download, SignaturePath: signature}
+}
+
+func TestVerifyAction(t *testing.T) {
+	tempDir := t.TempDir()
+
+	entity, err := openpgp.NewEntity("test", "test", "test@example.com", nil)
+	require.NoError(t, err)
+
+	valid := writeTestPackage(t, tempDir, entity, "nginx", "1.0.0")
+	tampered := writeTestPackage(t, tempDir, entity, "nginx", "1.1.0")
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "nginx-1.1.0.zip"), []byte("tampered"), 0644))
+	download, signature := config{}.downloadPathForPackage("nginx", "1.2.0")
+	missing := packageInfo{Name: "nginx", Version: "1.2.0", Download: download, SignaturePath: signature}
+
+	action := &verifyAction{Destination: tempDir}
+	require.NoError(t, action.init(config{}))
+	action.keyRing = openpgp.EntityList{entity}
+
+	require.NoError(t, action.perform(valid))
+	require.NoError(t, action.finish([]packageInfo{valid}))
+
+	for _, info := range []packageInfo{tampered, missing} {
+		require.NoError(t, action.perform(info))
+	}
+	err = action.finish([]packageInfo{valid, tampered, missing})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "verification failed for 2 of 3 packages")
+	assert.Contains(t, action.failures, "nginx-1.1.0.zip")
+	assert.Contains(t, action.failures, "nginx-1.2.0.zip")
+
+	// Invalid files are not removed.
+	assert.FileExists(t, filepath.Join(tempDir, "nginx-1.1.0.zip"))
+}
+
+func TestVerifyActionInit(t *testing.T) {
+	action := &verifyAction{}
+	assert.Error(t, action.init(config{}))
+
+	action = &verifyAction{Destination: t.TempDir()}
+	require.NoError(t, action.init(config{}))
+	assert.NotNil(t, action.keyRing)
+}
@@ -0,0 +1,401 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/gorilla/mux"
+	"go.elastic.co/apm/module/apmzap/v2"
+	"go.uber.org/zap"
+
+	"github.com/elastic/package-registry/internal/util"
+	"github.com/elastic/package-registry/packages"
+	"github.com/elastic/package-registry/proxymode"
+)
+
+const (
+	dependenciesRouterPath = "/package/{packageName:[a-z0-9_]+}/{packageVersion}/dependencies"
+	dependentsRouterPath   = "/package/{packageName:[a-z0-9_]+}/dependents"
+
+	requirementTypeInput   = "input"
+	requirementTypeContent = "content"
+)
+
+// dependencies is the body of the responses of the dependencies endpoint.
+type dependencies struct {
+	Name         string            `json:"name"`
+	Version      string            `json:"version"`
+	Dependencies []*dependencyNode `json:"dependencies"`
+
+	// Dangling are the requirements in the tree that cannot be satisfied by any package.
+	Dangling []danglingRequirement `json:"dangling"`
+}
+
+// dependencyNode is a package required by another package, with its own dependencies.
+type dependencyNode struct {
+	Name         string            `json:"name"`
+	Version      string            `json:"version"`
+	Type         string            `json:"type"`
+	Constraint   string            `json:"constraint"`
+	Dependencies []*dependencyNode `json:"dependencies"`
+
+	// Cycle is set when the package is also one of the packages requiring it, its
+	// dependencies are not included again.
+	Cycle bool `json:"cycle,omitempty"`
+}
+
+type danglingRequirement struct {
+	// Name and Version of the package with the requirement.
+	Name        string                      `json:"name"`
+	Version     string                      `json:"version"`
+	Type        string                      `json:"type"`
+	Requirement packages.PackageRequirement `json:"requirement"`
+	Reason      string                      `json:"reason"`
+}
+
+// dependent is a package that requires the package queried in the dependents endpoint.
+type dependent struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Type       string `json:"type"`
+	Constraint string `json:"constraint"`
+}
+
+type dependenciesHandler struct {
+	logger    *zap.Logger
+	indexer   Indexer
+	cacheTime time.Duration
+
+	proxyMode *proxymode.ProxyMode
+}
+
+type dependenciesOption func(*dependenciesHandler)
+
+func newDependenciesHandler(logger *zap.Logger, indexer Indexer, cacheTime time.Duration, opts ...dependenciesOption) (*dependenciesHandler, error) {
+	if indexer == nil {
+		return nil, errors.New("indexer is required for dependencies handler")
+	}
+	if cacheTime <= 0 {
+		return nil, errors.New("cache time must be greater than 0s")
+	}
+
+	h := &dependenciesHandler{
+		logger:    logger,
+		indexer:   indexer,
+		cacheTime: cacheTime,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h, nil
+}
+
+// dependenciesWithProxy includes the packages of the upstreams when looking for the packages
+// required by a package, and for the packages requiring it.
+func dependenciesWithProxy(pm *proxymode.ProxyMode) dependenciesOption {
+	return func(h *dependenciesHandler) {
+		h.proxyMode = pm
+	}
+}
+
+// dependenciesHandler serves the tree of packages required by a package, resolved with the
+// newest versions satisfying the requirements.
+func (h *dependenciesHandler) dependenciesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := h.logger.With(apmzap.TraceContext(r.Context())...)
+
+		vars := mux.Vars(r)
+		packageName := vars["packageName"]
+		packageVersion := vars["packageVersion"]
+		if _, err := semver.StrictNewVersion(packageVersion); err != nil {
+			badRequest(w, "invalid package version")
+			return
+		}
+		kibanaVersion, err := dependenciesKibanaVersion(r.URL.Query())
+		if err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+
+		restrictions := packages.AccessRestrictionsFromContext(r.Context())
+		opts := packages.NameVersionFilter(packageName, packageVersion)
+		opts.Filter.Restrictions = restrictions
+		pkgs, err := h.indexer.Get(r.Context(), &opts)
+		if err != nil {
+			logger.Error("getting package failed", zap.Error(err))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if len(pkgs) == 0 && h.proxyMode.Enabled() {
+			proxiedPackage, err := h.proxyMode.Package(r)
+			if err != nil {
+				logger.Error("proxy mode: package failed", zap.Error(err))
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			if proxiedPackage != nil && restrictions.Allows(proxiedPackage) {
+				pkgs = pkgs.Join(packages.Packages{proxiedPackage})
+			}
+		}
+		if len(pkgs) == 0 {
+			notFoundError(w, errPackageRevisionNotFound)
+			return
+		}
+
+		resolver := dependencyResolver{
+			logger:    logger,
+			indexer:   h.indexer,
+			proxyMode: h.proxyMode,
+			filter: packages.Filter{
+				AllVersions:   true,
+				Prerelease:    true,
+				KibanaVersion: kibanaVersion,
+				Restrictions:  restrictions,
+			},
+			candidates: make(map[string]packages.Packages),
+		}
+		result := dependencies{
+			Name:     pkgs[0].Name,
+			Version:  pkgs[0].Version,
+			Dangling: []danglingRequirement{},
+		}
+		result.Dependencies, err = resolver.resolve(r.Context(), pkgs[0], []string{pkgs[0].Name}, &result.Dangling)
+		if err != nil {
+			logger.Error("resolving package dependencies failed", zap.Error(err))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		data, err := util.MarshalJSONPretty(result)
+		if err != nil {
+			logger.Error("marshaling package dependencies failed", zap.Error(err))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		serveJSONResponse(w, r, h.cacheTime, newJSONResponse(data))
+	})
+}
+
+// dependentsHandler serves the list of packages that require a package, in any version.
+func (h *dependenciesHandler) dependentsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := h.logger.With(apmzap.TraceContext(r.Context())...)
+
+		packageName := mux.Vars(r)["packageName"]
+		kibanaVersion, err := dependenciesKibanaVersion(r.URL.Query())
+		if err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+
+		filter := packages.Filter{
+			AllVersions:   true,
+			Prerelease:    true,
+			KibanaVersion: kibanaVersion,
+			Restrictions:  packages.AccessRestrictionsFromContext(r.Context()),
+		}
+		pkgs, err := h.indexer.Get(r.Context(), &packages.GetOptions{Filter: &filter})
+		if err != nil {
+			logger.Error("getting packages failed", zap.Error(err))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if h.proxyMode.Enabled() {
+			proxiedPackages, err := proxySearch(r.Context(), h.proxyMode, "", filter)
+			if err != nil {
+				logger.Warn("proxy mode: search failed in some upstreams, dependents include only the available ones", zap.Error(err))
+			}
+			pkgs = pkgs.Join(proxiedPackages)
+		}
+
+		result := []dependent{}
+		for _, p := range pkgs {
+			for _, requirement := range packageRequirements(p) {
+				if requirement.Package != packageName {
+					continue
+				}
+				result = append(result, dependent{
+					Name:       p.Name,
+					Version:    p.Version,
+					Type:       requirement.Type,
+					Constraint: requirement.Version,
+				})
+			}
+		}
+		slices.SortFunc(result, func(a, b dependent) int {
+			return cmp.Or(strings.Compare(a.Name, b.Name), packages.CompareVersions(a.Version, b.Version))
+		})
+
+		data, err := util.MarshalJSONPretty(result)
+		if err != nil {
+			logger.Error("marshaling package dependents failed", zap.Error(err))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		serveJSONResponse(w, r, h.cacheTime, newJSONResponse(data))
+	})
+}
+
+func dependenciesKibanaVersion(query url.Values) (*semver.Version, error) {
+	v := query.Get("kibana.version")
+	if v == "" {
+		return nil, nil
+	}
+	version, err := semver.NewVersion(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Kibana version '%s': %w", v, err)
+	}
+	return version, nil
+}
+
+// proxySearch looks for the packages matching the filter in the upstreams, all of them if
+// name is empty. Packages of the upstreams that answered are returned even if others fail.
+func proxySearch(ctx context.Context, pm *proxymode.ProxyMode, name string, filter packages.Filter) (packages.Packages, error) {
+	query := url.Values{}
+	if name != "" {
+		query.Set("package", name)
+	}
+	query.Set("all", "true")
+	query.Set("prerelease", "true")
+	if filter.KibanaVersion != nil {
+		query.Set("kibana.version", filter.KibanaVersion.String())
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "/search?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := pm.Search(r)
+	return filter.Restrictions.Filter(pkgs), err
+}
+
+// dependencyResolver resolves the requirements of packages with the packages in an indexer,
+// and in the upstreams if proxy mode is enabled.
+type dependencyResolver struct {
+	logger    *zap.Logger
+	indexer   Indexer
+	proxyMode *proxymode.ProxyMode
+	filter    packages.Filter
+
+	// candidates are the packages found for each required package name.
+	candidates map[string]packages.Packages
+}
+
+// resolve returns the dependencies of the package. ancestors are the names of the packages
+// requiring it, used to detect cycles. Requirements that cannot be satisfied are added to
+// dangling.
+func (r *dependencyResolver) resolve(ctx context.Context, p *packages.Package, ancestors []string, dangling *[]danglingRequirement) ([]*dependencyNode, error) {
+	nodes := []*dependencyNode{}
+	for _, requirement := range packageRequirements(p) {
+		danglingWithReason := func(reason string) {
+			*dangling = append(*dangling, danglingRequirement{
+				Name:        p.Name,
+				Version:     p.Version,
+				Type:        requirement.Type,
+				Requirement: requirement.PackageRequirement,
+				Reason:      reason,
+			})
+		}
+
+		constraint, err := semver.NewConstraint(requirement.Version)
+		if err != nil {
+			danglingWithReason(fmt.Sprintf("invalid version constraint: %s", err))
+			continue
+		}
+		required, err := r.newest(ctx, requirement.Package, constraint)
+		if err != nil {
+			return nil, err
+		}
+		if required == nil {
+			danglingWithReason("no package satisfies the requirement")
+			continue
+		}
+
+		node := dependencyNode{
+			Name:       required.Name,
+			Version:    required.Version,
+			Type:       requirement.Type,
+			Constraint: requirement.Version,
+		}
+		if slices.Contains(ancestors, required.Name) {
+			node.Cycle = true
+		} else {
+			node.Dependencies, err = r.resolve(ctx, required, append(slices.Clip(ancestors), required.Name), dangling)
+			if err != nil {
+				return nil, err
+			}
+		}
+		nodes = append(nodes, &node)
+	}
+	return nodes, nil
+}
+
+// newest returns the newest version of the package that satisfies the constraint, or nil
+// if there is none.
+func (r *dependencyResolver) newest(ctx context.Context, name string, constraint *semver.Constraints) (*packages.Package, error) {
+	candidates, found := r.candidates[name]
+	if !found {
+		filter := r.filter
+		filter.PackageName = name
+		var err error
+		candidates, err = r.indexer.Get(ctx, &packages.GetOptions{Filter: &filter})
+		if err != nil {
+			return nil, fmt.Errorf("getting versions of package %s: %w", name, err)
+		}
+		if r.proxyMode.Enabled() {
+			proxiedPackages, err := proxySearch(ctx, r.proxyMode, name, r.filter)
+			if err != nil {
+				r.logger.Warn("proxy mode: search failed in some upstreams, dependencies include only the available ones",
+					zap.String("package.name", name),
+					zap.Error(err))
+			}
+			candidates = candidates.Join(proxiedPackages)
+		}
+		r.candidates[name] = candidates
+	}
+
+	var newest *packages.Package
+	var newestVersion *semver.Version
+	for _, p := range candidates {
+		v, err := semver.NewVersion(p.Version)
+		if err != nil || !constraint.Check(v) {
+			continue
+		}
+		if newestVersion == nil || v.GreaterThan(newestVersion) {
+			newest, newestVersion = p, v
+		}
+	}
+	return newest, nil
+}
+
+type typedRequirement struct {
+	packages.PackageRequirement
+	Type string
+}
+
+func packageRequirements(p *packages.Package) []typedRequirement {
+	if p.Requires == nil {
+		return nil
+	}
+	var requirements []typedRequirement
+	for _, requirement := range p.Requires.Input {
+		requirements = append(requirements, typedRequirement{PackageRequirement: requirement, Type: requirementTypeInput})
+	}
+	for _, requirement := range p.Requires.Content {
+		requirements = append(requirements, typedRequirement{PackageRequirement: requirement, Type: requirementTypeContent})
+	}
+	return requirements
+}
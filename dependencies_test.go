@@ -0,0 +1,240 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/package-registry/packages"
+	"github.com/elastic/package-registry/proxymode"
+)
+
+func TestDependencies(t *testing.T) {
+	t.Parallel()
+
+	fsOpts := packages.FSIndexerOptions{
+		Logger: testLogger,
+	}
+	indexer := packages.NewFileSystemIndexer(fsOpts, "./testdata/package")
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+
+	err := indexer.Init(t.Context())
+	require.NoError(t, err)
+
+	handler, err := newDependenciesHandler(testLogger, indexer, testCacheTime)
+	require.NoError(t, err)
+
+	tests := []struct {
+		endpoint string
+		path     string
+		file     string
+		handler  http.Handler
+	}{
+		{"/package/package_reference/0.1.0/dependencies", dependenciesRouterPath, "dependencies-package-reference.json", handler.dependenciesHandler()},
+		{"/package/example/1.0.0/dependencies", dependenciesRouterPath, "dependencies-example.json", handler.dependenciesHandler()},
+		{"/package/missing/1.0.0/dependencies", dependenciesRouterPath, "dependencies-package-not-found.txt", handler.dependenciesHandler()},
+		{"/package/example/a.b.c/dependencies", dependenciesRouterPath, "dependencies-invalid-version.txt", handler.dependenciesHandler()},
+		{"/package/example/1.0.0/dependencies?kibana.version=foo", dependenciesRouterPath, "dependencies-invalid-kibana-version.txt", handler.dependenciesHandler()},
+		{"/package/sql_input/dependents", dependentsRouterPath, "dependents-sql-input.json", handler.dependentsHandler()},
+		{"/package/example/dependents", dependentsRouterPath, "dependents-example.json", handler.dependentsHandler()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.endpoint, func(t *testing.T) {
+			runEndpoint(t, test.endpoint, test.path, test.file, test.handler)
+		})
+	}
+}
+
+func TestDependenciesResolution(t *testing.T) {
+	t.Parallel()
+
+	packagesPath := t.TempDir()
+	copyTestPackage := func(name, version string, replacer *strings.Replacer) {
+		dest := filepath.Join(packagesPath, name, version)
+		require.NoError(t, os.CopyFS(dest, os.DirFS(filepath.Join("testdata", "package", name, version))))
+		if replacer == nil {
+			return
+		}
+		manifestPath := filepath.Join(dest, "manifest.yml")
+		d, err := os.ReadFile(manifestPath)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(manifestPath, []byte(replacer.Replace(string(d))), 0644))
+	}
+	copyTestPackage("package_reference", "0.1.0", strings.NewReplacer(`requires:
+  input:
+    - package: sql_input
+      version: 0.2.0
+`, `requires:
+  input:
+    - package: sql_input
+      version: ">=0.2.0"
+    - package: missing_input
+      version: 1.0.0
+  content:
+    - package: sql_input
+      version: not a constraint
+`))
+	copyTestPackage("sql_input", "0.2.0", nil)
+	copyTestPackage("sql_input", "0.3.0", strings.NewReplacer("license: basic\n", `license: basic
+conditions:
+  kibana:
+    version: ^9.0.0
+`))
+
+	indexer := packages.NewFileSystemIndexer(packages.FSIndexerOptions{Logger: testLogger}, packagesPath)
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+	require.NoError(t, indexer.Init(t.Context()))
+
+	handler, err := newDependenciesHandler(testLogger, indexer, testCacheTime)
+	require.NoError(t, err)
+
+	getDependencies := func(t *testing.T, endpoint string) dependencies {
+		recorder := recordRequest(t, endpoint, dependenciesRouterPath, handler.dependenciesHandler())
+		require.Equal(t, http.StatusOK, recorder.Code)
+		var result dependencies
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+		return result
+	}
+
+	t.Run("newest version", func(t *testing.T) {
+		result := getDependencies(t, "/package/package_reference/0.1.0/dependencies")
+		require.Len(t, result.Dependencies, 1)
+		assert.Equal(t, dependencyNode{Name: "sql_input", Version: "0.3.0", Type: "input", Constraint: ">=0.2.0", Dependencies: []*dependencyNode{}}, *result.Dependencies[0])
+	})
+
+	t.Run("kibana version", func(t *testing.T) {
+		result := getDependencies(t, "/package/package_reference/0.1.0/dependencies?kibana.version=8.19.0")
+		require.Len(t, result.Dependencies, 1)
+		assert.Equal(t, "0.2.0", result.Dependencies[0].Version)
+	})
+
+	t.Run("dangling requirements", func(t *testing.T) {
+		result := getDependencies(t, "/package/package_reference/0.1.0/dependencies")
+		assert.Equal(t, []danglingRequirement{
+			{
+				Name:        "package_reference",
+				Version:     "0.1.0",
+				Type:        "input",
+				Requirement: packages.PackageRequirement{Package: "missing_input", Version: "1.0.0"},
+				Reason:      "no package satisfies the requirement",
+			},
+			{
+				Name:        "package_reference",
+				Version:     "0.1.0",
+				Type:        "content",
+				Requirement: packages.PackageRequirement{Package: "sql_input", Version: "not a constraint"},
+				Reason:      "invalid version constraint: improper constraint: \"not a constraint\"",
+			},
+		}, result.Dangling)
+	})
+}
+
+func TestDependenciesRoutes(t *testing.T) {
+	config := defaultConfig
+	indexer := packages.NewFileSystemIndexer(packages.FSIndexerOptions{Logger: testLogger}, "./testdata/package")
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+	require.NoError(t, indexer.Init(t.Context()))
+
+	router, err := getRouter(testLogger, serverOptions{
+		config:  &config,
+		indexer: indexer,
+	})
+	require.NoError(t, err)
+
+	// These paths would also match the package index and static routes.
+	for _, path := range []string{"/package/package_reference/0.1.0/dependencies", "/package/sql_input/dependents"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code, path)
+		assert.True(t, json.Valid(recorder.Body.Bytes()), path)
+		assert.Contains(t, recorder.Body.String(), "package_reference", path)
+	}
+}
+
+func TestDependenciesWithProxy(t *testing.T) {
+	t.Parallel()
+
+	webServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" {
+			http.NotFound(w, r)
+			return
+		}
+		upstreamPackages := []packages.BasePackage{
+			{Name: "upstream_input", Version: "1.0.0", Type: "input"},
+			{Name: "upstream_input", Version: "1.1.0", Type: "input"},
+			{
+				Name:     "upstream_integration",
+				Version:  "2.0.0",
+				Type:     "integration",
+				Requires: &packages.PackageRequirements{Input: []packages.PackageRequirement{{Package: "sql_input", Version: "^0.2.0"}}},
+			},
+		}
+		var result []packages.BasePackage
+		for _, p := range upstreamPackages {
+			if name := r.URL.Query().Get("package"); name == "" || name == p.Name {
+				result = append(result, p)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(result))
+	}))
+	t.Cleanup(webServer.Close)
+
+	packagesPath := t.TempDir()
+	dest := filepath.Join(packagesPath, "package_reference", "0.1.0")
+	require.NoError(t, os.CopyFS(dest, os.DirFS(filepath.Join("testdata", "package", "package_reference", "0.1.0"))))
+	manifestPath := filepath.Join(dest, "manifest.yml")
+	d, err := os.ReadFile(manifestPath)
+	require.NoError(t, err)
+	d = []byte(strings.Replace(string(d), "package: sql_input\n      version: 0.2.0", "package: upstream_input\n      version: ^1.0.0", 1))
+	require.NoError(t, os.WriteFile(manifestPath, d, 0644))
+	require.NoError(t, os.CopyFS(filepath.Join(packagesPath, "sql_input", "0.2.0"), os.DirFS(filepath.Join("testdata", "package", "sql_input", "0.2.0"))))
+
+	indexer := packages.NewFileSystemIndexer(packages.FSIndexerOptions{Logger: testLogger}, packagesPath)
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+	require.NoError(t, indexer.Init(t.Context()))
+
+	proxyMode, err := proxymode.NewProxyMode(testLogger, proxymode.ProxyOptions{
+		Enabled: true,
+		ProxyTo: webServer.URL,
+	})
+	require.NoError(t, err)
+
+	handler, err := newDependenciesHandler(testLogger, indexer, testCacheTime,
+		dependenciesWithProxy(proxyMode),
+	)
+	require.NoError(t, err)
+
+	t.Run("dependencies", func(t *testing.T) {
+		recorder := recordRequest(t, "/package/package_reference/0.1.0/dependencies", dependenciesRouterPath, handler.dependenciesHandler())
+		require.Equal(t, http.StatusOK, recorder.Code)
+		var result dependencies
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+		require.Len(t, result.Dependencies, 1)
+		assert.Equal(t, dependencyNode{Name: "upstream_input", Version: "1.1.0", Type: "input", Constraint: "^1.0.0", Dependencies: []*dependencyNode{}}, *result.Dependencies[0])
+		assert.Empty(t, result.Dangling)
+	})
+
+	t.Run("dependents", func(t *testing.T) {
+		recorder := recordRequest(t, "/package/sql_input/dependents", dependentsRouterPath, handler.dependentsHandler())
+		require.Equal(t, http.StatusOK, recorder.Code)
+		var result []dependent
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+		assert.Equal(t, []dependent{
+			{Name: "upstream_integration", Version: "2.0.0", Type: "input", Constraint: "^0.2.0"},
+		}, result)
+	})
+}
@@ -0,0 +1,90 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/gorilla/mux"
+	"go.elastic.co/apm/module/apmzap/v2"
+	"go.uber.org/zap"
+
+	"github.com/elastic/package-registry/internal/util"
+	"github.com/elastic/package-registry/packages"
+)
+
+const diffRouterPath = "/package/{packageName:[a-z0-9_]+}/diff"
+
+type diffHandler struct {
+	logger    *zap.Logger
+	indexer   Indexer
+	cacheTime time.Duration
+}
+
+func newDiffHandler(logger *zap.Logger, indexer Indexer, cacheTime time.Duration) (*diffHandler, error) {
+	if indexer == nil {
+		return nil, errors.New("indexer is required for diff handler")
+	}
+	if cacheTime <= 0 {
+		return nil, errors.New("cache time must be greater than 0s")
+	}
+
+	return &diffHandler{
+		logger:    logger,
+		indexer:   indexer,
+		cacheTime: cacheTime,
+	}, nil
+}
+
+func (h *diffHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.logger.With(apmzap.TraceContext(r.Context())...)
+
+	packageName := mux.Vars(r)["packageName"]
+	query := r.URL.Query()
+	var versions [2]string
+	for i, param := range []string{"from", "to"} {
+		v := query.Get(param)
+		if v == "" {
+			badRequest(w, fmt.Sprintf("missing '%s' version", param))
+			return
+		}
+		if _, err := semver.StrictNewVersion(v); err != nil {
+			badRequest(w, fmt.Sprintf("invalid '%s' version '%s'", param, v))
+			return
+		}
+		versions[i] = v
+	}
+
+	var pkgs [2]*packages.Package
+	for i, version := range versions {
+		opts := packages.NameVersionFilter(packageName, version)
+		opts.Filter.Restrictions = packages.AccessRestrictionsFromContext(r.Context())
+		opts.FullData = true
+
+		found, err := h.indexer.Get(r.Context(), &opts)
+		if err != nil {
+			logger.Error("getting package failed", zap.Error(err))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if len(found) == 0 {
+			notFoundError(w, fmt.Errorf("package %s version %s not found", packageName, version))
+			return
+		}
+		pkgs[i] = found[0]
+	}
+
+	data, err := util.MarshalJSONPretty(packages.Diff(pkgs[0], pkgs[1]))
+	if err != nil {
+		logger.Error("marshaling package diff failed", zap.Error(err))
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	serveJSONResponse(w, r, h.cacheTime, newJSONResponse(data))
+}
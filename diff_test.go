@@ -0,0 +1,74 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/package-registry/packages"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	fsOpts := packages.FSIndexerOptions{
+		Logger: testLogger,
+	}
+	indexer := packages.NewFileSystemIndexer(fsOpts, "./testdata/package")
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+
+	err := indexer.Init(t.Context())
+	require.NoError(t, err)
+
+	diffHandler, err := newDiffHandler(testLogger, indexer, testCacheTime)
+	require.NoError(t, err)
+
+	tests := []struct {
+		endpoint string
+		path     string
+		file     string
+		handler  http.Handler
+	}{
+		{"/package/example/diff?from=1.0.0&to=1.1.0", diffRouterPath, "diff-example-1.0.0-1.1.0.json", diffHandler},
+		{"/package/integration_input/diff?from=1.0.0&to=1.0.2", diffRouterPath, "diff-integration-input-1.0.0-1.0.2.json", diffHandler},
+		{"/package/sql_input/diff?from=0.2.0&to=0.3.0", diffRouterPath, "diff-sql-input-0.2.0-0.3.0.json", diffHandler},
+		{"/package/example/diff?from=1.1.0&to=1.1.0", diffRouterPath, "diff-example-same-version.json", diffHandler},
+		{"/package/example/diff?to=1.1.0", diffRouterPath, "diff-missing-from.txt", diffHandler},
+		{"/package/example/diff?from=1.0.0&to=a.b.c", diffRouterPath, "diff-invalid-to.txt", diffHandler},
+		{"/package/example/diff?from=1.0.0&to=9.9.9", diffRouterPath, "diff-version-not-found.txt", diffHandler},
+		{"/package/missing/diff?from=1.0.0&to=1.1.0", diffRouterPath, "diff-package-not-found.txt", diffHandler},
+	}
+
+	for _, test := range tests {
+		t.Run(test.endpoint, func(t *testing.T) {
+			runEndpoint(t, test.endpoint, test.path, test.file, test.handler)
+		})
+	}
+}
+
+func TestDiffRoute(t *testing.T) {
+	config := defaultConfig
+	indexer := packages.NewFileSystemIndexer(packages.FSIndexerOptions{Logger: testLogger}, "./testdata/package")
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+	require.NoError(t, indexer.Init(t.Context()))
+
+	router, err := getRouter(testLogger, serverOptions{
+		config:  &config,
+		indexer: indexer,
+	})
+	require.NoError(t, err)
+
+	// This path would also match the package index route.
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/package/example/diff?from=1.0.0&to=1.1.0", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"kibana.version"`)
+}
@@ -0,0 +1,140 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/gorilla/mux"
+	"go.elastic.co/apm/module/apmzap/v2"
+	"go.uber.org/zap"
+
+	"github.com/elastic/package-registry/internal/util"
+	"github.com/elastic/package-registry/packages"
+	"github.com/elastic/package-registry/proxymode"
+)
+
+const (
+	fieldsRouterPath = "/package/{packageName:[a-z0-9_]+}/{packageVersion}/data_stream/{dataStream}/fields"
+
+	// fieldsRemoteTimeout is the maximum time to get each fields file of remote packages.
+	fieldsRemoteTimeout = 30 * time.Second
+)
+
+var errDataStreamNotFound = errors.New("data stream not found")
+
+type fieldsHandler struct {
+	logger    *zap.Logger
+	indexer   Indexer
+	cacheTime time.Duration
+
+	// client is used to follow the redirections of the remote resolvers.
+	client    *http.Client
+	proxyMode *proxymode.ProxyMode
+}
+
+type fieldsOption func(*fieldsHandler)
+
+func newFieldsHandler(logger *zap.Logger, indexer Indexer, cacheTime time.Duration, opts ...fieldsOption) (*fieldsHandler, error) {
+	if indexer == nil {
+		return nil, errors.New("indexer is required for fields handler")
+	}
+	if cacheTime <= 0 {
+		return nil, errors.New("cache time must be greater than 0s")
+	}
+
+	h := &fieldsHandler{
+		logger:    logger,
+		indexer:   indexer,
+		cacheTime: cacheTime,
+		client:    &http.Client{Timeout: fieldsRemoteTimeout},
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h, nil
+}
+
+func fieldsWithProxy(pm *proxymode.ProxyMode) fieldsOption {
+	return func(h *fieldsHandler) {
+		h.proxyMode = pm
+	}
+}
+
+func (h *fieldsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.logger.With(apmzap.TraceContext(r.Context())...)
+
+	vars := mux.Vars(r)
+	packageName := vars["packageName"]
+	packageVersion := vars["packageVersion"]
+	dataStreamName := vars["dataStream"]
+	if _, err := semver.StrictNewVersion(packageVersion); err != nil {
+		badRequest(w, "invalid package version")
+		return
+	}
+
+	opts := packages.NameVersionFilter(packageName, packageVersion)
+	opts.Filter.Restrictions = packages.AccessRestrictionsFromContext(r.Context())
+	opts.FullData = true
+
+	pkgs, err := h.indexer.Get(r.Context(), &opts)
+	if err != nil {
+		logger.Error("getting package failed", zap.Error(err))
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(pkgs) == 0 && h.proxyMode.Enabled() {
+		proxiedPackage, err := h.proxyMode.Package(r)
+		if err != nil {
+			logger.Error("proxy mode: package failed", zap.Error(err))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if proxiedPackage != nil && opts.Filter.Restrictions.Allows(proxiedPackage) {
+			pkgs = pkgs.Join(packages.Packages{proxiedPackage})
+		}
+	}
+	if len(pkgs) == 0 {
+		notFoundError(w, errPackageRevisionNotFound)
+		return
+	}
+
+	// Data streams can be referenced by their dataset or by the name of their directory.
+	var dataStream *packages.DataStream
+	for _, ds := range pkgs[0].DataStreams {
+		if ds.Dataset == dataStreamName || ds.Path == dataStreamName {
+			dataStream = ds
+			break
+		}
+	}
+	if dataStream == nil {
+		notFoundError(w, errDataStreamNotFound)
+		return
+	}
+
+	fields, err := packages.ReadDataStreamFields(r.Context(), h.client, pkgs[0], dataStream)
+	if err != nil {
+		logger.Error("reading data stream fields failed",
+			zap.String("package.name", packageName),
+			zap.String("package.version", packageVersion),
+			zap.String("data_stream.dataset", dataStream.Dataset),
+			zap.Error(err))
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := util.MarshalJSONPretty(fields)
+	if err != nil {
+		logger.Error("marshaling data stream fields failed", zap.Error(err))
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	serveJSONResponse(w, r, h.cacheTime, newJSONResponse(data))
+}
@@ -0,0 +1,87 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/package-registry/archiver"
+	"github.com/elastic/package-registry/packages"
+)
+
+func TestFields(t *testing.T) {
+	t.Parallel()
+
+	fsOpts := packages.FSIndexerOptions{
+		Logger: testLogger,
+	}
+	indexer := packages.NewFileSystemIndexer(fsOpts, "./testdata/package")
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+
+	err := indexer.Init(t.Context())
+	require.NoError(t, err)
+
+	fieldsHandler, err := newFieldsHandler(testLogger, indexer, testCacheTime)
+	require.NoError(t, err)
+
+	tests := []struct {
+		endpoint string
+		path     string
+		file     string
+		handler  http.Handler
+	}{
+		{"/package/datastream_without_release/0.1.0/data_stream/nodes/fields", fieldsRouterPath, "fields-datastream-without-release-nodes.json", fieldsHandler},
+		{"/package/datastream_without_release/0.1.0/data_stream/datastream_without_release.nodes/fields", fieldsRouterPath, "fields-datastream-without-release-nodes.json", fieldsHandler},
+		{"/package/datastream_without_release/0.1.0/data_stream/missing/fields", fieldsRouterPath, "fields-data-stream-not-found.txt", fieldsHandler},
+		{"/package/missing/1.0.0/data_stream/foo/fields", fieldsRouterPath, "fields-package-not-found.txt", fieldsHandler},
+		{"/package/datastream_without_release/a.b.c/data_stream/nodes/fields", fieldsRouterPath, "fields-invalid-version.txt", fieldsHandler},
+	}
+
+	for _, test := range tests {
+		t.Run(test.endpoint, func(t *testing.T) {
+			runEndpoint(t, test.endpoint, test.path, test.file, test.handler)
+		})
+	}
+}
+
+func TestFieldsZipPackage(t *testing.T) {
+	t.Parallel()
+
+	zipPath := t.TempDir()
+	f, err := os.Create(filepath.Join(zipPath, "datastream_without_release-0.1.0.zip"))
+	require.NoError(t, err)
+	err = archiver.ArchivePackage(f, archiver.PackageProperties{
+		Name:    "datastream_without_release",
+		Version: "0.1.0",
+		Path:    "./testdata/package/datastream_without_release/0.1.0",
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	indexer := packages.NewZipFileSystemIndexer(packages.FSIndexerOptions{Logger: testLogger}, zipPath)
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+	require.NoError(t, indexer.Init(t.Context()))
+
+	fieldsHandler, err := newFieldsHandler(testLogger, indexer, testCacheTime)
+	require.NoError(t, err)
+
+	recorder := recordRequest(t, "/package/datastream_without_release/0.1.0/data_stream/nodes/fields", fieldsRouterPath, fieldsHandler)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var fields []packages.Field
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &fields))
+	require.NotEmpty(t, fields)
+
+	expected, err := os.ReadFile(filepath.Join(generatedFilesPath, "fields-datastream-without-release-nodes.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), recorder.Body.String())
+}
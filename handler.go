@@ -5,9 +5,15 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/elastic/package-registry/internal/util"
 )
 
 func notFoundHandler(err error) http.Handler {
@@ -40,3 +46,63 @@ func noCacheHeaders(w http.ResponseWriter) {
 func jsonHeader(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
 }
+
+// jsonResponse is a JSON response body with its entity tag, so it can be cached
+// without needing to hash the body again on each request. Compressed versions of the
+// body are also kept once they are requested.
+type jsonResponse struct {
+	body []byte
+	etag string
+
+	m       sync.Mutex
+	encoded map[string][]byte
+}
+
+func newJSONResponse(body []byte) *jsonResponse {
+	sum := sha256.Sum256(body)
+	return &jsonResponse{
+		body: body,
+		etag: `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}
+}
+
+// encode returns the body compressed with the given encoding, and the entity tag of
+// this representation.
+func (r *jsonResponse) encode(encoding string) ([]byte, string, error) {
+	etag := strings.TrimSuffix(r.etag, `"`) + "-" + encoding + `"`
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	if body, found := r.encoded[encoding]; found {
+		return body, etag, nil
+	}
+	body, err := util.Compress(encoding, r.body)
+	if err != nil {
+		return nil, "", err
+	}
+	if r.encoded == nil {
+		r.encoded = make(map[string][]byte)
+	}
+	r.encoded[encoding] = body
+	return body, etag, nil
+}
+
+// etagMatches checks if any of the entity tags in the value of an If-None-Match header
+// matches the given one. As defined for this header, weak comparison is used.
+// https://www.rfc-editor.org/rfc/rfc9110#field.if-none-match
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
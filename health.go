@@ -5,15 +5,123 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/elastic/package-registry/internal/util"
+	"github.com/elastic/package-registry/packages"
+)
+
+const (
+	healthStatusReady    = "ready"
+	healthStatusNotReady = "not_ready"
 )
 
-// healthHandler is used for Docker/K8s deployments. It returns 200 if the service is live
-// In addition ?ready=true can be used for a ready request. Currently both are identical.
-type healthHandler struct{}
+// indexerStatusReporter is implemented by indexers that can report the status of their index.
+type indexerStatusReporter interface {
+	Status(ctx context.Context) packages.IndexerStatus
+}
+
+type healthResponse struct {
+	Status   string                   `json:"status"`
+	Indexers []packages.IndexerStatus `json:"indexers"`
+}
+
+// healthHandler is used for Docker/K8s deployments. It returns 200 if the service is live.
+// In addition ?ready=true can be used for a ready request, that reports the status of the
+// indexers and returns 503 if any of them is not ready to serve requests.
+type healthHandler struct {
+	indexer     Indexer
+	maxIndexAge time.Duration
+	readiness   bool
+}
+
+type healthOption func(*healthHandler)
+
+func newHealthHandler(opts ...healthOption) *healthHandler {
+	h := &healthHandler{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func healthWithIndexer(indexer Indexer) healthOption {
+	return func(h *healthHandler) {
+		h.indexer = indexer
+	}
+}
+
+// healthWithMaxIndexAge sets the maximum time since the last successful update
+// of a periodically updated index before it is considered stale.
+func healthWithMaxIndexAge(maxIndexAge time.Duration) healthOption {
+	return func(h *healthHandler) {
+		h.maxIndexAge = maxIndexAge
+	}
+}
 
-func newHealthHandler() *healthHandler {
-	return &healthHandler{}
+// healthWithReadiness makes the handler to always report readiness.
+func healthWithReadiness() healthOption {
+	return func(h *healthHandler) {
+		h.readiness = true
+	}
 }
 
-func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.readiness {
+		ready, _ := strconv.ParseBool(r.URL.Query().Get("ready"))
+		if !ready {
+			return
+		}
+	}
+
+	response := h.readinessStatus(r.Context(), time.Now())
+	body, err := util.MarshalJSONPretty(response)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	noCacheHeaders(w)
+	jsonHeader(w)
+	if response.Status != healthStatusReady {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(body)
+}
+
+func (h *healthHandler) readinessStatus(ctx context.Context, now time.Time) healthResponse {
+	response := healthResponse{
+		Status:   healthStatusReady,
+		Indexers: []packages.IndexerStatus{},
+	}
+	for _, status := range indexersStatus(ctx, h.indexer) {
+		switch {
+		case status.Stale(now, h.maxIndexAge):
+			response.Status = healthStatusNotReady
+		case status.LastSuccessfulUpdate == nil:
+			response.Status = healthStatusNotReady
+		case status.Database != nil && !status.Database.Reachable:
+			response.Status = healthStatusNotReady
+		}
+		response.Indexers = append(response.Indexers, status)
+	}
+	return response
+}
+
+func indexersStatus(ctx context.Context, indexer Indexer) []packages.IndexerStatus {
+	switch indexer := indexer.(type) {
+	case CombinedIndexer:
+		var result []packages.IndexerStatus
+		for _, i := range indexer {
+			result = append(result, indexersStatus(ctx, i)...)
+		}
+		return result
+	case indexerStatusReporter:
+		return []packages.IndexerStatus{indexer.Status(ctx)}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/package-registry/packages"
+)
+
+type fakeStatusIndexer struct {
+	status packages.IndexerStatus
+}
+
+func (i *fakeStatusIndexer) Init(context.Context) error { return nil }
+func (i *fakeStatusIndexer) Get(context.Context, *packages.GetOptions) (packages.Packages, error) {
+	return nil, nil
+}
+func (i *fakeStatusIndexer) Close(context.Context) error { return nil }
+func (i *fakeStatusIndexer) Status(context.Context) packages.IndexerStatus {
+	return i.status
+}
+
+func TestHealthHandler(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-1 * time.Minute)
+	old := now.Add(-1 * time.Hour)
+
+	cases := []struct {
+		title          string
+		endpoint       string
+		readiness      bool
+		statuses       []packages.IndexerStatus
+		expectedCode   int
+		expectedStatus string
+	}{
+		{
+			title:        "liveness",
+			endpoint:     "/health",
+			expectedCode: http.StatusOK,
+			statuses: []packages.IndexerStatus{
+				{Name: "failing", Periodic: true},
+			},
+		},
+		{
+			title:          "ready with recent update",
+			endpoint:       "/health?ready=true",
+			expectedCode:   http.StatusOK,
+			expectedStatus: healthStatusReady,
+			statuses: []packages.IndexerStatus{
+				{Name: "storage", Periodic: true, LastSuccessfulUpdate: &recent},
+				{Name: "fs", LastSuccessfulUpdate: &old},
+			},
+		},
+		{
+			title:          "stale index",
+			endpoint:       "/health/ready",
+			readiness:      true,
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedStatus: healthStatusNotReady,
+			statuses: []packages.IndexerStatus{
+				{Name: "storage", Periodic: true, LastSuccessfulUpdate: &old, LastFailedUpdate: &recent},
+			},
+		},
+		{
+			title:          "never updated",
+			endpoint:       "/health/ready",
+			readiness:      true,
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedStatus: healthStatusNotReady,
+			statuses: []packages.IndexerStatus{
+				{Name: "fs"},
+			},
+		},
+		{
+			title:          "unreachable database",
+			endpoint:       "/health/ready",
+			readiness:      true,
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedStatus: healthStatusNotReady,
+			statuses: []packages.IndexerStatus{
+				{Name: "sql", Periodic: true, LastSuccessfulUpdate: &recent, Database: &packages.DatabaseStatus{Reachable: false}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			var indexer CombinedIndexer
+			for _, status := range c.statuses {
+				indexer = append(indexer, &fakeStatusIndexer{status: status})
+			}
+			opts := []healthOption{
+				healthWithIndexer(indexer),
+				healthWithMaxIndexAge(10 * time.Minute),
+			}
+			if c.readiness {
+				opts = append(opts, healthWithReadiness())
+			}
+			handler := newHealthHandler(opts...)
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, c.endpoint, nil)
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, c.expectedCode, recorder.Code)
+			if c.expectedStatus == "" {
+				assert.Empty(t, recorder.Body.Bytes())
+				return
+			}
+
+			var response healthResponse
+			err := json.Unmarshal(recorder.Body.Bytes(), &response)
+			require.NoError(t, err)
+			assert.Equal(t, c.expectedStatus, response.Status)
+			assert.Len(t, response.Indexers, len(c.statuses))
+		})
+	}
+}
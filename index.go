@@ -19,7 +19,7 @@ type indexData struct {
 
 type indexHandler struct {
 	cacheTime time.Duration
-	body      []byte
+	response  *jsonResponse
 }
 
 func newIndexHandler(cacheTime time.Duration) (*indexHandler, error) {
@@ -37,10 +37,10 @@ func newIndexHandler(cacheTime time.Duration) (*indexHandler, error) {
 
 	return &indexHandler{
 		cacheTime: cacheTime,
-		body:      body,
+		response:  newJSONResponse(body),
 	}, nil
 }
 
 func (h *indexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	serveJSONResponse(r.Context(), w, h.cacheTime, h.body)
+	serveJSONResponse(w, r, h.cacheTime, h.response)
 }
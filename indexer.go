@@ -46,10 +46,11 @@ func (c CombinedIndexer) Get(ctx context.Context, opts *packages.GetOptions) (pa
 	}
 
 	if opts != nil && opts.Filter != nil && !opts.Filter.AllVersions {
-		return latestPackagesVersion(packages), nil
+		packages = latestPackagesVersion(packages)
 	}
 
-	return packages, nil
+	// Each indexer returns its own page, select the global one.
+	return opts.SelectPage(packages), nil
 }
 
 func (c CombinedIndexer) Close(ctx context.Context) error {
@@ -0,0 +1,260 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/elastic/package-registry/internal/util"
+)
+
+const realm = "package-registry"
+
+// Config is the configuration of the authentication of the HTTP API. Authentication
+// is enabled if any of the methods is configured.
+type Config struct {
+	// Tokens are the static bearer tokens accepted, each one identified by a name.
+	Tokens []TokenConfig `config:"tokens"`
+
+	// HtpasswdFile is the path to a file with users and bcrypt hashed passwords for
+	// basic authentication, in the format generated by `htpasswd -B`.
+	HtpasswdFile string `config:"htpasswd_file"`
+
+	// ClientCertificateAuthorities are the paths to the PEM encoded certificate authorities
+	// used to verify client certificates. They require TLS to be enabled in the server.
+	ClientCertificateAuthorities []string `config:"client_certificate_authorities"`
+
+	// ExemptPaths are the paths that can be requested without authentication.
+	ExemptPaths []string `config:"exempt_paths"`
+
+	// UserScopes are the scopes of the users authenticated with basic authentication
+	// or client certificates. Scopes of bearer tokens are set in their configuration.
+	UserScopes map[string][]string `config:"user_scopes"`
+}
+
+// TokenConfig is a static bearer token. The token can be set directly, or read from a file.
+type TokenConfig struct {
+	Name      string   `config:"name"`
+	Token     string   `config:"token"`
+	TokenFile string   `config:"token_file"`
+	Scopes    []string `config:"scopes"`
+}
+
+// Enabled returns true if any authentication method is configured.
+func (c Config) Enabled() bool {
+	return len(c.Tokens) > 0 || c.HtpasswdFile != "" || len(c.ClientCertificateAuthorities) > 0
+}
+
+// Authenticator checks the credentials of the requests to the HTTP API.
+type Authenticator struct {
+	logger *zap.Logger
+
+	tokens      map[string]string
+	tokenScopes map[string][]string
+	userScopes  map[string][]string
+	users       htpasswd
+	clientCAs   *x509.CertPool
+	exemptPaths []string
+}
+
+// NewAuthenticator creates an authenticator with the given configuration, loading the
+// files referenced by it.
+func NewAuthenticator(logger *zap.Logger, config Config) (*Authenticator, error) {
+	a := Authenticator{
+		logger:      logger,
+		tokens:      make(map[string]string),
+		tokenScopes: make(map[string][]string),
+		userScopes:  config.UserScopes,
+		exemptPaths: config.ExemptPaths,
+	}
+
+	for _, t := range config.Tokens {
+		if t.Name == "" {
+			return nil, errors.New("missing name of bearer token")
+		}
+		// Scopes of tokens and users are kept apart, but the same name for both would be
+		// ambiguous in the configuration and in the logs.
+		if _, found := config.UserScopes[t.Name]; found {
+			return nil, fmt.Errorf("bearer token name %q is also used in user_scopes", t.Name)
+		}
+		token := t.Token
+		if t.TokenFile != "" {
+			if token != "" {
+				return nil, fmt.Errorf("token and token_file cannot be used at the same time in token %q", t.Name)
+			}
+			d, err := os.ReadFile(t.TokenFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read token file of %q: %w", t.Name, err)
+			}
+			token = strings.TrimSpace(string(d))
+		}
+		if token == "" {
+			return nil, fmt.Errorf("empty bearer token for %q", t.Name)
+		}
+		if _, found := a.tokens[token]; found {
+			return nil, fmt.Errorf("bearer token for %q already used by another name", t.Name)
+		}
+		a.tokens[token] = t.Name
+		if len(t.Scopes) > 0 {
+			a.tokenScopes[t.Name] = t.Scopes
+		}
+	}
+
+	if config.HtpasswdFile != "" {
+		users, err := loadHtpasswd(config.HtpasswdFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load htpasswd file: %w", err)
+		}
+		a.users = users
+	}
+
+	if len(config.ClientCertificateAuthorities) > 0 {
+		a.clientCAs = x509.NewCertPool()
+		for _, path := range config.ClientCertificateAuthorities {
+			d, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read client certificate authority: %w", err)
+			}
+			if !a.clientCAs.AppendCertsFromPEM(d) {
+				return nil, fmt.Errorf("no certificates found in %s", path)
+			}
+		}
+	}
+
+	return &a, nil
+}
+
+// ConfigureTLS requests client certificates in the TLS configuration of the server when
+// they are used for authentication. Connections without certificates are still accepted,
+// so other authentication methods can be used.
+func (a *Authenticator) ConfigureTLS(config *tls.Config) {
+	if a.clientCAs == nil {
+		return
+	}
+	config.ClientCAs = a.clientCAs
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+}
+
+// Middleware returns a middleware that rejects requests to non-exempt paths without
+// valid credentials. The authenticated user is included in the request logs, and its
+// scopes are available in the context of the request.
+func (a *Authenticator) Middleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(a.exemptPaths, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, method, err := a.authenticate(r)
+			if err != nil {
+				a.logger.Debug("request not authenticated", zap.String("url.path", r.URL.Path), zap.Error(err))
+				a.unauthorized(w)
+				return
+			}
+			util.SetRequestUser(r, user)
+			ctx := context.WithValue(r.Context(), scopesKey{}, a.scopes(user, method))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+type scopesKey struct{}
+
+// authMethod is the method used to authenticate a request.
+type authMethod int
+
+const (
+	authMethodToken authMethod = iota
+	authMethodBasic
+	authMethodClientCertificate
+)
+
+// scopes returns the scopes of the user authenticated with the given method. Bearer tokens
+// only get the scopes of their configuration, users authenticated with other methods the
+// ones in UserScopes.
+func (a *Authenticator) scopes(user string, method authMethod) []string {
+	if method == authMethodToken {
+		return a.tokenScopes[user]
+	}
+	return a.userScopes[user]
+}
+
+// Scopes returns the scopes of the user authenticated in the request with the given context.
+func Scopes(ctx context.Context) []string {
+	scopes, _ := ctx.Value(scopesKey{}).([]string)
+	return scopes
+}
+
+var errMissingCredentials = errors.New("missing credentials")
+
+// authenticate returns the name of the user authenticated by the credentials of the request,
+// and the method used to authenticate it.
+func (a *Authenticator) authenticate(r *http.Request) (string, authMethod, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, credentials, _ := strings.Cut(header, " ")
+		switch {
+		case strings.EqualFold(scheme, "Bearer") && len(a.tokens) > 0:
+			user, err := a.authenticateToken(credentials)
+			return user, authMethodToken, err
+		case strings.EqualFold(scheme, "Basic") && a.users != nil:
+			user, password, ok := r.BasicAuth()
+			if !ok {
+				return "", authMethodBasic, errors.New("invalid basic authentication header")
+			}
+			if err := a.users.verify(user, password); err != nil {
+				return "", authMethodBasic, err
+			}
+			return user, authMethodBasic, nil
+		default:
+			return "", 0, fmt.Errorf("unsupported authorization scheme %q", scheme)
+		}
+	}
+
+	if a.clientCAs != nil && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return r.TLS.VerifiedChains[0][0].Subject.CommonName, authMethodClientCertificate, nil
+	}
+
+	return "", 0, errMissingCredentials
+}
+
+func (a *Authenticator) authenticateToken(token string) (string, error) {
+	token = strings.TrimSpace(token)
+	// Compare with all tokens in constant time, so timing doesn't reveal valid prefixes.
+	var user string
+	for t, name := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			user = name
+		}
+	}
+	if user == "" {
+		return "", errors.New("invalid bearer token")
+	}
+	return user, nil
+}
+
+func (a *Authenticator) unauthorized(w http.ResponseWriter) {
+	if len(a.tokens) > 0 {
+		w.Header().Add("WWW-Authenticate", `Bearer realm="`+realm+`"`)
+	}
+	if a.users != nil {
+		w.Header().Add("WWW-Authenticate", `Basic realm="`+realm+`", charset="UTF-8"`)
+	}
+	w.Header().Add("Cache-Control", "max-age=0")
+	w.Header().Add("Cache-Control", "private, no-store")
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
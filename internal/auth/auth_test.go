@@ -0,0 +1,268 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/elastic/package-registry/internal/util"
+)
+
+func TestMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	htpasswdFile := filepath.Join(dir, "htpasswd")
+	require.NoError(t, os.WriteFile(htpasswdFile, []byte("# users\nalice:"+string(hash)+"\n"), 0o600))
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("file-token\n"), 0o600))
+
+	authenticator, err := NewAuthenticator(util.NewTestLogger(), Config{
+		Tokens: []TokenConfig{
+			{Name: "ci", Token: "ci-token"},
+			{Name: "deploy", TokenFile: tokenFile},
+		},
+		HtpasswdFile: htpasswdFile,
+		ExemptPaths:  []string{"/health"},
+	})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.Use(authenticator.Middleware())
+
+	cases := []struct {
+		title         string
+		path          string
+		authorization func(r *http.Request)
+		status        int
+		user          string
+	}{
+		{title: "no credentials", path: "/search", status: http.StatusUnauthorized},
+		{title: "exempt path", path: "/health", status: http.StatusOK},
+		{
+			title:         "valid token",
+			path:          "/search",
+			authorization: func(r *http.Request) { r.Header.Set("Authorization", "Bearer ci-token") },
+			status:        http.StatusOK,
+			user:          "ci",
+		},
+		{
+			title:         "valid token from file",
+			path:          "/search",
+			authorization: func(r *http.Request) { r.Header.Set("Authorization", "bearer file-token") },
+			status:        http.StatusOK,
+			user:          "deploy",
+		},
+		{
+			title:         "invalid token",
+			path:          "/search",
+			authorization: func(r *http.Request) { r.Header.Set("Authorization", "Bearer ci-token2") },
+			status:        http.StatusUnauthorized,
+		},
+		{
+			title:         "valid password",
+			path:          "/search",
+			authorization: func(r *http.Request) { r.SetBasicAuth("alice", "secret") },
+			status:        http.StatusOK,
+			user:          "alice",
+		},
+		{
+			title:         "invalid password",
+			path:          "/search",
+			authorization: func(r *http.Request) { r.SetBasicAuth("alice", "wrong") },
+			status:        http.StatusUnauthorized,
+		},
+		{
+			title:         "unknown user",
+			path:          "/search",
+			authorization: func(r *http.Request) { r.SetBasicAuth("bob", "secret") },
+			status:        http.StatusUnauthorized,
+		},
+		{
+			title:         "unsupported scheme",
+			path:          "/search",
+			authorization: func(r *http.Request) { r.Header.Set("Authorization", "Digest foo") },
+			status:        http.StatusUnauthorized,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, c.path, nil)
+			if c.authorization != nil {
+				c.authorization(req)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, c.status, recorder.Code)
+			if c.user != "" {
+				user, _, err := authenticator.authenticate(req)
+				require.NoError(t, err)
+				assert.Equal(t, c.user, user)
+			}
+			if c.status == http.StatusUnauthorized {
+				assert.Equal(t, []string{
+					`Bearer realm="package-registry"`,
+					`Basic realm="package-registry", charset="UTF-8"`,
+				}, recorder.Header().Values("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestNewAuthenticatorErrors(t *testing.T) {
+	dir := t.TempDir()
+	md5File := filepath.Join(dir, "htpasswd")
+	require.NoError(t, os.WriteFile(md5File, []byte("alice:$apr1$5P4L5cKz$yEgG5T1JC0Cg8Ml1/GXpv0\n"), 0o600))
+
+	cases := []struct {
+		title  string
+		config Config
+	}{
+		{title: "token without name", config: Config{Tokens: []TokenConfig{{Token: "foo"}}}},
+		{title: "empty token", config: Config{Tokens: []TokenConfig{{Name: "foo"}}}},
+		{title: "duplicated token", config: Config{Tokens: []TokenConfig{{Name: "foo", Token: "a"}, {Name: "bar", Token: "a"}}}},
+		{title: "missing token file", config: Config{Tokens: []TokenConfig{{Name: "foo", TokenFile: filepath.Join(dir, "missing")}}}},
+		{title: "token name in user scopes", config: Config{
+			Tokens:     []TokenConfig{{Name: "ci", Token: "a", Scopes: []string{"upload"}}},
+			UserScopes: map[string][]string{"ci": {"internal"}},
+		}},
+		{title: "unsupported hash", config: Config{HtpasswdFile: md5File}},
+		{title: "invalid certificate authority", config: Config{ClientCertificateAuthorities: []string{md5File}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			_, err := NewAuthenticator(util.NewTestLogger(), c.config)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey := newTestCertificate(t, "Test CA", nil, nil)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw}), 0o600))
+
+	authenticator, err := NewAuthenticator(util.NewTestLogger(), Config{
+		Tokens:                       []TokenConfig{{Name: "ci", Token: "ci-token", Scopes: []string{"upload"}}},
+		ClientCertificateAuthorities: []string{caFile},
+		UserScopes:                   map[string][]string{"deploy-bot": {"internal"}},
+	})
+	require.NoError(t, err)
+
+	var user string
+	var scopes []string
+	router := mux.NewRouter()
+	router.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scopes = Scopes(r.Context())
+	})
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, _, _ = authenticator.authenticate(r)
+			next.ServeHTTP(w, r)
+		})
+	})
+	router.Use(authenticator.Middleware())
+
+	server := httptest.NewUnstartedServer(router)
+	server.TLS = &tls.Config{}
+	authenticator.ConfigureTLS(server.TLS)
+	server.StartTLS()
+	defer server.Close()
+
+	newClient := func(cert *tls.Certificate) *http.Client {
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		if cert != nil {
+			transport.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+		}
+		return &http.Client{Transport: transport}
+	}
+
+	t.Run("valid certificate", func(t *testing.T) {
+		clientCert, clientKey := newTestCertificate(t, "deploy-bot", caCert, caKey)
+		resp, err := newClient(&tls.Certificate{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey}).Get(server.URL + "/search")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "deploy-bot", user)
+		assert.Equal(t, []string{"internal"}, scopes)
+	})
+
+	t.Run("certificate with the name of a token", func(t *testing.T) {
+		clientCert, clientKey := newTestCertificate(t, "ci", caCert, caKey)
+		resp, err := newClient(&tls.Certificate{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey}).Get(server.URL + "/search")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "ci", user)
+		assert.Empty(t, scopes, "scopes of the token are not granted to the certificate")
+	})
+
+	t.Run("no certificate", func(t *testing.T) {
+		resp, err := newClient(nil).Get(server.URL + "/search")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("certificate from other authority", func(t *testing.T) {
+		otherCA, otherKey := newTestCertificate(t, "Other CA", nil, nil)
+		clientCert, clientKey := newTestCertificate(t, "intruder", otherCA, otherKey)
+		// The client doesn't send certificates not accepted by the server.
+		resp, err := newClient(&tls.Certificate{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey}).Get(server.URL + "/search")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+// newTestCertificate creates a certificate signed by the given parent, or a self-signed
+// certificate authority if no parent is given.
+func newTestCertificate(t *testing.T, commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
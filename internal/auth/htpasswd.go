@@ -0,0 +1,77 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// htpasswd maps users to their bcrypt password hashes.
+type htpasswd map[string][]byte
+
+// dummyHash is used to spend the same time verifying unknown users than known ones.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	return hash
+})
+
+func loadHtpasswd(path string) (htpasswd, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseHtpasswd(f)
+}
+
+// parseHtpasswd parses htpasswd content with one `user:hash` entry per line. Only
+// bcrypt hashes are supported, other formats used by htpasswd are considered insecure.
+func parseHtpasswd(r io.Reader) (htpasswd, error) {
+	users := make(htpasswd)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, found := strings.Cut(line, ":")
+		if !found || user == "" {
+			return nil, fmt.Errorf("invalid entry in line %d", lineNumber)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("unsupported password hash for user %q in line %d, only bcrypt is supported", user, lineNumber)
+		}
+		if _, found := users[user]; found {
+			return nil, fmt.Errorf("duplicated user %q in line %d", user, lineNumber)
+		}
+		users[user] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (h htpasswd) verify(user, password string) error {
+	hash, found := h[user]
+	if !found {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return errors.New("unknown user")
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		return fmt.Errorf("invalid password for user %q", user)
+	}
+	return nil
+}
@@ -0,0 +1,487 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package contentcache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/elastic/package-registry/metrics"
+)
+
+const (
+	// tmpDir is the directory, relative to the path of the cache, where content is
+	// downloaded before being added to the cache.
+	tmpDir = ".tmp"
+
+	// metaDir is the directory, relative to the path of the cache, where the metadata of
+	// the cached content is stored, with the same relative paths as the content. The
+	// modification time of the metadata files is the last time the content was used.
+	metaDir = ".meta"
+
+	// fillTimeout is the maximum time to download content that is not cached yet.
+	fillTimeout = 5 * time.Minute
+)
+
+var errTooLarge = errors.New("content larger than the maximum size of the cache")
+
+// Options are the options of a content cache.
+type Options struct {
+	// Path is the directory where the cached content is stored.
+	Path string
+
+	// MaxSize is the maximum size in bytes of the cached content. The least recently
+	// used content is evicted when it is exceeded.
+	MaxSize int64
+
+	// HTTPClient is used to download the content when the cached handlers respond
+	// with a redirection.
+	HTTPClient *http.Client
+}
+
+// Cache is a size-bounded on-disk cache of the content served by HTTP handlers.
+type Cache struct {
+	logger  *zap.Logger
+	path    string
+	maxSize int64
+	client  *http.Client
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	lru     *list.List
+
+	group singleflight.Group
+}
+
+type entry struct {
+	key         string
+	size        int64
+	contentType string
+}
+
+// metadata is the information about the cached content persisted in its metadata file.
+type metadata struct {
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// New creates a content cache in the given path. Content cached by previous instances
+// in the same path is reused.
+func New(logger *zap.Logger, options Options) (*Cache, error) {
+	if options.Path == "" {
+		return nil, errors.New("missing path")
+	}
+	if options.MaxSize <= 0 {
+		return nil, errors.New("maximum size must be greater than 0")
+	}
+	client := options.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: fillTimeout}
+	}
+
+	c := Cache{
+		logger:  logger,
+		path:    options.Path,
+		maxSize: options.MaxSize,
+		client:  client,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+
+	// Remove content of previous instances that was not completely downloaded.
+	err := os.RemoveAll(filepath.Join(c.path, tmpDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to clean temporary directory: %w", err)
+	}
+	err = os.MkdirAll(filepath.Join(c.path, tmpDir), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	err = c.load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cached content: %w", err)
+	}
+	return &c, nil
+}
+
+// load adds to the cache the content found in its path, with the metadata persisted for
+// it. Content is considered to be used for last time when its metadata file was modified.
+func (c *Cache) load() error {
+	type cachedFile struct {
+		entry    *entry
+		lastUsed time.Time
+	}
+	var files []cachedFile
+	err := filepath.WalkDir(c.path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p == filepath.Join(c.path, tmpDir) || p == filepath.Join(c.path, metaDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(c.path, p)
+		if err != nil {
+			return err
+		}
+		e := entry{key: filepath.ToSlash(rel), size: info.Size()}
+		lastUsed := info.ModTime()
+		meta, metaInfo, err := c.readMetadata(e.key)
+		if err != nil {
+			// Content is still served, with the content type obtained from its contents.
+			c.logger.Debug("metadata of cached content not available", zap.String("cache.key", e.key), zap.Error(err))
+		} else {
+			e.contentType = meta.ContentType
+			lastUsed = metaInfo.ModTime()
+		}
+		files = append(files, cachedFile{entry: &e, lastUsed: lastUsed})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slices.SortFunc(files, func(a, b cachedFile) int {
+		return a.lastUsed.Compare(b.lastUsed)
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range files {
+		c.entries[f.entry.key] = c.lru.PushFront(f.entry)
+		c.size += f.entry.size
+	}
+	c.evict()
+	metrics.ContentCacheSizeBytes.Set(float64(c.size))
+	return nil
+}
+
+// Serve responds to the request with the content cached with the given key. If it is
+// not cached, it is obtained from the fetch handler and added to the cache. Only GET and
+// HEAD requests are cached, and range requests are supported for cached content. Requests
+// for content that cannot be cached are served by the fetch handler.
+func (c *Cache) Serve(w http.ResponseWriter, r *http.Request, key string, fetch http.HandlerFunc) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead || !fs.ValidPath(key) || isReservedKey(key) {
+		fetch(w, r)
+		return
+	}
+
+	if c.serveCached(w, r, key) {
+		metrics.ContentCacheHitsTotal.Inc()
+		return
+	}
+	metrics.ContentCacheMissesTotal.Inc()
+
+	// Concurrent requests for the same content wait for a single download.
+	_, err, _ := c.group.Do(key, func() (any, error) {
+		return nil, c.fill(r, key, fetch)
+	})
+	if err != nil {
+		c.logger.Debug("content not added to cache", zap.String("cache.key", key), zap.Error(err))
+	}
+	if err == nil && c.serveCached(w, r, key) {
+		return
+	}
+	fetch(w, r)
+}
+
+func (c *Cache) serveCached(w http.ResponseWriter, r *http.Request, key string) bool {
+	c.mu.Lock()
+	element, found := c.entries[key]
+	if found {
+		c.lru.MoveToFront(element)
+	}
+	c.mu.Unlock()
+	if !found {
+		return false
+	}
+	e := element.Value.(*entry)
+
+	// Once open, the file can be served even if it is evicted meanwhile.
+	f, err := os.Open(c.filePath(key))
+	if err != nil {
+		c.logger.Warn("failed to open cached content", zap.String("cache.key", key), zap.Error(err))
+		c.remove(element)
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	// Touch the metadata file so the order of use is kept after restarts.
+	now := time.Now()
+	if err := os.Chtimes(c.metaPath(key), now, now); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		c.logger.Debug("failed to update last use of cached content", zap.String("cache.key", key), zap.Error(err))
+	}
+
+	if e.contentType != "" {
+		w.Header().Set("Content-Type", e.contentType)
+	}
+	http.ServeContent(w, r, path.Base(key), info.ModTime(), f)
+	return true
+}
+
+// fill obtains the content from the fetch handler and adds it to the cache. When the
+// handler responds with a redirection, the content is downloaded from its location.
+func (c *Cache) fill(r *http.Request, key string, fetch http.HandlerFunc) error {
+	// The download is shared by concurrent requests, so it is not cancelled with the
+	// request that started it.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), fillTimeout)
+	defer cancel()
+
+	req := r.Clone(ctx)
+	req.Method = http.MethodGet
+	for _, header := range []string{"Range", "If-Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"} {
+		req.Header.Del(header)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(c.path, tmpDir), "content-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	capture := captureWriter{header: make(http.Header), w: tmp, limit: c.maxSize}
+	fetch(&capture, req)
+	if capture.err != nil {
+		return capture.err
+	}
+
+	header := capture.header
+	switch status := capture.statusCode(); status {
+	case http.StatusOK:
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		location, err := req.URL.Parse(header.Get("Location"))
+		if err != nil || location.Host == "" {
+			return fmt.Errorf("unexpected redirection to %q", header.Get("Location"))
+		}
+		header, capture.written, err = c.download(ctx, location.String(), tmp)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unexpected status code %d", status)
+	}
+
+	if v := header.Get("Content-Length"); v != "" && v != strconv.FormatInt(capture.written, 10) {
+		return fmt.Errorf("incomplete content, %d bytes received, %s expected", capture.written, v)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return c.add(&entry{key: key, size: capture.written, contentType: header.Get("Content-Type")}, tmp.Name())
+}
+
+// download writes the content in the given URL to the file.
+func (c *Cache) download(ctx context.Context, url string, f *os.File) (http.Header, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("unexpected status code %d downloading %s", resp.StatusCode, url)
+	}
+
+	if err := f.Truncate(0); err != nil {
+		return nil, 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	written, err := io.Copy(f, io.LimitReader(resp.Body, c.maxSize+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if written > c.maxSize {
+		return nil, 0, errTooLarge
+	}
+	return resp.Header, written, nil
+}
+
+func (c *Cache) add(e *entry, tmpPath string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, found := c.entries[e.key]; found {
+		c.lru.MoveToFront(element)
+		return nil
+	}
+
+	if err := c.writeMetadata(e); err != nil {
+		return err
+	}
+	dest := c.filePath(e.key)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, dest); err != nil {
+		return err
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.size += e.size
+	c.evict()
+	metrics.ContentCacheSizeBytes.Set(float64(c.size))
+	return nil
+}
+
+// evict removes the least recently used content until the size of the cache is below
+// its maximum. It must be called with the lock held.
+func (c *Cache) evict() {
+	for c.size > c.maxSize {
+		element := c.lru.Back()
+		if element == nil {
+			return
+		}
+		e := c.removeLocked(element)
+		if err := os.Remove(c.filePath(e.key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			c.logger.Warn("failed to remove evicted content", zap.String("cache.key", e.key), zap.Error(err))
+		}
+		if err := os.Remove(c.metaPath(e.key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			c.logger.Warn("failed to remove metadata of evicted content", zap.String("cache.key", e.key), zap.Error(err))
+		}
+		metrics.ContentCacheEvictionsTotal.Inc()
+	}
+}
+
+func (c *Cache) remove(element *list.Element) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries[element.Value.(*entry).key] != element {
+		return
+	}
+	c.removeLocked(element)
+	metrics.ContentCacheSizeBytes.Set(float64(c.size))
+}
+
+func (c *Cache) removeLocked(element *list.Element) *entry {
+	e := c.lru.Remove(element).(*entry)
+	delete(c.entries, e.key)
+	c.size -= e.size
+	return e
+}
+
+func (c *Cache) filePath(key string) string {
+	return filepath.Join(c.path, filepath.FromSlash(key))
+}
+
+func (c *Cache) metaPath(key string) string {
+	return filepath.Join(c.path, metaDir, filepath.FromSlash(key))
+}
+
+// readMetadata reads the metadata of the cached content, and the information of its file.
+func (c *Cache) readMetadata(key string) (metadata, fs.FileInfo, error) {
+	var meta metadata
+	f, err := os.Open(c.metaPath(key))
+	if err != nil {
+		return meta, nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return meta, nil, err
+	}
+	err = json.NewDecoder(f).Decode(&meta)
+	if err != nil {
+		return meta, nil, err
+	}
+	return meta, info, nil
+}
+
+// writeMetadata persists the metadata of the content, it must be written before the content
+// is added to the cache.
+func (c *Cache) writeMetadata(e *entry) error {
+	d, err := json.Marshal(metadata{ContentType: e.contentType})
+	if err != nil {
+		return err
+	}
+	dest := c.metaPath(e.key)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dest, d, 0644)
+}
+
+// isReservedKey returns true for the keys in the directories used internally by the cache.
+func isReservedKey(key string) bool {
+	first := strings.Split(key, "/")[0]
+	return first == tmpDir || first == metaDir
+}
+
+// captureWriter is a response writer that writes the body of the response to a file.
+type captureWriter struct {
+	header http.Header
+	status int
+
+	w       io.Writer
+	written int64
+	limit   int64
+	err     error
+}
+
+func (cw *captureWriter) Header() http.Header {
+	return cw.header
+}
+
+func (cw *captureWriter) WriteHeader(statusCode int) {
+	if cw.status == 0 {
+		cw.status = statusCode
+	}
+}
+
+func (cw *captureWriter) Write(d []byte) (int, error) {
+	cw.WriteHeader(http.StatusOK)
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	if cw.written+int64(len(d)) > cw.limit {
+		cw.err = errTooLarge
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(d)
+	cw.written += int64(n)
+	if err != nil {
+		cw.err = err
+	}
+	return n, err
+}
+
+func (cw *captureWriter) statusCode() int {
+	if cw.status == 0 {
+		return http.StatusOK
+	}
+	return cw.status
+}
@@ -0,0 +1,212 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package contentcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/package-registry/internal/util"
+	"github.com/elastic/package-registry/metrics"
+	"github.com/elastic/package-registry/packages"
+)
+
+func TestCachedResolver(t *testing.T) {
+	cache, err := New(util.NewTestLogger(), Options{Path: t.TempDir(), MaxSize: 1024})
+	require.NoError(t, err)
+
+	remote := &testResolver{}
+	resolver := cache.Resolver(remote)
+	pkg := &packages.Package{BasePackage: packages.BasePackage{Name: "system", Version: "1.0.0"}}
+
+	hits := testutil.ToFloat64(metrics.ContentCacheHitsTotal)
+	misses := testutil.ToFloat64(metrics.ContentCacheMissesTotal)
+
+	for range 3 {
+		recorder := httptest.NewRecorder()
+		resolver.ArtifactsHandler(recorder, httptest.NewRequest(http.MethodGet, "/epr/system/system-1.0.0.zip", nil), pkg)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "content of system-1.0.0.zip", recorder.Body.String())
+		assert.Equal(t, "application/zip", recorder.Header().Get("Content-Type"))
+	}
+	assert.EqualValues(t, 1, remote.requests.Load())
+	assert.Equal(t, hits+2, testutil.ToFloat64(metrics.ContentCacheHitsTotal))
+	assert.Equal(t, misses+1, testutil.ToFloat64(metrics.ContentCacheMissesTotal))
+	assert.FileExists(t, filepath.Join(cache.path, "system", "1.0.0", "system-1.0.0.zip"))
+
+	t.Run("range request", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/epr/system/system-1.0.0.zip", nil)
+		request.Header.Set("Range", "bytes=0-6")
+		recorder := httptest.NewRecorder()
+		resolver.ArtifactsHandler(recorder, request, pkg)
+		assert.Equal(t, http.StatusPartialContent, recorder.Code)
+		assert.Equal(t, "content", recorder.Body.String())
+	})
+
+	t.Run("range request of content not cached yet", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/package/system/1.0.0/img/icon.svg", nil)
+		request.Header.Set("Range", "bytes=11-")
+		recorder := httptest.NewRecorder()
+		resolver.StaticHandler(recorder, request, pkg, "img/icon.svg")
+		assert.Equal(t, http.StatusPartialContent, recorder.Code)
+		assert.Equal(t, "img/icon.svg", recorder.Body.String())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		requests := remote.requests.Load()
+		for range 2 {
+			recorder := httptest.NewRecorder()
+			resolver.StaticHandler(recorder, httptest.NewRequest(http.MethodGet, "/package/system/1.0.0/missing", nil), pkg, "missing")
+			assert.Equal(t, http.StatusNotFound, recorder.Code)
+		}
+		assert.NoFileExists(t, filepath.Join(cache.path, "system", "1.0.0", "static", "missing"))
+		assert.Equal(t, requests+4, remote.requests.Load())
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		resolver.StaticHandler(recorder, httptest.NewRequest(http.MethodGet, "/package/system/1.0.0/", nil), pkg, "../../../other")
+		assert.NoFileExists(t, filepath.Join(cache.path, "other"))
+	})
+}
+
+func TestCachedResolverRedirect(t *testing.T) {
+	var downloads atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		w.Header().Set("Content-Type", "application/zip")
+		fmt.Fprint(w, "remote content")
+	}))
+	defer server.Close()
+
+	cache, err := New(util.NewTestLogger(), Options{Path: t.TempDir(), MaxSize: 1024})
+	require.NoError(t, err)
+
+	remote := &testResolver{}
+	remote.redirectTo = server.URL
+	resolver := cache.Resolver(remote)
+	pkg := &packages.Package{BasePackage: packages.BasePackage{Name: "elastic_agent", Version: "2.0.0"}}
+
+	for range 2 {
+		recorder := httptest.NewRecorder()
+		resolver.ArtifactsHandler(recorder, httptest.NewRequest(http.MethodGet, "/epr/elastic_agent/elastic_agent-2.0.0.zip", nil), pkg)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "remote content", recorder.Body.String())
+	}
+	assert.EqualValues(t, 1, downloads.Load())
+}
+
+func TestCacheEviction(t *testing.T) {
+	path := t.TempDir()
+	cache, err := New(util.NewTestLogger(), Options{Path: path, MaxSize: 60})
+	require.NoError(t, err)
+
+	remote := &testResolver{}
+	resolver := cache.Resolver(remote)
+	get := func(name string) {
+		pkg := &packages.Package{BasePackage: packages.BasePackage{Name: name, Version: "1.0.0"}}
+		recorder := httptest.NewRecorder()
+		resolver.ArtifactsHandler(recorder, httptest.NewRequest(http.MethodGet, "/", nil), pkg)
+		require.Equal(t, http.StatusOK, recorder.Code)
+	}
+
+	evictions := testutil.ToFloat64(metrics.ContentCacheEvictionsTotal)
+	get("first")
+	get("second")
+	get("first")
+	get("third")
+	assert.Equal(t, evictions+1, testutil.ToFloat64(metrics.ContentCacheEvictionsTotal))
+	assert.FileExists(t, filepath.Join(path, "first", "1.0.0", "first-1.0.0.zip"))
+	assert.NoFileExists(t, filepath.Join(path, "second", "1.0.0", "second-1.0.0.zip"))
+	assert.FileExists(t, filepath.Join(path, "third", "1.0.0", "third-1.0.0.zip"))
+
+	// Content larger than the cache is served, but not cached.
+	get(strings.Repeat("large", 10))
+	assert.NoDirExists(t, filepath.Join(path, strings.Repeat("large", 10)))
+
+	// Content is reused after restarts.
+	cache, err = New(util.NewTestLogger(), Options{Path: path, MaxSize: 60})
+	require.NoError(t, err)
+	assert.Len(t, cache.entries, 2)
+	assert.Contains(t, cache.entries, "third/1.0.0/third-1.0.0.zip")
+}
+
+func TestCacheRestart(t *testing.T) {
+	path := t.TempDir()
+	cache, err := New(util.NewTestLogger(), Options{Path: path, MaxSize: 60})
+	require.NoError(t, err)
+
+	remote := &testResolver{}
+	get := func(cache *Cache, name string) *httptest.ResponseRecorder {
+		pkg := &packages.Package{BasePackage: packages.BasePackage{Name: name, Version: "1.0.0"}}
+		recorder := httptest.NewRecorder()
+		cache.Resolver(remote).ArtifactsHandler(recorder, httptest.NewRequest(http.MethodGet, "/", nil), pkg)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		return recorder
+	}
+
+	get(cache, "first")
+	time.Sleep(10 * time.Millisecond)
+	get(cache, "second")
+	time.Sleep(10 * time.Millisecond)
+	get(cache, "first")
+	requests := remote.requests.Load()
+
+	cache, err = New(util.NewTestLogger(), Options{Path: path, MaxSize: 60})
+	require.NoError(t, err)
+
+	// The least recently used content is evicted, not the least recently downloaded.
+	get(cache, "third")
+	assert.FileExists(t, filepath.Join(path, "first", "1.0.0", "first-1.0.0.zip"))
+	assert.NoFileExists(t, filepath.Join(path, "second", "1.0.0", "second-1.0.0.zip"))
+	assert.NoFileExists(t, filepath.Join(path, metaDir, "second", "1.0.0", "second-1.0.0.zip"))
+	requests++
+
+	// The content type of the upstream is kept.
+	recorder := get(cache, "first")
+	assert.Equal(t, "application/zip", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, requests, remote.requests.Load(), "content should be served from the cache")
+}
+
+type testResolver struct {
+	requests   atomic.Int64
+	redirectTo string
+}
+
+func (tr *testResolver) serve(w http.ResponseWriter, r *http.Request, name, contentType string) {
+	tr.requests.Add(1)
+	if tr.redirectTo != "" {
+		http.Redirect(w, r, tr.redirectTo+"/"+name, http.StatusMovedPermanently)
+		return
+	}
+	if strings.HasSuffix(name, "missing") {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, "", time.Time{}, strings.NewReader("content of "+name))
+}
+
+func (tr *testResolver) ArtifactsHandler(w http.ResponseWriter, r *http.Request, p *packages.Package) {
+	tr.serve(w, r, fmt.Sprintf("%s-%s.zip", p.Name, p.Version), "application/zip")
+}
+
+func (tr *testResolver) StaticHandler(w http.ResponseWriter, r *http.Request, p *packages.Package, resourcePath string) {
+	tr.serve(w, r, resourcePath, "image/svg+xml")
+}
+
+func (tr *testResolver) SignaturesHandler(w http.ResponseWriter, r *http.Request, p *packages.Package) {
+	tr.serve(w, r, fmt.Sprintf("%s-%s.zip.sig", p.Name, p.Version), "text/plain")
+}
@@ -0,0 +1,66 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package contentcache
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/elastic/package-registry/packages"
+)
+
+type cachedResolver struct {
+	cache    *Cache
+	resolver packages.RemoteResolver
+}
+
+// Resolver returns a resolver that serves the content of the given resolver through the
+// cache. If the cache is nil, the resolver is returned as is.
+func (c *Cache) Resolver(resolver packages.RemoteResolver) packages.RemoteResolver {
+	if c == nil {
+		return resolver
+	}
+	return &cachedResolver{cache: c, resolver: resolver}
+}
+
+// packageKey returns the key of a file of a package, identified by its name and version.
+// It returns an empty key, that is not cached, for invalid paths.
+func packageKey(p *packages.Package, name string) string {
+	for _, elem := range []string{p.Name, p.Version} {
+		if strings.Contains(elem, "/") {
+			return ""
+		}
+	}
+	if !fs.ValidPath(name) {
+		return ""
+	}
+	return path.Join(p.Name, p.Version, name)
+}
+
+func (cr *cachedResolver) ArtifactsHandler(w http.ResponseWriter, r *http.Request, p *packages.Package) {
+	key := packageKey(p, fmt.Sprintf("%s-%s.zip", p.Name, p.Version))
+	cr.cache.Serve(w, r, key, func(w http.ResponseWriter, r *http.Request) {
+		cr.resolver.ArtifactsHandler(w, r, p)
+	})
+}
+
+func (cr *cachedResolver) StaticHandler(w http.ResponseWriter, r *http.Request, p *packages.Package, resourcePath string) {
+	key := packageKey(p, "static/"+resourcePath)
+	cr.cache.Serve(w, r, key, func(w http.ResponseWriter, r *http.Request) {
+		cr.resolver.StaticHandler(w, r, p, resourcePath)
+	})
+}
+
+func (cr *cachedResolver) SignaturesHandler(w http.ResponseWriter, r *http.Request, p *packages.Package) {
+	key := packageKey(p, fmt.Sprintf("%s-%s.zip.sig", p.Name, p.Version))
+	cr.cache.Serve(w, r, key, func(w http.ResponseWriter, r *http.Request) {
+		cr.resolver.SignaturesHandler(w, r, p)
+	})
+}
+
+var _ packages.RemoteResolver = new(cachedResolver)
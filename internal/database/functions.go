@@ -13,6 +13,8 @@ import (
 
 	"github.com/Masterminds/semver/v3"
 	"modernc.org/sqlite"
+
+	"github.com/elastic/package-registry/packages"
 )
 
 func init() {
@@ -22,6 +24,7 @@ func init() {
 	sqlite.MustRegisterScalarFunction("all_capabilities_are_supported", 2, allCapabilitiesAreSupported)
 	sqlite.MustRegisterScalarFunction("all_discovery_filters_are_supported", 2, allDiscoveryFiltersAreSupported)
 	sqlite.MustRegisterScalarFunction("any_discovery_filter_is_supported", 2, anyDiscoveryFilterIsSupported)
+	sqlite.MustRegisterCollationUtf8("semver", packages.CompareVersions)
 }
 
 // semverCompare checks if a version satisfies a given semver constraint.
@@ -21,7 +21,17 @@ type Package struct {
 	DiscoveryFilterFields   string
 	DiscoveryFilterDatasets string
 	Type                    string
+	OwnerType               string
+	Visibility              string
 	Path                    string
+	Title                   string
+	Description             string
+	PolicyTemplatesTitles   string
+	DataStreamsTitles       string
 	Data                    []byte
 	BaseData                []byte
+
+	// Datasets and Fields are stored in lookup tables, they are not retrieved in queries.
+	Datasets []string
+	Fields   []string
 }
@@ -12,11 +12,15 @@ import (
 type Repository interface {
 	Initialize(ctx context.Context) error
 	BulkAdd(ctx context.Context, tx *sql.Tx, database string, pkgs []*Package) error
+	Delete(ctx context.Context, tx *sql.Tx, database, name, version string) (int64, error)
 	All(ctx context.Context, database string, whereOptions WhereOptions) ([]*Package, error)
 	FilterFunc(ctx context.Context, database string, whereOptions WhereOptions, process func(ctx context.Context, pkg *Package) error) error
 	AllFunc(ctx context.Context, database string, whereOptions WhereOptions, process func(ctx context.Context, pkg *Package) error) error
 	LatestFunc(ctx context.Context, database string, whereOptions WhereOptions, process func(ctx context.Context, pkg *Package) error) error
 	Drop(ctx context.Context, table string) error
+
+	SetMetadata(ctx context.Context, tx *sql.Tx, key, value string) error
+	Metadata(ctx context.Context, key string) (string, error)
 	Close(ctx context.Context) error
 
 	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
@@ -35,4 +39,5 @@ type WhereOptions interface {
 	UseFullData() bool
 	SkipJSONFields() bool
 	GetLatestPackages() bool
+	GetLimit() int
 }
@@ -19,10 +19,19 @@ import (
 )
 
 const (
+	// SchemaVersion is the version of the schema of the database. It must be increased
+	// when the schema changes, so existing databases are not reused.
+	SchemaVersion = "3"
+
 	defaultMaxBulkAddBatch = 500
 
 	dataColumnName     = "data"
 	baseDataColumnName = "baseData"
+
+	// datasetsTableSuffix and fieldsTableSuffix are the suffixes of the tables with the
+	// datasets and the fields of the packages of a table, used to look up packages by them.
+	datasetsTableSuffix = "_datasets"
+	fieldsTableSuffix   = "_fields"
 )
 
 var (
@@ -54,7 +63,13 @@ var keys = []keyDefinition{
 	{"kibanaVersion", "TEXT NOT NULL"},
 	{"capabilities", "TEXT NOT NULL"},
 	{"type", "TEXT NOT NULL"},
+	{"ownerType", "TEXT NOT NULL"},
+	{"visibility", "TEXT NOT NULL"},
 	{"path", "TEXT NOT NULL"},
+	{"title", "TEXT NOT NULL"},
+	{"description", "TEXT NOT NULL"},
+	{"policyTemplatesTitles", "TEXT NOT NULL"},
+	{"dataStreamsTitles", "TEXT NOT NULL"},
 	{dataColumnName, "BLOB NOT NULL"},
 	{baseDataColumnName, "BLOB NOT NULL"},
 }
@@ -182,6 +197,44 @@ func (r *SQLiteRepository) Initialize(ctx context.Context) error {
 	if _, err := r.db.ExecContext(ctx, query); err != nil {
 		return fmt.Errorf("failed to create indices: %w", err)
 	}
+
+	// Full-text search index over the searchable text of the packages. It doesn't
+	// store the contents, and it is kept in sync with the packages table using triggers.
+	// https://www.sqlite.org/fts5.html#external_content_tables
+	query = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS packages_fts USING fts5(
+		name, title, description, policyTemplatesTitles, dataStreamsTitles,
+		content='packages', tokenize='unicode61 remove_diacritics 0'
+	);
+	CREATE TRIGGER IF NOT EXISTS packages_fts_insert AFTER INSERT ON packages BEGIN
+		INSERT INTO packages_fts (rowid, name, title, description, policyTemplatesTitles, dataStreamsTitles)
+		VALUES (new.rowid, new.name, new.title, new.description, new.policyTemplatesTitles, new.dataStreamsTitles);
+	END;
+	CREATE TRIGGER IF NOT EXISTS packages_fts_delete AFTER DELETE ON packages BEGIN
+		INSERT INTO packages_fts (packages_fts, rowid, name, title, description, policyTemplatesTitles, dataStreamsTitles)
+		VALUES ('delete', old.rowid, old.name, old.title, old.description, old.policyTemplatesTitles, old.dataStreamsTitles);
+	END;
+	`
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create full-text search index: %w", err)
+	}
+
+	// Reverse indices of the datasets and the fields of the packages.
+	query = `
+	CREATE TABLE IF NOT EXISTS packages_datasets (name TEXT NOT NULL, version TEXT NOT NULL, dataset TEXT NOT NULL);
+	CREATE INDEX IF NOT EXISTS idx_datasets_dataset ON packages_datasets (dataset);
+	CREATE TABLE IF NOT EXISTS packages_fields (name TEXT NOT NULL, version TEXT NOT NULL, field TEXT NOT NULL);
+	CREATE INDEX IF NOT EXISTS idx_fields_field ON packages_fields (field);
+	`
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create lookup tables: %w", err)
+	}
+
+	// Key-value entries describing the contents of the database.
+	query = `CREATE TABLE IF NOT EXISTS metadata (key TEXT PRIMARY KEY, value TEXT NOT NULL);`
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create metadata table: %w", err)
+	}
 	return nil
 }
 
@@ -263,7 +316,13 @@ func (r *SQLiteRepository) BulkAdd(ctx context.Context, tx *sql.Tx, database str
 				pkgs[i].KibanaVersion,
 				pkgs[i].Capabilities,
 				pkgs[i].Type,
+				pkgs[i].OwnerType,
+				pkgs[i].Visibility,
 				pkgs[i].Path,
+				pkgs[i].Title,
+				pkgs[i].Description,
+				pkgs[i].PolicyTemplatesTitles,
+				pkgs[i].DataStreamsTitles,
 				pkgs[i].Data,
 				pkgs[i].BaseData,
 			)
@@ -292,9 +351,77 @@ func (r *SQLiteRepository) BulkAdd(ctx context.Context, tx *sql.Tx, database str
 		args = args[:0]
 	}
 
+	var datasets, fields []lookupRow
+	for _, pkg := range pkgs {
+		for _, dataset := range pkg.Datasets {
+			datasets = append(datasets, lookupRow{pkg.Name, pkg.Version, dataset})
+		}
+		for _, field := range pkg.Fields {
+			fields = append(fields, lookupRow{pkg.Name, pkg.Version, field})
+		}
+	}
+	err = r.bulkAddLookup(ctx, db, database+datasetsTableSuffix, "dataset", datasets)
+	if err != nil {
+		return fmt.Errorf("failed to insert datasets: %w", err)
+	}
+	err = r.bulkAddLookup(ctx, db, database+fieldsTableSuffix, "field", fields)
+	if err != nil {
+		return fmt.Errorf("failed to insert fields: %w", err)
+	}
+
 	return nil
 }
 
+// lookupRow is an entry of a lookup table, that relates a value with a package version.
+type lookupRow struct {
+	name    string
+	version string
+	value   string
+}
+
+// bulkAddLookup inserts rows in a lookup table, in batches with no more than maxTotalArgs arguments.
+func (r *SQLiteRepository) bulkAddLookup(ctx context.Context, db dbWriter, table, column string, rows []lookupRow) error {
+	const argsPerRow = 3
+	batchSize := r.maxTotalArgs / argsPerRow
+	for start := 0; start < len(rows); start += batchSize {
+		batch := rows[start:min(start+batchSize, len(rows))]
+		query := fmt.Sprintf("INSERT INTO %s (name, version, %s) values %s", table, column,
+			strings.TrimSuffix(strings.Repeat("(?, ?, ?),", len(batch)), ","))
+		args := make([]any, 0, len(batch)*argsPerRow)
+		for _, row := range batch {
+			args = append(args, row.name, row.version, row.value)
+		}
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes all the rows of the given package version, and returns the number of rows removed.
+func (r *SQLiteRepository) Delete(ctx context.Context, tx *sql.Tx, database, name, version string) (int64, error) {
+	span, ctx := apm.StartSpan(ctx, "SQL: Delete", "app")
+	span.Context.SetLabel("database.path", r.File(ctx))
+	defer span.End()
+
+	db, err := r.writer(tx)
+	if err != nil {
+		return 0, err
+	}
+	for _, table := range []string{database + datasetsTableSuffix, database + fieldsTableSuffix} {
+		query := fmt.Sprintf("DELETE FROM %s WHERE name = ? AND version = ?", table)
+		if _, err := db.ExecContext(ctx, query, name, version); err != nil {
+			return 0, fmt.Errorf("failed to delete lookup entries of package %s-%s: %w", name, version, err)
+		}
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE name = ? AND version = ?", database)
+	result, err := db.ExecContext(ctx, query, name, version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete package %s-%s: %w", name, version, err)
+	}
+	return result.RowsAffected()
+}
+
 func (r *SQLiteRepository) All(ctx context.Context, database string, whereOptions WhereOptions) ([]*Package, error) {
 	span, ctx := apm.StartSpan(ctx, "SQL: Get All", "app")
 	span.Context.SetLabel("database.path", r.File(ctx))
@@ -413,7 +540,8 @@ func sqlQueryWithWindowing(database string, whereOptions WhereOptions) (string,
                ORDER BY
                 versionMajor DESC,
                 versionMinor DESC,
-                versionPatch DESC
+                versionPatch DESC,
+                version COLLATE semver DESC
             ) AS rnk
     FROM (
         SELECT `)
@@ -430,6 +558,10 @@ func sqlQueryWithWindowing(database string, whereOptions WhereOptions) (string,
 	query.WriteString(`
     ) p
 ) WHERE rnk = 1`)
+	if limit := whereOptions.GetLimit(); limit > 0 {
+		query.WriteString(" ORDER BY name LIMIT ?")
+		whereArgs = append(whereArgs, limit)
+	}
 	// Example of query generated:
 	// SELECT name, version, formatVersion, release, path, baseData FROM (
 	//    SELECT p.name, p.version, p.formatVersion, p.release, p.path, p.baseData ,
@@ -438,14 +570,15 @@ func sqlQueryWithWindowing(database string, whereOptions WhereOptions) (string,
 	//               ORDER BY
 	//                versionMajor DESC,
 	//                versionMinor DESC,
-	//                versionPatch DESC
+	//                versionPatch DESC,
+	//                version COLLATE semver DESC
 	//            ) AS rnk
 	//    FROM (
 	//        SELECT pp.name, pp.version, pp.formatVersion, pp.release, pp.path, pp.baseData, pp.versionMajor, pp.versionMinor, pp.versionPatch, pp.versionPrerelease
 	//        FROM packages pp
 	//        WHERE cursor = ? AND prerelease = 0 AND release != 'experimental' AND semver_compare_ge(formatVersionMajorMinor, ?) = 1 AND semver_compare_le(formatVersionMajorMinor, ?) = 1
 	//    ) p
-	// ) WHERE rnk = 1
+	// ) WHERE rnk = 1 ORDER BY name LIMIT ?
 
 	return query.String(), whereArgs
 }
@@ -467,6 +600,10 @@ func sqlQueryGeneric(database string, whereOptions WhereOptions) (string, []any)
 		clause, whereArgs = whereOptions.Where()
 		query.WriteString(clause)
 	}
+	if limit := whereOptions.GetLimit(); limit > 0 {
+		query.WriteString(" ORDER BY name, version COLLATE semver LIMIT ?")
+		whereArgs = append(whereArgs, limit)
+	}
 	return query.String(), whereArgs
 }
 
@@ -486,8 +623,13 @@ func filterKeysForSelect(useBaseData, useJSONFields bool) []string {
 		// columns used only for filtering, not required in SELECT
 		case k.Name == "cursor" || k.Name == "formatVersionMajorMinor" || k.Name == "prerelease" || k.Name == "kibanaVersion" || k.Name == "type":
 			continue
+		case k.Name == "ownerType" || k.Name == "visibility":
+			continue
 		case k.Name == "capabilities" || k.Name == "discoveryFilterFields" || k.Name == "discoveryFilterDatasets":
 			continue
+		// columns used only for full-text search
+		case k.Name == "title" || k.Name == "description" || k.Name == "policyTemplatesTitles" || k.Name == "dataStreamsTitles":
+			continue
 		default:
 			getKeys = append(getKeys, k.Name)
 		}
@@ -499,7 +641,9 @@ func (r *SQLiteRepository) Drop(ctx context.Context, table string) error {
 	span, ctx := apm.StartSpan(ctx, "SQL: Drop", "app")
 	span.Context.SetLabel("database.path", r.File(ctx))
 	defer span.End()
-	query := fmt.Sprintf("DROP TABLE IF EXISTS %s", table)
+	// Drop also the full-text search index and the lookup tables of the table, if any.
+	query := fmt.Sprintf("DROP TABLE IF EXISTS %[1]s_fts; DROP TABLE IF EXISTS %[1]s%[2]s; DROP TABLE IF EXISTS %[1]s%[3]s; DROP TABLE IF EXISTS %[1]s",
+		table, datasetsTableSuffix, fieldsTableSuffix)
 	_, err := r.db.ExecContext(ctx, query)
 	if err != nil {
 		return err
@@ -507,6 +651,32 @@ func (r *SQLiteRepository) Drop(ctx context.Context, table string) error {
 	return nil
 }
 
+// SetMetadata sets the value of a metadata entry, replacing the previous one if any.
+func (r *SQLiteRepository) SetMetadata(ctx context.Context, tx *sql.Tx, key, value string) error {
+	db, err := r.writer(tx)
+	if err != nil {
+		return err
+	}
+	query := "INSERT INTO metadata (key, value) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value"
+	if _, err := db.ExecContext(ctx, query, key, value); err != nil {
+		return fmt.Errorf("failed to set metadata %q: %w", key, err)
+	}
+	return nil
+}
+
+// Metadata returns the value of a metadata entry, or ErrNotExists if it is not set.
+func (r *SQLiteRepository) Metadata(ctx context.Context, key string) (string, error) {
+	var value string
+	err := r.db.QueryRowContext(ctx, "SELECT value FROM metadata WHERE key = ?", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotExists
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get metadata %q: %w", key, err)
+	}
+	return value, nil
+}
+
 func (r *SQLiteRepository) Close(ctx context.Context) error {
 	return r.db.Close()
 }
@@ -524,6 +694,14 @@ type FilterOptions struct {
 	DiscoveryFilterFields   string
 	DiscoveryFilterDatasets string
 
+	// Query is a free-text query, matched with prefixes of the tokens in the full-text search index.
+	Query string
+
+	// Dataset and Field select the packages with a data stream with the given dataset, or
+	// with the given field, as found in the lookup tables.
+	Dataset string
+	Field   string
+
 	// It cannot be filtered by categories at database level, since
 	// the category filter is applied once all the others have been processed.
 	// Therefore, it must be handled at the application level.
@@ -539,6 +717,15 @@ type SQLOptions struct {
 	SkipPackageData bool // If true, no need to retrieve Data nor BaseData fields
 
 	JustLatestPackages bool // If true, only the latest packages will be retrieved
+
+	// After and Limit select a page of packages sorted by name and version. Only packages
+	// sorted after the given package are retrieved, and up to Limit packages if it is not zero.
+	After *packages.PackageKey
+	Limit int
+
+	// Restrictions are the access rules the caller is not entitled to, packages matching
+	// any of them are not retrieved.
+	Restrictions packages.AccessRestrictions
 }
 
 func (o *SQLOptions) Where() (string, []any) {
@@ -553,6 +740,25 @@ func (o *SQLOptions) Where() (string, []any) {
 		args = append(args, o.CurrentCursor)
 	}
 
+	if o.After != nil {
+		if sb.Len() > 0 {
+			sb.WriteString(" AND ")
+		}
+		sb.WriteString("(name > ? OR (name = ? AND version > ? COLLATE semver))")
+		args = append(args, o.After.Name, o.After.Name, o.After.Version)
+	}
+
+	for _, rule := range o.Restrictions {
+		if sb.Len() > 0 {
+			sb.WriteString(" AND ")
+		}
+		clause, ruleArgs := accessRuleClause(rule)
+		sb.WriteString("NOT (")
+		sb.WriteString(clause)
+		sb.WriteString(")")
+		args = append(args, ruleArgs...)
+	}
+
 	if o.Filter == nil {
 		if sb.Len() == 0 {
 			return "", nil
@@ -643,12 +849,80 @@ func (o *SQLOptions) Where() (string, []any) {
 		args = append(args, o.Filter.DiscoveryFilterDatasets)
 	}
 
+	if matchExpression := textSearchMatchExpression(o.Filter.Query); matchExpression != "" {
+		if sb.Len() > 0 {
+			sb.WriteString(" AND ")
+		}
+		sb.WriteString("rowid IN (SELECT rowid FROM packages_fts WHERE packages_fts MATCH ?)")
+		args = append(args, matchExpression)
+	}
+
+	if o.Filter.Dataset != "" {
+		if sb.Len() > 0 {
+			sb.WriteString(" AND ")
+		}
+		sb.WriteString("(name, version) IN (SELECT name, version FROM packages_datasets WHERE dataset = ?)")
+		args = append(args, o.Filter.Dataset)
+	}
+
+	if o.Filter.Field != "" {
+		if sb.Len() > 0 {
+			sb.WriteString(" AND ")
+		}
+		sb.WriteString("(name, version) IN (SELECT name, version FROM packages_fields WHERE field = ?)")
+		args = append(args, o.Filter.Field)
+	}
+
 	if sb.String() == "" {
 		return "", nil
 	}
 	return fmt.Sprintf(" WHERE %s", sb.String()), args
 }
 
+// accessRuleClause builds a condition that matches the packages matched by an access rule.
+// Package name patterns are matched with GLOB, whose syntax is compatible with path.Match
+// for package names.
+func accessRuleClause(rule packages.AccessRule) (string, []any) {
+	var conditions []string
+	var args []any
+	if len(rule.Packages) > 0 {
+		patterns := make([]string, len(rule.Packages))
+		for i, pattern := range rule.Packages {
+			patterns[i] = "name GLOB ?"
+			args = append(args, pattern)
+		}
+		conditions = append(conditions, "("+strings.Join(patterns, " OR ")+")")
+	}
+	if len(rule.OwnerTypes) > 0 {
+		conditions = append(conditions, "ownerType IN ("+placeholders(len(rule.OwnerTypes))+")")
+		for _, ownerType := range rule.OwnerTypes {
+			args = append(args, ownerType)
+		}
+	}
+	if len(rule.Visibility) > 0 {
+		conditions = append(conditions, "visibility IN ("+placeholders(len(rule.Visibility))+")")
+		for _, visibility := range rule.Visibility {
+			args = append(args, visibility)
+		}
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// textSearchMatchExpression builds an FTS5 expression that matches all the terms of the query
+// as prefixes. Terms are tokenized as in the in-memory text indexes to obtain the same results.
+// https://www.sqlite.org/fts5.html#full_text_query_syntax
+func textSearchMatchExpression(query string) string {
+	terms := packages.TokenizeText(query)
+	for i, term := range terms {
+		terms[i] = `"` + term + `"*`
+	}
+	return strings.Join(terms, " AND ")
+}
+
 func (o *SQLOptions) UseFullData() bool {
 	if o == nil {
 		return false
@@ -669,3 +943,10 @@ func (o *SQLOptions) GetLatestPackages() bool {
 	}
 	return o.JustLatestPackages
 }
+
+func (o *SQLOptions) GetLimit() int {
+	if o == nil {
+		return 0
+	}
+	return o.Limit
+}
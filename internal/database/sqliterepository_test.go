@@ -0,0 +1,289 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/package-registry/packages"
+)
+
+func TestFullTextSearch(t *testing.T) {
+	db, err := NewMemorySQLDB(MemorySQLDBOptions{Path: "fts"})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close(context.Background()) })
+
+	newPackage := func(name string, minor int, title, description, dataStreamsTitles string) *Package {
+		return &Package{
+			Cursor:            "1",
+			Name:              name,
+			Version:           fmt.Sprintf("1.%d.0", minor),
+			VersionMajor:      1,
+			VersionMinor:      minor,
+			Title:             title,
+			Description:       description,
+			DataStreamsTitles: dataStreamsTitles,
+			Data:              []byte("{}"),
+			BaseData:          []byte("{}"),
+		}
+	}
+	addPackages := func(t *testing.T) {
+		err := db.BulkAdd(t.Context(), nil, "packages", []*Package{
+			newPackage("nginx", 0, "Nginx", "Collect logs from Nginx HTTP servers.", "Access logs"),
+			newPackage("nginx", 1, "Nginx", "Collect logs from Nginx HTTP servers.", "Access logs\nError logs"),
+			newPackage("apache", 0, "Apache HTTP Server", "Collect logs from Apache.", "Access logs"),
+			newPackage("kubernetes", 0, "Kubernetes", "Collect metrics from Kubernetes.", "Ingress controller"),
+		})
+		require.NoError(t, err)
+	}
+	search := func(t *testing.T, query string, latest bool) []string {
+		options := &SQLOptions{
+			CurrentCursor:      "1",
+			Filter:             &FilterOptions{Prerelease: true, Query: query},
+			SkipPackageData:    true,
+			JustLatestPackages: latest,
+		}
+		var found []string
+		err := db.FilterFunc(t.Context(), "packages", options, func(ctx context.Context, pkg *Package) error {
+			found = append(found, pkg.Name+"-"+pkg.Version)
+			return nil
+		})
+		require.NoError(t, err)
+		return found
+	}
+
+	addPackages(t)
+
+	assert.ElementsMatch(t, []string{"nginx-1.0.0", "nginx-1.1.0"}, search(t, "NGINX", false))
+	assert.ElementsMatch(t, []string{"nginx-1.0.0", "nginx-1.1.0", "apache-1.0.0"}, search(t, "htt serv", false))
+	assert.ElementsMatch(t, []string{"nginx-1.1.0", "apache-1.0.0"}, search(t, "logs", true))
+	assert.ElementsMatch(t, []string{"nginx-1.1.0"}, search(t, "error", true))
+	assert.ElementsMatch(t, []string{"kubernetes-1.0.0"}, search(t, "ingress", false))
+	assert.Empty(t, search(t, "redis", false))
+	assert.Len(t, search(t, "", false), 4)
+
+	// Recreating the table must also reset the full-text search index.
+	require.NoError(t, db.Drop(t.Context(), "packages"))
+	require.NoError(t, db.Initialize(t.Context()))
+	assert.Empty(t, search(t, "nginx", false))
+
+	addPackages(t)
+	assert.ElementsMatch(t, []string{"nginx-1.0.0", "nginx-1.1.0"}, search(t, "nginx", false))
+}
+
+func TestLookupTables(t *testing.T) {
+	db, err := NewMemorySQLDB(MemorySQLDBOptions{Path: "lookup"})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close(context.Background()) })
+
+	newPackage := func(name string, minor int, datasets, fields []string) *Package {
+		return &Package{
+			Cursor:       "1",
+			Name:         name,
+			Version:      fmt.Sprintf("1.%d.0", minor),
+			VersionMajor: 1,
+			VersionMinor: minor,
+			Datasets:     datasets,
+			Fields:       fields,
+			Data:         []byte("{}"),
+			BaseData:     []byte("{}"),
+		}
+	}
+	err = db.BulkAdd(t.Context(), nil, "packages", []*Package{
+		newPackage("nginx", 0, []string{"nginx.access"}, []string{"nginx.access.remote_ip_list"}),
+		newPackage("nginx", 1, []string{"nginx.access", "nginx.error"}, []string{"nginx.access.remote_ip_list", "nginx.error.connection_id"}),
+		newPackage("apache", 0, []string{"apache.access"}, []string{"apache.access.ssl.protocol", "nginx.access.remote_ip_list"}),
+	})
+	require.NoError(t, err)
+
+	lookup := func(t *testing.T, filter FilterOptions, latest bool) []string {
+		filter.Prerelease = true
+		options := &SQLOptions{
+			CurrentCursor:      "1",
+			Filter:             &filter,
+			SkipPackageData:    true,
+			JustLatestPackages: latest,
+		}
+		var found []string
+		err := db.FilterFunc(t.Context(), "packages", options, func(ctx context.Context, pkg *Package) error {
+			found = append(found, pkg.Name+"-"+pkg.Version)
+			return nil
+		})
+		require.NoError(t, err)
+		return found
+	}
+
+	assert.ElementsMatch(t, []string{"nginx-1.0.0", "nginx-1.1.0"}, lookup(t, FilterOptions{Dataset: "nginx.access"}, false))
+	assert.ElementsMatch(t, []string{"nginx-1.1.0"}, lookup(t, FilterOptions{Dataset: "nginx.access"}, true))
+	assert.ElementsMatch(t, []string{"nginx-1.1.0"}, lookup(t, FilterOptions{Dataset: "nginx.error"}, false))
+	assert.ElementsMatch(t, []string{"nginx-1.1.0", "apache-1.0.0"}, lookup(t, FilterOptions{Field: "nginx.access.remote_ip_list"}, true))
+	assert.ElementsMatch(t, []string{"apache-1.0.0"}, lookup(t, FilterOptions{Dataset: "apache.access", Field: "nginx.access.remote_ip_list"}, false))
+	assert.Empty(t, lookup(t, FilterOptions{Dataset: "apache.access", Field: "nginx.error.connection_id"}, false))
+
+	// Deleted packages are removed from the lookup tables.
+	_, err = db.Delete(t.Context(), nil, "packages", "nginx", "1.1.0")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"nginx-1.0.0"}, lookup(t, FilterOptions{Dataset: "nginx.access"}, false))
+	err = db.BulkAdd(t.Context(), nil, "packages", []*Package{newPackage("nginx", 1, []string{"nginx.access"}, nil)})
+	require.NoError(t, err)
+	assert.Empty(t, lookup(t, FilterOptions{Dataset: "nginx.error"}, false))
+
+	// Recreating the table must also reset the lookup tables.
+	require.NoError(t, db.Drop(t.Context(), "packages"))
+	require.NoError(t, db.Initialize(t.Context()))
+	assert.Empty(t, lookup(t, FilterOptions{Field: "nginx.access.remote_ip_list"}, false))
+}
+
+func TestTextSearchMatchExpression(t *testing.T) {
+	assert.Equal(t, `"aws"* AND "bedrock"*`, textSearchMatchExpression(`AWS "bedrock`))
+	assert.Equal(t, "", textSearchMatchExpression(" * "))
+}
+
+func TestPagination(t *testing.T) {
+	db, err := NewMemorySQLDB(MemorySQLDBOptions{Path: "pagination"})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close(context.Background()) })
+
+	newPackage := func(name, version string, major, minor, patch int) *Package {
+		return &Package{
+			Cursor:       "1",
+			Name:         name,
+			Version:      version,
+			VersionMajor: major,
+			VersionMinor: minor,
+			VersionPatch: patch,
+			Prerelease:   strings.Contains(version, "-"),
+			Data:         []byte("{}"),
+			BaseData:     []byte("{}"),
+		}
+	}
+	err = db.BulkAdd(t.Context(), nil, "packages", []*Package{
+		newPackage("nginx", "1.10.0", 1, 10, 0),
+		newPackage("nginx", "1.9.0", 1, 9, 0),
+		newPackage("nginx", "1.10.0-beta.1", 1, 10, 0),
+		newPackage("apache", "2.0.0", 2, 0, 0),
+		newPackage("kubernetes", "1.0.0", 1, 0, 0),
+		newPackage("kubernetes", "1.0.0-beta.2", 1, 0, 0),
+		newPackage("kubernetes", "1.0.0-beta.10", 1, 0, 0),
+	})
+	require.NoError(t, err)
+
+	paginate := func(t *testing.T, limit int, latest bool) (pages [][]string) {
+		var after *packages.PackageKey
+		for {
+			options := &SQLOptions{
+				CurrentCursor:      "1",
+				Filter:             &FilterOptions{Prerelease: true},
+				SkipPackageData:    true,
+				JustLatestPackages: latest,
+				After:              after,
+				Limit:              limit,
+			}
+			var page []string
+			err := db.FilterFunc(t.Context(), "packages", options, func(ctx context.Context, pkg *Package) error {
+				page = append(page, pkg.Name+"-"+pkg.Version)
+				after = &packages.PackageKey{Name: pkg.Name, Version: pkg.Version}
+				return nil
+			})
+			require.NoError(t, err)
+			if len(page) == 0 {
+				return pages
+			}
+			pages = append(pages, page)
+		}
+	}
+
+	assert.Equal(t, [][]string{
+		{"apache-2.0.0", "kubernetes-1.0.0-beta.2", "kubernetes-1.0.0-beta.10"},
+		{"kubernetes-1.0.0", "nginx-1.9.0", "nginx-1.10.0-beta.1"},
+		{"nginx-1.10.0"},
+	}, paginate(t, 3, false))
+
+	assert.Equal(t, [][]string{
+		{"apache-2.0.0", "kubernetes-1.0.0"},
+		{"nginx-1.10.0"},
+	}, paginate(t, 2, true))
+}
+
+func TestMetadata(t *testing.T) {
+	db, err := NewMemorySQLDB(MemorySQLDBOptions{Path: "metadata"})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close(context.Background()) })
+
+	_, err = db.Metadata(t.Context(), "cursor")
+	assert.ErrorIs(t, err, ErrNotExists)
+
+	require.NoError(t, db.SetMetadata(t.Context(), nil, "cursor", "1"))
+	require.NoError(t, db.SetMetadata(t.Context(), nil, "cursor", "2"))
+
+	value, err := db.Metadata(t.Context(), "cursor")
+	require.NoError(t, err)
+	assert.Equal(t, "2", value)
+
+	require.NoError(t, db.Drop(t.Context(), "metadata"))
+	require.NoError(t, db.Initialize(t.Context()))
+	_, err = db.Metadata(t.Context(), "cursor")
+	assert.ErrorIs(t, err, ErrNotExists)
+}
+
+func TestAccessRestrictions(t *testing.T) {
+	db, err := NewMemorySQLDB(MemorySQLDBOptions{Path: "restrictions"})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close(context.Background()) })
+
+	newPackage := func(name, ownerType, visibility string) *Package {
+		return &Package{
+			Cursor:       "1",
+			Name:         name,
+			Version:      "1.0.0",
+			VersionMajor: 1,
+			OwnerType:    ownerType,
+			Visibility:   visibility,
+			Data:         []byte("{}"),
+			BaseData:     []byte("{}"),
+		}
+	}
+	err = db.BulkAdd(t.Context(), nil, "packages", []*Package{
+		newPackage("acme_logs", "partner", ""),
+		newPackage("acme_metrics", "partner", "private"),
+		newPackage("nginx", "elastic", ""),
+		newPackage("internal_tools", "elastic", "private"),
+	})
+	require.NoError(t, err)
+
+	rules, err := packages.NewAccessRules([]packages.AccessRule{
+		{Packages: []string{"acme_*"}, OwnerTypes: []string{"partner"}, Scopes: []string{"acme"}},
+		{Visibility: []string{"private"}, Scopes: []string{"internal"}},
+	})
+	require.NoError(t, err)
+
+	search := func(t *testing.T, scopes ...string) []string {
+		options := &SQLOptions{
+			CurrentCursor:      "1",
+			Filter:             &FilterOptions{Prerelease: true},
+			SkipPackageData:    true,
+			JustLatestPackages: true,
+			Restrictions:       rules.Restrictions(scopes),
+		}
+		var found []string
+		err := db.FilterFunc(t.Context(), "packages", options, func(ctx context.Context, pkg *Package) error {
+			found = append(found, pkg.Name)
+			return nil
+		})
+		require.NoError(t, err)
+		return found
+	}
+
+	assert.ElementsMatch(t, []string{"nginx"}, search(t))
+	assert.ElementsMatch(t, []string{"nginx", "acme_logs"}, search(t, "acme"))
+	assert.ElementsMatch(t, []string{"nginx", "internal_tools"}, search(t, "internal"))
+	assert.ElementsMatch(t, []string{"nginx", "acme_logs", "acme_metrics", "internal_tools"}, search(t, "acme", "internal"))
+}
@@ -7,9 +7,9 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
-	"cloud.google.com/go/storage"
 	"go.elastic.co/apm/v2"
 	"go.uber.org/zap"
 )
@@ -26,16 +26,16 @@ func (c *cursor) String() string {
 	return string(b)
 }
 
-func loadCursor(ctx context.Context, logger *zap.Logger, storageClient *storage.Client, bucketName, rootStoragePath string) (*cursor, error) {
+func loadCursor(ctx context.Context, logger *zap.Logger, store ObjectStore, rootStoragePath string) (*cursor, error) {
 	span, ctx := apm.StartSpan(ctx, "LoadCursor", "app")
 	defer span.End()
 
 	logger.Debug("load cursor file")
 
 	rootedCursorStoragePath := joinObjectPaths(rootStoragePath, cursorStoragePath)
-	objectReader, err := storageClient.Bucket(bucketName).Object(rootedCursorStoragePath).NewReader(ctx)
-	if err == storage.ErrObjectNotExist {
-		return nil, fmt.Errorf("cursor file doesn't exist, most likely a first run (bucketName: %s, path: %s): %w", bucketName, rootedCursorStoragePath, err)
+	objectReader, err := store.NewReader(ctx, rootedCursorStoragePath)
+	if errors.Is(err, ErrObjectNotExist) {
+		return nil, fmt.Errorf("cursor file doesn't exist, most likely a first run (path: %s): %w", rootedCursorStoragePath, err)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("can't read the cursor file (path: %s): %w", rootedCursorStoragePath, err)
@@ -53,12 +53,8 @@ func loadCursor(ctx context.Context, logger *zap.Logger, storageClient *storage.
 }
 
 // LoadLatestCursorValue reads cursor.json and returns the Current timestamp value.
-func LoadLatestCursorValue(ctx context.Context, logger *zap.Logger, storageClient *storage.Client, storageBucketInternal string) (string, error) {
-	bucketName, rootStoragePath, err := extractBucketNameFromURL(storageBucketInternal)
-	if err != nil {
-		return "", fmt.Errorf("can't extract bucket name from URL: %w", err)
-	}
-	c, err := loadCursor(ctx, logger, storageClient, bucketName, rootStoragePath)
+func LoadLatestCursorValue(ctx context.Context, logger *zap.Logger, store ObjectStore, rootStoragePath string) (string, error) {
+	c, err := loadCursor(ctx, logger, store, rootStoragePath)
 	if err != nil {
 		return "", err
 	}
@@ -38,7 +38,7 @@ func TestListCursorsBetween_EmptyRange(t *testing.T) {
 	server := newCursorsServer(t, "2", "3")
 	client := ClientNoAuth(server)
 
-	timestamps, err := listCursorsBetween(t.Context(), client, FakePackageStorageBucketInternal, "", "3", "3")
+	timestamps, err := listCursorsBetween(t.Context(), NewGCSObjectStore(client, FakePackageStorageBucketInternal), "", "3", "3")
 	require.NoError(t, err)
 	assert.Empty(t, timestamps)
 }
@@ -47,7 +47,7 @@ func TestListCursorsBetween_SingleIntermediate(t *testing.T) {
 	server := newCursorsServer(t, "1", "2", "3")
 	client := ClientNoAuth(server)
 
-	timestamps, err := listCursorsBetween(t.Context(), client, FakePackageStorageBucketInternal, "", "1", "2")
+	timestamps, err := listCursorsBetween(t.Context(), NewGCSObjectStore(client, FakePackageStorageBucketInternal), "", "1", "2")
 	require.NoError(t, err)
 	require.Len(t, timestamps, 1)
 	assert.Equal(t, "2", timestamps[0])
@@ -57,7 +57,7 @@ func TestListCursorsBetween_MultipleIntermediates(t *testing.T) {
 	server := newCursorsServer(t, "1", "2", "3", "4", "5")
 	client := ClientNoAuth(server)
 
-	timestamps, err := listCursorsBetween(t.Context(), client, FakePackageStorageBucketInternal, "", "1", "4")
+	timestamps, err := listCursorsBetween(t.Context(), NewGCSObjectStore(client, FakePackageStorageBucketInternal), "", "1", "4")
 	require.NoError(t, err)
 	assert.Equal(t, []string{"2", "3", "4"}, timestamps)
 }
@@ -70,7 +70,7 @@ func TestListCursorsBetween_FixedLengthSort(t *testing.T) {
 	server := newCursorsServer(t, "09", "10", "11", "12")
 	client := ClientNoAuth(server)
 
-	timestamps, err := listCursorsBetween(t.Context(), client, FakePackageStorageBucketInternal, "", "09", "12")
+	timestamps, err := listCursorsBetween(t.Context(), NewGCSObjectStore(client, FakePackageStorageBucketInternal), "", "09", "12")
 	require.NoError(t, err)
 	assert.Equal(t, []string{"10", "11", "12"}, timestamps)
 }
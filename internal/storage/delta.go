@@ -9,7 +9,6 @@ import (
 	"encoding/json"
 	"fmt"
 
-	"cloud.google.com/go/storage"
 	"go.elastic.co/apm/v2"
 	"go.uber.org/zap"
 
@@ -38,14 +37,14 @@ type removedPackageRef struct {
 	Version string `json:"version"`
 }
 
-func loadSearchIndexDelta(ctx context.Context, logger *zap.Logger, storageClient *storage.Client, bucketName, rootStoragePath string, aCursor cursor) (*SearchIndexDelta, error) {
+func loadSearchIndexDelta(ctx context.Context, logger *zap.Logger, store ObjectStore, rootStoragePath string, aCursor cursor) (*SearchIndexDelta, error) {
 	span, ctx := apm.StartSpan(ctx, "LoadSearchIndexDelta", "app")
 	defer span.End()
 
 	logger.Debug("load search-index-delta", zap.String("delta.file", searchIndexDeltaFile))
 
 	rootedPath := buildIndexStoragePath(rootStoragePath, aCursor, searchIndexDeltaFile)
-	objectReader, err := storageClient.Bucket(bucketName).Object(rootedPath).NewReader(ctx)
+	objectReader, err := store.NewReader(ctx, rootedPath)
 	if err != nil {
 		return nil, fmt.Errorf("can't read the delta file (path: %s): %w", rootedPath, err)
 	}
@@ -59,10 +58,6 @@ func loadSearchIndexDelta(ctx context.Context, logger *zap.Logger, storageClient
 }
 
 // LoadSearchIndexDelta reads and parses the delta file for the given cursor value.
-func LoadSearchIndexDelta(ctx context.Context, logger *zap.Logger, storageClient *storage.Client, storageBucketInternal, cursorValue string) (*SearchIndexDelta, error) {
-	bucketName, rootStoragePath, err := extractBucketNameFromURL(storageBucketInternal)
-	if err != nil {
-		return nil, fmt.Errorf("can't extract bucket name from URL: %w", err)
-	}
-	return loadSearchIndexDelta(ctx, logger, storageClient, bucketName, rootStoragePath, cursor{Current: cursorValue})
+func LoadSearchIndexDelta(ctx context.Context, logger *zap.Logger, store ObjectStore, rootStoragePath, cursorValue string) (*SearchIndexDelta, error) {
+	return loadSearchIndexDelta(ctx, logger, store, rootStoragePath, cursor{Current: cursorValue})
 }
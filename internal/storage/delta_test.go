@@ -33,7 +33,7 @@ func newDeltaServer(t *testing.T, deltaJSON string) (*fakestorage.Server, *stora
 func TestLoadSearchIndexDelta_ParsesAdded(t *testing.T) {
 	server, client := newDeltaServer(t, `{"added":[{"package_manifest":{"name":"mypkg","version":"1.0.0","type":"integration"}}],"updated":[],"removed":[]}`)
 
-	delta, err := loadSearchIndexDelta(t.Context(), zap.NewNop(), client, FakePackageStorageBucketInternal, "", cursor{Current: "1"})
+	delta, err := loadSearchIndexDelta(t.Context(), zap.NewNop(), NewGCSObjectStore(client, FakePackageStorageBucketInternal), "", cursor{Current: "1"})
 	require.NoError(t, err)
 	require.Len(t, delta.Added, 1)
 	assert.Equal(t, "mypkg", delta.Added[0].PackageManifest.Name)
@@ -46,7 +46,7 @@ func TestLoadSearchIndexDelta_ParsesAdded(t *testing.T) {
 func TestLoadSearchIndexDelta_ParsesUpdated(t *testing.T) {
 	server, client := newDeltaServer(t, `{"added":[],"updated":[{"package_manifest":{"name":"mypkg","version":"2.0.0","type":"integration"}}],"removed":[]}`)
 
-	delta, err := loadSearchIndexDelta(t.Context(), zap.NewNop(), client, FakePackageStorageBucketInternal, "", cursor{Current: "1"})
+	delta, err := loadSearchIndexDelta(t.Context(), zap.NewNop(), NewGCSObjectStore(client, FakePackageStorageBucketInternal), "", cursor{Current: "1"})
 	require.NoError(t, err)
 	assert.Empty(t, delta.Added)
 	require.Len(t, delta.Updated, 1)
@@ -59,7 +59,7 @@ func TestLoadSearchIndexDelta_ParsesUpdated(t *testing.T) {
 func TestLoadSearchIndexDelta_ParsesRemoved(t *testing.T) {
 	server, client := newDeltaServer(t, `{"added":[],"updated":[],"removed":[{"name":"oldpkg","version":"1.0.0"}]}`)
 
-	delta, err := loadSearchIndexDelta(t.Context(), zap.NewNop(), client, FakePackageStorageBucketInternal, "", cursor{Current: "1"})
+	delta, err := loadSearchIndexDelta(t.Context(), zap.NewNop(), NewGCSObjectStore(client, FakePackageStorageBucketInternal), "", cursor{Current: "1"})
 	require.NoError(t, err)
 	assert.Empty(t, delta.Added)
 	assert.Empty(t, delta.Updated)
@@ -76,7 +76,7 @@ func TestLoadSearchIndexDelta_AllFields(t *testing.T) {
 		"removed":[{"name":"oldpkg","version":"1.0.0"}]
 	}`)
 
-	delta, err := loadSearchIndexDelta(t.Context(), zap.NewNop(), client, FakePackageStorageBucketInternal, "", cursor{Current: "1"})
+	delta, err := loadSearchIndexDelta(t.Context(), zap.NewNop(), NewGCSObjectStore(client, FakePackageStorageBucketInternal), "", cursor{Current: "1"})
 	require.NoError(t, err)
 	require.Len(t, delta.Added, 1)
 	require.Len(t, delta.Updated, 1)
@@ -92,7 +92,7 @@ func TestLoadSearchIndexDelta_MissingFile_ReturnsError(t *testing.T) {
 	t.Cleanup(server.Stop)
 	client := ClientNoAuth(server)
 
-	_, err := loadSearchIndexDelta(t.Context(), zap.NewNop(), client, FakePackageStorageBucketInternal, "", cursor{Current: "1"})
+	_, err := loadSearchIndexDelta(t.Context(), zap.NewNop(), NewGCSObjectStore(client, FakePackageStorageBucketInternal), "", cursor{Current: "1"})
 	require.Error(t, err)
 	assert.ErrorIs(t, err, storage.ErrObjectNotExist)
 }
@@ -0,0 +1,195 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package storage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// FakeS3Server is an in-process stand-in of an S3-compatible service. It only implements
+// the subset of the API used by the storage indexers: getting objects and listing them with
+// the ListObjectsV2 operation. Requests are not authenticated.
+type FakeS3Server struct {
+	server *httptest.Server
+
+	m       sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+// NewFakeS3Server starts a fake S3 server. It must be closed after use.
+func NewFakeS3Server() *FakeS3Server {
+	s := &FakeS3Server{
+		buckets: make(map[string]map[string][]byte),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// PrepareFakeS3Server starts a fake S3 server with the first revision of the given index.
+func PrepareFakeS3Server(tb testing.TB, indexPath string) *FakeS3Server {
+	indexContent, err := os.ReadFile(indexPath)
+	require.NoError(tb, err, "index file must be populated")
+
+	const firstRevision = "1"
+	serverObjects, _, err := PrepareServerObjects(firstRevision, indexContent)
+	require.NoError(tb, err, "failed to prepare server objects")
+
+	s := NewFakeS3Server()
+	for _, object := range serverObjects {
+		s.PutObject(object.BucketName, object.Name, object.Content)
+	}
+	return s
+}
+
+// PutObject creates or replaces an object, creating the bucket if it doesn't exist.
+func (s *FakeS3Server) PutObject(bucketName, name string, content []byte) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	bucket, found := s.buckets[bucketName]
+	if !found {
+		bucket = make(map[string][]byte)
+		s.buckets[bucketName] = bucket
+	}
+	bucket[name] = content
+}
+
+// Options returns the options to connect to this server.
+func (s *FakeS3Server) Options() S3Options {
+	u, _ := url.Parse(s.server.URL)
+	return S3Options{
+		Endpoint:        u.Host,
+		Region:          "us-east-1",
+		Insecure:        true,
+		AccessKeyID:     "fake",
+		SecretAccessKey: "fake",
+	}
+}
+
+// Close stops the server.
+func (s *FakeS3Server) Close() {
+	s.server.Close()
+}
+
+// fakeS3ModTime is the modification time reported for all objects, S3 clients require it.
+var fakeS3ModTime = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type fakeS3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+type fakeS3ListBucketResult struct {
+	XMLName        xml.Name `xml:"ListBucketResult"`
+	Name           string   `xml:"Name"`
+	Prefix         string   `xml:"Prefix"`
+	Delimiter      string   `xml:"Delimiter,omitempty"`
+	KeyCount       int      `xml:"KeyCount"`
+	IsTruncated    bool     `xml:"IsTruncated"`
+	Contents       []fakeS3Object
+	CommonPrefixes []fakeS3CommonPrefix
+}
+
+type fakeS3Object struct {
+	Key  string `xml:"Key"`
+	Size int    `xml:"Size"`
+}
+
+type fakeS3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+func (s *FakeS3Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeFakeS3Error(w, http.StatusNotImplemented, "NotImplemented", "operation not implemented by the fake server")
+		return
+	}
+
+	// Only path-style requests are supported: /bucket/key.
+	bucketName, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	s.m.RLock()
+	defer s.m.RUnlock()
+	bucket, found := s.buckets[bucketName]
+	if !found {
+		writeFakeS3Error(w, http.StatusNotFound, "NoSuchBucket", "the specified bucket does not exist")
+		return
+	}
+
+	if name == "" {
+		if r.URL.Query().Get("list-type") != "2" {
+			writeFakeS3Error(w, http.StatusNotImplemented, "NotImplemented", "only ListObjectsV2 is implemented by the fake server")
+			return
+		}
+		s.listObjects(w, r, bucketName, bucket)
+		return
+	}
+
+	content, found := bucket[name]
+	if !found {
+		writeFakeS3Error(w, http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+		return
+	}
+	w.Header().Set("ETag", `"fake"`)
+	http.ServeContent(w, r, name, fakeS3ModTime, bytes.NewReader(content))
+}
+
+func (s *FakeS3Server) listObjects(w http.ResponseWriter, r *http.Request, bucketName string, bucket map[string][]byte) {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+
+	result := fakeS3ListBucketResult{
+		Name:      bucketName,
+		Prefix:    prefix,
+		Delimiter: delimiter,
+	}
+
+	names := make([]string, 0, len(bucket))
+	for name := range bucket {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	seenPrefixes := make(map[string]bool)
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+				commonPrefix := name[:len(prefix)+i+len(delimiter)]
+				if !seenPrefixes[commonPrefix] {
+					seenPrefixes[commonPrefix] = true
+					result.CommonPrefixes = append(result.CommonPrefixes, fakeS3CommonPrefix{Prefix: commonPrefix})
+				}
+				continue
+			}
+		}
+		result.Contents = append(result.Contents, fakeS3Object{Key: name, Size: len(bucket[name])})
+	}
+	result.KeyCount = len(result.Contents) + len(result.CommonPrefixes)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func writeFakeS3Error(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(fakeS3Error{Code: code, Message: message})
+}
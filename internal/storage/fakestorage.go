@@ -9,7 +9,9 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"cloud.google.com/go/storage"
@@ -75,6 +77,25 @@ func PrepareFakeServer(tb testing.TB, indexPath string) *fakestorage.Server {
 	return fakestorage.NewServer(serverObjects)
 }
 
+// PrepareLocalBucket writes the first revision of the given index in a temporary directory
+// with the layout of the internal bucket, and returns its file:// URL.
+func PrepareLocalBucket(tb testing.TB, indexPath string) string {
+	indexContent, err := os.ReadFile(indexPath)
+	require.NoError(tb, err, "index file must be populated")
+
+	const firstRevision = "1"
+	serverObjects, _, err := PrepareServerObjects(firstRevision, indexContent)
+	require.NoError(tb, err, "failed to prepare server objects")
+
+	dir := tb.TempDir()
+	for _, object := range serverObjects {
+		objectPath := filepath.Join(dir, filepath.FromSlash(object.Name))
+		require.NoError(tb, os.MkdirAll(filepath.Dir(objectPath), 0o755))
+		require.NoError(tb, os.WriteFile(objectPath, object.Content, 0o644))
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}).String()
+}
+
 // ClientNoAuth returns a GCS client configured to talk to the server without any authentication.
 // Base on https://github.com/fsouza/fake-gcs-server/blob/0c333c15145e533e5595bc79def33fbbb5792e8a/fakestorage/server.go#L502-L508
 func ClientNoAuth(server *fakestorage.Server) *storage.Client {
@@ -90,7 +111,7 @@ func ClientNoAuth(server *fakestorage.Server) *storage.Client {
 
 // UpdateFakeServer simulates an index update by stopping the given fake
 // server and returning a new one that contains the previous server's objects
-// plus the new revision's objects, together with a storage client configured
+// plus the new revision's objects, together with an object store configured
 // to use the new server.
 //
 // It doesn't add the new revision's objects to the running server via
@@ -104,7 +125,7 @@ func ClientNoAuth(server *fakestorage.Server) *storage.Client {
 //
 // New revision objects are appended after the existing ones so they win any
 // name conflicts (e.g. the cursor object is overwritten with the new revision).
-func UpdateFakeServer(tb testing.TB, server *fakestorage.Server, revision, indexPath string) (*fakestorage.Server, *storage.Client) {
+func UpdateFakeServer(tb testing.TB, server *fakestorage.Server, revision, indexPath string) (*fakestorage.Server, ObjectStore) {
 	indexContent, err := os.ReadFile(indexPath)
 	require.NoError(tb, err, "index file must be populated")
 
@@ -138,7 +159,7 @@ func UpdateFakeServer(tb testing.TB, server *fakestorage.Server, revision, index
 
 	server.Stop()
 	newServer := fakestorage.NewServer(allObjects)
-	return newServer, ClientNoAuth(newServer)
+	return newServer, NewGCSObjectStore(ClientNoAuth(newServer), FakePackageStorageBucketInternal)
 }
 
 type searchIndexAll struct {
@@ -194,7 +215,7 @@ func prepareDeltaServerObjects(revision string, deltaContent []byte) []fakestora
 // UpdateFakeServerWithDelta simulates a delta update by stopping the given fake
 // server and returning a new one with the previous objects plus the new revision's
 // delta file (no search-index-all.json for the new revision).
-func UpdateFakeServerWithDelta(tb testing.TB, server *fakestorage.Server, revision string, deltaContent []byte) (*fakestorage.Server, *storage.Client) {
+func UpdateFakeServerWithDelta(tb testing.TB, server *fakestorage.Server, revision string, deltaContent []byte) (*fakestorage.Server, ObjectStore) {
 	newObjects := prepareDeltaServerObjects(revision, deltaContent)
 
 	var existingAttrs []fakestorage.ObjectAttrs
@@ -218,5 +239,5 @@ func UpdateFakeServerWithDelta(tb testing.TB, server *fakestorage.Server, revisi
 
 	server.Stop()
 	newServer := fakestorage.NewServer(allObjects)
-	return newServer, ClientNoAuth(newServer)
+	return newServer, NewGCSObjectStore(ClientNoAuth(newServer), FakePackageStorageBucketInternal)
 }
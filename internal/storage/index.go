@@ -9,7 +9,6 @@ import (
 	"encoding/json"
 	"fmt"
 
-	"cloud.google.com/go/storage"
 	"go.elastic.co/apm/v2"
 	"go.uber.org/zap"
 
@@ -20,7 +19,7 @@ type packageIndex struct {
 	PackageManifest *packages.Package `json:"package_manifest"`
 }
 
-func loadSearchIndexAll(ctx context.Context, logger *zap.Logger, storageClient *storage.Client, bucketName, rootStoragePath string, aCursor cursor) (*packages.Packages, error) {
+func loadSearchIndexAll(ctx context.Context, logger *zap.Logger, store ObjectStore, rootStoragePath string, aCursor cursor) (*packages.Packages, error) {
 	span, ctx := apm.StartSpan(ctx, "LoadSearchIndexAll", "app")
 	span.Context.SetLabel("load.method", "full")
 	defer span.End()
@@ -30,7 +29,7 @@ func loadSearchIndexAll(ctx context.Context, logger *zap.Logger, storageClient *
 	logger.Debug("load search-index-all index", zap.String("index.file", indexFile))
 
 	rootedIndexStoragePath := buildIndexStoragePath(rootStoragePath, aCursor, indexFile)
-	objectReader, err := storageClient.Bucket(bucketName).Object(rootedIndexStoragePath).NewReader(ctx)
+	objectReader, err := store.NewReader(ctx, rootedIndexStoragePath)
 	if err != nil {
 		return nil, fmt.Errorf("can't read the index file (path: %s): %w", rootedIndexStoragePath, err)
 	}
@@ -91,7 +90,7 @@ func buildIndexStoragePath(rootStoragePath string, aCursor cursor, indexFile str
 	return joinObjectPaths(rootStoragePath, v2MetadataStoragePath, aCursor.Current, indexFile)
 }
 
-func loadSearchIndexAllBatches(ctx context.Context, logger *zap.Logger, storageClient *storage.Client, bucketName, rootStoragePath string, aCursor cursor, batchSize int, process func(context.Context, packages.Packages, string) error) error {
+func loadSearchIndexAllBatches(ctx context.Context, logger *zap.Logger, store ObjectStore, rootStoragePath string, aCursor cursor, batchSize int, process func(context.Context, packages.Packages, string) error) error {
 	span, ctx := apm.StartSpan(ctx, "LoadSearchIndexAll", "app")
 	span.Context.SetLabel("load.method", "batches")
 	span.Context.SetLabel("load.batch.size", batchSize)
@@ -102,7 +101,7 @@ func loadSearchIndexAllBatches(ctx context.Context, logger *zap.Logger, storageC
 	logger.Debug("load search-index-all index", zap.String("index.file", indexFile))
 
 	rootedIndexStoragePath := buildIndexStoragePath(rootStoragePath, aCursor, indexFile)
-	objectReader, err := storageClient.Bucket(bucketName).Object(rootedIndexStoragePath).NewReader(ctx)
+	objectReader, err := store.NewReader(ctx, rootedIndexStoragePath)
 	if err != nil {
 		return fmt.Errorf("can't read the index file (path: %s): %w", rootedIndexStoragePath, err)
 	}
@@ -176,13 +175,8 @@ func loadSearchIndexAllBatches(ctx context.Context, logger *zap.Logger, storageC
 	return nil
 }
 
-func LoadPackagesAndCursorFromIndexBatches(ctx context.Context, logger *zap.Logger, storageClient *storage.Client, storageBucketInternal, currentCursor string, batchSize int, process func(context.Context, packages.Packages, string) error) (string, error) {
-	bucketName, rootStoragePath, err := extractBucketNameFromURL(storageBucketInternal)
-	if err != nil {
-		return "", fmt.Errorf("can't extract bucket name from URL (url: %s): %w", storageBucketInternal, err)
-	}
-
-	storageCursor, err := loadCursor(ctx, logger, storageClient, bucketName, rootStoragePath)
+func LoadPackagesAndCursorFromIndexBatches(ctx context.Context, logger *zap.Logger, store ObjectStore, rootStoragePath, currentCursor string, batchSize int, process func(context.Context, packages.Packages, string) error) (string, error) {
+	storageCursor, err := loadCursor(ctx, logger, store, rootStoragePath)
 	if err != nil {
 		return "", fmt.Errorf("can't load latest cursor: %w", err)
 	}
@@ -193,7 +187,7 @@ func LoadPackagesAndCursorFromIndexBatches(ctx context.Context, logger *zap.Logg
 	}
 	logger.Info("cursor will be updated", zap.String("cursor.current", currentCursor), zap.String("cursor.next", storageCursor.Current))
 
-	err = loadSearchIndexAllBatches(ctx, logger, storageClient, bucketName, rootStoragePath, *storageCursor, batchSize, process)
+	err = loadSearchIndexAllBatches(ctx, logger, store, rootStoragePath, *storageCursor, batchSize, process)
 	if err != nil {
 		return "", fmt.Errorf("can't load the search-index-all index content: %w", err)
 	}
@@ -201,10 +195,6 @@ func LoadPackagesAndCursorFromIndexBatches(ctx context.Context, logger *zap.Logg
 }
 
 // LoadSearchIndexAllForCursor reads search-index-all.json for a specific cursor value.
-func LoadSearchIndexAllForCursor(ctx context.Context, logger *zap.Logger, storageClient *storage.Client, storageBucketInternal, cursorValue string) (*packages.Packages, error) {
-	bucketName, rootStoragePath, err := extractBucketNameFromURL(storageBucketInternal)
-	if err != nil {
-		return nil, fmt.Errorf("can't extract bucket name from URL: %w", err)
-	}
-	return loadSearchIndexAll(ctx, logger, storageClient, bucketName, rootStoragePath, cursor{Current: cursorValue})
+func LoadSearchIndexAllForCursor(ctx context.Context, logger *zap.Logger, store ObjectStore, rootStoragePath, cursorValue string) (*packages.Packages, error) {
+	return loadSearchIndexAll(ctx, logger, store, rootStoragePath, cursor{Current: cursorValue})
 }
@@ -0,0 +1,69 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+type localObjectStore struct {
+	root *os.Root
+}
+
+// NewLocalObjectStore returns an object store for a local directory, where object names are
+// paths relative to the directory. Objects outside of the directory cannot be accessed.
+func NewLocalObjectStore(dir string) (ObjectStore, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &localObjectStore{root: root}, nil
+}
+
+func (s *localObjectStore) NewReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	f, err := s.root.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %w", ErrObjectNotExist, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *localObjectStore) ListPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	// Prefixes are resolved in the directory of their last path element.
+	dir, _ := path.Split(prefix)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := fs.ReadDir(s.root.FS(), strings.TrimSuffix(dir, "/"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var prefixes []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := path.Join(dir, entry.Name()) + "/"
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		prefixes = append(prefixes, name)
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,94 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLocalObjectStore(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	writeFile("v2/metadata/cursor.json", `{"current":"2"}`)
+	writeFile("v2/metadata/1/search-index-delta.json", `{}`)
+	writeFile("v2/metadata/2/search-index-delta.json", `{"removed":[{"name":"mypkg","version":"1.0.0"}]}`)
+	writeFile("v2/metadata/3/search-index-delta.json", `{}`)
+	writeFile("secret.json", `{}`)
+
+	store, rootStoragePath, err := OpenObjectStore("file://"+filepath.ToSlash(dir), nil, S3Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "", rootStoragePath)
+
+	t.Run("read object", func(t *testing.T) {
+		reader, err := store.NewReader(t.Context(), "v2/metadata/cursor.json")
+		require.NoError(t, err)
+		defer reader.Close()
+		content, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, `{"current":"2"}`, string(content))
+	})
+
+	t.Run("read missing object", func(t *testing.T) {
+		_, err := store.NewReader(t.Context(), "v2/metadata/4/search-index-all.json")
+		assert.ErrorIs(t, err, ErrObjectNotExist)
+	})
+
+	t.Run("read object outside of the bucket", func(t *testing.T) {
+		_, err := store.NewReader(t.Context(), "../"+filepath.Base(dir)+"/secret.json")
+		assert.Error(t, err)
+	})
+
+	t.Run("list prefixes", func(t *testing.T) {
+		prefixes, err := store.ListPrefixes(t.Context(), "v2/metadata/")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"v2/metadata/1/", "v2/metadata/2/", "v2/metadata/3/"}, prefixes)
+
+		prefixes, err = store.ListPrefixes(t.Context(), "v2/metadata/3")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"v2/metadata/3/"}, prefixes)
+
+		prefixes, err = store.ListPrefixes(t.Context(), "v3/")
+		require.NoError(t, err)
+		assert.Empty(t, prefixes)
+	})
+
+	t.Run("load cursor", func(t *testing.T) {
+		c, err := loadCursor(t.Context(), zap.NewNop(), store, rootStoragePath)
+		require.NoError(t, err)
+		assert.Equal(t, "2", c.Current)
+	})
+
+	t.Run("list cursors", func(t *testing.T) {
+		cursors, err := listCursorsBetween(t.Context(), store, rootStoragePath, "1", "3")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2", "3"}, cursors)
+	})
+
+	t.Run("load delta", func(t *testing.T) {
+		delta, err := loadSearchIndexDelta(t.Context(), zap.NewNop(), store, rootStoragePath, cursor{Current: "2"})
+		require.NoError(t, err)
+		require.Len(t, delta.Removed, 1)
+		assert.Equal(t, "mypkg", delta.Removed[0].Name)
+	})
+}
+
+func TestOpenLocalObjectStore(t *testing.T) {
+	_, _, err := OpenObjectStore("file://remote-host/some/path", nil, S3Options{})
+	assert.Error(t, err)
+
+	_, _, err = OpenObjectStore("file://"+filepath.ToSlash(filepath.Join(t.TempDir(), "missing")), nil, S3Options{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,141 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// ErrObjectNotExist is returned by object stores when the requested object doesn't exist.
+var ErrObjectNotExist = errors.New("object doesn't exist")
+
+// ObjectStore provides read access to the objects of a bucket with the Package Storage v2 layout.
+type ObjectStore interface {
+	// NewReader returns a reader for the content of the object with the given name.
+	// It returns ErrObjectNotExist if the object doesn't exist.
+	NewReader(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// ListPrefixes returns the common prefixes, ending with "/", of the objects whose
+	// names start with the given prefix, as if the bucket was a file system and these
+	// prefixes were the subdirectories of the one given.
+	ListPrefixes(ctx context.Context, prefix string) ([]string, error)
+}
+
+// OpenObjectStore returns the object store for the bucket in the given URL, and the path
+// of the Package Storage root in the bucket. Buckets with the gs:// scheme are read with the
+// given Google Cloud Storage client, buckets with the s3:// scheme with a new S3 client, and
+// file:// URLs are read from a local directory.
+func OpenObjectStore(bucketURL string, storageClient *storage.Client, s3Options S3Options) (ObjectStore, string, error) {
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't parse bucket URL: %w", err)
+	}
+	if u.Scheme == "file" {
+		dir, err := localPathFromURL(u)
+		if err != nil {
+			return nil, "", err
+		}
+		store, err := NewLocalObjectStore(dir)
+		if err != nil {
+			return nil, "", fmt.Errorf("can't open local bucket: %w", err)
+		}
+		return store, "", nil
+	}
+
+	bucketName, rootStoragePath, err := extractBucketNameFromURL(bucketURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't extract bucket name from URL: %w", err)
+	}
+	if bucketName == "" {
+		return nil, "", fmt.Errorf("missing bucket name in URL %q", bucketURL)
+	}
+
+	switch u.Scheme {
+	case "gs":
+		if storageClient == nil {
+			return nil, "", errors.New("storage client required for gs:// buckets")
+		}
+		return NewGCSObjectStore(storageClient, bucketName), rootStoragePath, nil
+	case "s3":
+		store, err := NewS3ObjectStore(bucketName, s3Options)
+		if err != nil {
+			return nil, "", fmt.Errorf("can't create S3 client: %w", err)
+		}
+		return store, rootStoragePath, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported bucket URL scheme %q", u.Scheme)
+	}
+}
+
+// IsSupportedBucketURL returns true if the URL uses one of the schemes supported by OpenObjectStore.
+func IsSupportedBucketURL(bucketURL string) bool {
+	return strings.HasPrefix(bucketURL, "gs://") || strings.HasPrefix(bucketURL, "s3://") || strings.HasPrefix(bucketURL, "file://")
+}
+
+// localPathFromURL returns the local path of a file:// URL, only absolute paths in the
+// local host are supported.
+func localPathFromURL(u *url.URL) (string, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("unsupported host %q in file URL, only local paths are supported", u.Host)
+	}
+	if u.Path == "" {
+		return "", errors.New("missing path in file URL")
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+type gcsObjectStore struct {
+	bucket *storage.BucketHandle
+}
+
+// NewGCSObjectStore returns an object store for a Google Cloud Storage bucket.
+func NewGCSObjectStore(storageClient *storage.Client, bucketName string) ObjectStore {
+	return &gcsObjectStore{bucket: storageClient.Bucket(bucketName)}
+}
+
+func (s *gcsObjectStore) NewReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	reader, err := s.bucket.Object(name).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%w: %w", ErrObjectNotExist, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (s *gcsObjectStore) ListPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	query := &storage.Query{
+		Prefix:    prefix,
+		Delimiter: "/",
+	}
+
+	it := s.bucket.Objects(ctx, query)
+
+	var prefixes []string
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if attrs.Prefix == "" {
+			continue
+		}
+		prefixes = append(prefixes, attrs.Prefix)
+	}
+	return prefixes, nil
+}
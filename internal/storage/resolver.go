@@ -7,8 +7,12 @@ package storage
 import (
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
+	"strings"
 
 	"github.com/elastic/package-registry/packages"
 )
@@ -96,3 +100,70 @@ func (resolver storageResolver) SignaturesHandler(w http.ResponseWriter, r *http
 }
 
 var _ packages.RemoteResolver = new(storageResolver)
+
+// localResolver serves package artifacts and static files from a local directory with the
+// layout of the public Package Storage bucket.
+type localResolver struct {
+	artifactsPackages fs.FS
+	artifactsStatic   fs.FS
+}
+
+// NewLocalResolver returns a resolver serving artifacts from the artifacts/packages and
+// artifacts/static directories of the path in the given file:// URL.
+func NewLocalResolver(baseURL *url.URL) (packages.RemoteResolver, error) {
+	dir, err := localPathFromURL(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	artifactsPackages, err := fs.Sub(root.FS(), artifactsPackagesStoragePath)
+	if err != nil {
+		return nil, err
+	}
+	artifactsStatic, err := fs.Sub(root.FS(), artifactsStaticStoragePath)
+	if err != nil {
+		return nil, err
+	}
+	return localResolver{
+		artifactsPackages: artifactsPackages,
+		artifactsStatic:   artifactsStatic,
+	}, nil
+}
+
+func (resolver localResolver) serveFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) {
+	if !fs.ValidPath(name) {
+		http.NotFound(w, r)
+		return
+	}
+	info, err := fs.Stat(fsys, name)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFileFS(w, r, fsys, name)
+}
+
+func (resolver localResolver) ArtifactsHandler(w http.ResponseWriter, r *http.Request, p *packages.Package) {
+	nameVersionZip := fmt.Sprintf("%s-%s.zip", p.Name, p.Version)
+	resolver.serveFile(w, r, resolver.artifactsPackages, nameVersionZip)
+}
+
+func (resolver localResolver) StaticHandler(w http.ResponseWriter, r *http.Request, p *packages.Package, resourcePath string) {
+	nameVersion := fmt.Sprintf("%s-%s", p.Name, p.Version)
+	staticPath := path.Join(nameVersion, resourcePath)
+	if !strings.HasPrefix(staticPath, nameVersion+"/") {
+		http.NotFound(w, r)
+		return
+	}
+	resolver.serveFile(w, r, resolver.artifactsStatic, staticPath)
+}
+
+func (resolver localResolver) SignaturesHandler(w http.ResponseWriter, r *http.Request, p *packages.Package) {
+	nameVersionSigZip := fmt.Sprintf("%s-%s.zip.sig", p.Name, p.Version)
+	resolver.serveFile(w, r, resolver.artifactsPackages, nameVersionSigZip)
+}
+
+var _ packages.RemoteResolver = new(localResolver)
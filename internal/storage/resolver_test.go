@@ -0,0 +1,94 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package storage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/package-registry/packages"
+)
+
+func TestLocalResolver(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	writeFile("artifacts/packages/mypkg-1.0.0.zip", "zip content")
+	writeFile("artifacts/packages/mypkg-1.0.0.zip.sig", "signature")
+	writeFile("artifacts/static/mypkg-1.0.0/docs/README.md", "# mypkg")
+	writeFile("artifacts/static/otherpkg-1.0.0/docs/README.md", "# otherpkg")
+
+	resolver, err := NewLocalResolver(&url.URL{Scheme: "file", Path: filepath.ToSlash(dir)})
+	require.NoError(t, err)
+
+	pkg := &packages.Package{BasePackage: packages.BasePackage{Name: "mypkg", Version: "1.0.0"}}
+	missing := &packages.Package{BasePackage: packages.BasePackage{Name: "mypkg", Version: "2.0.0"}}
+
+	cases := []struct {
+		title    string
+		handler  func(w http.ResponseWriter, r *http.Request)
+		status   int
+		expected string
+	}{
+		{
+			title:    "artifact",
+			handler:  func(w http.ResponseWriter, r *http.Request) { resolver.ArtifactsHandler(w, r, pkg) },
+			status:   http.StatusOK,
+			expected: "zip content",
+		},
+		{
+			title:    "signature",
+			handler:  func(w http.ResponseWriter, r *http.Request) { resolver.SignaturesHandler(w, r, pkg) },
+			status:   http.StatusOK,
+			expected: "signature",
+		},
+		{
+			title:    "static file",
+			handler:  func(w http.ResponseWriter, r *http.Request) { resolver.StaticHandler(w, r, pkg, "docs/README.md") },
+			status:   http.StatusOK,
+			expected: "# mypkg",
+		},
+		{
+			title:   "missing artifact",
+			handler: func(w http.ResponseWriter, r *http.Request) { resolver.ArtifactsHandler(w, r, missing) },
+			status:  http.StatusNotFound,
+		},
+		{
+			title:   "static directory",
+			handler: func(w http.ResponseWriter, r *http.Request) { resolver.StaticHandler(w, r, pkg, "docs") },
+			status:  http.StatusNotFound,
+		},
+		{
+			title: "static file of other package",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				resolver.StaticHandler(w, r, pkg, "../otherpkg-1.0.0/docs/README.md")
+			},
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c.handler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, c.status, recorder.Code)
+			if c.expected != "" {
+				assert.Equal(t, c.expected, recorder.Body.String())
+				assert.NotEmpty(t, recorder.Header().Get("Last-Modified"))
+			}
+		})
+	}
+}
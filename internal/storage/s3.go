@@ -0,0 +1,100 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const defaultS3Endpoint = "s3.amazonaws.com"
+
+// S3Options are the options used to connect to S3-compatible services.
+type S3Options struct {
+	// Endpoint is the host, and optionally the port, of the service. Defaults to AWS S3.
+	Endpoint string
+
+	// Region of the bucket. If empty, it is discovered on the first request.
+	Region string
+
+	// Insecure enables the use of plain HTTP to connect to the service.
+	Insecure bool
+
+	// AccessKeyID and SecretAccessKey are static credentials to use. If not set, credentials
+	// are read from the AWS_* or MINIO_* environment variables, the AWS credentials file or
+	// the IAM role of the instance.
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// Transport is the HTTP transport used for requests, a default one is used if not set.
+	Transport http.RoundTripper
+}
+
+type s3ObjectStore struct {
+	client     minio.Core
+	bucketName string
+}
+
+// NewS3ObjectStore returns an object store for a bucket in an S3-compatible service.
+func NewS3ObjectStore(bucketName string, options S3Options) (ObjectStore, error) {
+	endpoint := options.Endpoint
+	if endpoint == "" {
+		endpoint = defaultS3Endpoint
+	}
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{},
+	})
+	if options.AccessKeyID != "" {
+		creds = credentials.NewStaticV4(options.AccessKeyID, options.SecretAccessKey, "")
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:     creds,
+		Secure:    !options.Insecure,
+		Region:    options.Region,
+		Transport: options.Transport,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3ObjectStore{
+		client:     minio.Core{Client: client},
+		bucketName: bucketName,
+	}, nil
+}
+
+func (s *s3ObjectStore) NewReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	reader, _, _, err := s.client.GetObject(ctx, s.bucketName, name, minio.GetObjectOptions{})
+	if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+		return nil, fmt.Errorf("%w: %w", ErrObjectNotExist, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (s *s3ObjectStore) ListPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	var prefixes []string
+	for object := range s.client.ListObjectsIter(ctx, s.bucketName, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		// Without recursion, common prefixes are listed as keys ending with the delimiter.
+		if len(object.Key) > 0 && object.Key[len(object.Key)-1] == '/' {
+			prefixes = append(prefixes, object.Key)
+		}
+	}
+	return prefixes, nil
+}
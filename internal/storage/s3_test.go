@@ -0,0 +1,83 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package storage
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestS3ObjectStore(t *testing.T) {
+	server := NewFakeS3Server()
+	t.Cleanup(server.Close)
+
+	server.PutObject("packages", "root/v2/metadata/cursor.json", []byte(`{"current":"2"}`))
+	server.PutObject("packages", "root/v2/metadata/1/search-index-delta.json", []byte(`{}`))
+	server.PutObject("packages", "root/v2/metadata/2/search-index-delta.json", []byte(`{}`))
+	server.PutObject("packages", "root/v2/metadata/3/search-index-delta.json", []byte(`{}`))
+
+	store, rootStoragePath, err := OpenObjectStore("s3://packages/root", nil, server.Options())
+	require.NoError(t, err)
+	assert.Equal(t, "root", rootStoragePath)
+
+	t.Run("read object", func(t *testing.T) {
+		reader, err := store.NewReader(t.Context(), "root/v2/metadata/cursor.json")
+		require.NoError(t, err)
+		defer reader.Close()
+		content, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, `{"current":"2"}`, string(content))
+	})
+
+	t.Run("read missing object", func(t *testing.T) {
+		_, err := store.NewReader(t.Context(), "root/v2/metadata/4/search-index-all.json")
+		assert.ErrorIs(t, err, ErrObjectNotExist)
+	})
+
+	t.Run("list prefixes", func(t *testing.T) {
+		prefixes, err := store.ListPrefixes(t.Context(), "root/v2/metadata/")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"root/v2/metadata/1/", "root/v2/metadata/2/", "root/v2/metadata/3/"}, prefixes)
+	})
+
+	t.Run("load cursor", func(t *testing.T) {
+		c, err := loadCursor(t.Context(), zap.NewNop(), store, rootStoragePath)
+		require.NoError(t, err)
+		assert.Equal(t, "2", c.Current)
+	})
+
+	t.Run("list cursors", func(t *testing.T) {
+		cursors, err := listCursorsBetween(t.Context(), store, rootStoragePath, "1", "3")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2", "3"}, cursors)
+	})
+
+	t.Run("missing bucket", func(t *testing.T) {
+		store, _, err := OpenObjectStore("s3://other", nil, server.Options())
+		require.NoError(t, err)
+		_, err = store.NewReader(t.Context(), "v2/metadata/cursor.json")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrObjectNotExist)
+	})
+}
+
+func TestOpenObjectStore(t *testing.T) {
+	_, _, err := OpenObjectStore("gs://bucket", nil, S3Options{})
+	assert.Error(t, err, "gs:// buckets require a storage client")
+
+	_, _, err = OpenObjectStore("s3://", nil, S3Options{})
+	assert.Error(t, err, "bucket name is required")
+
+	_, _, err = OpenObjectStore("ftp://bucket", nil, S3Options{})
+	assert.Error(t, err, "unsupported scheme")
+
+	_, rootStoragePath, err := OpenObjectStore("s3://bucket/some/prefix", nil, S3Options{Region: "eu-west-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "some/prefix", rootStoragePath)
+}
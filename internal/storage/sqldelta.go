@@ -0,0 +1,194 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"go.elastic.co/apm/v2"
+	"go.uber.org/zap"
+
+	"github.com/elastic/package-registry/internal/database"
+	"github.com/elastic/package-registry/metrics"
+	"github.com/elastic/package-registry/packages"
+)
+
+// sqlDelta contains the changes of a delta file, ready to be applied to the database.
+type sqlDelta struct {
+	cursor  string
+	removed []removedPackageRef
+	added   []*database.Package
+	changed packages.Packages
+}
+
+// applyDeltas updates the current database with the delta files of the revisions published
+// after the current cursor. It returns true if the index is up to date after the call, and
+// false if a full update is needed because some delta file is not available.
+func (i *SQLIndexer) applyDeltas(ctx context.Context) (bool, error) {
+	span, ctx := apm.StartSpan(ctx, "applyDeltas", "app")
+	defer span.End()
+
+	latestCursor, err := LoadLatestCursorValue(ctx, i.logger, i.store, i.rootStoragePath)
+	if err != nil {
+		return false, fmt.Errorf("can't load latest cursor: %w", err)
+	}
+	if latestCursor == i.cursor {
+		return true, nil
+	}
+
+	cursors, err := ListCursorsBetween(ctx, i.store, i.rootStoragePath, i.cursor, latestCursor)
+	if err != nil {
+		return false, fmt.Errorf("can't list cursors between %s and %s: %w", i.cursor, latestCursor, err)
+	}
+	if len(cursors) == 0 || cursors[len(cursors)-1] != latestCursor {
+		return false, fmt.Errorf("revisions between %s and %s not found", i.cursor, latestCursor)
+	}
+
+	// Deltas are loaded and prepared before locking the indexer.
+	deltas := make([]sqlDelta, 0, len(cursors))
+	for _, cursor := range cursors {
+		delta, err := LoadSearchIndexDelta(ctx, i.logger, i.store, i.rootStoragePath, cursor)
+		if err != nil {
+			i.logger.Info("Delta not available, a full index update is needed", zap.String("cursor", cursor), zap.Error(err))
+			return false, nil
+		}
+		prepared, err := i.prepareSQLDelta(ctx, cursor, delta)
+		if err != nil {
+			return false, fmt.Errorf("can't prepare delta for cursor %s: %w", cursor, err)
+		}
+		deltas = append(deltas, prepared)
+	}
+
+	start := time.Now()
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	numPackages, err := i.applySQLDeltas(ctx, deltas, latestCursor)
+	if err != nil {
+		metrics.StorageIndexerUpdateIndexErrorsTotal.Inc()
+		return false, err
+	}
+	i.cursor = latestCursor
+	i.numPackages = numPackages
+	i.logger.Info("Applied deltas to the index",
+		zap.String("cursor", latestCursor), zap.Int("num.deltas", len(deltas)), zap.Int("index.packages.size", numPackages),
+		zap.Duration("lock.duration", time.Since(start)))
+
+	i.notifyChanges(ctx, i.deltaFingerprints(deltas))
+
+	names := affectedPackageNames(deltas)
+	switch {
+	case i.options.AfterApplyDeltasHook != nil:
+		i.options.AfterApplyDeltasHook(ctx, names)
+	case i.afterUpdateHook != nil:
+		i.afterUpdateHook(ctx)
+	}
+
+	metrics.StorageIndexerUpdateIndexSuccessTotal.Inc()
+	metrics.NumberIndexedPackages.Set(float64(numPackages))
+	return true, nil
+}
+
+// prepareSQLDelta creates the database packages of the entries added or updated in a delta.
+func (i *SQLIndexer) prepareSQLDelta(ctx context.Context, cursor string, delta *SearchIndexDelta) (sqlDelta, error) {
+	prepared := sqlDelta{
+		cursor:  cursor,
+		removed: delta.Removed,
+	}
+	for _, entry := range slices.Concat(delta.Added, delta.Updated) {
+		pkg := entry.PackageManifest
+		dbPackage, err := createDatabasePackage(&pkg, i.packagesCursor)
+		if err != nil {
+			return sqlDelta{}, err
+		}
+		prepared.added = append(prepared.added, dbPackage)
+		prepared.changed = append(prepared.changed, &pkg)
+	}
+	i.setFieldNames(ctx, prepared.changed, prepared.added)
+	return prepared, nil
+}
+
+// applySQLDeltas applies the deltas to the current database in a single transaction, and
+// returns the resulting number of packages. Must be called with i.m held for writing.
+func (i *SQLIndexer) applySQLDeltas(ctx context.Context, deltas []sqlDelta, cursor string) (int, error) {
+	db := *i.current
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction in current database: %w", err)
+	}
+	defer tx.Rollback()
+
+	numPackages := i.numPackages
+	for _, delta := range deltas {
+		for _, ref := range delta.removed {
+			deleted, err := db.Delete(ctx, tx, "packages", ref.Name, ref.Version)
+			if err != nil {
+				return 0, err
+			}
+			numPackages -= int(deleted)
+		}
+		// Added and updated packages replace any existing row of the same version.
+		for _, pkg := range delta.added {
+			deleted, err := db.Delete(ctx, tx, "packages", pkg.Name, pkg.Version)
+			if err != nil {
+				return 0, err
+			}
+			numPackages -= int(deleted)
+		}
+		err := db.BulkAdd(ctx, tx, "packages", delta.added)
+		if err != nil {
+			return 0, fmt.Errorf("failed to add packages of delta %s: %w", delta.cursor, err)
+		}
+		numPackages += len(delta.added)
+
+		packages.UpdateLatestDeprecatedPackagesMapByName(delta.changed, i.deprecatedPackages)
+	}
+
+	err = i.writeIndexMetadata(ctx, db, tx, cursor, i.packagesCursor, numPackages)
+	if err != nil {
+		return 0, fmt.Errorf("can't persist index metadata: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction in current database: %w", err)
+	}
+	return numPackages, nil
+}
+
+// deltaFingerprints returns the fingerprints of the packages after applying the deltas, or
+// nil if they are not kept or the previous ones are not known.
+func (i *SQLIndexer) deltaFingerprints(deltas []sqlDelta) packages.PackageFingerprints {
+	if i.fingerprints == nil {
+		return nil
+	}
+	fingerprints := maps.Clone(i.fingerprints)
+	for _, delta := range deltas {
+		for _, ref := range delta.removed {
+			delete(fingerprints, packages.PackageKey{Name: ref.Name, Version: ref.Version})
+		}
+		for _, pkg := range delta.added {
+			fingerprints.Add(pkg.Name, pkg.Version, pkg.Data)
+		}
+	}
+	return fingerprints
+}
+
+// affectedPackageNames returns the sorted names of the packages changed by the deltas.
+func affectedPackageNames(deltas []sqlDelta) []string {
+	var names []string
+	for _, delta := range deltas {
+		for _, ref := range delta.removed {
+			names = append(names, ref.Name)
+		}
+		for _, pkg := range delta.added {
+			names = append(names, pkg.Name)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
@@ -22,6 +22,7 @@ import (
 	"go.elastic.co/apm/v2"
 	"go.uber.org/zap"
 
+	"github.com/elastic/package-registry/internal/contentcache"
 	"github.com/elastic/package-registry/internal/database"
 	"github.com/elastic/package-registry/metrics"
 	"github.com/elastic/package-registry/packages"
@@ -30,13 +31,24 @@ import (
 const (
 	indexerGetDurationPrometheusLabel = "SQLStorageIndexer"
 	defaultReadPackagesBatchSize      = 2000
+
+	// fieldsReadTimeout is the maximum time to get each fields file when indexing fields.
+	fieldsReadTimeout = 30 * time.Second
 )
 
 type SQLIndexer struct {
 	options       IndexerOptions
 	storageClient *storage.Client
 
-	cursor string
+	store           ObjectStore
+	rootStoragePath string
+
+	cursor      string
+	numPackages int
+
+	// packagesCursor is the cursor stored in the rows of the current database. It is the
+	// cursor of the last full index loaded, deltas applied later don't change it.
+	packagesCursor string
 
 	label string
 
@@ -44,6 +56,13 @@ type SQLIndexer struct {
 
 	resolver packages.RemoteResolver
 
+	// fieldNames keeps the names of the fields of the packages, nil if fields are not indexed.
+	fieldNames *packages.FieldNamesReader
+
+	// fingerprints of the packages of the current database, to find the changes of the next
+	// update. Only kept if there is an index changes hook.
+	fingerprints packages.PackageFingerprints
+
 	database     database.Repository
 	swapDatabase database.Repository
 
@@ -58,17 +77,42 @@ type SQLIndexer struct {
 
 	// in-memory storage of deprecated packages, updated with every index update
 	deprecatedPackages packages.DeprecatedPackages
+
+	status packages.IndexerStatusTracker
 }
 
 type IndexerOptions struct {
 	APMTracer                    *apm.Tracer
 	PackageStorageBucketInternal string
 	PackageStorageEndpoint       string
+	S3                           S3Options
 	WatchInterval                time.Duration
 	Database                     database.Repository
 	SwapDatabase                 database.Repository
 	ReadPackagesBatchsize        int
 	AfterUpdateIndexHook         func(ctx context.Context)
+
+	// IncrementalUpdates enables updating the current database with the delta files
+	// of the index, instead of loading the full index in the backup database.
+	IncrementalUpdates bool
+
+	// AfterApplyDeltasHook is called after applying deltas, with the names of the
+	// packages added, updated or removed. AfterUpdateIndexHook is used if not set.
+	AfterApplyDeltasHook func(ctx context.Context, packageNames []string)
+
+	// ContentCache, if set, caches the artifacts and static files served from the
+	// package storage endpoint.
+	ContentCache *contentcache.Cache
+
+	// IndexFields enables the indexing of the names of the fields of the packages, so they
+	// can be looked up by field. Fields files are read from the package storage endpoint.
+	IndexFields bool
+
+	// IndexChangesHook, if set, is called with the packages changed by each update of the
+	// index. It is not called for the initial load of the index, but it is called for the
+	// first update after restoring the index persisted by a previous run. It can be called
+	// holding the lock of the indexer, so it must not use the indexer.
+	IndexChangesHook packages.IndexChangesHook
 }
 
 func NewIndexer(logger *zap.Logger, storageClient *storage.Client, options IndexerOptions) *SQLIndexer {
@@ -85,6 +129,7 @@ func NewIndexer(logger *zap.Logger, storageClient *storage.Client, options Index
 		label:                 fmt.Sprintf("storage-%s", options.PackageStorageEndpoint),
 		readPackagesBatchSize: defaultReadPackagesBatchSize,
 		cursor:                "init",
+		packagesCursor:        "init",
 		afterUpdateHook:       options.AfterUpdateIndexHook,
 		deprecatedPackages:    make(packages.DeprecatedPackages),
 	}
@@ -96,6 +141,10 @@ func NewIndexer(logger *zap.Logger, storageClient *storage.Client, options Index
 		indexer.readPackagesBatchSize = options.ReadPackagesBatchsize
 	}
 
+	if options.IndexFields {
+		indexer.fieldNames = packages.NewFieldNamesReader(&http.Client{Timeout: fieldsReadTimeout})
+	}
+
 	return indexer
 }
 
@@ -107,11 +156,39 @@ func (i *SQLIndexer) Init(ctx context.Context) error {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
+	i.store, i.rootStoragePath, err = OpenObjectStore(i.options.PackageStorageBucketInternal, i.storageClient, i.options.S3)
+	if err != nil {
+		return fmt.Errorf("can't open bucket: %w", err)
+	}
+
 	err = i.setupResolver()
 	if err != nil {
 		return fmt.Errorf("can't setup remote resolver: %w", err)
 	}
 
+	// Reuse the index persisted by a previous run if possible. The backup database
+	// could contain partial or stale contents, so it is always cleaned.
+	restored, err := i.restoreDatabase(ctx)
+	if err != nil {
+		i.logger.Warn("Failed to restore index from database", zap.Error(err))
+	}
+	err = i.cleanBackupDatabase(ctx)
+	if err != nil {
+		return fmt.Errorf("can't clean backup database: %w", err)
+	}
+	if restored {
+		if i.options.IndexChangesHook != nil {
+			i.fingerprints, err = i.loadFingerprints(ctx)
+			if err != nil {
+				i.logger.Warn("Failed to read packages of the restored index, changes of the next update won't be notified", zap.Error(err))
+			}
+		}
+		// Serve the restored index while catching up in the background.
+		i.status.UpdateSucceeded(i.cursor, i.numPackages)
+		go i.watchIndices(apm.ContextWithTransaction(ctx, nil))
+		return nil
+	}
+
 	// Populate index file for the first time.
 	start := time.Now()
 	err = i.updateIndex(ctx)
@@ -125,7 +202,7 @@ func (i *SQLIndexer) Init(ctx context.Context) error {
 }
 
 func validateIndexerOptions(options IndexerOptions) error {
-	if !strings.HasPrefix(options.PackageStorageBucketInternal, "gs://") {
+	if !IsSupportedBucketURL(options.PackageStorageBucketInternal) {
 		return errors.New("missing or invalid options.PackageStorageBucketInternal")
 	}
 	_, err := url.Parse(options.PackageStorageEndpoint)
@@ -148,6 +225,11 @@ func (i *SQLIndexer) setupResolver() error {
 		return err
 	}
 
+	if baseURL.Scheme == "file" {
+		i.resolver, err = NewLocalResolver(baseURL)
+		return err
+	}
+
 	httpClient := http.Client{
 		Transport: &http.Transport{
 			DialContext: (&net.Dialer{
@@ -157,7 +239,7 @@ func (i *SQLIndexer) setupResolver() error {
 		},
 	}
 
-	i.resolver = NewStorageResolver(&httpClient, baseURL)
+	i.resolver = i.options.ContentCache.Resolver(NewStorageResolver(&httpClient, baseURL))
 	return nil
 }
 
@@ -194,17 +276,37 @@ func (i *SQLIndexer) watchIndices(ctx context.Context) {
 	}
 }
 
-func (i *SQLIndexer) updateIndex(ctx context.Context) error {
+func (i *SQLIndexer) updateIndex(ctx context.Context) (err error) {
 	span, ctx := apm.StartSpan(ctx, "UpdateIndex", "app")
 	span.Context.SetLabel("read.packages.batch.size", i.readPackagesBatchSize)
 	defer span.End()
 
+	defer func() {
+		if err != nil {
+			i.status.UpdateFailed(err)
+			return
+		}
+		i.m.RLock()
+		defer i.m.RUnlock()
+		i.status.UpdateSucceeded(i.cursor, i.numPackages)
+	}()
+
 	i.logger.Debug("Update indices")
 	start := time.Now()
 	defer func() {
 		metrics.StorageIndexerUpdateIndexDurationSeconds.Observe(time.Since(start).Seconds())
 	}()
 
+	if i.options.IncrementalUpdates && i.cursor != "init" {
+		applied, err := i.applyDeltas(ctx)
+		if err != nil {
+			i.logger.Warn("Failed to apply deltas, falling back to full index update", zap.Error(err))
+		}
+		if applied {
+			return nil
+		}
+	}
+
 	defer func(initialCursor string) {
 		if initialCursor == i.cursor {
 			return
@@ -218,11 +320,15 @@ func (i *SQLIndexer) updateIndex(ctx context.Context) error {
 	}(i.cursor)
 
 	numPackages := 0
-	currentCursor, err := LoadPackagesAndCursorFromIndexBatches(ctx, i.logger, i.storageClient, i.options.PackageStorageBucketInternal, i.cursor, i.readPackagesBatchSize,
+	var fingerprints packages.PackageFingerprints
+	if i.options.IndexChangesHook != nil {
+		fingerprints = make(packages.PackageFingerprints)
+	}
+	currentCursor, err := LoadPackagesAndCursorFromIndexBatches(ctx, i.logger, i.store, i.rootStoragePath, i.cursor, i.readPackagesBatchSize,
 		func(ctx context.Context, pkgs packages.Packages, newCursor string) error {
 			// This function is called for each batch of packages read from the index.
 			startUpdate := time.Now()
-			if err := i.updateDatabase(ctx, &pkgs, newCursor); err != nil {
+			if err := i.updateDatabase(ctx, &pkgs, newCursor, fingerprints); err != nil {
 				return fmt.Errorf("failed to update database: %w", err)
 			}
 			startDuration := time.Since(startUpdate)
@@ -241,14 +347,23 @@ func (i *SQLIndexer) updateIndex(ctx context.Context) error {
 	}
 	i.logger.Info("Downloaded new search-index-all index", zap.String("index.packages.size", fmt.Sprintf("%d", numPackages)))
 
+	err = i.persistIndexMetadata(ctx, *i.backup, currentCursor, numPackages)
+	if err != nil {
+		return fmt.Errorf("can't persist index metadata: %w", err)
+	}
+
 	startLock := time.Now()
 	i.swapDatabases(ctx, currentCursor, numPackages)
 	i.logger.Debug("Elapsed time in lock for updating index database", zap.Duration("lock.duration", time.Since(startLock)))
 
+	i.notifyChanges(ctx, fingerprints)
+
 	return nil
 }
 
-func (i *SQLIndexer) updateDatabase(ctx context.Context, index *packages.Packages, cursor string) error {
+// updateDatabase adds the packages to the backup database. The fingerprints of the packages
+// are also added to the given fingerprints, if not nil.
+func (i *SQLIndexer) updateDatabase(ctx context.Context, index *packages.Packages, cursor string, fingerprints packages.PackageFingerprints) error {
 	span, ctx := apm.StartSpan(ctx, "updateDatabase", "app")
 	defer span.End()
 
@@ -272,7 +387,11 @@ func (i *SQLIndexer) updateDatabase(ctx context.Context, index *packages.Package
 			}
 
 			dbPackages = append(dbPackages, newPackage)
+			if fingerprints != nil {
+				fingerprints.Add(newPackage.Name, newPackage.Version, newPackage.Data)
+			}
 		}
+		i.setFieldNames(ctx, (*index)[totalProcessed:totalProcessed+len(dbPackages)], dbPackages)
 		err := (*i.backup).BulkAdd(ctx, tx, "packages", dbPackages)
 		if err != nil {
 			return fmt.Errorf("failed to create all packages (bulk operation): %w", err)
@@ -292,6 +411,65 @@ func (i *SQLIndexer) updateDatabase(ctx context.Context, index *packages.Package
 	return nil
 }
 
+// setFieldNames reads the names of the fields of the packages and sets them in their database
+// packages, if fields are indexed. Packages whose fields cannot be read are indexed without fields.
+func (i *SQLIndexer) setFieldNames(ctx context.Context, pkgs packages.Packages, dbPackages []*database.Package) {
+	if i.fieldNames == nil {
+		return
+	}
+	for _, p := range pkgs {
+		p.BasePath = fmt.Sprintf("%s-%s.zip", p.Name, p.Version)
+		p.SetRemoteResolver(i.resolver)
+	}
+	err := i.fieldNames.Preload(ctx, pkgs)
+	if err != nil {
+		i.logger.Warn("failed to read fields of some packages, they won't be found by their fields", zap.Error(err))
+	}
+	for j, p := range pkgs {
+		dbPackages[j].Fields, _ = i.fieldNames.Loaded(ctx, p)
+	}
+}
+
+// loadFingerprints obtains the fingerprints of the packages of the current database.
+func (i *SQLIndexer) loadFingerprints(ctx context.Context) (packages.PackageFingerprints, error) {
+	i.m.RLock()
+	defer i.m.RUnlock()
+
+	fingerprints := make(packages.PackageFingerprints)
+	options := &database.SQLOptions{
+		CurrentCursor:   i.packagesCursor,
+		IncludeFullData: true,
+	}
+	err := (*i.current).AllFunc(ctx, "packages", options, func(ctx context.Context, p *database.Package) error {
+		fingerprints.Add(p.Name, p.Version, p.Data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fingerprints, nil
+}
+
+// notifyChanges calls the index changes hook with the packages changed since the previous
+// fingerprints, and keeps the new ones. Nothing is notified if there are no previous
+// fingerprints, as in the initial load of the index.
+func (i *SQLIndexer) notifyChanges(ctx context.Context, fingerprints packages.PackageFingerprints) {
+	if i.options.IndexChangesHook == nil {
+		return
+	}
+	previous := i.fingerprints
+	i.fingerprints = fingerprints
+	if previous == nil {
+		return
+	}
+	changes := previous.Changes(fingerprints)
+	if changes.Empty() {
+		return
+	}
+	changes.Indexer = indexerGetDurationPrometheusLabel
+	i.options.IndexChangesHook(ctx, changes)
+}
+
 func (i *SQLIndexer) cleanBackupDatabase(ctx context.Context) error {
 	span, ctx := apm.StartSpan(ctx, "cleanBackupDatabase", "app")
 	defer span.End()
@@ -301,6 +479,11 @@ func (i *SQLIndexer) cleanBackupDatabase(ctx context.Context) error {
 		return fmt.Errorf("failed to drop packages table: %w", err)
 	}
 
+	err = (*i.backup).Drop(ctx, "metadata")
+	if err != nil {
+		return fmt.Errorf("failed to drop metadata table: %w", err)
+	}
+
 	err = (*i.backup).Initialize(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to create schema in backup database: %w", err)
@@ -312,6 +495,8 @@ func (i *SQLIndexer) swapDatabases(ctx context.Context, currentCursor string, nu
 	i.m.Lock()
 	defer i.m.Unlock()
 	i.cursor = currentCursor
+	i.packagesCursor = currentCursor
+	i.numPackages = numPackages
 
 	i.current, i.backup = i.backup, i.current
 	i.logger.Debug("Current database changed", zap.String("current.database.path", (*i.current).File(ctx)), zap.String("previous.database.path", (*i.backup).File(ctx)))
@@ -373,6 +558,13 @@ func createDatabasePackage(pkg *packages.Package, cursor string) (*database.Pack
 		capabilities = strings.Join(pkg.Conditions.Elastic.Capabilities, ",")
 	}
 
+	ownerType := ""
+	if pkg.Owner != nil {
+		ownerType = pkg.Owner.Type
+	}
+
+	searchableText := packages.NewSearchableText(pkg)
+
 	newPackage := database.Package{
 		Cursor:                  cursor,
 		Name:                    pkg.Name,
@@ -387,12 +579,19 @@ func createDatabasePackage(pkg *packages.Package, cursor string) (*database.Pack
 		FormatVersionMajorMinor: formatVersionMajorMinor,
 		Path:                    fmt.Sprintf("%s-%s.zip", pkg.Name, pkg.Version),
 		Type:                    pkg.Type,
+		OwnerType:               ownerType,
+		Visibility:              pkg.Visibility,
 		Release:                 pkg.Release,
 		KibanaVersion:           kibanaVersion,
 		Capabilities:            capabilities,
 		Prerelease:              pkg.IsPrerelease(),
+		Title:                   searchableText.Title,
+		Description:             searchableText.Description,
+		PolicyTemplatesTitles:   searchableText.PolicyTemplatesTitles,
+		DataStreamsTitles:       searchableText.DataStreamsTitles,
 		Data:                    fullContents,
 		BaseData:                baseContents,
+		Datasets:                packages.PackageDatasets(pkg),
 	}
 
 	return &newPackage, nil
@@ -415,7 +614,7 @@ func (i *SQLIndexer) Get(ctx context.Context, opts *packages.GetOptions) (packag
 		i.m.RLock()
 		defer i.m.RUnlock()
 
-		options := createDatabaseOptions(i.cursor, opts)
+		options := createDatabaseOptions(i.packagesCursor, opts)
 
 		err := (*i.current).FilterFunc(ctx, "packages", options, func(ctx context.Context, p *database.Package) error {
 			pkg := &packages.Package{}
@@ -476,7 +675,7 @@ func (i *SQLIndexer) Get(ctx context.Context, opts *packages.GetOptions) (packag
 		}
 	}
 
-	return readPackages, nil
+	return opts.SelectPage(readPackages), nil
 }
 
 func createDatabaseOptions(cursor string, opts *packages.GetOptions) *database.SQLOptions {
@@ -493,10 +692,17 @@ func createDatabaseOptions(cursor string, opts *packages.GetOptions) *database.S
 	sqlOptions.IncludeFullData = opts.FullData
 	sqlOptions.SkipPackageData = opts.SkipPackageData
 
+	if paginateInDatabase(opts) {
+		sqlOptions.After = opts.After
+		sqlOptions.Limit = opts.Limit
+	}
+
 	if opts.Filter == nil {
 		return sqlOptions
 	}
 
+	sqlOptions.Restrictions = opts.Filter.Restrictions
+
 	if opts.Filter.Experimental {
 		// Experimental is also used in endpoints like /package or /epr to get a specific package.
 		// https://github.com/elastic/package-registry/blob/4b4eea9301902c15a75a8ef303c6e719f9ff6abd/packages/packages.go#L645
@@ -509,6 +715,9 @@ func createDatabaseOptions(cursor string, opts *packages.GetOptions) *database.S
 			Version: opts.Filter.PackageVersion,
 			// When experimental is set, prerelease should also be included.
 			Prerelease: true,
+			Query:      opts.Filter.Query,
+			Dataset:    opts.Filter.Dataset,
+			Field:      opts.Filter.Field,
 		}
 
 		if opts.Filter.KibanaVersion != nil {
@@ -528,6 +737,9 @@ func createDatabaseOptions(cursor string, opts *packages.GetOptions) *database.S
 		Version:      opts.Filter.PackageVersion,
 		Prerelease:   opts.Filter.Prerelease,
 		Capabilities: opts.Filter.Capabilities,
+		Query:        opts.Filter.Query,
+		Dataset:      opts.Filter.Dataset,
+		Field:        opts.Filter.Field,
 	}
 	if opts.Filter.KibanaVersion != nil {
 		sqlOptions.Filter.KibanaVersion = opts.Filter.KibanaVersion.String()
@@ -565,6 +777,45 @@ func createDatabaseOptions(cursor string, opts *packages.GetOptions) *database.S
 	return sqlOptions
 }
 
+// paginateInDatabase returns true if pagination can be done at database level. This is
+// not possible when some filters are only applied at application level, as they could
+// discard packages of the page selected by the database.
+func paginateInDatabase(opts *packages.GetOptions) bool {
+	if !opts.Paginated() {
+		return false
+	}
+	if opts.Filter == nil {
+		return true
+	}
+	return !opts.Filter.Experimental &&
+		opts.Filter.Category == "" &&
+		opts.Filter.AgentVersion == nil &&
+		opts.Filter.SpecMin == nil && opts.Filter.SpecMax == nil &&
+		opts.Filter.Discovery == nil &&
+		opts.Filter.Query == ""
+}
+
+// Status returns the status of the last updates of the index, including
+// the reachability of the database currently used to serve requests.
+func (i *SQLIndexer) Status(ctx context.Context) packages.IndexerStatus {
+	status := i.status.Status()
+	status.Name = indexerGetDurationPrometheusLabel
+	status.Periodic = i.options.WatchInterval > 0
+
+	i.m.RLock()
+	defer i.m.RUnlock()
+	dbStatus := packages.DatabaseStatus{
+		Path:      (*i.current).File(ctx),
+		Reachable: true,
+	}
+	if err := (*i.current).Ping(ctx); err != nil {
+		dbStatus.Reachable = false
+		dbStatus.Error = err.Error()
+	}
+	status.Database = &dbStatus
+	return status
+}
+
 func (i *SQLIndexer) Close(ctx context.Context) error {
 	err := i.database.Close(ctx)
 	errSwap := i.swapDatabase.Close(ctx)
@@ -8,11 +8,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"cloud.google.com/go/storage"
 	"github.com/Masterminds/semver/v3"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zapcore"
@@ -46,6 +49,145 @@ func TestSQLInit(t *testing.T) {
 
 	// then
 	require.NoError(t, err)
+
+	status := indexer.Status(t.Context())
+	assert.Equal(t, "1", status.Cursor)
+	assert.NotZero(t, status.PackagesCount)
+	assert.NotNil(t, status.LastSuccessfulUpdate)
+	require.NotNil(t, status.Database)
+	assert.True(t, status.Database.Reachable)
+}
+
+func TestSQLInitWithS3(t *testing.T) {
+	t.Parallel()
+
+	// given
+	db, err := database.NewMemorySQLDB(database.MemorySQLDBOptions{Path: "main-s3"})
+	require.NoError(t, err)
+
+	swapDb, err := database.NewMemorySQLDB(database.MemorySQLDBOptions{Path: "swap-s3"})
+	require.NoError(t, err)
+
+	options, err := CreateFakeIndexerOptions(db, swapDb)
+	require.NoError(t, err)
+
+	s3Server := PrepareFakeS3Server(t, "../../storage/testdata/search-index-all-small.json")
+	defer s3Server.Close()
+
+	options.PackageStorageBucketInternal = "s3://" + FakePackageStorageBucketInternal
+	options.S3 = s3Server.Options()
+
+	indexer := NewIndexer(util.NewTestLogger(), nil, options)
+	defer indexer.Close(t.Context())
+
+	// when
+	err = indexer.Init(t.Context())
+
+	// then
+	require.NoError(t, err)
+
+	status := indexer.Status(t.Context())
+	assert.Equal(t, "1", status.Cursor)
+	assert.NotZero(t, status.PackagesCount)
+}
+
+func TestSQLInitWithLocalBucket(t *testing.T) {
+	t.Parallel()
+
+	// given
+	db, err := database.NewMemorySQLDB(database.MemorySQLDBOptions{Path: "main-local"})
+	require.NoError(t, err)
+
+	swapDb, err := database.NewMemorySQLDB(database.MemorySQLDBOptions{Path: "swap-local"})
+	require.NoError(t, err)
+
+	options, err := CreateFakeIndexerOptions(db, swapDb)
+	require.NoError(t, err)
+	options.PackageStorageBucketInternal = PrepareLocalBucket(t, "../../storage/testdata/search-index-all-small.json")
+	options.PackageStorageEndpoint = "file://" + filepath.ToSlash(t.TempDir())
+
+	indexer := NewIndexer(util.NewTestLogger(), nil, options)
+	defer indexer.Close(t.Context())
+
+	// when
+	err = indexer.Init(t.Context())
+
+	// then
+	require.NoError(t, err)
+
+	status := indexer.Status(t.Context())
+	assert.Equal(t, "1", status.Cursor)
+	assert.NotZero(t, status.PackagesCount)
+	assert.IsType(t, localResolver{}, indexer.resolver)
+}
+
+func TestSQLInitRestoresDatabase(t *testing.T) {
+	t.Parallel()
+
+	folder := t.TempDir()
+	openIndexer := func(t *testing.T, storageClient *storage.Client, bucket string) *SQLIndexer {
+		db, err := database.NewFileSQLDB(database.FileSQLDBOptions{Path: filepath.Join(folder, "test.db")})
+		require.NoError(t, err)
+		swapDb, err := database.NewFileSQLDB(database.FileSQLDBOptions{Path: filepath.Join(folder, "swap_test.db")})
+		require.NoError(t, err)
+
+		options, err := CreateFakeIndexerOptions(db, swapDb)
+		require.NoError(t, err)
+		if bucket != "" {
+			options.PackageStorageBucketInternal = bucket
+		}
+		return NewIndexer(util.NewTestLogger(), storageClient, options)
+	}
+	getTitle := func(t *testing.T, indexer *SQLIndexer) string {
+		foundPackages, err := indexer.Get(t.Context(), &packages.GetOptions{
+			Filter: &packages.Filter{
+				PackageName: "1password",
+				PackageType: "integration",
+				Prerelease:  true,
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, foundPackages, 1)
+		return *foundPackages[0].Title
+	}
+
+	fs := PrepareFakeServer(t, "../../storage/testdata/search-index-all-small.json")
+	t.Cleanup(func() { fs.Stop() })
+
+	// First run populates the database.
+	indexer := openIndexer(t, ClientNoAuth(fs), "")
+	require.NoError(t, indexer.Init(t.Context()))
+	expectedCount := indexer.Status(t.Context()).PackagesCount
+	require.NoError(t, indexer.Close(t.Context()))
+
+	// Second run reuses the database, and catches up with the updates.
+	fs, _ = UpdateFakeServer(t, fs, "2", "../../storage/testdata/search-index-all-small-updated-fields.json")
+	indexer = openIndexer(t, ClientNoAuth(fs), "")
+	require.NoError(t, indexer.Init(t.Context()))
+
+	status := indexer.Status(t.Context())
+	assert.Equal(t, "1", status.Cursor)
+	assert.Equal(t, expectedCount, status.PackagesCount)
+	assert.Equal(t, "1Password Events Reporting", getTitle(t, indexer))
+
+	require.NoError(t, indexer.updateIndex(t.Context()))
+	assert.Equal(t, "2", indexer.Status(t.Context()).Cursor)
+	assert.Equal(t, "1Password Events Reporting UPDATED", getTitle(t, indexer))
+	require.NoError(t, indexer.Close(t.Context()))
+
+	// Databases obtained from other buckets are not reused.
+	indexer = openIndexer(t, nil, PrepareLocalBucket(t, "../../storage/testdata/search-index-all-small.json"))
+	_, err := indexer.loadPersistedIndex(t.Context(), &indexer.database)
+	assert.ErrorContains(t, err, "different bucket")
+	require.NoError(t, indexer.Close(t.Context()))
+
+	// Databases with a cursor newer than the latest one are not reused.
+	fs, _ = UpdateFakeServer(t, fs, "1", "../../storage/testdata/search-index-all-small.json")
+	indexer = openIndexer(t, ClientNoAuth(fs), "")
+	require.NoError(t, indexer.Init(t.Context()))
+	assert.Equal(t, "1", indexer.Status(t.Context()).Cursor)
+	assert.Equal(t, "1Password Events Reporting", getTitle(t, indexer))
+	require.NoError(t, indexer.Close(t.Context()))
 }
 
 func BenchmarkSQLInit(b *testing.B) {
@@ -110,7 +252,7 @@ func BenchmarkSQLIndexerUpdateIndex(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		b.StopTimer()
 		revision := fmt.Sprintf("%d", i+2)
-		fs, indexer.storageClient = UpdateFakeServer(b, fs, revision, "../../storage/testdata/search-index-all-full.json")
+		fs, indexer.store = UpdateFakeServer(b, fs, revision, "../../storage/testdata/search-index-all-full.json")
 		b.StartTimer()
 		start = time.Now()
 		err = indexer.updateIndex(b.Context())
@@ -498,7 +640,7 @@ func TestSQLGet_IndexUpdated(t *testing.T) {
 	require.Equal(t, "0.2.0", foundPackages[0].Version)
 
 	// when: index update is performed
-	fs, indexer.storageClient = UpdateFakeServer(t, fs, "2", "../../storage/testdata/search-index-all-full.json")
+	fs, indexer.store = UpdateFakeServer(t, fs, "2", "../../storage/testdata/search-index-all-full.json")
 	err = indexer.updateIndex(t.Context())
 	require.NoError(t, err, "index should be updated successfully")
 
@@ -517,7 +659,7 @@ func TestSQLGet_IndexUpdated(t *testing.T) {
 	require.Equal(t, "1.4.0", foundPackages[0].Version)
 
 	// when: index update is performed removing packages
-	fs, indexer.storageClient = UpdateFakeServer(t, fs, "3", "../../storage/testdata/search-index-all-small.json")
+	fs, indexer.store = UpdateFakeServer(t, fs, "3", "../../storage/testdata/search-index-all-small.json")
 	err = indexer.updateIndex(t.Context())
 	require.NoError(t, err, "index should be updated successfully")
 
@@ -537,7 +679,7 @@ func TestSQLGet_IndexUpdated(t *testing.T) {
 	require.Equal(t, "1Password Events Reporting", *foundPackages[0].Title)
 
 	// when: index update is performed updating some field of an existing package
-	fs, indexer.storageClient = UpdateFakeServer(t, fs, "4", "../../storage/testdata/search-index-all-small-updated-fields.json")
+	fs, indexer.store = UpdateFakeServer(t, fs, "4", "../../storage/testdata/search-index-all-small-updated-fields.json")
 	err = indexer.updateIndex(t.Context())
 	require.NoError(t, err, "index should be updated successfully")
 
@@ -559,6 +701,172 @@ func TestSQLGet_IndexUpdated(t *testing.T) {
 	require.Equal(t, "1Password Events Reporting UPDATED", *foundPackages[0].Title)
 }
 
+func TestSQLIncrementalUpdates(t *testing.T) {
+	t.Parallel()
+
+	readDelta := func(t *testing.T, name string) []byte {
+		content, err := os.ReadFile(filepath.Join("../../storage/testdata", name))
+		require.NoError(t, err)
+		return content
+	}
+	newIndexer := func(t *testing.T, fs *fakestorage.Server) *SQLIndexer {
+		db, err := database.NewMemorySQLDB(database.MemorySQLDBOptions{Path: "main-" + t.Name()})
+		require.NoError(t, err)
+		swapDb, err := database.NewMemorySQLDB(database.MemorySQLDBOptions{Path: "swap-" + t.Name()})
+		require.NoError(t, err)
+
+		options, err := CreateFakeIndexerOptions(db, swapDb)
+		require.NoError(t, err)
+		options.IncrementalUpdates = true
+
+		indexer := NewIndexer(util.NewTestLogger(), ClientNoAuth(fs), options)
+		t.Cleanup(func() { indexer.Close(context.Background()) })
+		require.NoError(t, indexer.Init(t.Context()))
+		return indexer
+	}
+	getVersions := func(t *testing.T, indexer *SQLIndexer) map[string]*packages.Package {
+		foundPackages, err := indexer.Get(t.Context(), &packages.GetOptions{
+			Filter: &packages.Filter{AllVersions: true, Prerelease: true, PackageName: "1password"},
+		})
+		require.NoError(t, err)
+		versions := make(map[string]*packages.Package)
+		for _, p := range foundPackages {
+			versions[p.Version] = p
+		}
+		return versions
+	}
+
+	t.Run("multiple deltas in order", func(t *testing.T) {
+		t.Parallel()
+
+		fs := PrepareFakeServer(t, "../../storage/testdata/search-index-all-small.json")
+		indexer := newIndexer(t, fs)
+		initialCount := indexer.Status(t.Context()).PackagesCount
+
+		var affected []string
+		indexer.options.AfterApplyDeltasHook = func(_ context.Context, packageNames []string) {
+			affected = packageNames
+		}
+		currentDatabase := indexer.current
+
+		fs, _ = UpdateFakeServerWithDelta(t, fs, "2", readDelta(t, "search-index-delta-add.json"))
+		fs, _ = UpdateFakeServerWithDelta(t, fs, "3", readDelta(t, "search-index-delta-remove.json"))
+		fs, indexer.store = UpdateFakeServerWithDelta(t, fs, "4", readDelta(t, "search-index-delta-update.json"))
+		t.Cleanup(fs.Stop)
+
+		require.NoError(t, indexer.updateIndex(t.Context()))
+
+		status := indexer.Status(t.Context())
+		assert.Equal(t, "4", status.Cursor)
+		assert.Equal(t, initialCount, status.PackagesCount, "one package added and one removed")
+		assert.Same(t, currentDatabase, indexer.current, "deltas must be applied to the current database")
+		assert.Equal(t, []string{"1password"}, affected)
+
+		versions := getVersions(t, indexer)
+		require.Len(t, versions, 2)
+		assert.Contains(t, versions, "0.3.0")
+		assert.NotContains(t, versions, "0.1.1")
+		require.Contains(t, versions, "0.2.0")
+		assert.Equal(t, "1Password Events Reporting UPDATED DELTA", *versions["0.2.0"].Title)
+
+		persisted, err := indexer.loadPersistedIndex(t.Context(), indexer.current)
+		require.NoError(t, err)
+		assert.Equal(t, "4", persisted.cursor)
+		assert.Equal(t, "1", persisted.packagesCursor)
+		assert.Equal(t, initialCount, persisted.numPackages)
+	})
+
+	t.Run("missing delta falls back to full update", func(t *testing.T) {
+		t.Parallel()
+
+		fs := PrepareFakeServer(t, "../../storage/testdata/search-index-all-small.json")
+		indexer := newIndexer(t, fs)
+		currentDatabase := indexer.current
+
+		fs, indexer.store = UpdateFakeServer(t, fs, "2", "../../storage/testdata/search-index-all-small-updated-fields.json")
+		t.Cleanup(fs.Stop)
+
+		require.NoError(t, indexer.updateIndex(t.Context()))
+
+		assert.Equal(t, "2", indexer.Status(t.Context()).Cursor)
+		assert.NotSame(t, currentDatabase, indexer.current, "full updates swap databases")
+		assert.Equal(t, "1Password Events Reporting UPDATED", *getVersions(t, indexer)["0.2.0"].Title)
+	})
+
+	t.Run("invalid delta is not applied", func(t *testing.T) {
+		t.Parallel()
+
+		fs := PrepareFakeServer(t, "../../storage/testdata/search-index-all-small.json")
+		indexer := newIndexer(t, fs)
+
+		fs, indexer.store = UpdateFakeServerWithDelta(t, fs, "2", []byte(`{invalid json`))
+		t.Cleanup(fs.Stop)
+
+		// Without a delta nor a full index for the new revision, the update fails.
+		assert.Error(t, indexer.updateIndex(t.Context()))
+		assert.Equal(t, "1", indexer.Status(t.Context()).Cursor)
+		assert.Len(t, getVersions(t, indexer), 2)
+	})
+}
+
+func TestSQLIndexChangesHook(t *testing.T) {
+	t.Parallel()
+
+	db, err := database.NewMemorySQLDB(database.MemorySQLDBOptions{Path: "main-" + t.Name()})
+	require.NoError(t, err)
+	swapDb, err := database.NewMemorySQLDB(database.MemorySQLDBOptions{Path: "swap-" + t.Name()})
+	require.NoError(t, err)
+
+	options, err := CreateFakeIndexerOptions(db, swapDb)
+	require.NoError(t, err)
+	options.IncrementalUpdates = true
+	var notified []packages.IndexChanges
+	options.IndexChangesHook = func(_ context.Context, changes packages.IndexChanges) {
+		notified = append(notified, changes)
+	}
+
+	fs := PrepareFakeServer(t, "../../storage/testdata/search-index-all-small.json")
+	indexer := NewIndexer(util.NewTestLogger(), ClientNoAuth(fs), options)
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+	require.NoError(t, indexer.Init(t.Context()))
+	assert.Empty(t, notified, "initial load is not notified")
+
+	// when: deltas are applied
+	content, err := os.ReadFile("../../storage/testdata/search-index-delta-add.json")
+	require.NoError(t, err)
+	fs, _ = UpdateFakeServerWithDelta(t, fs, "2", content)
+	content, err = os.ReadFile("../../storage/testdata/search-index-delta-remove.json")
+	require.NoError(t, err)
+	fs, _ = UpdateFakeServerWithDelta(t, fs, "3", content)
+	content, err = os.ReadFile("../../storage/testdata/search-index-delta-update.json")
+	require.NoError(t, err)
+	fs, indexer.store = UpdateFakeServerWithDelta(t, fs, "4", content)
+	require.NoError(t, indexer.updateIndex(t.Context()))
+
+	// then
+	require.Len(t, notified, 1)
+	assert.Equal(t, packages.IndexChanges{
+		Indexer: indexerGetDurationPrometheusLabel,
+		Added:   []packages.PackageKey{{Name: "1password", Version: "0.3.0"}},
+		Updated: []packages.PackageKey{{Name: "1password", Version: "0.2.0"}},
+		Removed: []packages.PackageKey{{Name: "1password", Version: "0.1.1"}},
+	}, notified[0])
+
+	// when: the full index is loaded again
+	fs, indexer.store = UpdateFakeServer(t, fs, "5", "../../storage/testdata/search-index-all-small.json")
+	t.Cleanup(fs.Stop)
+	require.NoError(t, indexer.updateIndex(t.Context()))
+
+	// then
+	require.Len(t, notified, 2)
+	assert.Equal(t, packages.IndexChanges{
+		Indexer: indexerGetDurationPrometheusLabel,
+		Added:   []packages.PackageKey{{Name: "1password", Version: "0.1.1"}},
+		Updated: []packages.PackageKey{{Name: "1password", Version: "0.2.0"}},
+		Removed: []packages.PackageKey{{Name: "1password", Version: "0.3.0"}},
+	}, notified[1])
+}
+
 func TestCreateDatabasePackage(t *testing.T) {
 	t.Parallel()
 
@@ -595,6 +903,7 @@ func TestCreateDatabasePackage(t *testing.T) {
 				FormatVersion:           "2.2.2",
 				FormatVersionMajorMinor: "2.2.0",
 				Type:                    "integration",
+				Description:             "My package description",
 				Path:                    "mypackage-1.2.3.zip",
 				Data:                    []byte(`{"name":"mypackage","version":"1.2.3","description":"My package description","type":"integration","download":"","path":"","conditions":{"kibana":{"version":"^8.17.0"}},"categories":["cat1","cat2"],"format_version":"2.2.2"}`),
 				BaseData:                []byte(`{"name":"mypackage","version":"1.2.3","description":"My package description","type":"integration","download":"","path":"","conditions":{"kibana":{"version":"^8.17.0"}},"categories":["cat1","cat2"]}`),
@@ -629,6 +938,7 @@ func TestCreateDatabasePackage(t *testing.T) {
 				FormatVersion:           "2.2.2",
 				FormatVersionMajorMinor: "2.2.0",
 				Type:                    "integration",
+				Description:             "My package description",
 				Path:                    "mypackage-1.2.3-beta1.zip",
 				Data:                    []byte(`{"name":"mypackage","version":"1.2.3-beta1","description":"My package description","type":"integration","download":"","path":"","conditions":{"kibana":{"version":"^8.17.0"}},"categories":["cat1","cat2"],"format_version":"2.2.2"}`),
 				BaseData:                []byte(`{"name":"mypackage","version":"1.2.3-beta1","description":"My package description","type":"integration","download":"","path":"","conditions":{"kibana":{"version":"^8.17.0"}},"categories":["cat1","cat2"]}`),
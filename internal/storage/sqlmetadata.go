@@ -0,0 +1,172 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"go.elastic.co/apm/v2"
+	"go.uber.org/zap"
+
+	"github.com/elastic/package-registry/internal/database"
+	"github.com/elastic/package-registry/metrics"
+	"github.com/elastic/package-registry/packages"
+)
+
+// Metadata entries stored in the databases once they contain a complete index.
+const (
+	metadataSchemaVersion      = "schema_version"
+	metadataBucket             = "bucket"
+	metadataCursor             = "cursor"
+	metadataPackagesCursor     = "packages_cursor"
+	metadataNumPackages        = "num_packages"
+	metadataDeprecatedPackages = "deprecated_packages"
+)
+
+// persistedIndex describes a complete index found in a database.
+type persistedIndex struct {
+	db                 *database.Repository
+	cursor             string
+	packagesCursor     string
+	numPackages        int
+	deprecatedPackages packages.DeprecatedPackages
+}
+
+// persistIndexMetadata stores in the database the metadata needed to reuse its index after a restart.
+func (i *SQLIndexer) persistIndexMetadata(ctx context.Context, db database.Repository, cursor string, numPackages int) error {
+	span, ctx := apm.StartSpan(ctx, "persistIndexMetadata", "app")
+	defer span.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Packages are stored with the cursor of the full index they were loaded from.
+	err = i.writeIndexMetadata(ctx, db, tx, cursor, cursor, numPackages)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// writeIndexMetadata sets the metadata of the index in the given transaction. The packages
+// cursor is the one stored in the rows of the packages, that doesn't change when deltas are applied.
+func (i *SQLIndexer) writeIndexMetadata(ctx context.Context, db database.Repository, tx *sql.Tx, cursor, packagesCursor string, numPackages int) error {
+	deprecatedPackages, err := json.Marshal(i.deprecatedPackages)
+	if err != nil {
+		return fmt.Errorf("failed to encode deprecated packages: %w", err)
+	}
+
+	metadata := map[string]string{
+		metadataSchemaVersion:      database.SchemaVersion,
+		metadataBucket:             i.options.PackageStorageBucketInternal,
+		metadataCursor:             cursor,
+		metadataPackagesCursor:     packagesCursor,
+		metadataNumPackages:        strconv.Itoa(numPackages),
+		metadataDeprecatedPackages: string(deprecatedPackages),
+	}
+	for key, value := range metadata {
+		if err := db.SetMetadata(ctx, tx, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPersistedIndex reads the metadata of the index persisted in the database. It fails if
+// the database doesn't contain a complete index compatible with the current configuration.
+func (i *SQLIndexer) loadPersistedIndex(ctx context.Context, db *database.Repository) (*persistedIndex, error) {
+	metadata := make(map[string]string)
+	for _, key := range []string{metadataSchemaVersion, metadataBucket, metadataCursor, metadataPackagesCursor, metadataNumPackages, metadataDeprecatedPackages} {
+		value, err := (*db).Metadata(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("can't read %s: %w", key, err)
+		}
+		metadata[key] = value
+	}
+
+	if v := metadata[metadataSchemaVersion]; v != database.SchemaVersion {
+		return nil, fmt.Errorf("schema version %q doesn't match current version %q", v, database.SchemaVersion)
+	}
+	if v := metadata[metadataBucket]; v != i.options.PackageStorageBucketInternal {
+		return nil, fmt.Errorf("index was obtained from a different bucket (%s)", v)
+	}
+
+	numPackages, err := strconv.Atoi(metadata[metadataNumPackages])
+	if err != nil {
+		return nil, fmt.Errorf("invalid number of packages: %w", err)
+	}
+	var deprecatedPackages packages.DeprecatedPackages
+	err = json.Unmarshal([]byte(metadata[metadataDeprecatedPackages]), &deprecatedPackages)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deprecated packages: %w", err)
+	}
+
+	return &persistedIndex{
+		db:                 db,
+		cursor:             metadata[metadataCursor],
+		packagesCursor:     metadata[metadataPackagesCursor],
+		numPackages:        numPackages,
+		deprecatedPackages: deprecatedPackages,
+	}, nil
+}
+
+// restoreDatabase reuses the index persisted in one of the databases by a previous run, if it
+// was obtained from the same bucket and its cursor is not newer than the latest one available.
+// If both databases contain a valid index, the most recent one is used.
+func (i *SQLIndexer) restoreDatabase(ctx context.Context) (bool, error) {
+	span, ctx := apm.StartSpan(ctx, "restoreDatabase", "app")
+	defer span.End()
+
+	latestCursor, err := LoadLatestCursorValue(ctx, i.logger, i.store, i.rootStoragePath)
+	if err != nil {
+		return false, fmt.Errorf("can't load latest cursor: %w", err)
+	}
+
+	var restored *persistedIndex
+	for _, db := range []*database.Repository{&i.database, &i.swapDatabase} {
+		index, err := i.loadPersistedIndex(ctx, db)
+		if err != nil {
+			i.logger.Debug("Database cannot be reused", zap.String("database.path", (*db).File(ctx)), zap.Error(err))
+			continue
+		}
+		// Cursors are lexicographically comparable in chronological order.
+		if index.cursor > latestCursor {
+			i.logger.Debug("Database contains an index newer than the latest one",
+				zap.String("database.path", (*db).File(ctx)), zap.String("cursor", index.cursor), zap.String("cursor.latest", latestCursor))
+			continue
+		}
+		if restored == nil || index.cursor > restored.cursor {
+			restored = index
+		}
+	}
+	if restored == nil {
+		return false, nil
+	}
+
+	i.m.Lock()
+	defer i.m.Unlock()
+	i.current = restored.db
+	if restored.db == &i.database {
+		i.backup = &i.swapDatabase
+	} else {
+		i.backup = &i.database
+	}
+	i.cursor = restored.cursor
+	i.packagesCursor = restored.packagesCursor
+	i.numPackages = restored.numPackages
+	i.deprecatedPackages = restored.deprecatedPackages
+
+	i.logger.Info("Restored index from database",
+		zap.String("database.path", (*i.current).File(ctx)), zap.String("cursor", i.cursor), zap.Int("num.packages", i.numPackages))
+	metrics.NumberIndexedPackages.Set(float64(i.numPackages))
+	return true, nil
+}
@@ -0,0 +1,119 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+
+	// CompressionMinSize is the minimum size of a response to compress it, smaller
+	// responses don't benefit from compression.
+	CompressionMinSize = 1024
+)
+
+// zstdEncoder is shared, EncodeAll can be used concurrently.
+var zstdEncoder = func() *zstd.Encoder {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		panic(err.Error())
+	}
+	return encoder
+}()
+
+// NegotiateEncoding selects the content encoding of a response, based on the value of the
+// Accept-Encoding header of the request. zstd is preferred over gzip when both are accepted
+// with the same quality. It returns an empty string if the response should not be compressed.
+func NegotiateEncoding(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+	for entry := range strings.SplitSeq(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(entry, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		quality := 1.0
+		for param := range strings.SplitSeq(params, ";") {
+			name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if !found || strings.ToLower(name) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				q = 0
+			}
+			quality = q
+		}
+		qualities[coding] = quality
+	}
+
+	quality := func(coding string) float64 {
+		if q, found := qualities[coding]; found {
+			return q
+		}
+		return qualities["*"]
+	}
+
+	selected, selectedQuality := "", 0.0
+	for _, coding := range []string{EncodingZstd, EncodingGzip} {
+		if q := quality(coding); q > selectedQuality {
+			selected, selectedQuality = coding, q
+		}
+	}
+	return selected
+}
+
+// Compress compresses the data with the given content encoding.
+func Compress(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingZstd:
+		return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/4)), nil
+	case EncodingGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// CompressibleContentType returns true for content types that benefit from compression.
+// Content types of already compressed formats, such as zip files or most images, are not
+// compressible.
+func CompressibleContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/yaml", "application/x-yaml", "application/xml",
+		"application/javascript", "image/svg+xml":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
@@ -0,0 +1,78 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", EncodingGzip},
+		{"gzip, deflate, br", EncodingGzip},
+		{"gzip, deflate, br, zstd", EncodingZstd},
+		{"zstd;q=0.5, gzip", EncodingGzip},
+		{"zstd;q=0, gzip;q=0.1", EncodingGzip},
+		{"GZIP;Q=0.8", EncodingGzip},
+		{"*", EncodingZstd},
+		{"*, zstd;q=0", EncodingGzip},
+		{"*;q=0", ""},
+		{"br", ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.acceptEncoding, func(t *testing.T) {
+			assert.Equal(t, c.expected, NegotiateEncoding(c.acceptEncoding))
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	data := []byte(strings.Repeat(`{"name": "nginx", "version": "1.0.0"}`, 100))
+
+	compressed, err := Compress(EncodingGzip, data)
+	require.NoError(t, err)
+	assert.Less(t, len(compressed), len(data))
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, data, decompressed)
+
+	compressed, err = Compress(EncodingZstd, data)
+	require.NoError(t, err)
+	assert.Less(t, len(compressed), len(data))
+	decoder, err := zstd.NewReader(nil)
+	require.NoError(t, err)
+	defer decoder.Close()
+	decompressed, err = decoder.DecodeAll(compressed, nil)
+	require.NoError(t, err)
+	assert.Equal(t, data, decompressed)
+
+	_, err = Compress("br", data)
+	assert.Error(t, err)
+}
+
+func TestCompressibleContentType(t *testing.T) {
+	assert.True(t, CompressibleContentType("application/json"))
+	assert.True(t, CompressibleContentType("text/markdown; charset=utf-8"))
+	assert.True(t, CompressibleContentType("image/svg+xml"))
+	assert.False(t, CompressibleContentType("application/zip"))
+	assert.False(t, CompressibleContentType("image/png"))
+	assert.False(t, CompressibleContentType(""))
+}
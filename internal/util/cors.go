@@ -6,15 +6,26 @@ package util
 
 import (
 	"net/http"
+	"slices"
 
 	"github.com/gorilla/mux"
 )
 
-// CORSMiddleware is a middleware used to add CORS related headers.
-func CORSMiddleware() mux.MiddlewareFunc {
+// CORSMiddleware is a middleware used to add CORS related headers. Any origin is allowed
+// if no allowed origins are given.
+func CORSMiddleware(allowedOrigins ...string) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
+			if len(allowedOrigins) == 0 {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			if origin := r.Header.Get("Origin"); origin != "" && slices.Contains(allowedOrigins, origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
 			next.ServeHTTP(w, r)
 		})
 	}
@@ -26,3 +26,31 @@ func TestCORSHeaders(t *testing.T) {
 	allowOrigin := recorder.Header().Values("Access-Control-Allow-Origin")
 	assert.Equal(t, []string{"*"}, allowOrigin)
 }
+
+func TestCORSHeadersAllowedOrigins(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/", func(http.ResponseWriter, *http.Request) {})
+	router.Use(CORSMiddleware("https://kibana.example.com"))
+
+	cases := []struct {
+		origin   string
+		expected []string
+	}{
+		{origin: "https://kibana.example.com", expected: []string{"https://kibana.example.com"}},
+		{origin: "https://other.example.com", expected: nil},
+		{origin: "", expected: nil},
+	}
+
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if c.origin != "" {
+			request.Header.Set("Origin", c.origin)
+		}
+
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, c.expected, recorder.Header().Values("Access-Control-Allow-Origin"), c.origin)
+		assert.Equal(t, []string{"Origin"}, recorder.Header().Values("Vary"))
+	}
+}
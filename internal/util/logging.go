@@ -5,6 +5,7 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
@@ -111,7 +112,7 @@ func LoggingMiddleware(logger *zap.Logger) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			switch r.RequestURI {
-			case "/health":
+			case "/health", "/health/live", "/health/ready":
 				// Do not log requests to these endpoints
 				next.ServeHTTP(w, r)
 			default:
@@ -128,8 +129,22 @@ func logRequest(logger *zap.Logger, handler http.Handler, w http.ResponseWriter,
 	logger.With(apmzap.TraceContext(req.Context())...).Info(message, fields...)
 }
 
+type requestUserKey struct{}
+
+// SetRequestUser sets the name of the user authenticated in a request, so it is included
+// in the log of the request.
+func SetRequestUser(req *http.Request, name string) {
+	if user, ok := req.Context().Value(requestUserKey{}).(*string); ok {
+		*user = name
+	}
+}
+
 // captureZapFieldsForRequest handles a request and captures fields for zap logger.
 func captureZapFieldsForRequest(handler http.Handler, w http.ResponseWriter, req *http.Request) (string, []zap.Field) {
+	// The user is set by inner handlers, once the request is authenticated.
+	var user string
+	req = req.WithContext(context.WithValue(req.Context(), requestUserKey{}, &user))
+
 	resp := httpsnoop.CaptureMetrics(handler, w, req)
 
 	domain, port, err := net.SplitHostPort(req.Host)
@@ -173,6 +188,9 @@ func captureZapFieldsForRequest(handler http.Handler, w http.ResponseWriter, req
 	if query := req.URL.RawQuery; query != "" {
 		fields = append(fields, zap.String("url.query", query))
 	}
+	if user != "" {
+		fields = append(fields, zap.String("user.name", user))
+	}
 	if port != "" {
 		if intPort, err := strconv.Atoi(port); err == nil && intPort != 0 {
 			fields = append(fields, zap.Int("url.port", intPort))
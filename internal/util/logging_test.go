@@ -80,6 +80,27 @@ func TestCaptureZapFieldsForRequest(t *testing.T) {
 				zap.String("url.path", "/search"),
 			},
 		},
+		{
+			title: "Authenticated user",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				SetRequestUser(req, "ci")
+				w.Write([]byte("Hello!"))
+			}),
+			method:          "GET",
+			url:             "http://epr.elastic.co/search",
+			remoteAddress:   "233.252.0.252:33442",
+			expectedMessage: "GET /search HTTP/1.1",
+			expectedFields: []zap.Field{
+				zap.Int64("http.response.code", 200),
+				zap.Int("http.response.body.bytes", 6),
+				zap.String("http.request.method", "GET"),
+				zap.String("source.address", "233.252.0.252"),
+				zap.String("source.ip", "233.252.0.252"),
+				zap.String("url.domain", "epr.elastic.co"),
+				zap.String("url.path", "/search"),
+				zap.String("user.name", "ci"),
+			},
+		},
 		{
 			title: "IPv4 address",
 			handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
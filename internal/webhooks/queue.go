@@ -0,0 +1,235 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package webhooks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	deliveryFileExt = ".json"
+
+	// tmpFilePrefix is the prefix of the files where deliveries are written before being
+	// moved to their final location.
+	tmpFilePrefix = ".tmp-"
+)
+
+// delivery is an event to deliver to an endpoint, as persisted in its queue.
+type delivery struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Event       string    `json:"event"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	Body        []byte    `json:"body"`
+
+	// file is the name of the file of the delivery in the directory of the queue.
+	file string
+}
+
+// queue keeps the deliveries pending to be sent to an endpoint, in order. Each delivery
+// is stored in a file, named so they are sorted in the order they were queued.
+type queue struct {
+	url string
+	dir string
+
+	// notify receives a value when a delivery is pushed.
+	notify chan struct{}
+
+	mu         sync.Mutex
+	deliveries []*delivery
+	seq        uint64
+}
+
+func newQueue(queuePath string, url string) *queue {
+	return &queue{
+		url:    url,
+		dir:    filepath.Join(queuePath, queueDirName(url)),
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// queueDirNameSize is the size of the hash of the URL of an endpoint used as name of the
+// directory of its queue.
+const queueDirNameSize = 8
+
+// queueDirName returns the name of the directory of the queue of an endpoint.
+func queueDirName(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:queueDirNameSize])
+}
+
+// isQueueDirName returns true if the name has the format of the names of the directories
+// of the queues.
+func isQueueDirName(name string) bool {
+	if len(name) != hex.EncodedLen(queueDirNameSize) {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil && strings.ToLower(name) == name
+}
+
+// removeStaleQueues removes the queues in the path that don't belong to any of the given
+// directories. Other files and directories in the path are kept.
+func removeStaleQueues(logger *zap.Logger, queuePath string, dirs map[string]struct{}) error {
+	err := os.MkdirAll(queuePath, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create webhooks queue directory: %w", err)
+	}
+	entries, err := os.ReadDir(queuePath)
+	if err != nil {
+		return fmt.Errorf("failed to read webhooks queue directory: %w", err)
+	}
+	for _, entry := range entries {
+		dir := filepath.Join(queuePath, entry.Name())
+		if _, found := dirs[dir]; found || !entry.IsDir() || !isQueueDirName(entry.Name()) {
+			continue
+		}
+		logger.Warn("Discarding pending deliveries of webhook not configured anymore", zap.String("path", dir))
+		err := os.RemoveAll(dir)
+		if err != nil {
+			return fmt.Errorf("failed to remove queue of webhook not configured anymore: %w", err)
+		}
+	}
+	return nil
+}
+
+// load reads the deliveries persisted in the directory of the queue.
+func (q *queue) load() error {
+	err := os.MkdirAll(q.dir, 0755)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return err
+	}
+
+	var deliveries []*delivery
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, tmpFilePrefix) {
+			// Not completely written, it was not queued.
+			os.Remove(filepath.Join(q.dir, name))
+			continue
+		}
+		if entry.IsDir() || filepath.Ext(name) != deliveryFileExt {
+			continue
+		}
+		d, err := os.ReadFile(filepath.Join(q.dir, name))
+		if err != nil {
+			return err
+		}
+		var delivery delivery
+		err = json.Unmarshal(d, &delivery)
+		if err != nil {
+			return fmt.Errorf("failed to decode delivery (file: %s): %w", name, err)
+		}
+		delivery.file = name
+		deliveries = append(deliveries, &delivery)
+	}
+	slices.SortFunc(deliveries, func(a, b *delivery) int {
+		return strings.Compare(a.file, b.file)
+	})
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.deliveries = deliveries
+	return nil
+}
+
+func (q *queue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.deliveries)
+}
+
+// push persists the delivery and adds it to the end of the queue.
+func (q *queue) push(d *delivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	d.URL = q.url
+	d.file = fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), q.seq%1000000, deliveryFileExt)
+	err := q.write(d)
+	if err != nil {
+		return err
+	}
+	q.deliveries = append(q.deliveries, d)
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// peek returns the first delivery of the queue.
+func (q *queue) peek() (*delivery, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.deliveries) == 0 {
+		return nil, false
+	}
+	return q.deliveries[0], true
+}
+
+// update persists the changes in the first delivery of the queue.
+func (q *queue) update(d *delivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.write(d)
+}
+
+// pop removes the first delivery from the queue.
+func (q *queue) pop(d *delivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.deliveries) > 0 && q.deliveries[0] == d {
+		q.deliveries = q.deliveries[1:]
+	}
+	err := os.Remove(filepath.Join(q.dir, d.file))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// write persists the delivery, replacing its file atomically so it is never left partially
+// written.
+func (q *queue) write(d *delivery) error {
+	content, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(q.dir, tmpFilePrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(content)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(q.dir, d.file))
+}
@@ -0,0 +1,397 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/elastic/package-registry/metrics"
+	"github.com/elastic/package-registry/packages"
+)
+
+const (
+	// EventIndexChanged is the type of the events sent when packages change in the index.
+	EventIndexChanged = "index.changed"
+
+	// SignatureHeader is the header with the HMAC-SHA256 signature of the body of the
+	// deliveries, in the format "sha256=<hex encoded signature>".
+	SignatureHeader = "X-EPR-Signature"
+
+	// DeliveryHeader is the header with the identifier of the delivery. It is kept between
+	// retries, so receivers can use it to discard duplicated deliveries.
+	DeliveryHeader = "X-EPR-Delivery"
+
+	// EventHeader is the header with the type of event delivered.
+	EventHeader = "X-EPR-Event"
+
+	defaultMaxAttempts = 10
+	defaultTimeout     = 10 * time.Second
+
+	initialBackoff = time.Second
+	maxBackoff     = 10 * time.Minute
+
+	// fingerprintsDirName is the directory in the queue path where the indexers persist the
+	// fingerprints of their packages. Its name cannot be confused with the ones of the queues.
+	fingerprintsDirName = "fingerprints"
+)
+
+// Config is the configuration of the webhooks notified when the index changes.
+type Config struct {
+	// QueuePath is the directory where the deliveries pending to be sent are persisted,
+	// so they are not lost on restarts.
+	QueuePath string `config:"queue_path"`
+
+	// MaxAttempts is the maximum number of attempts to deliver an event to an endpoint
+	// before discarding it.
+	MaxAttempts int `config:"max_attempts"`
+
+	// Endpoints are the webhooks notified.
+	Endpoints []EndpointConfig `config:"endpoints"`
+}
+
+// EndpointConfig is a webhook. The secret used to sign the deliveries can be set directly,
+// or read from a file.
+type EndpointConfig struct {
+	URL        string        `config:"url"`
+	Secret     string        `config:"secret"`
+	SecretFile string        `config:"secret_file"`
+	Timeout    time.Duration `config:"timeout"`
+}
+
+// Enabled returns true if any webhook is configured.
+func (c Config) Enabled() bool {
+	return len(c.Endpoints) > 0
+}
+
+// FingerprintsDir returns the directory where the indexers persist the fingerprints of their
+// packages, so the changes made while the registry is stopped are also notified.
+func (c Config) FingerprintsDir() string {
+	return filepath.Join(c.QueuePath, fingerprintsDirName)
+}
+
+// Event is the payload of the deliveries.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Indexer   string    `json:"indexer"`
+
+	Added   []PackageVersion `json:"added"`
+	Updated []PackageVersion `json:"updated"`
+	Removed []PackageVersion `json:"removed"`
+}
+
+// PackageVersion is a package version changed in the index.
+type PackageVersion struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Notifier sends the changes of the index to the configured webhooks. Deliveries are
+// persisted before being sent, and retried with exponential backoff until they succeed
+// or the maximum number of attempts is reached. Deliveries to each endpoint are sent in
+// order, one at a time.
+type Notifier struct {
+	logger      *zap.Logger
+	maxAttempts int
+
+	endpoints []*endpoint
+
+	// backoff returns the time to wait before the next attempt after the given number
+	// of failed attempts.
+	backoff func(attempts int) time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewNotifier creates a notifier for the given configuration, and loads the deliveries
+// pending from previous runs. Pending deliveries for endpoints that are not configured
+// anymore are discarded.
+func NewNotifier(logger *zap.Logger, config Config) (*Notifier, error) {
+	if config.QueuePath == "" {
+		return nil, errors.New("missing queue path")
+	}
+	maxAttempts := config.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	n := Notifier{
+		logger:      logger,
+		maxAttempts: maxAttempts,
+		backoff:     exponentialBackoff,
+	}
+	dirs := make(map[string]struct{})
+	for _, c := range config.Endpoints {
+		e, err := newEndpoint(c, config.QueuePath)
+		if err != nil {
+			return nil, err
+		}
+		if _, found := dirs[e.queue.dir]; found {
+			return nil, fmt.Errorf("webhook %s configured more than once", c.URL)
+		}
+		dirs[e.queue.dir] = struct{}{}
+		n.endpoints = append(n.endpoints, e)
+	}
+
+	err := removeStaleQueues(logger, config.QueuePath, dirs)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range n.endpoints {
+		err := e.queue.load()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load pending deliveries of webhook %s: %w", e.url, err)
+		}
+		if size := e.queue.len(); size > 0 {
+			logger.Info("Loaded pending webhook deliveries", zap.String("url", e.url), zap.Int("deliveries", size))
+		}
+	}
+	return &n, nil
+}
+
+type endpoint struct {
+	url    string
+	secret []byte
+	client *http.Client
+	queue  *queue
+}
+
+func newEndpoint(config EndpointConfig, queuePath string) (*endpoint, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook URL %q: %w", config.URL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q, expected an http or https URL", config.URL)
+	}
+
+	secret := config.Secret
+	if config.SecretFile != "" {
+		if secret != "" {
+			return nil, fmt.Errorf("secret and secret_file cannot be used at the same time in webhook %s", config.URL)
+		}
+		d, err := os.ReadFile(config.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret file of webhook %s: %w", config.URL, err)
+		}
+		secret = strings.TrimSpace(string(d))
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("empty secret for webhook %s", config.URL)
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &endpoint{
+		url:    config.URL,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: timeout},
+		queue:  newQueue(queuePath, config.URL),
+	}, nil
+}
+
+// Start starts sending the pending deliveries in the background.
+func (n *Notifier) Start(ctx context.Context) {
+	ctx, n.cancel = context.WithCancel(ctx)
+	for _, e := range n.endpoints {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			n.run(ctx, e)
+		}()
+	}
+}
+
+// Close stops sending deliveries. Deliveries not sent yet are kept in the queue, and
+// sent on the next start.
+func (n *Notifier) Close() {
+	if n.cancel != nil {
+		n.cancel()
+	}
+	n.wg.Wait()
+}
+
+// IndexChanged queues a delivery of the changes to each webhook. It can be used as index
+// changes hook of the indexers.
+func (n *Notifier) IndexChanged(_ context.Context, changes packages.IndexChanges) {
+	id, err := newID()
+	if err != nil {
+		n.logger.Error("failed to generate identifier of webhook event", zap.Error(err))
+		return
+	}
+	event := Event{
+		ID:        id,
+		Type:      EventIndexChanged,
+		Timestamp: time.Now().UTC(),
+		Indexer:   changes.Indexer,
+		Added:     packageVersions(changes.Added),
+		Updated:   packageVersions(changes.Updated),
+		Removed:   packageVersions(changes.Removed),
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		n.logger.Error("failed to encode webhook event", zap.Error(err))
+		return
+	}
+
+	for _, e := range n.endpoints {
+		d := delivery{
+			Event: EventIndexChanged,
+			Body:  body,
+		}
+		// Each endpoint has its own delivery of the event.
+		d.ID, err = newID()
+		if err == nil {
+			err = e.queue.push(&d)
+		}
+		if err != nil {
+			n.logger.Error("failed to queue webhook delivery, event lost",
+				zap.String("url", e.url), zap.String("event.id", event.ID), zap.Error(err))
+			continue
+		}
+		n.logger.Debug("Queued webhook delivery",
+			zap.String("url", e.url), zap.String("event.id", event.ID), zap.String("delivery.id", d.ID))
+	}
+}
+
+// run sends the deliveries queued for the endpoint until the context is done.
+func (n *Notifier) run(ctx context.Context, e *endpoint) {
+	logger := n.logger.With(zap.String("url", e.url))
+	for {
+		d, found := e.queue.peek()
+		if !found {
+			select {
+			case <-ctx.Done():
+				return
+			case <-e.queue.notify:
+				continue
+			}
+		}
+
+		if wait := time.Until(d.NextAttempt); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		err := e.send(ctx, d)
+		if ctx.Err() != nil {
+			// Interrupted, it will be sent again on next start.
+			return
+		}
+		if err == nil {
+			metrics.WebhookDeliveriesTotal.WithLabelValues("success").Inc()
+			logger.Debug("Webhook delivery sent", zap.String("delivery.id", d.ID))
+			if err := e.queue.pop(d); err != nil {
+				logger.Error("failed to remove sent webhook delivery from queue", zap.String("delivery.id", d.ID), zap.Error(err))
+			}
+			continue
+		}
+
+		d.Attempts++
+		if d.Attempts >= n.maxAttempts {
+			metrics.WebhookDeliveriesTotal.WithLabelValues("dropped").Inc()
+			logger.Error("Webhook delivery failed, maximum number of attempts reached, discarding it",
+				zap.String("delivery.id", d.ID), zap.Int("attempts", d.Attempts), zap.Error(err))
+			if err := e.queue.pop(d); err != nil {
+				logger.Error("failed to remove discarded webhook delivery from queue", zap.String("delivery.id", d.ID), zap.Error(err))
+			}
+			continue
+		}
+
+		metrics.WebhookDeliveriesTotal.WithLabelValues("failure").Inc()
+		d.NextAttempt = time.Now().Add(n.backoff(d.Attempts))
+		logger.Warn("Webhook delivery failed, it will be retried",
+			zap.String("delivery.id", d.ID), zap.Int("attempts", d.Attempts), zap.Time("next_attempt", d.NextAttempt), zap.Error(err))
+		if err := e.queue.update(d); err != nil {
+			logger.Error("failed to update webhook delivery in queue", zap.String("delivery.id", d.ID), zap.Error(err))
+		}
+	}
+}
+
+// send makes a request to the endpoint with the delivery, it fails if the response is not
+// successful.
+func (e *endpoint) send(ctx context.Context, d *delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(d.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(SignatureHeader, Sign(e.secret, d.Body))
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature of a body, as sent in the signature header.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// exponentialBackoff doubles the time to wait after each failed attempt, up to a maximum.
+func exponentialBackoff(attempts int) time.Duration {
+	backoff := initialBackoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+func packageVersions(keys []packages.PackageKey) []PackageVersion {
+	versions := make([]PackageVersion, 0, len(keys))
+	for _, key := range keys {
+		versions = append(versions, PackageVersion{Name: key.Name, Version: key.Version})
+	}
+	return versions
+}
+
+func newID() (string, error) {
+	var id [16]byte
+	_, err := rand.Read(id[:])
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
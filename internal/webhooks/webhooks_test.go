@@ -0,0 +1,355 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/elastic/package-registry/internal/util"
+	"github.com/elastic/package-registry/packages"
+)
+
+const testSecret = "s3cr3t"
+
+var testChanges = packages.IndexChanges{
+	Indexer: "FileSystemIndexer",
+	Added:   []packages.PackageKey{{Name: "nginx", Version: "1.1.0"}},
+	Removed: []packages.PackageKey{{Name: "nginx", Version: "1.0.0"}},
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// testReceiver is a webhook that responds with the given status codes, in order, and
+// with 200 once all of them are used.
+type testReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	codes    []int
+	requests []receivedRequest
+	received chan struct{}
+}
+
+func newTestReceiver(t *testing.T, codes ...int) *testReceiver {
+	r := testReceiver{
+		codes:    codes,
+		received: make(chan struct{}, 100),
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		assert.NoError(t, err)
+
+		r.mu.Lock()
+		r.requests = append(r.requests, receivedRequest{header: req.Header.Clone(), body: body})
+		code := http.StatusOK
+		if len(r.codes) > 0 {
+			code, r.codes = r.codes[0], r.codes[1:]
+		}
+		r.mu.Unlock()
+
+		w.WriteHeader(code)
+		r.received <- struct{}{}
+	}))
+	t.Cleanup(r.Close)
+	return &r
+}
+
+func (r *testReceiver) waitRequests(t *testing.T, count int) []receivedRequest {
+	t.Helper()
+	for range count {
+		select {
+		case <-r.received:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timeout waiting for webhook deliveries")
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+func newTestNotifier(t *testing.T, config Config) *Notifier {
+	t.Helper()
+	n, err := NewNotifier(util.NewTestLoggerLevel(zapcore.FatalLevel), config)
+	require.NoError(t, err)
+	n.backoff = func(int) time.Duration { return time.Millisecond }
+	return n
+}
+
+func queuedFiles(t *testing.T, queuePath string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(queuePath, "*", "*"+deliveryFileExt))
+	require.NoError(t, err)
+	return files
+}
+
+func TestNotifierDelivery(t *testing.T) {
+	receiver := newTestReceiver(t)
+	queuePath := t.TempDir()
+	n := newTestNotifier(t, Config{
+		QueuePath: queuePath,
+		Endpoints: []EndpointConfig{{URL: receiver.URL, Secret: testSecret}},
+	})
+	n.Start(t.Context())
+	t.Cleanup(n.Close)
+
+	n.IndexChanged(t.Context(), testChanges)
+	n.IndexChanged(t.Context(), packages.IndexChanges{
+		Indexer: "SQLStorageIndexer",
+		Updated: []packages.PackageKey{{Name: "apache", Version: "2.0.0"}},
+	})
+
+	requests := receiver.waitRequests(t, 2)
+	require.Len(t, requests, 2)
+
+	var events []Event
+	for _, req := range requests {
+		assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+		assert.Equal(t, EventIndexChanged, req.header.Get(EventHeader))
+		assert.NotEmpty(t, req.header.Get(DeliveryHeader))
+		assert.Equal(t, Sign([]byte(testSecret), req.body), req.header.Get(SignatureHeader))
+
+		var event Event
+		require.NoError(t, json.Unmarshal(req.body, &event))
+		events = append(events, event)
+	}
+
+	// Events are delivered in order.
+	assert.Equal(t, "FileSystemIndexer", events[0].Indexer)
+	assert.Equal(t, EventIndexChanged, events[0].Type)
+	assert.NotEmpty(t, events[0].ID)
+	assert.Equal(t, []PackageVersion{{Name: "nginx", Version: "1.1.0"}}, events[0].Added)
+	assert.Equal(t, []PackageVersion{}, events[0].Updated)
+	assert.Equal(t, []PackageVersion{{Name: "nginx", Version: "1.0.0"}}, events[0].Removed)
+
+	assert.Equal(t, "SQLStorageIndexer", events[1].Indexer)
+	assert.Equal(t, []PackageVersion{{Name: "apache", Version: "2.0.0"}}, events[1].Updated)
+
+	assert.Eventually(t, func() bool {
+		return len(queuedFiles(t, queuePath)) == 0
+	}, 5*time.Second, 10*time.Millisecond, "sent deliveries should be removed from the queue")
+}
+
+func TestNotifierRetries(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+	n := newTestNotifier(t, Config{
+		QueuePath: t.TempDir(),
+		Endpoints: []EndpointConfig{{URL: receiver.URL, Secret: testSecret}},
+	})
+	n.Start(t.Context())
+	t.Cleanup(n.Close)
+
+	n.IndexChanged(t.Context(), testChanges)
+
+	requests := receiver.waitRequests(t, 3)
+	require.Len(t, requests, 3)
+	deliveryID := requests[0].header.Get(DeliveryHeader)
+	for _, req := range requests {
+		assert.Equal(t, deliveryID, req.header.Get(DeliveryHeader))
+		assert.Equal(t, requests[0].body, req.body)
+	}
+}
+
+func TestNotifierMaxAttempts(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusInternalServerError, http.StatusInternalServerError)
+	queuePath := t.TempDir()
+	n := newTestNotifier(t, Config{
+		QueuePath:   queuePath,
+		MaxAttempts: 2,
+		Endpoints:   []EndpointConfig{{URL: receiver.URL, Secret: testSecret}},
+	})
+	n.Start(t.Context())
+	t.Cleanup(n.Close)
+
+	n.IndexChanged(t.Context(), testChanges)
+	n.IndexChanged(t.Context(), packages.IndexChanges{
+		Indexer: "SQLStorageIndexer",
+		Updated: []packages.PackageKey{{Name: "apache", Version: "2.0.0"}},
+	})
+
+	// The first event is discarded after two attempts, the second one is delivered.
+	requests := receiver.waitRequests(t, 3)
+	require.Len(t, requests, 3)
+	var event Event
+	require.NoError(t, json.Unmarshal(requests[2].body, &event))
+	assert.Equal(t, "SQLStorageIndexer", event.Indexer)
+
+	assert.Eventually(t, func() bool {
+		return len(queuedFiles(t, queuePath)) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestNotifierPersistentQueue(t *testing.T) {
+	receiver := newTestReceiver(t)
+	queuePath := t.TempDir()
+	config := Config{
+		QueuePath: queuePath,
+		Endpoints: []EndpointConfig{{URL: receiver.URL, Secret: testSecret}},
+	}
+
+	// Events are queued while the notifier is not running.
+	n := newTestNotifier(t, config)
+	n.IndexChanged(t.Context(), testChanges)
+	n.IndexChanged(t.Context(), testChanges)
+	n.Close()
+	assert.Len(t, queuedFiles(t, queuePath), 2)
+
+	// A partially written delivery is ignored.
+	dir := filepath.Dir(queuedFiles(t, queuePath)[0])
+	require.NoError(t, os.WriteFile(filepath.Join(dir, tmpFilePrefix+"partial"), []byte("{"), 0644))
+
+	n = newTestNotifier(t, config)
+	n.Start(t.Context())
+	t.Cleanup(n.Close)
+
+	requests := receiver.waitRequests(t, 2)
+	require.Len(t, requests, 2)
+	assert.NotEqual(t, requests[0].header.Get(DeliveryHeader), requests[1].header.Get(DeliveryHeader))
+	assert.Eventually(t, func() bool {
+		return len(queuedFiles(t, queuePath)) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoFileExists(t, filepath.Join(dir, tmpFilePrefix+"partial"))
+}
+
+func TestNotifierStaleQueues(t *testing.T) {
+	queuePath := t.TempDir()
+	n := newTestNotifier(t, Config{
+		QueuePath: queuePath,
+		Endpoints: []EndpointConfig{{URL: "http://old.example.com/hook", Secret: testSecret}},
+	})
+	n.IndexChanged(t.Context(), testChanges)
+	n.Close()
+	assert.Len(t, queuedFiles(t, queuePath), 1)
+
+	// Other content of the path is not removed.
+	otherDir := filepath.Join(queuePath, "content_cache")
+	require.NoError(t, os.Mkdir(otherDir, 0755))
+
+	newTestNotifier(t, Config{
+		QueuePath: queuePath,
+		Endpoints: []EndpointConfig{{URL: "http://new.example.com/hook", Secret: testSecret}},
+	})
+	assert.Empty(t, queuedFiles(t, queuePath))
+	assert.NoDirExists(t, filepath.Join(queuePath, queueDirName("http://old.example.com/hook")))
+	assert.DirExists(t, otherDir)
+}
+
+func TestIsQueueDirName(t *testing.T) {
+	assert.True(t, isQueueDirName(queueDirName("http://example.com/hook")))
+	assert.False(t, isQueueDirName("content_cache"))
+	assert.False(t, isQueueDirName("0123456789ABCDEF"))
+	assert.False(t, isQueueDirName("0123456789abcdef0"))
+	assert.False(t, isQueueDirName("0123456789abcdeg"))
+}
+
+func TestNotifierInterruptedDelivery(t *testing.T) {
+	blocked := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(blocked)
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	queuePath := t.TempDir()
+	n := newTestNotifier(t, Config{
+		QueuePath: queuePath,
+		Endpoints: []EndpointConfig{{URL: server.URL, Secret: testSecret}},
+	})
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	n.Start(ctx)
+	n.IndexChanged(ctx, testChanges)
+
+	<-blocked
+	n.Close()
+
+	// Deliveries interrupted on close are kept to be sent on next start.
+	assert.Len(t, queuedFiles(t, queuePath), 1)
+}
+
+func TestNewNotifierErrors(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte(testSecret+"\n"), 0600))
+
+	cases := []struct {
+		title  string
+		config Config
+		err    string
+	}{
+		{
+			title:  "missing queue path",
+			config: Config{Endpoints: []EndpointConfig{{URL: "http://example.com", Secret: testSecret}}},
+			err:    "missing queue path",
+		},
+		{
+			title:  "invalid scheme",
+			config: Config{QueuePath: t.TempDir(), Endpoints: []EndpointConfig{{URL: "ftp://example.com", Secret: testSecret}}},
+			err:    `invalid webhook URL "ftp://example.com", expected an http or https URL`,
+		},
+		{
+			title:  "missing secret",
+			config: Config{QueuePath: t.TempDir(), Endpoints: []EndpointConfig{{URL: "http://example.com"}}},
+			err:    "empty secret for webhook http://example.com",
+		},
+		{
+			title:  "secret and secret file",
+			config: Config{QueuePath: t.TempDir(), Endpoints: []EndpointConfig{{URL: "http://example.com", Secret: testSecret, SecretFile: secretFile}}},
+			err:    "secret and secret_file cannot be used at the same time in webhook http://example.com",
+		},
+		{
+			title: "duplicated endpoint",
+			config: Config{QueuePath: t.TempDir(), Endpoints: []EndpointConfig{
+				{URL: "http://example.com", Secret: testSecret},
+				{URL: "http://example.com", SecretFile: secretFile},
+			}},
+			err: "webhook http://example.com configured more than once",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			_, err := NewNotifier(util.NewTestLoggerLevel(zapcore.FatalLevel), c.config)
+			assert.EqualError(t, err, c.err)
+		})
+	}
+
+	t.Run("secret file", func(t *testing.T) {
+		n, err := NewNotifier(util.NewTestLoggerLevel(zapcore.FatalLevel), Config{
+			QueuePath: t.TempDir(),
+			Endpoints: []EndpointConfig{{URL: "http://example.com", SecretFile: secretFile}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []byte(testSecret), n.endpoints[0].secret)
+	})
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, exponentialBackoff(1))
+	assert.Equal(t, 2*time.Second, exponentialBackoff(2))
+	assert.Equal(t, 8*time.Second, exponentialBackoff(4))
+	assert.Equal(t, maxBackoff, exponentialBackoff(20))
+	assert.Equal(t, maxBackoff, exponentialBackoff(1000))
+}
@@ -0,0 +1,129 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"go.elastic.co/apm/module/apmzap/v2"
+	"go.uber.org/zap"
+
+	"github.com/elastic/package-registry/packages"
+)
+
+const (
+	lookupFieldsRouterPath   = "/lookup/fields"
+	lookupDatasetsRouterPath = "/lookup/datasets"
+)
+
+type lookupHandler struct {
+	logger    *zap.Logger
+	indexer   Indexer
+	cacheTime time.Duration
+}
+
+type lookupOption func(*lookupHandler)
+
+func newLookupHandler(logger *zap.Logger, indexer Indexer, cacheTime time.Duration, opts ...lookupOption) (*lookupHandler, error) {
+	if indexer == nil {
+		return nil, errors.New("indexer is required for lookup handler")
+	}
+	if cacheTime <= 0 {
+		return nil, errors.New("cache time must be greater than 0s")
+	}
+
+	h := &lookupHandler{
+		logger:    logger,
+		indexer:   indexer,
+		cacheTime: cacheTime,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h, nil
+}
+
+// fieldsHandler serves the packages that define a field with the given name in any of
+// their data streams.
+func (h *lookupHandler) fieldsHandler() http.Handler {
+	return h.handler(func(filter *packages.Filter, name string) {
+		filter.Field = name
+	})
+}
+
+// datasetsHandler serves the packages with a data stream with the given dataset.
+func (h *lookupHandler) datasetsHandler() http.Handler {
+	return h.handler(func(filter *packages.Filter, name string) {
+		filter.Dataset = name
+	})
+}
+
+func (h *lookupHandler) handler(setName func(filter *packages.Filter, name string)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := h.logger.With(apmzap.TraceContext(r.Context())...)
+
+		filter, name, err := newLookupFilterFromQuery(r.URL.Query())
+		if err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+		setName(filter, name)
+		filter.Restrictions = packages.AccessRestrictionsFromContext(r.Context())
+
+		pkgs, err := h.indexer.Get(r.Context(), &packages.GetOptions{Filter: filter})
+		if err != nil {
+			logger.Error("getting packages failed", zap.Error(err))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		data, err := getSearchOutput(r.Context(), pkgs, "")
+		if err != nil {
+			logger.Error("marshaling packages failed", zap.Error(err))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		serveJSONResponse(w, r, h.cacheTime, newJSONResponse(data))
+	})
+}
+
+// newLookupFilterFromQuery parses the query parameters of the lookup endpoints, it returns
+// the filter and the name looked up.
+func newLookupFilterFromQuery(query url.Values) (*packages.Filter, string, error) {
+	var filter packages.Filter
+	name := query.Get("name")
+	if name == "" {
+		return nil, "", errors.New("missing 'name' query param")
+	}
+
+	var err error
+	if v := query.Get("kibana.version"); v != "" {
+		filter.KibanaVersion, err = semver.NewVersion(v)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid Kibana version '%s': %w", v, err)
+		}
+	}
+	if v := query.Get("prerelease"); v != "" {
+		filter.Prerelease, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid 'prerelease' query param: '%s'", v)
+		}
+	}
+	if v := query.Get("all"); v != "" {
+		filter.AllVersions, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid 'all' query param: '%s'", v)
+		}
+	}
+	return &filter, name, nil
+}
@@ -0,0 +1,84 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/package-registry/packages"
+)
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	fsOpts := packages.FSIndexerOptions{
+		Logger: testLogger,
+	}
+	indexer := packages.NewFileSystemIndexer(fsOpts, "./testdata/package")
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+
+	err := indexer.Init(t.Context())
+	require.NoError(t, err)
+
+	lookupHandler, err := newLookupHandler(testLogger, indexer, testCacheTime)
+	require.NoError(t, err)
+	fieldsHandler := lookupHandler.fieldsHandler()
+	datasetsHandler := lookupHandler.datasetsHandler()
+
+	tests := []struct {
+		endpoint string
+		path     string
+		file     string
+		handler  http.Handler
+	}{
+		{"/lookup/datasets?name=example.foo", lookupDatasetsRouterPath, "lookup-datasets-example.json", datasetsHandler},
+		{"/lookup/datasets?name=example.foo&all=true", lookupDatasetsRouterPath, "lookup-datasets-example-all.json", datasetsHandler},
+		{"/lookup/datasets?name=example.foo&prerelease=true", lookupDatasetsRouterPath, "lookup-datasets-example-prerelease.json", datasetsHandler},
+		{"/lookup/datasets?name=datastream_without_release.nodes", lookupDatasetsRouterPath, "lookup-datasets-nodes.json", datasetsHandler},
+		{"/lookup/datasets?name=missing.dataset", lookupDatasetsRouterPath, "lookup-datasets-missing.json", datasetsHandler},
+		{"/lookup/datasets", lookupDatasetsRouterPath, "lookup-datasets-missing-name.txt", datasetsHandler},
+		{"/lookup/datasets?name=example.foo&kibana.version=a.b.c", lookupDatasetsRouterPath, "lookup-datasets-invalid-kibana-version.txt", datasetsHandler},
+		{"/lookup/fields?name=apache_spark.nodes.main.applications.count", lookupFieldsRouterPath, "lookup-fields-apache-spark.json", fieldsHandler},
+		{"/lookup/fields?name=data_stream.dataset", lookupFieldsRouterPath, "lookup-fields-data-stream-dataset.json", fieldsHandler},
+		{"/lookup/fields?name=data_stream.dataset&kibana.version=7.6.0", lookupFieldsRouterPath, "lookup-fields-data-stream-dataset-kibana-7.6.0.json", fieldsHandler},
+		{"/lookup/fields?name=missing.field", lookupFieldsRouterPath, "lookup-fields-missing.json", fieldsHandler},
+		{"/lookup/fields?name=data_stream.dataset&all=maybe", lookupFieldsRouterPath, "lookup-fields-invalid-all.txt", fieldsHandler},
+	}
+
+	for _, test := range tests {
+		t.Run(test.endpoint, func(t *testing.T) {
+			runEndpoint(t, test.endpoint, test.path, test.file, test.handler)
+		})
+	}
+}
+
+func TestLookupRoutes(t *testing.T) {
+	config := defaultConfig
+	indexer := packages.NewFileSystemIndexer(packages.FSIndexerOptions{Logger: testLogger}, "./testdata/package")
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+	require.NoError(t, indexer.Init(t.Context()))
+
+	router, err := getRouter(testLogger, serverOptions{
+		config:  &config,
+		indexer: indexer,
+	})
+	require.NoError(t, err)
+
+	for _, endpoint := range []string{
+		"/lookup/datasets?name=example.foo",
+		"/lookup/fields?name=apache_spark.nodes.main.applications.count",
+	} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, endpoint, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code, endpoint)
+		assert.NotEqual(t, "[]", recorder.Body.String(), endpoint)
+	}
+}
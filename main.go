@@ -39,9 +39,12 @@ import (
 
 	ucfgYAML "github.com/elastic/go-ucfg/yaml"
 
+	"github.com/elastic/package-registry/internal/auth"
+	"github.com/elastic/package-registry/internal/contentcache"
 	"github.com/elastic/package-registry/internal/database"
 	internalStorage "github.com/elastic/package-registry/internal/storage"
 	"github.com/elastic/package-registry/internal/util"
+	"github.com/elastic/package-registry/internal/webhooks"
 	"github.com/elastic/package-registry/metrics"
 	"github.com/elastic/package-registry/packages"
 	"github.com/elastic/package-registry/proxymode"
@@ -77,9 +80,13 @@ var (
 
 	featureStorageIndexer        bool
 	featureIncrementalUpdates    bool
+	featureIndexFields           bool
 	storageIndexerBucketInternal string
 	storageEndpoint              string
 	storageIndexerWatchInterval  time.Duration
+	storageIndexerS3Endpoint     string
+	storageIndexerS3Region       string
+	storageIndexerS3Insecure     bool
 
 	allowUnknownQueryParameters bool
 
@@ -104,6 +111,10 @@ var (
 		SearchCacheTTL:      24 * time.Hour,
 		CategoriesCacheSize: 100,
 		CategoriesCacheTTL:  24 * time.Hour,
+
+		ContentCache: ContentCacheConfig{
+			MaxSize: 10 * 1024 * 1024 * 1024, // 10GiB
+		},
 	}
 )
 
@@ -125,13 +136,18 @@ func init() {
 	flag.BoolVar(&allowUnknownQueryParameters, "allow-unknown-query-parameters", true, "Allow unknown query parameters in the request. If set to false, the server will return an error if any unknown query parameter is present in the request.")
 
 	flag.BoolVar(&featureStorageIndexer, "feature-storage-indexer", false, "Enable storage indexer to include packages from Package Storage v2.")
-	flag.StringVar(&storageIndexerBucketInternal, "storage-indexer-bucket-internal", "", "Path to the internal Package Storage bucket (with gs:// prefix).")
-	flag.StringVar(&storageEndpoint, "storage-endpoint", "https://package-storage.elastic.co/", "Package Storage public endpoint.")
+	flag.StringVar(&storageIndexerBucketInternal, "storage-indexer-bucket-internal", "", "Path to the internal Package Storage bucket (with gs://, s3:// or file:// prefix).")
+	flag.StringVar(&storageEndpoint, "storage-endpoint", "https://package-storage.elastic.co/", "Package Storage public endpoint (or file:// path to a local copy of its contents).")
 	flag.DurationVar(&storageIndexerWatchInterval, "storage-indexer-watch-interval", 1*time.Minute, "Address of the package-registry service.")
+	flag.StringVar(&storageIndexerS3Endpoint, "storage-indexer-s3-endpoint", "", "Endpoint of the S3-compatible service used for s3:// buckets (defaults to AWS S3).")
+	flag.StringVar(&storageIndexerS3Region, "storage-indexer-s3-region", "", "Region of the s3:// bucket (discovered automatically if empty).")
+	flag.BoolVar(&storageIndexerS3Insecure, "storage-indexer-s3-insecure", false, "Use plain HTTP to connect to the S3-compatible service. Use only in development or trusted environments.")
 	// The following storage related flags are technical preview and might be removed in the future or renamed
 	flag.BoolVar(&featureSQLStorageIndexer, "feature-sql-storage-indexer", false, "Enable SQL storage indexer to include packages from Package Storage v2 (technical preview).")
 	flag.BoolVar(&featureIncrementalUpdates, "feature-incremental-updates", false,
-		"Enable incremental index updates using delta files (technical preview). Not supported with the SQL storage indexer.")
+		"Enable incremental index updates using delta files (technical preview).")
+	flag.BoolVar(&featureIndexFields, "feature-storage-index-fields", false,
+		"Index the fields of the packages of the storage indexers to look up packages by field, reading their fields files from the storage endpoint (technical preview).")
 	flag.BoolVar(&featureEnableSearchCache, "feature-enable-search-cache", false, "Enable cache for search requests. Just supported with the SQL storage indexer. (technical preview).")
 	flag.BoolVar(&featureEnableCategoriesCache, "feature-enable-categories-cache", false, "Enable cache for categories requests. Just supported with the SQL storage indexer. (technical preview).")
 
@@ -146,18 +162,59 @@ func init() {
 }
 
 type Config struct {
-	PackagePaths                 []string      `config:"package_paths"`
-	CacheTimeIndex               time.Duration `config:"cache_time.index"`
-	CacheTimeSearch              time.Duration `config:"cache_time.search"`
-	CacheTimeCategories          time.Duration `config:"cache_time.categories"`
-	CacheTimeCatchAll            time.Duration `config:"cache_time.catch_all"`
-	SQLIndexerDatabaseFolderPath string        `config:"sql_indexer.database_folder_path"` // technical preview, used by the SQL storage indexer
-	SearchCacheSize              int           `config:"search.cache_size"`                // technical preview, used by the SQL storage indexer
-	SearchCacheTTL               time.Duration `config:"search.cache_ttl"`                 // technical preview, used by the SQL storage indexer
-	CategoriesCacheSize          int           `config:"categories.cache_size"`            // technical preview, used by the SQL storage indexer
-	CategoriesCacheTTL           time.Duration `config:"categories.cache_ttl"`             // technical preview, used by the SQL storage indexer
+	PackagePaths                 []string              `config:"package_paths"`
+	CacheTimeIndex               time.Duration         `config:"cache_time.index"`
+	CacheTimeSearch              time.Duration         `config:"cache_time.search"`
+	CacheTimeCategories          time.Duration         `config:"cache_time.categories"`
+	CacheTimeCatchAll            time.Duration         `config:"cache_time.catch_all"`
+	SQLIndexerDatabaseFolderPath string                `config:"sql_indexer.database_folder_path"` // technical preview, used by the SQL storage indexer
+	SearchCacheSize              int                   `config:"search.cache_size"`                // technical preview, used by the SQL storage indexer
+	SearchCacheTTL               time.Duration         `config:"search.cache_ttl"`                 // technical preview, used by the SQL storage indexer
+	CategoriesCacheSize          int                   `config:"categories.cache_size"`            // technical preview, used by the SQL storage indexer
+	CategoriesCacheTTL           time.Duration         `config:"categories.cache_ttl"`             // technical preview, used by the SQL storage indexer
+	HealthMaxIndexAge            time.Duration         `config:"health.max_index_age"`
+	CORSAllowedOrigins           []string              `config:"cors.allowed_origins"`
+	Auth                         auth.Config           `config:"auth"`
+	Visibility                   []packages.AccessRule `config:"visibility"`
+	Upload                       UploadConfig          `config:"upload"`
+	Signatures                   SignaturesConfig      `config:"signatures"`
+	Proxy                        ProxyConfig           `config:"proxy"`
+	ContentCache                 ContentCacheConfig    `config:"content_cache"`
+	Webhooks                     webhooks.Config       `config:"webhooks"`
+}
+
+// ContentCacheConfig is the configuration of the on-disk cache of the artifacts and static
+// files served from remote locations, as the package storage or the upstreams of the proxy mode.
+type ContentCacheConfig struct {
+	// Path is the directory where the cached files are stored. The cache is disabled if empty.
+	Path string `config:"path"`
+
+	// MaxSize is the maximum size in bytes of the cached files.
+	MaxSize int64 `config:"max_size"`
+}
+
+// ProxyConfig is the configuration of the proxy mode.
+type ProxyConfig struct {
+	// Upstreams are the registries included in the responses in proxy mode, in order of
+	// precedence. If empty, the endpoint in -proxy-to is used.
+	Upstreams []proxymode.UpstreamOptions `config:"upstreams"`
+}
+
+// SignaturesConfig is the configuration of the verification of the signatures of zipped packages.
+type SignaturesConfig struct {
+	// PublicKeys are the paths to the armored public keys trusted to sign packages.
+	PublicKeys []string `config:"public_keys"`
+
+	// OnInvalid is the action when a package has an invalid signature: "reject" to leave
+	// it out of the index, or "flag" to index it with the failed verification.
+	OnInvalid string `config:"on_invalid"`
 }
 
+const (
+	signaturesOnInvalidReject = "reject"
+	signaturesOnInvalidFlag   = "flag"
+)
+
 func main() {
 	err := parseFlags()
 	if err != nil {
@@ -203,6 +260,20 @@ func main() {
 		config:    config,
 	}
 
+	options.authenticator, err = initAuthenticator(logger, config)
+	if err != nil {
+		logger.Fatal("failed to initialize authentication", zap.Error(err))
+	}
+
+	options.accessRules, err = packages.NewAccessRules(config.Visibility)
+	if err != nil {
+		logger.Fatal("invalid visibility rules", zap.Error(err))
+	}
+
+	if err := validateProxyUpstreams(config.Proxy.Upstreams, proxyAllowInsecure); err != nil {
+		logger.Fatal("invalid proxy upstreams", zap.Error(err))
+	}
+
 	if dryRun {
 		logger.Info("Running dry-run mode")
 		indexer := initIndexer(ctx, logger, options)
@@ -231,10 +302,34 @@ func main() {
 		defer fakeServer.Stop()
 	}
 	if featureSQLStorageIndexer && featureEnableSearchCache {
-		options.searchCache = expirable.NewLRU[string, []byte](config.SearchCacheSize, nil, config.SearchCacheTTL)
+		options.searchCache = expirable.NewLRU[string, *jsonResponse](config.SearchCacheSize, nil, config.SearchCacheTTL)
 	}
 	if featureSQLStorageIndexer && featureEnableCategoriesCache {
-		options.categoriesCache = expirable.NewLRU[string, []byte](config.CategoriesCacheSize, nil, config.CategoriesCacheTTL)
+		options.categoriesCache = expirable.NewLRU[string, *jsonResponse](config.CategoriesCacheSize, nil, config.CategoriesCacheTTL)
+	}
+
+	options.signatureVerifier, err = initSignatureVerifier(config)
+	if err != nil {
+		logger.Fatal("failed to initialize signature verification", zap.Error(err))
+	}
+
+	options.contentCache, err = initContentCache(logger, config)
+	if err != nil {
+		logger.Fatal("failed to initialize content cache", zap.Error(err))
+	}
+
+	options.webhookNotifier, err = initWebhookNotifier(logger, config)
+	if err != nil {
+		logger.Fatal("failed to initialize webhooks", zap.Error(err))
+	}
+	if options.webhookNotifier != nil {
+		options.webhookNotifier.Start(ctx)
+		defer options.webhookNotifier.Close()
+	}
+
+	options.uploadIndexer, err = initUploadIndexer(logger, options)
+	if err != nil {
+		logger.Fatal("failed to initialize package uploads", zap.Error(err))
 	}
 
 	options.indexer = initIndexer(ctx, logger, options)
@@ -266,21 +361,8 @@ func initDatabase(ctx context.Context, logger *zap.Logger, databaseFolderPath, d
 
 	dbPath := filepath.Join(databaseFolderPath, dbFileName)
 
-	logger.Debug("Creating database", zap.String("path", dbPath))
-	exists := true
-	_, err := os.Stat(dbPath)
-	if errors.Is(err, os.ErrNotExist) {
-		exists = false
-	} else if err != nil {
-		return nil, err
-	}
-	if exists {
-		err = os.Remove(dbPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to delete previous database (path %q): %w", dbPath, err)
-		}
-	}
-
+	// Existing databases are kept, the indexer reuses their contents if they are still valid.
+	logger.Debug("Opening database", zap.String("path", dbPath))
 	options := database.FileSQLDBOptions{
 		Path: dbPath,
 	}
@@ -297,11 +379,21 @@ func initDatabase(ctx context.Context, logger *zap.Logger, databaseFolderPath, d
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database (path %q): %w", dbPath, err)
 	}
-	logger.Debug("Database created successfully", zap.String("path", dbPath))
+	logger.Debug("Database opened successfully", zap.String("path", dbPath))
 
 	return packageRepository, nil
 }
 
+func initAuthenticator(logger *zap.Logger, config *Config) (*auth.Authenticator, error) {
+	if !config.Auth.Enabled() {
+		return nil, nil
+	}
+	if len(config.Auth.ClientCertificateAuthorities) > 0 && (tlsCertFile == "" || tlsKeyFile == "") {
+		return nil, errors.New("client certificates authentication requires TLS, set -tls-cert and -tls-key")
+	}
+	return auth.NewAuthenticator(logger.Named("auth"), config.Auth)
+}
+
 func initHttpProf(logger *zap.Logger) {
 	if httpProfAddress == "" {
 		return
@@ -394,6 +486,9 @@ func initIndexer(ctx context.Context, logger *zap.Logger, options serverOptions)
 	switch {
 	case featureSQLStorageIndexer:
 		logger.Warn("Technical preview: SQL storage indexer is an experimental feature and it may be unstable.")
+		if featureIncrementalUpdates {
+			logger.Warn("Technical preview: Incremental updates feature is enabled.")
+		}
 		indexer, err := initSQLStorageIndexer(ctx, logger, options)
 		if err != nil {
 			logger.Fatal("failed to initialize SQL storage indexer", zap.Error(err))
@@ -410,16 +505,13 @@ func initIndexer(ctx context.Context, logger *zap.Logger, options serverOptions)
 		combined = append(combined, indexer)
 	}
 
-	fsOptions := packages.FSIndexerOptions{
-		Logger:             logger,
-		EnablePathsWatcher: packagePathsEnableWatcher,
-		APMTracer:          options.apmTracer,
-		PathsWorkers:       packagePathsWorkers,
-		RequireSignatures:  packageRequireSignatures,
-	}
+	fsOptions := fsIndexerOptions(logger, options)
 	logger.Debug("Using workers to read packages from package paths", zap.Int("workers", fsOptions.PathsWorkers))
 	logger.Debug("Watching package paths for changes", zap.Bool("enabled", fsOptions.EnablePathsWatcher))
 
+	if options.uploadIndexer != nil {
+		combined = append(combined, options.uploadIndexer)
+	}
 	combined = append(combined,
 		packages.NewZipFileSystemIndexer(fsOptions, packagesBasePaths...),
 		packages.NewFileSystemIndexer(fsOptions, packagesBasePaths...),
@@ -428,8 +520,97 @@ func initIndexer(ctx context.Context, logger *zap.Logger, options serverOptions)
 	return combined
 }
 
+func fsIndexerOptions(logger *zap.Logger, options serverOptions) packages.FSIndexerOptions {
+	return packages.FSIndexerOptions{
+		Logger:             logger,
+		EnablePathsWatcher: packagePathsEnableWatcher,
+		APMTracer:          options.apmTracer,
+		PathsWorkers:       packagePathsWorkers,
+		RequireSignatures:  packageRequireSignatures,
+
+		SignatureVerifier:       options.signatureVerifier,
+		RejectInvalidSignatures: options.config.Signatures.OnInvalid != signaturesOnInvalidFlag,
+
+		IndexChangesHook: indexChangesHook(options),
+		FingerprintsDir:  fingerprintsDir(options),
+	}
+}
+
+func initWebhookNotifier(logger *zap.Logger, config *Config) (*webhooks.Notifier, error) {
+	if !config.Webhooks.Enabled() {
+		return nil, nil
+	}
+	return webhooks.NewNotifier(logger.Named("webhooks"), config.Webhooks)
+}
+
+// indexChangesHook returns the hook that notifies the webhooks of the changes in the
+// indexers, nil if there are no webhooks.
+func indexChangesHook(options serverOptions) packages.IndexChangesHook {
+	if options.webhookNotifier == nil {
+		return nil
+	}
+	return options.webhookNotifier.IndexChanged
+}
+
+// fingerprintsDir returns the directory where the indexers persist the fingerprints of their
+// packages to notify the changes made while the registry is stopped, empty if there are no
+// webhooks.
+func fingerprintsDir(options serverOptions) string {
+	if options.webhookNotifier == nil {
+		return ""
+	}
+	return options.config.Webhooks.FingerprintsDir()
+}
+
+func initContentCache(logger *zap.Logger, config *Config) (*contentcache.Cache, error) {
+	if config.ContentCache.Path == "" {
+		return nil, nil
+	}
+	return contentcache.New(logger, contentcache.Options{
+		Path:    config.ContentCache.Path,
+		MaxSize: config.ContentCache.MaxSize,
+	})
+}
+
+func initSignatureVerifier(config *Config) (*packages.SignatureVerifier, error) {
+	switch config.Signatures.OnInvalid {
+	case "", signaturesOnInvalidReject, signaturesOnInvalidFlag:
+	default:
+		return nil, fmt.Errorf("invalid value for signatures.on_invalid %q, expected %q or %q",
+			config.Signatures.OnInvalid, signaturesOnInvalidReject, signaturesOnInvalidFlag)
+	}
+	if len(config.Signatures.PublicKeys) == 0 {
+		return nil, nil
+	}
+	return packages.NewSignatureVerifier(config.Signatures.PublicKeys...)
+}
+
+// initUploadIndexer creates the indexer of the packages uploaded with the API, if enabled.
+func initUploadIndexer(logger *zap.Logger, options serverOptions) (*packages.FileSystemIndexer, error) {
+	config := options.config
+	if config.Upload.Path == "" {
+		return nil, nil
+	}
+	if !config.Auth.Enabled() {
+		return nil, errors.New("uploading packages requires authentication to be configured")
+	}
+	info, err := os.Stat(config.Upload.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload path: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("upload path %s is not a directory", config.Upload.Path)
+	}
+	for _, path := range getPackagesBasePaths(config) {
+		if filepath.Clean(path) == filepath.Clean(config.Upload.Path) {
+			return nil, fmt.Errorf("upload path %s cannot be also a package path", config.Upload.Path)
+		}
+	}
+	return packages.NewZipFileSystemIndexer(fsIndexerOptions(logger, options), config.Upload.Path), nil
+}
+
 func initStorageIndexer(ctx context.Context, logger *zap.Logger, options serverOptions) (*storage.Indexer, error) {
-	storageClient, err := newStorageClient(ctx, logger)
+	storageClient, err := newBucketStorageClient(ctx, logger, storageIndexerBucketInternal)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create storage client: %w", err)
 	}
@@ -437,13 +618,18 @@ func initStorageIndexer(ctx context.Context, logger *zap.Logger, options serverO
 		APMTracer:                    options.apmTracer,
 		PackageStorageBucketInternal: storageIndexerBucketInternal,
 		PackageStorageEndpoint:       storageEndpoint,
+		S3:                           storageIndexerS3Options(),
 		WatchInterval:                storageIndexerWatchInterval,
 		IncrementalUpdates:           featureIncrementalUpdates,
+		ContentCache:                 options.contentCache,
+		IndexFields:                  featureIndexFields,
+		IndexChangesHook:             indexChangesHook(options),
+		FingerprintsDir:              fingerprintsDir(options),
 	}), nil
 }
 
 func initSQLStorageIndexer(ctx context.Context, logger *zap.Logger, options serverOptions) (*internalStorage.SQLIndexer, error) {
-	storageClient, err := newStorageClient(ctx, logger)
+	storageClient, err := newBucketStorageClient(ctx, logger, storageIndexerBucketInternal)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create storage client: %w", err)
 	}
@@ -461,9 +647,14 @@ func initSQLStorageIndexer(ctx context.Context, logger *zap.Logger, options serv
 		APMTracer:                    options.apmTracer,
 		PackageStorageBucketInternal: storageIndexerBucketInternal,
 		PackageStorageEndpoint:       storageEndpoint,
+		S3:                           storageIndexerS3Options(),
 		WatchInterval:                storageIndexerWatchInterval,
 		Database:                     storageDatabase,
 		SwapDatabase:                 storageSwapDatabase,
+		IncrementalUpdates:           featureIncrementalUpdates,
+		ContentCache:                 options.contentCache,
+		IndexFields:                  featureIndexFields,
+		IndexChangesHook:             indexChangesHook(options),
 		AfterUpdateIndexHook: func(context.Context) {
 			// Purge the caches after updating the index
 			// there could be new, updated or removed packages
@@ -481,6 +672,17 @@ func initSQLStorageIndexer(ctx context.Context, logger *zap.Logger, options serv
 				logger.Debug("Caches purged after updating the index")
 			}
 		},
+		AfterApplyDeltasHook: func(_ context.Context, packageNames []string) {
+			// Deltas change only some packages, search responses of other packages are still valid.
+			// Categories are counted over all packages, so their responses are always purged.
+			logger.Debug("Running after apply deltas hook", zap.Strings("packages", packageNames))
+			if options.searchCache != nil {
+				purgeSearchCacheForPackages(options.searchCache, packageNames)
+			}
+			if options.categoriesCache != nil {
+				options.categoriesCache.Purge()
+			}
+		},
 	}
 
 	if v, found := os.LookupEnv("EPR_SQL_INDEXER_READ_PACKAGES_BATCH_SIZE"); found && v != "" {
@@ -494,7 +696,20 @@ func initSQLStorageIndexer(ctx context.Context, logger *zap.Logger, options serv
 	return internalStorage.NewIndexer(logger, storageClient, indexerOptions), nil
 }
 
-func newStorageClient(ctx context.Context, logger *zap.Logger) (*gstorage.Client, error) {
+func storageIndexerS3Options() internalStorage.S3Options {
+	return internalStorage.S3Options{
+		Endpoint: storageIndexerS3Endpoint,
+		Region:   storageIndexerS3Region,
+		Insecure: storageIndexerS3Insecure,
+	}
+}
+
+// newBucketStorageClient returns the Google Cloud Storage client for gs:// buckets, other
+// buckets don't need it.
+func newBucketStorageClient(ctx context.Context, logger *zap.Logger, bucketURL string) (*gstorage.Client, error) {
+	if !strings.HasPrefix(bucketURL, "gs://") {
+		return nil, nil
+	}
 	opts := []option.ClientOption{}
 	if emulatorHost := os.Getenv("STORAGE_EMULATOR_HOST"); emulatorHost != "" {
 		// https://pkg.go.dev/cloud.google.com/go/storage#hdr-Creating_a_Client
@@ -510,8 +725,15 @@ type serverOptions struct {
 	apmTracer       *apm.Tracer
 	config          *Config
 	indexer         Indexer
-	searchCache     *expirable.LRU[string, []byte]
-	categoriesCache *expirable.LRU[string, []byte]
+	searchCache     *expirable.LRU[string, *jsonResponse]
+	categoriesCache *expirable.LRU[string, *jsonResponse]
+	authenticator   *auth.Authenticator
+	accessRules     packages.AccessRules
+	uploadIndexer   *packages.FileSystemIndexer
+
+	signatureVerifier *packages.SignatureVerifier
+	contentCache      *contentcache.Cache
+	webhookNotifier   *webhooks.Notifier
 }
 
 func initServer(logger *zap.Logger, options serverOptions) *http.Server {
@@ -522,6 +744,9 @@ func initServer(logger *zap.Logger, options serverOptions) *http.Server {
 	tlsConfig := tls.Config{
 		MinVersion: effectiveTLSMinVersion(tlsMinVersionValue, isFIPSBinary()),
 	}
+	if options.authenticator != nil {
+		options.authenticator.ConfigureTLS(&tlsConfig)
+	}
 
 	return &http.Server{Addr: address, Handler: router, TLSConfig: &tlsConfig}
 }
@@ -623,6 +848,37 @@ func printConfig(logger *zap.Logger, config *Config) {
 	logger.Info("Cache time for /search: " + config.CacheTimeSearch.String())
 	logger.Info("Cache time for /categories: " + config.CacheTimeCategories.String())
 	logger.Info("Cache time for all others: " + config.CacheTimeCatchAll.String())
+	if config.HealthMaxIndexAge > 0 {
+		logger.Info("Maximum index age for readiness: " + config.HealthMaxIndexAge.String())
+	}
+	if len(config.CORSAllowedOrigins) > 0 {
+		logger.Info("CORS allowed origins: " + strings.Join(config.CORSAllowedOrigins, ", "))
+	}
+	if config.Auth.Enabled() {
+		logger.Info("Authentication enabled, paths exempt from authentication: " + strings.Join(config.Auth.ExemptPaths, ", "))
+	}
+	if len(config.Visibility) > 0 {
+		logger.Info("Visibility rules configured: " + strconv.Itoa(len(config.Visibility)))
+	}
+	if config.Upload.Path != "" {
+		logger.Info("Package uploads enabled, path: " + config.Upload.Path)
+	}
+	if len(config.Signatures.PublicKeys) > 0 {
+		logger.Info("Signatures of zipped packages verified with public keys: " + strings.Join(config.Signatures.PublicKeys, ", "))
+	}
+	if config.ContentCache.Path != "" {
+		logger.Info("Content cache enabled, path: " + config.ContentCache.Path + ", maximum size in bytes: " + strconv.FormatInt(config.ContentCache.MaxSize, 10))
+	}
+	if featureProxyMode {
+		var upstreams []string
+		for _, upstream := range config.Proxy.Upstreams {
+			upstreams = append(upstreams, upstream.URL)
+		}
+		if len(upstreams) == 0 {
+			upstreams = append(upstreams, proxyTo)
+		}
+		logger.Info("Proxy mode upstreams: " + strings.Join(upstreams, ", "))
+	}
 
 	if featureSQLStorageIndexer {
 		logger.Info("(technical preview) SQL storage indexer database path: " + config.SQLIndexerDatabaseFolderPath)
@@ -673,8 +929,10 @@ func getRouter(logger *zap.Logger, options serverOptions) (*mux.Router, error) {
 		logger.Info("Technical preview: Proxy mode is an experimental feature and it may be unstable.")
 	}
 	proxyMode, err := proxymode.NewProxyMode(logger, proxymode.ProxyOptions{
-		Enabled: featureProxyMode,
-		ProxyTo: proxyTo,
+		Enabled:      featureProxyMode,
+		ProxyTo:      proxyTo,
+		Upstreams:    options.config.Proxy.Upstreams,
+		ContentCache: options.contentCache,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("can't create proxy mode: %w", err)
@@ -701,7 +959,16 @@ func getRouter(logger *zap.Logger, options serverOptions) (*mux.Router, error) {
 		return nil, fmt.Errorf("can't create index handler: %w", err)
 	}
 
-	healthHandler := newHealthHandler()
+	healthHandler := newHealthHandler(
+		healthWithIndexer(options.indexer),
+		healthWithMaxIndexAge(options.config.HealthMaxIndexAge),
+	)
+	liveHandler := newHealthHandler()
+	readyHandler := newHealthHandler(
+		healthWithIndexer(options.indexer),
+		healthWithMaxIndexAge(options.config.HealthMaxIndexAge),
+		healthWithReadiness(),
+	)
 
 	categoriesHandler, err := newCategoriesHandler(logger, options.indexer, options.config.CacheTimeCategories,
 		categoriesWithProxy(proxyMode),
@@ -726,6 +993,32 @@ func getRouter(logger *zap.Logger, options serverOptions) (*mux.Router, error) {
 	if err != nil {
 		return nil, fmt.Errorf("can't create search handler: %w", err)
 	}
+	changelogHandler, err := newChangelogHandler(logger, options.indexer, options.config.CacheTimeCatchAll,
+		changelogWithProxy(proxyMode),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("can't create changelog handler: %w", err)
+	}
+	fieldsHandler, err := newFieldsHandler(logger, options.indexer, options.config.CacheTimeCatchAll,
+		fieldsWithProxy(proxyMode),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("can't create fields handler: %w", err)
+	}
+	dependenciesHandler, err := newDependenciesHandler(logger, options.indexer, options.config.CacheTimeIndex,
+		dependenciesWithProxy(proxyMode),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("can't create dependencies handler: %w", err)
+	}
+	diffHandler, err := newDiffHandler(logger, options.indexer, options.config.CacheTimeIndex)
+	if err != nil {
+		return nil, fmt.Errorf("can't create diff handler: %w", err)
+	}
+	lookupHandler, err := newLookupHandler(logger, options.indexer, options.config.CacheTimeSearch)
+	if err != nil {
+		return nil, fmt.Errorf("can't create lookup handler: %w", err)
+	}
 	staticHandler, err := newStaticHandler(logger, options.indexer, options.config.CacheTimeCatchAll,
 		staticWithProxy(proxyMode),
 	)
@@ -739,15 +1032,53 @@ func getRouter(logger *zap.Logger, options serverOptions) (*mux.Router, error) {
 	router.Handle("/search", searchHandler)
 	router.Handle("/categories", categoriesHandler)
 	router.Handle("/health", healthHandler)
+	router.Handle("/health/live", liveHandler)
+	router.Handle("/health/ready", readyHandler)
 	router.Handle("/favicon.ico", faviconHandler)
+	router.Handle(lookupFieldsRouterPath, lookupHandler.fieldsHandler())
+	router.Handle(lookupDatasetsRouterPath, lookupHandler.datasetsHandler())
 	router.Handle(artifactsRouterPath, artifactsHandler)
 	router.Handle(signaturesRouterPath, signaturesHandler)
+	// Changelog, fields, dependencies and diff routes must be registered before the package
+	// index and static routes, that would also match their paths.
+	router.Handle(changelogRouterPath, changelogHandler)
+	router.Handle(fieldsRouterPath, fieldsHandler)
+	router.Handle(dependenciesRouterPath, dependenciesHandler.dependenciesHandler())
+	router.Handle(dependentsRouterPath, dependenciesHandler.dependentsHandler())
+	router.Handle(diffRouterPath, diffHandler)
 	router.Handle(packageIndexRouterPath, packageIndexHandler)
 	router.Handle(staticRouterPath, staticHandler)
-	router.Use(util.CORSMiddleware())
+	if options.uploadIndexer != nil {
+		uploadHandler, err := newUploadHandler(logger, options.indexer, options.uploadIndexer, options.config.Upload.Path,
+			uploadWithScopes(options.config.Upload.Scopes),
+			uploadWithMaxSize(options.config.Upload.MaxSize),
+			uploadWithRequireSignatures(packageRequireSignatures),
+			uploadWithSignatureVerifier(options.signatureVerifier, options.config.Signatures.OnInvalid != signaturesOnInvalidFlag),
+			uploadWithAfterChangeHook(func(ctx context.Context) {
+				if options.searchCache != nil {
+					options.searchCache.Purge()
+				}
+				if options.categoriesCache != nil {
+					options.categoriesCache.Purge()
+				}
+			}),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("can't create upload handler: %w", err)
+		}
+		router.Handle(uploadRouterPath, uploadHandler.uploadPackageHandler()).Methods(http.MethodPost)
+		router.Handle(uploadDeleteRouterPath, uploadHandler.deletePackageHandler()).Methods(http.MethodDelete)
+	}
+	router.Use(util.CORSMiddleware(options.config.CORSAllowedOrigins...))
 	if metricsAddress != "" {
 		router.Use(metrics.MetricsMiddleware())
 	}
+	if options.authenticator != nil {
+		router.Use(options.authenticator.Middleware())
+	}
+	if len(options.accessRules) > 0 {
+		router.Use(accessMiddleware(options.accessRules))
+	}
 	router.NotFoundHandler = notFoundHandler(fmt.Errorf("404 page not found"))
 	return router, nil
 }
@@ -761,10 +1092,6 @@ func validateFlags() error {
 		return fmt.Errorf("both -feature-storage-indexer and -feature-sql-storage-indexer flags are enabled but are mutually exclusive")
 	}
 
-	if featureIncrementalUpdates && featureSQLStorageIndexer {
-		return fmt.Errorf("feature-incremental-updates is not supported with feature-sql-storage-indexer; disable one of them")
-	}
-
 	if featureEnableSearchCache && !featureSQLStorageIndexer {
 		return fmt.Errorf("search cache is only supported in SQL storage indexer: feature-enable-search-cache is enabled, but feature-sql-storage-indexer is not enabled")
 	}
@@ -790,6 +1117,21 @@ func validateFlags() error {
 	return nil
 }
 
+// validateProxyUpstreams checks that the upstreams configured for the proxy mode use HTTPS,
+// unless insecure upstreams are allowed.
+func validateProxyUpstreams(upstreams []proxymode.UpstreamOptions, allowInsecure bool) error {
+	for _, upstream := range upstreams {
+		upstreamURL, err := url.Parse(upstream.URL)
+		if err != nil {
+			return fmt.Errorf("invalid upstream URL: %w", err)
+		}
+		if !allowInsecure && upstreamURL.Scheme != "https" {
+			return fmt.Errorf("proxy upstreams must use HTTPS (got %q); use -proxy-allow-insecure to allow HTTP", upstream.URL)
+		}
+	}
+	return nil
+}
+
 func validateTLSFlags(certFile, keyFile string, minVersion tlsVersionValue, fips bool) error {
 	if minVersion == 0 {
 		return nil
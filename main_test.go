@@ -7,6 +7,7 @@ package main
 import (
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"flag"
 	"fmt"
@@ -22,10 +23,13 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
 
+	"github.com/elastic/package-registry/internal/auth"
 	"github.com/elastic/package-registry/internal/util"
 	"github.com/elastic/package-registry/packages"
 )
@@ -58,6 +62,48 @@ func TestRouter(t *testing.T) {
 	assert.Equal(t, []string{"*"}, allowOrigin)
 }
 
+func TestRouterWithAuthentication(t *testing.T) {
+	logger := util.NewTestLogger()
+	config := defaultConfig
+	config.Auth = auth.Config{
+		Tokens:      []auth.TokenConfig{{Name: "ci", Token: "secret"}},
+		ExemptPaths: []string{"/health"},
+	}
+	indexer := NewCombinedIndexer()
+	defer indexer.Close(t.Context())
+
+	authenticator, err := initAuthenticator(logger, &config)
+	require.NoError(t, err)
+	router, err := getRouter(logger, serverOptions{
+		config:        &config,
+		indexer:       indexer,
+		authenticator: authenticator,
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		path          string
+		authorization string
+		status        int
+	}{
+		{path: "/", status: http.StatusUnauthorized},
+		{path: "/", authorization: "Bearer secret", status: http.StatusOK},
+		{path: "/health", status: http.StatusOK},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, c.path, nil)
+		if c.authorization != "" {
+			request.Header.Set("Authorization", c.authorization)
+		}
+
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, c.status, recorder.Code, c.path)
+		assert.Equal(t, []string{"*"}, recorder.Header().Values("Access-Control-Allow-Origin"))
+	}
+}
+
 func TestEndpoints(t *testing.T) {
 	t.Parallel()
 	fsOpts := packages.FSIndexerOptions{
@@ -165,6 +211,19 @@ func TestEndpoints(t *testing.T) {
 
 		// Test queries with deprecated packages
 		{"/search?package=multiversion&all=true", "/search", "search-deprecated-package-versions.json", searchHandler},
+
+		// Test free-text queries
+		{"/search?q=apache%20spark", "/search", "search-query-apache-spark.json", searchHandler},
+		{"/search?q=DATAS", "/search", "search-query-prefix.json", searchHandler},
+		{"/search?q=logs&category=web", "/search", "search-query-category.json", searchHandler},
+		{"/search?q=nonexistingterm", "/search", "search-query-no-match.json", searchHandler},
+
+		// Test pagination
+		{"/search?limit=2", "/search", "search-limit.json", searchHandler},
+		{"/search?all=true&limit=3", "/search", "search-all-limit.json", searchHandler},
+		{"/search?limit=0", "/search", "search-limit-error.txt", searchHandler},
+		{"/search?page_token=foo", "/search", "search-page-token-without-limit-error.txt", searchHandler},
+		{"/search?limit=2&page_token=foo", "/search", "search-page-token-error.txt", searchHandler},
 	}
 
 	for _, test := range tests {
@@ -378,6 +437,192 @@ func TestStaticsModifiedTime(t *testing.T) {
 	}
 }
 
+func TestJSONEntityTags(t *testing.T) {
+	t.Parallel()
+
+	const ifNoneMatchHeader = "If-None-Match"
+	const etagHeader = "ETag"
+
+	fsOpts := packages.FSIndexerOptions{
+		Logger: testLogger,
+	}
+
+	indexer := NewCombinedIndexer(
+		packages.NewZipFileSystemIndexer(fsOpts, "./testdata/local-storage"),
+		packages.NewFileSystemIndexer(fsOpts, "./testdata/package"),
+	)
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+
+	err := indexer.Init(t.Context())
+	require.NoError(t, err)
+
+	searchHandler, err := newSearchHandler(testLogger, indexer, testCacheTime,
+		searchWithCache(expirable.NewLRU[string, *jsonResponse](10, nil, time.Minute)),
+	)
+	require.NoError(t, err)
+	categoriesHandler, err := newCategoriesHandler(testLogger, indexer, testCacheTime,
+		categoriesWithCache(expirable.NewLRU[string, *jsonResponse](10, nil, time.Minute)),
+	)
+	require.NoError(t, err)
+	packageIndexHandler, err := newPackageIndexHandler(testLogger, indexer, testCacheTime)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Handle("/search", searchHandler)
+	router.Handle("/categories", categoriesHandler)
+	router.Handle(packageIndexRouterPath, packageIndexHandler)
+
+	request := func(t *testing.T, endpoint string, headers map[string]string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", endpoint, nil)
+		require.NoError(t, err)
+		for k, v := range headers {
+			req.Header.Add(k, v)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	for _, endpoint := range []string{"/search?all=true", "/categories", "/package/example/1.0.0/"} {
+		t.Run(endpoint, func(t *testing.T) {
+			first := request(t, endpoint, nil)
+			require.Equal(t, http.StatusOK, first.Code)
+			etag := first.Header().Get(etagHeader)
+			require.NotEmpty(t, etag)
+
+			// Same entity tag, also when served from the cache.
+			second := request(t, endpoint, nil)
+			assert.Equal(t, etag, second.Header().Get(etagHeader))
+			assert.Equal(t, first.Body.Bytes(), second.Body.Bytes())
+
+			notModified := request(t, endpoint, map[string]string{ifNoneMatchHeader: `"other", ` + etag})
+			assert.Equal(t, http.StatusNotModified, notModified.Code)
+			assert.Empty(t, notModified.Body.Bytes())
+			assert.Equal(t, etag, notModified.Header().Get(etagHeader))
+			assert.NotEmpty(t, notModified.Header().Values("Cache-Control"))
+
+			weak := request(t, endpoint, map[string]string{ifNoneMatchHeader: "W/" + etag})
+			assert.Equal(t, http.StatusNotModified, weak.Code)
+
+			modified := request(t, endpoint, map[string]string{ifNoneMatchHeader: `"other"`})
+			assert.Equal(t, http.StatusOK, modified.Code)
+			assert.Equal(t, first.Body.Bytes(), modified.Body.Bytes())
+		})
+	}
+}
+
+func TestCompression(t *testing.T) {
+	t.Parallel()
+
+	fsOpts := packages.FSIndexerOptions{
+		Logger: testLogger,
+	}
+
+	indexer := NewCombinedIndexer(
+		packages.NewZipFileSystemIndexer(fsOpts, "./testdata/local-storage"),
+		packages.NewFileSystemIndexer(fsOpts, "./testdata/package"),
+	)
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+
+	err := indexer.Init(t.Context())
+	require.NoError(t, err)
+
+	searchHandler, err := newSearchHandler(testLogger, indexer, testCacheTime,
+		searchWithCache(expirable.NewLRU[string, *jsonResponse](10, nil, time.Minute)),
+	)
+	require.NoError(t, err)
+	staticHandler, err := newStaticHandler(testLogger, indexer, testCacheTime)
+	require.NoError(t, err)
+	artifactsHandler, err := newArtifactsHandler(testLogger, indexer, testCacheTime)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Handle("/search", searchHandler)
+	router.Handle(staticRouterPath, staticHandler)
+	router.Handle(artifactsRouterPath, artifactsHandler)
+
+	request := func(t *testing.T, endpoint string, headers map[string]string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", endpoint, nil)
+		require.NoError(t, err)
+		for k, v := range headers {
+			req.Header.Add(k, v)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		return recorder
+	}
+	decompress := func(t *testing.T, encoding string, body []byte) []byte {
+		switch encoding {
+		case util.EncodingGzip:
+			r, err := gzip.NewReader(bytes.NewReader(body))
+			require.NoError(t, err)
+			d, err := io.ReadAll(r)
+			require.NoError(t, err)
+			return d
+		case util.EncodingZstd:
+			decoder, err := zstd.NewReader(nil)
+			require.NoError(t, err)
+			defer decoder.Close()
+			d, err := decoder.DecodeAll(body, nil)
+			require.NoError(t, err)
+			return d
+		}
+		return body
+	}
+
+	compressed := []string{
+		"/search?all=true",
+		"/package/metricsonly/2.0.1/img/icon.svg",
+		"/package/deprecated_input_package/1.0.0/docs/README.md",
+	}
+	for _, endpoint := range compressed {
+		for _, encoding := range []string{util.EncodingGzip, util.EncodingZstd} {
+			t.Run(endpoint+" "+encoding, func(t *testing.T) {
+				identity := request(t, endpoint, nil)
+				assert.Empty(t, identity.Header().Get("Content-Encoding"))
+				assert.Contains(t, identity.Header().Values("Vary"), "Accept-Encoding")
+
+				// Repeat the request to also obtain the cached response, if any.
+				for range 2 {
+					recorder := request(t, endpoint, map[string]string{"Accept-Encoding": "br, " + encoding})
+					assert.Equal(t, encoding, recorder.Header().Get("Content-Encoding"))
+					assert.Equal(t, identity.Header().Get("Content-Type"), recorder.Header().Get("Content-Type"))
+					assert.Less(t, recorder.Body.Len(), identity.Body.Len())
+					assert.Equal(t, identity.Body.Bytes(), decompress(t, encoding, recorder.Body.Bytes()))
+					if etag := identity.Header().Get("ETag"); etag != "" {
+						assert.NotEqual(t, etag, recorder.Header().Get("ETag"))
+					}
+				}
+			})
+		}
+	}
+
+	uncompressed := []string{
+		"/search?package=example",
+		"/package/example/1.0.0/img/kibana-envoyproxy.jpg",
+		"/epr/example/example-1.0.1.zip",
+	}
+	for _, endpoint := range uncompressed {
+		t.Run(endpoint, func(t *testing.T) {
+			recorder := request(t, endpoint, map[string]string{"Accept-Encoding": "gzip, zstd"})
+			assert.Empty(t, recorder.Header().Get("Content-Encoding"))
+		})
+	}
+
+	t.Run("range request", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/package/metricsonly/2.0.1/img/icon.svg", nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("Range", "bytes=0-9")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusPartialContent, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("Content-Encoding"))
+		assert.Equal(t, 10, recorder.Body.Len())
+	})
+}
+
 func TestZippedArtifacts(t *testing.T) {
 	t.Parallel()
 
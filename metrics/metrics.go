@@ -59,6 +59,45 @@ var (
 		},
 	)
 
+	ContentCacheHitsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "content_cache_hits_total",
+			Help:      "A counter for requests of remote artifacts and static files served from the content cache.",
+		},
+	)
+
+	ContentCacheMissesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "content_cache_misses_total",
+			Help:      "A counter for requests of remote artifacts and static files not found in the content cache.",
+		},
+	)
+
+	ContentCacheEvictionsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "content_cache_evictions_total",
+			Help:      "A counter for files evicted from the content cache.",
+		},
+	)
+
+	ContentCacheSizeBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "content_cache_size_bytes",
+		Help:      "A gauge for the size of the files in the content cache.",
+	})
+
+	WebhookDeliveriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "webhook_deliveries_total",
+			Help:      "A counter for attempts to deliver events to webhooks, by result (success, failure or dropped).",
+		},
+		[]string{"result"},
+	)
+
 	IndexerGetDurationSeconds = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: metricsNamespace,
@@ -29,6 +29,11 @@ func MetricsMiddleware() mux.MiddlewareFunc {
 	prometheus.MustRegister(StorageIndexerUpdateIndexDurationSeconds)
 	prometheus.MustRegister(StorageIndexerUpdateIndexSuccessTotal)
 	prometheus.MustRegister(StorageIndexerUpdateIndexErrorsTotal)
+	prometheus.MustRegister(ContentCacheHitsTotal)
+	prometheus.MustRegister(ContentCacheMissesTotal)
+	prometheus.MustRegister(ContentCacheEvictionsTotal)
+	prometheus.MustRegister(ContentCacheSizeBytes)
+	prometheus.MustRegister(WebhookDeliveriesTotal)
 
 	return func(next http.Handler) http.Handler {
 		handler := next
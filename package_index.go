@@ -86,6 +86,7 @@ func (h *packageIndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 
 	opts := packages.NameVersionFilter(packageName, packageVersion)
+	opts.Filter.Restrictions = packages.AccessRestrictionsFromContext(r.Context())
 	// Just this endpoint needs the full data, so we set it here.
 	opts.FullData = true
 
@@ -102,7 +103,7 @@ func (h *packageIndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 			return
 		}
-		if proxiedPackage != nil {
+		if proxiedPackage != nil && opts.Filter.Restrictions.Allows(proxiedPackage) {
 			pkgs = pkgs.Join(packages.Packages{proxiedPackage})
 		}
 	}
@@ -120,7 +121,7 @@ func (h *packageIndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	serveJSONResponse(r.Context(), w, h.cacheTime, data)
+	serveJSONResponse(w, r, h.cacheTime, newJSONResponse(data))
 }
 
 func getPackageOutput(ctx context.Context, pkg *packages.Package) ([]byte, error) {
@@ -0,0 +1,136 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// AccessRule restricts the visibility of the packages it matches to the callers with
+// any of its scopes. A package matches the rule if it matches all its criteria, empty
+// criteria match any package.
+type AccessRule struct {
+	// Packages are glob patterns, as supported by path.Match, of the package names.
+	Packages []string `config:"packages"`
+
+	// OwnerTypes are the owner types of the packages, as set in `owner.type`.
+	OwnerTypes []string `config:"owner_types"`
+
+	// Visibility are the values of the `visibility` field of the package manifests.
+	Visibility []string `config:"visibility"`
+
+	// Scopes are the scopes granting access to the packages.
+	Scopes []string `config:"scopes"`
+
+	id int
+}
+
+// Matches returns true if the package matches the criteria of the rule.
+func (r AccessRule) Matches(p *Package) bool {
+	if len(r.Packages) > 0 && !slices.ContainsFunc(r.Packages, func(pattern string) bool {
+		matched, _ := path.Match(pattern, p.Name)
+		return matched
+	}) {
+		return false
+	}
+	if len(r.OwnerTypes) > 0 && (p.Owner == nil || !slices.Contains(r.OwnerTypes, p.Owner.Type)) {
+		return false
+	}
+	if len(r.Visibility) > 0 && !slices.Contains(r.Visibility, p.Visibility) {
+		return false
+	}
+	return true
+}
+
+// AccessRules is the set of access rules configured in the registry.
+type AccessRules []AccessRule
+
+// NewAccessRules validates the rules, and prepares them to be used.
+func NewAccessRules(rules []AccessRule) (AccessRules, error) {
+	result := make(AccessRules, len(rules))
+	for i, rule := range rules {
+		if len(rule.Packages) == 0 && len(rule.OwnerTypes) == 0 && len(rule.Visibility) == 0 {
+			return nil, fmt.Errorf("access rule %d doesn't have any criteria to match packages", i)
+		}
+		if len(rule.Scopes) == 0 {
+			return nil, fmt.Errorf("access rule %d doesn't have any scope", i)
+		}
+		for _, pattern := range rule.Packages {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid package pattern %q in access rule %d: %w", pattern, i, err)
+			}
+		}
+		rule.id = i
+		result[i] = rule
+	}
+	return result, nil
+}
+
+// Restrictions returns the restrictions that apply to a caller with the given scopes.
+func (rules AccessRules) Restrictions(scopes []string) AccessRestrictions {
+	var restrictions AccessRestrictions
+	for _, rule := range rules {
+		if slices.ContainsFunc(rule.Scopes, func(scope string) bool { return slices.Contains(scopes, scope) }) {
+			continue
+		}
+		restrictions = append(restrictions, rule)
+	}
+	return restrictions
+}
+
+// AccessRestrictions are the rules a caller is not entitled to, the packages matched by
+// any of them are not visible for the caller.
+type AccessRestrictions []AccessRule
+
+// Allows returns true if the package is not restricted.
+func (r AccessRestrictions) Allows(p *Package) bool {
+	for _, rule := range r {
+		if rule.Matches(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the packages allowed by the restrictions.
+func (r AccessRestrictions) Filter(pkgs Packages) Packages {
+	if len(r) == 0 {
+		return pkgs
+	}
+	var allowed Packages
+	for _, p := range pkgs {
+		if r.Allows(p) {
+			allowed = append(allowed, p)
+		}
+	}
+	return allowed
+}
+
+// Key identifies the set of restrictions, callers with the same key have access to the same packages.
+func (r AccessRestrictions) Key() string {
+	ids := make([]string, len(r))
+	for i, rule := range r {
+		ids[i] = strconv.Itoa(rule.id)
+	}
+	return strings.Join(ids, ",")
+}
+
+type accessRestrictionsKey struct{}
+
+// ContextWithAccessRestrictions returns a context with the restrictions that apply to the caller.
+func ContextWithAccessRestrictions(ctx context.Context, restrictions AccessRestrictions) context.Context {
+	return context.WithValue(ctx, accessRestrictionsKey{}, restrictions)
+}
+
+// AccessRestrictionsFromContext returns the restrictions that apply to the caller of a request.
+func AccessRestrictionsFromContext(ctx context.Context) AccessRestrictions {
+	restrictions, _ := ctx.Value(accessRestrictionsKey{}).(AccessRestrictions)
+	return restrictions
+}
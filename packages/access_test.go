@@ -0,0 +1,80 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessRules(t *testing.T) {
+	rules, err := NewAccessRules([]AccessRule{
+		{Packages: []string{"acme_*", "other"}, Scopes: []string{"acme"}},
+		{OwnerTypes: []string{"partner"}, Visibility: []string{"private"}, Scopes: []string{"partners", "admin"}},
+	})
+	require.NoError(t, err)
+
+	newPackage := func(name, ownerType, visibility string) *Package {
+		var p Package
+		p.Name = name
+		p.Visibility = visibility
+		if ownerType != "" {
+			p.Owner = &Owner{Type: ownerType}
+		}
+		return &p
+	}
+	acme := newPackage("acme_logs", "elastic", "")
+	other := newPackage("other", "", "")
+	private := newPackage("partner_private", "partner", "private")
+	public := newPackage("partner_public", "partner", "")
+
+	cases := []struct {
+		scopes  []string
+		allowed []*Package
+		key     string
+	}{
+		{scopes: nil, allowed: []*Package{public}, key: "0,1"},
+		{scopes: []string{"acme"}, allowed: []*Package{acme, other, public}, key: "1"},
+		{scopes: []string{"admin"}, allowed: []*Package{private, public}, key: "0"},
+		{scopes: []string{"acme", "partners"}, allowed: []*Package{acme, other, private, public}, key: ""},
+	}
+	for _, c := range cases {
+		restrictions := rules.Restrictions(c.scopes)
+		assert.Equal(t, c.key, restrictions.Key())
+		assert.Equal(t, Packages(c.allowed), restrictions.Filter(Packages{acme, other, private, public}))
+	}
+}
+
+func TestNewAccessRulesErrors(t *testing.T) {
+	cases := map[string]AccessRule{
+		"no criteria":     {Scopes: []string{"acme"}},
+		"no scopes":       {Packages: []string{"acme_*"}},
+		"invalid pattern": {Packages: []string{"acme_["}, Scopes: []string{"acme"}},
+	}
+	for name, rule := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewAccessRules([]AccessRule{rule})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFilterWithAccessRestrictions(t *testing.T) {
+	rules, err := NewAccessRules([]AccessRule{{Packages: []string{"example"}, Scopes: []string{"internal"}}})
+	require.NoError(t, err)
+
+	pkgs := Packages{
+		filterTestPackage{Name: "example", Version: "1.0.0", Type: "integration"}.Build(),
+		filterTestPackage{Name: "example", Version: "1.1.0", Type: "integration"}.Build(),
+		filterTestPackage{Name: "foo", Version: "1.0.0", Type: "integration"}.Build(),
+	}
+	filter := Filter{Restrictions: rules.Restrictions(nil)}
+	result, err := filter.Apply(t.Context(), pkgs)
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "foo", result[0].Name)
+}
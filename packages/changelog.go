@@ -0,0 +1,78 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v2"
+)
+
+// ChangelogFile is the path of the changelog in the packages.
+const ChangelogFile = "changelog.yml"
+
+// Changelog is the list of releases of a package in its changelog, newest first.
+type Changelog []ChangelogRelease
+
+// ChangelogRelease contains the changes of a version of a package.
+type ChangelogRelease struct {
+	Version string            `yaml:"version" json:"version"`
+	Changes []ChangelogChange `yaml:"changes" json:"changes"`
+}
+
+// ChangelogChange is a change in a release of a package.
+type ChangelogChange struct {
+	Description string `yaml:"description" json:"description"`
+	Type        string `yaml:"type" json:"type"`
+	Link        string `yaml:"link" json:"link"`
+}
+
+// ParseChangelog parses the content of a changelog file.
+func ParseChangelog(d []byte) (Changelog, error) {
+	var changelog Changelog
+	err := yaml.Unmarshal(d, &changelog)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse changelog: %w", err)
+	}
+	for i := range changelog {
+		if changelog[i].Changes == nil {
+			changelog[i].Changes = []ChangelogChange{}
+		}
+		for j, change := range changelog[i].Changes {
+			changelog[i].Changes[j].Description = strings.TrimSpace(change.Description)
+		}
+	}
+	if changelog == nil {
+		changelog = Changelog{}
+	}
+	return changelog, nil
+}
+
+// ReadChangelog reads the changelog of the package, from its file system or its remote resolver.
+func ReadChangelog(ctx context.Context, client *http.Client, p *Package) (Changelog, error) {
+	d, err := ReadPackageResource(ctx, client, p, ChangelogFile)
+	if err != nil {
+		return nil, err
+	}
+	return ParseChangelog(d)
+}
+
+// Since returns the releases of the changelog with versions newer than the given one.
+// Releases with invalid versions are not included.
+func (c Changelog) Since(version *semver.Version) Changelog {
+	releases := Changelog{}
+	for _, release := range c {
+		v, err := semver.NewVersion(release.Version)
+		if err != nil || !v.GreaterThan(version) {
+			continue
+		}
+		releases = append(releases, release)
+	}
+	return releases
+}
@@ -0,0 +1,113 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testChangelog = `
+- version: "1.1.0"
+  changes:
+    - description: |
+        Add new feature.
+      type: enhancement
+      link: https://github.com/elastic/integrations/pull/2
+- version: "1.0.0"
+  changes:
+    - description: Initial release.
+      type: enhancement
+      link: https://github.com/elastic/integrations/pull/1
+- version: "0.1.0"
+`
+
+func TestParseChangelog(t *testing.T) {
+	changelog, err := ParseChangelog([]byte(testChangelog))
+	require.NoError(t, err)
+	require.Len(t, changelog, 3)
+	assert.Equal(t, ChangelogRelease{
+		Version: "1.1.0",
+		Changes: []ChangelogChange{
+			{Description: "Add new feature.", Type: "enhancement", Link: "https://github.com/elastic/integrations/pull/2"},
+		},
+	}, changelog[0])
+	assert.Equal(t, []ChangelogChange{}, changelog[2].Changes)
+
+	changelog, err = ParseChangelog(nil)
+	require.NoError(t, err)
+	assert.Equal(t, Changelog{}, changelog)
+
+	_, err = ParseChangelog([]byte("version: 1.0.0"))
+	assert.Error(t, err)
+}
+
+func TestChangelogSince(t *testing.T) {
+	changelog := Changelog{{Version: "1.1.0"}, {Version: "1.0.0"}, {Version: "foo"}, {Version: "0.1.0"}}
+
+	tests := []struct {
+		from     string
+		expected Changelog
+	}{
+		{"0.0.1", Changelog{{Version: "1.1.0"}, {Version: "1.0.0"}, {Version: "0.1.0"}}},
+		{"1.0.0", Changelog{{Version: "1.1.0"}}},
+		{"1.1.0", Changelog{}},
+		{"2.0.0", Changelog{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.from, func(t *testing.T) {
+			assert.Equal(t, test.expected, changelog.Since(semver.MustParse(test.from)))
+		})
+	}
+}
+
+// redirectResolver is a remote resolver that redirects static resources to a server.
+type redirectResolver struct {
+	url string
+}
+
+func (r redirectResolver) ArtifactsHandler(w http.ResponseWriter, req *http.Request, p *Package) {
+	http.NotFound(w, req)
+}
+
+func (r redirectResolver) StaticHandler(w http.ResponseWriter, req *http.Request, p *Package, resourcePath string) {
+	http.Redirect(w, req, r.url+req.URL.Path, http.StatusMovedPermanently)
+}
+
+func (r redirectResolver) SignaturesHandler(w http.ResponseWriter, req *http.Request, p *Package) {
+	http.NotFound(w, req)
+}
+
+func TestReadRemoteChangelog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/package/foo/1.1.0/changelog.yml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testChangelog))
+	}))
+	t.Cleanup(server.Close)
+
+	newPackage := func(version string) *Package {
+		p := &Package{BasePackage: BasePackage{Name: "foo", Version: version}}
+		p.SetRemoteResolver(redirectResolver{url: server.URL})
+		return p
+	}
+
+	changelog, err := ReadChangelog(t.Context(), server.Client(), newPackage("1.1.0"))
+	require.NoError(t, err)
+	require.Len(t, changelog, 3)
+	assert.Equal(t, "1.1.0", changelog[0].Version)
+
+	_, err = ReadChangelog(t.Context(), server.Client(), newPackage("1.0.0"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
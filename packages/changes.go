@@ -0,0 +1,185 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// IndexChanges are the package versions added, updated and removed in an update of an indexer.
+type IndexChanges struct {
+	// Indexer is the name of the indexer updated.
+	Indexer string
+
+	Added   []PackageKey
+	Updated []PackageKey
+	Removed []PackageKey
+}
+
+// Empty returns true if no package changed.
+func (c IndexChanges) Empty() bool {
+	return len(c.Added) == 0 && len(c.Updated) == 0 && len(c.Removed) == 0
+}
+
+// IndexChangesHook is called after an update of an indexer that changed some package.
+type IndexChangesHook func(ctx context.Context, changes IndexChanges)
+
+// PackageFingerprints are digests of the contents of the package versions of an index,
+// compared between updates to find the packages that changed.
+type PackageFingerprints map[PackageKey][sha256.Size]byte
+
+// NewPackageFingerprints obtains the fingerprints of the given packages from their JSON
+// representation.
+func NewPackageFingerprints(packages Packages) (PackageFingerprints, error) {
+	fingerprints := make(PackageFingerprints, len(packages))
+	for _, p := range packages {
+		d, err := json.Marshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal package %s-%s: %w", p.Name, p.Version, err)
+		}
+		fingerprints.Add(p.Name, p.Version, d)
+	}
+	return fingerprints, nil
+}
+
+// Add sets the fingerprint of a package version from its contents.
+func (f PackageFingerprints) Add(name, version string, contents []byte) {
+	f[PackageKey{Name: name, Version: version}] = sha256.Sum256(contents)
+}
+
+// Changes returns the package versions added, updated and removed in next.
+func (f PackageFingerprints) Changes(next PackageFingerprints) IndexChanges {
+	var changes IndexChanges
+	for key, fingerprint := range next {
+		previous, found := f[key]
+		switch {
+		case !found:
+			changes.Added = append(changes.Added, key)
+		case previous != fingerprint:
+			changes.Updated = append(changes.Updated, key)
+		}
+	}
+	for key := range f {
+		if _, found := next[key]; !found {
+			changes.Removed = append(changes.Removed, key)
+		}
+	}
+	sortPackageKeys(changes.Added)
+	sortPackageKeys(changes.Updated)
+	sortPackageKeys(changes.Removed)
+	return changes
+}
+
+// fingerprintsFile is the content of the files where fingerprints are persisted.
+type fingerprintsFile struct {
+	Packages []packageFingerprint `json:"packages"`
+}
+
+type packageFingerprint struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	SHA256  string `json:"sha256"`
+}
+
+// FingerprintsPath returns the path of the file in dir where an indexer persists the
+// fingerprints of its packages, so the changes made while the registry is stopped can be
+// found on its initial load. Indexers are identified by their name and the locations of
+// their packages. It returns an empty path if dir is empty.
+func FingerprintsPath(dir string, indexer string, locations ...string) string {
+	if dir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(locations, "\n")))
+	return filepath.Join(dir, fmt.Sprintf("%s-%s.json", indexer, hex.EncodeToString(sum[:8])))
+}
+
+// ReadPackageFingerprints reads the fingerprints persisted in the file. It returns nil
+// fingerprints, without error, if the file doesn't exist.
+func ReadPackageFingerprints(path string) (PackageFingerprints, error) {
+	d, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fingerprints: %w", err)
+	}
+	var file fingerprintsFile
+	err = json.Unmarshal(d, &file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode fingerprints (path: %s): %w", path, err)
+	}
+	fingerprints := make(PackageFingerprints, len(file.Packages))
+	for _, p := range file.Packages {
+		sum, err := hex.DecodeString(p.SHA256)
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid fingerprint of package %s-%s (path: %s)", p.Name, p.Version, path)
+		}
+		fingerprints[PackageKey{Name: p.Name, Version: p.Version}] = [sha256.Size]byte(sum)
+	}
+	return fingerprints, nil
+}
+
+// WriteFile persists the fingerprints in the file. The file is replaced atomically, so it
+// is never left partially written.
+func (f PackageFingerprints) WriteFile(path string) error {
+	file := fingerprintsFile{Packages: make([]packageFingerprint, 0, len(f))}
+	for key, sum := range f {
+		file.Packages = append(file.Packages, packageFingerprint{
+			Name:    key.Name,
+			Version: key.Version,
+			SHA256:  hex.EncodeToString(sum[:]),
+		})
+	}
+	slices.SortFunc(file.Packages, func(a, b packageFingerprint) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), CompareVersions(a.Version, b.Version))
+	})
+	d, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to encode fingerprints: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create fingerprints directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-"+filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("failed to create fingerprints file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(d)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write fingerprints file: %w", err)
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("failed to replace fingerprints file: %w", err)
+	}
+	return nil
+}
+
+func sortPackageKeys(keys []PackageKey) {
+	slices.SortFunc(keys, func(a, b PackageKey) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return CompareVersions(a.Version, b.Version)
+	})
+}
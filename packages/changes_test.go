@@ -0,0 +1,161 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/elastic/package-registry/internal/util"
+)
+
+func TestPackageFingerprintsChanges(t *testing.T) {
+	previous := make(PackageFingerprints)
+	previous.Add("nginx", "1.0.0", []byte("nginx 1.0.0"))
+	previous.Add("nginx", "1.10.0", []byte("nginx 1.10.0"))
+	previous.Add("apache", "1.0.0", []byte("apache 1.0.0"))
+	previous.Add("mysql", "2.0.0", []byte("mysql 2.0.0"))
+
+	next := make(PackageFingerprints)
+	next.Add("nginx", "1.0.0", []byte("nginx 1.0.0"))
+	next.Add("nginx", "1.10.0", []byte("nginx 1.10.0, deprecated"))
+	next.Add("nginx", "1.9.0", []byte("nginx 1.9.0"))
+	next.Add("nginx", "1.11.0", []byte("nginx 1.11.0"))
+	next.Add("apache", "1.0.0", []byte("apache 1.0.0, deprecated"))
+
+	changes := previous.Changes(next)
+	assert.Equal(t, IndexChanges{
+		Added: []PackageKey{
+			{Name: "nginx", Version: "1.9.0"},
+			{Name: "nginx", Version: "1.11.0"},
+		},
+		Updated: []PackageKey{
+			{Name: "apache", Version: "1.0.0"},
+			{Name: "nginx", Version: "1.10.0"},
+		},
+		Removed: []PackageKey{
+			{Name: "mysql", Version: "2.0.0"},
+		},
+	}, changes)
+	assert.False(t, changes.Empty())
+
+	assert.True(t, next.Changes(next).Empty())
+}
+
+func TestFileSystemIndexerChangesHook(t *testing.T) {
+	packagesPath := t.TempDir()
+	copyTestPackage := func(name, version string) {
+		dest := filepath.Join(packagesPath, name, version)
+		require.NoError(t, os.CopyFS(dest, os.DirFS(filepath.Join("..", "testdata", "package", name, version))))
+	}
+	copyTestPackage("example", "1.0.0")
+	copyTestPackage("example", "1.0.1")
+
+	var notified []IndexChanges
+	indexer := NewFileSystemIndexer(FSIndexerOptions{
+		Logger: util.NewTestLoggerLevel(zapcore.FatalLevel),
+		IndexChangesHook: func(_ context.Context, changes IndexChanges) {
+			notified = append(notified, changes)
+		},
+	}, packagesPath)
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+
+	// Initial load is not notified.
+	require.NoError(t, indexer.Init(t.Context()))
+	assert.Empty(t, notified)
+
+	// Refreshing without changes is not notified.
+	require.NoError(t, indexer.Refresh(t.Context()))
+	assert.Empty(t, notified)
+
+	copyTestPackage("example", "1.1.0")
+	require.NoError(t, os.RemoveAll(filepath.Join(packagesPath, "example", "1.0.0")))
+	require.NoError(t, indexer.Refresh(t.Context()))
+	require.Len(t, notified, 1)
+	assert.Equal(t, fileSystemIndexerName, notified[0].Indexer)
+	assert.Equal(t, []PackageKey{{Name: "example", Version: "1.1.0"}}, notified[0].Added)
+	assert.Equal(t, []PackageKey{{Name: "example", Version: "1.0.0"}}, notified[0].Removed)
+}
+
+func TestPackageFingerprintsFile(t *testing.T) {
+	dir := t.TempDir()
+	assert.Empty(t, FingerprintsPath("", fileSystemIndexerName, "packages"))
+	assert.NotEqual(t,
+		FingerprintsPath(dir, zipFileSystemIndexerName, "packages"),
+		FingerprintsPath(dir, zipFileSystemIndexerName, "uploads"))
+	assert.NotEqual(t,
+		FingerprintsPath(dir, zipFileSystemIndexerName, "packages"),
+		FingerprintsPath(dir, fileSystemIndexerName, "packages"))
+
+	path := FingerprintsPath(filepath.Join(dir, "fingerprints"), fileSystemIndexerName, "packages")
+	fingerprints, err := ReadPackageFingerprints(path)
+	require.NoError(t, err)
+	assert.Nil(t, fingerprints)
+
+	expected := make(PackageFingerprints)
+	expected.Add("nginx", "1.0.0", []byte("nginx 1.0.0"))
+	expected.Add("apache", "1.0.0", []byte("apache 1.0.0"))
+	require.NoError(t, expected.WriteFile(path))
+	fingerprints, err = ReadPackageFingerprints(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, fingerprints)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"packages":[{"name":"nginx","version":"1.0.0","sha256":"foo"}]}`), 0644))
+	_, err = ReadPackageFingerprints(path)
+	assert.Error(t, err)
+}
+
+func TestFileSystemIndexerChangesHookPersistedFingerprints(t *testing.T) {
+	packagesPath := t.TempDir()
+	copyTestPackage := func(name, version string) {
+		dest := filepath.Join(packagesPath, name, version)
+		require.NoError(t, os.CopyFS(dest, os.DirFS(filepath.Join("..", "testdata", "package", name, version))))
+	}
+	copyTestPackage("example", "1.0.0")
+	copyTestPackage("example", "1.0.1")
+
+	var notified []IndexChanges
+	options := FSIndexerOptions{
+		Logger: util.NewTestLoggerLevel(zapcore.FatalLevel),
+		IndexChangesHook: func(_ context.Context, changes IndexChanges) {
+			notified = append(notified, changes)
+		},
+		FingerprintsDir: t.TempDir(),
+	}
+	initIndexer := func() {
+		indexer := NewFileSystemIndexer(options, packagesPath)
+		require.NoError(t, indexer.Init(t.Context()))
+		require.NoError(t, indexer.Close(context.Background()))
+	}
+
+	// First run, there are no previous fingerprints.
+	initIndexer()
+	assert.Empty(t, notified)
+
+	// Changes while the registry is stopped are notified on the next initial load.
+	copyTestPackage("example", "1.1.0")
+	require.NoError(t, os.RemoveAll(filepath.Join(packagesPath, "example", "1.0.0")))
+	initIndexer()
+	require.Len(t, notified, 1)
+	assert.Equal(t, fileSystemIndexerName, notified[0].Indexer)
+	assert.Equal(t, []PackageKey{{Name: "example", Version: "1.1.0"}}, notified[0].Added)
+	assert.Equal(t, []PackageKey{{Name: "example", Version: "1.0.0"}}, notified[0].Removed)
+
+	// Restarting without changes is not notified.
+	initIndexer()
+	assert.Len(t, notified, 1)
+
+	// Indexers of other kinds of packages keep their own fingerprints.
+	zipIndexer := NewZipFileSystemIndexer(options, packagesPath)
+	require.NoError(t, zipIndexer.Init(t.Context()))
+	require.NoError(t, zipIndexer.Close(context.Background()))
+	assert.Len(t, notified, 1)
+}
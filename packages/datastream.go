@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"slices"
 	"strings"
 
 	yamlv2 "gopkg.in/yaml.v2"
@@ -72,6 +73,11 @@ type DataStream struct {
 	// Reference to the package containing this data stream
 	packageRef *Package
 
+	// Names of the fields defined in the fields files, read when the data stream is loaded,
+	// or the error found reading them.
+	fieldNames    []string
+	fieldNamesErr error
+
 	Deprecated *Deprecated `config:"deprecated,omitempty" json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
 }
 
@@ -178,6 +184,16 @@ func NewDataStream(basePath string, p *Package) (*DataStream, error) {
 		return nil, fmt.Errorf("error building data stream (path: %s) in package: %s: %w", dataStreamPath, p.Name, err)
 	}
 
+	// Field names are read on validation, read them here if validation is disabled.
+	if d.fieldNames == nil && d.fieldNamesErr == nil {
+		fieldsFiles, err := d.readFieldsFiles(fsys)
+		if err != nil {
+			d.fieldNamesErr = err
+		} else {
+			d.setFieldNames(fieldsFiles)
+		}
+	}
+
 	// if id is not set, {package}.{dataStreamPath} is the default
 	if d.Dataset == "" {
 		d.Dataset = p.Name + "." + dataStreamPath
@@ -297,10 +313,15 @@ func (d *DataStream) Validate() error {
 		}
 	}
 
-	err = d.validateRequiredFields(fsys)
+	fieldsFiles, err := d.readFieldsFiles(fsys)
+	if err != nil {
+		return fmt.Errorf("validating required fields failed: %w", err)
+	}
+	err = validateRequiredFields(fieldsFiles)
 	if err != nil {
 		return fmt.Errorf("validating required fields failed: %w", err)
 	}
+	d.setFieldNames(fieldsFiles)
 
 	if err := d.validStreamsInput(); err != nil {
 		return err
@@ -344,33 +365,64 @@ func validateIngestPipelineFile(fs PackageFileSystem, pipelinePath string) error
 	return err
 }
 
-// validateRequiredFields method loads fields from all files and checks if required fields are present.
-func (d *DataStream) validateRequiredFields(fs PackageFileSystem) error {
-	fieldsDirPath := path.Join(d.BasePath, "fields")
+// fieldsFile is a file in the fields directory of a data stream.
+type fieldsFile struct {
+	path string
+	body []byte
+}
 
-	// Collect fields from all files
-	fieldsFiles, err := fs.Glob(path.Join(fieldsDirPath, "*"))
+// readFieldsFiles reads all the files in the fields directory of the data stream.
+func (d *DataStream) readFieldsFiles(fs PackageFileSystem) ([]fieldsFile, error) {
+	fieldsDirPath := path.Join(d.BasePath, "fields")
+	paths, err := fs.Glob(path.Join(fieldsDirPath, "*"))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	var allFields []util.MapStr
-	for _, path := range fieldsFiles {
+	var files []fieldsFile
+	for _, path := range paths {
 		body, err := ReadAll(fs, path)
 		if err != nil {
-			return fmt.Errorf("reading file failed (path: %s): %w", path, err)
+			return nil, fmt.Errorf("reading file failed (path: %s): %w", path, err)
 		}
+		files = append(files, fieldsFile{path: path, body: body})
+	}
+	return files, nil
+}
+
+// setFieldNames sets the names of the fields defined in the YAML files of the fields
+// directory, or the error found parsing them.
+func (d *DataStream) setFieldNames(files []fieldsFile) {
+	names := []string{}
+	for _, file := range files {
+		if ext := path.Ext(file.path); ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+		fields, err := ParseFields(file.body)
+		if err != nil {
+			d.fieldNames, d.fieldNamesErr = nil, fmt.Errorf("parsing fields file failed (path: %s): %w", file.path, err)
+			return
+		}
+		for _, field := range fields {
+			names = append(names, field.Name)
+		}
+	}
+	slices.Sort(names)
+	d.fieldNames, d.fieldNamesErr = slices.Compact(names), nil
+}
 
+// validateRequiredFields function loads fields from all files and checks if required fields are present.
+func validateRequiredFields(files []fieldsFile) error {
+	// Collect fields from all files
+	var allFields []util.MapStr
+	for _, file := range files {
 		var m []util.MapStr
-		err = yamlv2.Unmarshal(body, &m)
+		err := yamlv2.Unmarshal(file.body, &m)
 		if err != nil {
-			return fmt.Errorf("unmarshaling file failed (path: %s): %w", path, err)
+			return fmt.Errorf("unmarshaling file failed (path: %s): %w", file.path, err)
 		}
 
 		allFields = append(allFields, m...)
 	}
-	if err != nil {
-		return fmt.Errorf("walking through fields files failed: %w", err)
-	}
 
 	// Flatten all fields
 	for i, fields := range allFields {
@@ -378,7 +430,7 @@ func (d *DataStream) validateRequiredFields(fs PackageFileSystem) error {
 	}
 
 	// Verify required keys
-	err = requireField(allFields, "data_stream.type", "constant_keyword", err)
+	err := requireField(allFields, "data_stream.type", "constant_keyword", nil)
 	err = requireField(allFields, "data_stream.dataset", "constant_keyword", err)
 	err = requireField(allFields, "data_stream.namespace", "constant_keyword", err)
 	err = requireField(allFields, "@timestamp", "date", err)
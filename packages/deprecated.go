@@ -4,7 +4,12 @@
 
 package packages
 
-import "github.com/Masterminds/semver/v3"
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+)
 
 type deprecatedMeta struct {
 	deprecated *Deprecated
@@ -21,6 +26,44 @@ func (d DeprecatedPackages) Deprecated(name string) (*Deprecated, bool) {
 	return meta.deprecated, true
 }
 
+type deprecatedEntry struct {
+	Version    string      `json:"version"`
+	Deprecated *Deprecated `json:"deprecated"`
+}
+
+// MarshalJSON encodes the deprecation info with the version of the package it was obtained from.
+func (d DeprecatedPackages) MarshalJSON() ([]byte, error) {
+	entries := make(map[string]deprecatedEntry, len(d))
+	for name, meta := range d {
+		entries[name] = deprecatedEntry{
+			Version:    meta.version.Original(),
+			Deprecated: meta.deprecated,
+		}
+	}
+	return json.Marshal(entries)
+}
+
+// UnmarshalJSON decodes deprecation info encoded with MarshalJSON.
+func (d *DeprecatedPackages) UnmarshalJSON(data []byte) error {
+	var entries map[string]deprecatedEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	result := make(DeprecatedPackages, len(entries))
+	for name, entry := range entries {
+		version, err := semver.NewVersion(entry.Version)
+		if err != nil {
+			return fmt.Errorf("invalid version of deprecated package %s: %w", name, err)
+		}
+		result[name] = deprecatedMeta{
+			deprecated: entry.Deprecated,
+			version:    version,
+		}
+	}
+	*d = result
+	return nil
+}
+
 // UpdateLatestDeprecatedPackagesMapByName updates a map of the latest deprecated packages by name.
 // It ensures that for each package name, only the deprecation info of the latest version is stored.
 func UpdateLatestDeprecatedPackagesMapByName(input Packages, deprecatedPackages DeprecatedPackages) {
@@ -5,6 +5,7 @@
 package packages
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/Masterminds/semver/v3"
@@ -283,3 +284,22 @@ func TestPropagateLatestDeprecatedInfoToPackageList_DoesNotMutateOriginalPointer
 	require.NotNil(t, list[0].Deprecated)
 	assert.Equal(t, "2.0.0", list[0].Deprecated.Since)
 }
+
+func TestDeprecatedPackagesJSON(t *testing.T) {
+	deprecatedPackages := DeprecatedPackages{
+		"test-package": deprecatedMeta{
+			deprecated: &Deprecated{Since: "2.0.0", Description: "Use other-package instead."},
+			version:    semver.MustParse("2.1.0"),
+		},
+	}
+
+	d, err := json.Marshal(deprecatedPackages)
+	require.NoError(t, err)
+
+	var decoded DeprecatedPackages
+	require.NoError(t, json.Unmarshal(d, &decoded))
+	assert.Equal(t, deprecatedPackages, decoded)
+
+	err = json.Unmarshal([]byte(`{"test-package":{"version":"invalid"}}`), &decoded)
+	assert.Error(t, err)
+}
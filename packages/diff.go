@@ -0,0 +1,334 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// PackageDiff contains the differences between two versions of a package.
+type PackageDiff struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	DataStreams     ElementsDiff `json:"data_streams"`
+	PolicyTemplates ElementsDiff `json:"policy_templates"`
+
+	// Inputs are identified by the policy template and the type of the input.
+	Inputs ElementsDiff `json:"inputs"`
+
+	// Vars are identified by their path in the package, e.g. "data_streams.logs.streams.logfile.vars.paths".
+	Vars ElementsDiff `json:"vars"`
+
+	Conditions []ValueChange `json:"conditions"`
+
+	// ElasticsearchPrivileges contains the changes in the cluster privileges of the package,
+	// and in the indices privileges of its data streams.
+	ElasticsearchPrivileges []ValueChange `json:"elasticsearch_privileges"`
+
+	// Assets are identified by their path relative to the root of the package.
+	Assets ElementsDiff `json:"assets"`
+}
+
+// ElementsDiff contains the elements added, removed and changed between two versions of a package.
+type ElementsDiff struct {
+	Added   []string        `json:"added"`
+	Removed []string        `json:"removed"`
+	Changed []ElementChange `json:"changed"`
+}
+
+// ElementChange contains the changes of an element present in both versions of a package.
+type ElementChange struct {
+	Name    string        `json:"name"`
+	Changes []ValueChange `json:"changes"`
+}
+
+// ValueChange is a setting with different values in two versions of a package.
+type ValueChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// Diff compares two versions of a package. Packages are expected to be loaded with their full data.
+func Diff(from, to *Package) *PackageDiff {
+	return &PackageDiff{
+		Name: to.Name,
+		From: from.Version,
+		To:   to.Version,
+
+		DataStreams:             diffElements(from.DataStreams, to.DataStreams, dataStreamKey, compareDataStreams),
+		PolicyTemplates:         diffElements(from.PolicyTemplates, to.PolicyTemplates, policyTemplateKey, comparePolicyTemplates),
+		Inputs:                  diffElements(packageInputs(from), packageInputs(to), inputKey, compareInputs),
+		Vars:                    diffElements(packageVariables(from), packageVariables(to), variableKey, compareVariables),
+		Conditions:              compareConditions(from.Conditions, to.Conditions),
+		ElasticsearchPrivileges: compareElasticsearchPrivileges(from, to),
+		Assets:                  diffElements(packageAssets(from), packageAssets(to), assetKey, nil),
+	}
+}
+
+// diffElements compares two lists of elements identified by the given key. Elements present
+// in both lists are compared with the given function, if any.
+func diffElements[T any](from, to []T, key func(T) string, compare func(a, b T) []ValueChange) ElementsDiff {
+	diff := ElementsDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []ElementChange{},
+	}
+
+	fromElements := make(map[string]T, len(from))
+	for _, e := range from {
+		fromElements[key(e)] = e
+	}
+	toElements := make(map[string]T, len(to))
+	for _, e := range to {
+		toElements[key(e)] = e
+	}
+
+	for k, e := range toElements {
+		previous, found := fromElements[k]
+		if !found {
+			diff.Added = append(diff.Added, k)
+			continue
+		}
+		if compare == nil {
+			continue
+		}
+		if changes := compare(previous, e); len(changes) > 0 {
+			diff.Changed = append(diff.Changed, ElementChange{Name: k, Changes: changes})
+		}
+	}
+	for k := range fromElements {
+		if _, found := toElements[k]; !found {
+			diff.Removed = append(diff.Removed, k)
+		}
+	}
+
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.SortFunc(diff.Changed, func(a, b ElementChange) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return diff
+}
+
+// valueChanges collects the changes of the settings of an element.
+type valueChanges []ValueChange
+
+func (c *valueChanges) compare(field string, from, to interface{}) {
+	if reflect.DeepEqual(from, to) {
+		return
+	}
+	*c = append(*c, ValueChange{Field: field, From: from, To: to})
+}
+
+func dataStreamKey(ds *DataStream) string {
+	return ds.Path
+}
+
+func compareDataStreams(a, b *DataStream) []ValueChange {
+	var changes valueChanges
+	changes.compare("type", a.Type, b.Type)
+	changes.compare("dataset", a.Dataset, b.Dataset)
+	changes.compare("title", a.Title, b.Title)
+	changes.compare("release", a.Release, b.Release)
+	changes.compare("hidden", a.Hidden, b.Hidden)
+	changes.compare("ilm_policy", a.IlmPolicy, b.IlmPolicy)
+	changes.compare("ingest_pipeline", a.IngestPipeline, b.IngestPipeline)
+	changes.compare("streams", streamInputs(a.Streams), streamInputs(b.Streams))
+	return changes
+}
+
+func streamInputs(streams []Stream) []string {
+	inputs := []string{}
+	for _, s := range streams {
+		inputs = append(inputs, s.Input)
+	}
+	return inputs
+}
+
+func policyTemplateKey(pt PolicyTemplate) string {
+	return pt.Name
+}
+
+func comparePolicyTemplates(a, b PolicyTemplate) []ValueChange {
+	var changes valueChanges
+	changes.compare("title", a.Title, b.Title)
+	changes.compare("description", a.Description, b.Description)
+	changes.compare("data_streams", a.DataStreams, b.DataStreams)
+	changes.compare("multiple", a.Multiple, b.Multiple)
+	changes.compare("type", a.Type, b.Type)
+	changes.compare("input", a.Input, b.Input)
+	changes.compare("template_path", a.TemplatePath, b.TemplatePath)
+	return changes
+}
+
+// policyTemplateInput is an input of a policy template.
+type policyTemplateInput struct {
+	PolicyTemplate string
+	Input
+}
+
+func packageInputs(p *Package) []policyTemplateInput {
+	var inputs []policyTemplateInput
+	for _, pt := range p.PolicyTemplates {
+		for _, input := range pt.Inputs {
+			inputs = append(inputs, policyTemplateInput{PolicyTemplate: pt.Name, Input: input})
+		}
+	}
+	return inputs
+}
+
+func inputKey(i policyTemplateInput) string {
+	return i.PolicyTemplate + "." + i.Type
+}
+
+func compareInputs(a, b policyTemplateInput) []ValueChange {
+	var changes valueChanges
+	changes.compare("title", a.Title, b.Title)
+	changes.compare("description", a.Description, b.Description)
+	changes.compare("template_path", a.TemplatePath, b.TemplatePath)
+	changes.compare("input_group", a.InputGroup, b.InputGroup)
+	changes.compare("deployment_modes", a.DeploymentModes, b.DeploymentModes)
+	return changes
+}
+
+// packageVariable is a variable with its path in the package.
+type packageVariable struct {
+	Path string
+	Variable
+}
+
+// packageVariables returns the variables defined at the package level, in the inputs of the
+// policy templates and in the streams of the data streams.
+func packageVariables(p *Package) []packageVariable {
+	var vars []packageVariable
+	add := func(prefix string, variables []Variable) {
+		for _, v := range variables {
+			vars = append(vars, packageVariable{Path: prefix + "vars." + v.Name, Variable: v})
+		}
+	}
+	add("", p.Vars)
+	for _, pt := range p.PolicyTemplates {
+		for _, input := range pt.Inputs {
+			add("policy_templates."+pt.Name+".inputs."+input.Type+".", input.Vars)
+		}
+	}
+	for _, ds := range p.DataStreams {
+		for _, stream := range ds.Streams {
+			add("data_streams."+ds.Path+".streams."+stream.Input+".", stream.Vars)
+		}
+	}
+	return vars
+}
+
+func variableKey(v packageVariable) string {
+	return v.Path
+}
+
+func compareVariables(a, b packageVariable) []ValueChange {
+	var changes valueChanges
+	changes.compare("type", a.Type, b.Type)
+	changes.compare("required", a.Required, b.Required)
+	changes.compare("default", a.Default, b.Default)
+	changes.compare("multi", a.Multi, b.Multi)
+	changes.compare("show_user", a.ShowUser, b.ShowUser)
+	changes.compare("title", a.Title, b.Title)
+	return changes
+}
+
+func compareConditions(a, b *Conditions) []ValueChange {
+	var from, to Conditions
+	if a != nil {
+		from = *a
+	}
+	if b != nil {
+		to = *b
+	}
+
+	changes := valueChanges{}
+	changes.compare("kibana.version", kibanaVersionCondition(from.Kibana), kibanaVersionCondition(to.Kibana))
+	changes.compare("agent.version", agentVersionCondition(from.Agent), agentVersionCondition(to.Agent))
+	var fromElastic, toElastic ElasticConditions
+	if from.Elastic != nil {
+		fromElastic = *from.Elastic
+	}
+	if to.Elastic != nil {
+		toElastic = *to.Elastic
+	}
+	changes.compare("elastic.subscription", fromElastic.Subscription, toElastic.Subscription)
+	changes.compare("elastic.capabilities", fromElastic.Capabilities, toElastic.Capabilities)
+	return changes
+}
+
+func kibanaVersionCondition(c *KibanaConditions) string {
+	if c == nil {
+		return ""
+	}
+	return c.Version
+}
+
+func agentVersionCondition(c *AgentConditions) string {
+	if c == nil {
+		return ""
+	}
+	return c.Version
+}
+
+func compareElasticsearchPrivileges(from, to *Package) []ValueChange {
+	changes := valueChanges{}
+	changes.compare("cluster", clusterPrivileges(from), clusterPrivileges(to))
+
+	fromIndices := make(map[string][]string)
+	for _, ds := range from.DataStreams {
+		fromIndices[ds.Path] = indicesPrivileges(ds)
+	}
+	toIndices := make(map[string][]string)
+	for _, ds := range to.DataStreams {
+		toIndices[ds.Path] = indicesPrivileges(ds)
+	}
+	var dataStreams []string
+	for ds := range fromIndices {
+		dataStreams = append(dataStreams, ds)
+	}
+	for ds := range toIndices {
+		if _, found := fromIndices[ds]; !found {
+			dataStreams = append(dataStreams, ds)
+		}
+	}
+	slices.Sort(dataStreams)
+	for _, ds := range dataStreams {
+		a, b := fromIndices[ds], toIndices[ds]
+		if a == nil {
+			a = []string{}
+		}
+		if b == nil {
+			b = []string{}
+		}
+		changes.compare("data_streams."+ds+".indices", a, b)
+	}
+	return changes
+}
+
+func clusterPrivileges(p *Package) []string {
+	if p.Elasticsearch == nil || p.Elasticsearch.Privileges == nil || p.Elasticsearch.Privileges.Cluster == nil {
+		return []string{}
+	}
+	return p.Elasticsearch.Privileges.Cluster
+}
+
+func indicesPrivileges(ds *DataStream) []string {
+	if ds.Elasticsearch == nil || ds.Elasticsearch.Privileges == nil || ds.Elasticsearch.Privileges.Indices == nil {
+		return []string{}
+	}
+	return ds.Elasticsearch.Privileges.Indices
+}
+
+func assetKey(a string) string {
+	return a
+}
@@ -0,0 +1,151 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	from := &Package{
+		BasePackage: BasePackage{
+			Name:    "foo",
+			Version: "1.0.0",
+			Conditions: &Conditions{
+				Kibana: &KibanaConditions{Version: "^8.0.0"},
+			},
+		},
+		Vars: []Variable{
+			{Name: "hosts", Type: "text", Required: true},
+			{Name: "timeout", Type: "text", Default: "10s"},
+		},
+		PolicyTemplates: []PolicyTemplate{
+			{
+				Name: "foo",
+				Inputs: []Input{
+					{Type: "logfile", Title: "Logs", Vars: []Variable{{Name: "paths", Type: "text", Multi: true}}},
+					{Type: "httpjson"},
+				},
+			},
+		},
+		DataStreams: []*DataStream{
+			{
+				Path:  "logs",
+				Type:  "logs",
+				Title: "Logs",
+				Streams: []Stream{
+					{Input: "logfile", Vars: []Variable{{Name: "tags", Type: "text", Default: []interface{}{"foo"}}}},
+				},
+			},
+			{Path: "metrics", Type: "metrics"},
+		},
+		Elasticsearch: &PackageElasticsearch{
+			Privileges: &PackageElasticsearchPrivileges{Cluster: []string{"monitor"}},
+		},
+		Assets: []string{
+			"/package/foo/1.0.0/manifest.yml",
+			"/package/foo/1.0.0/data_stream/logs/manifest.yml",
+			"/package/foo/1.0.0/data_stream/metrics/manifest.yml",
+		},
+	}
+	to := &Package{
+		BasePackage: BasePackage{
+			Name:    "foo",
+			Version: "2.0.0",
+			Conditions: &Conditions{
+				Kibana: &KibanaConditions{Version: "^8.10.0"},
+				Agent:  &AgentConditions{Version: "^8.10.0"},
+			},
+		},
+		Vars: []Variable{
+			{Name: "hosts", Type: "url", Required: true},
+			{Name: "proxy", Type: "text"},
+		},
+		PolicyTemplates: []PolicyTemplate{
+			{
+				Name: "foo",
+				Inputs: []Input{
+					{Type: "logfile", Title: "Log files", Vars: []Variable{{Name: "paths", Type: "text", Multi: true, Required: true}}},
+					{Type: "cel"},
+				},
+			},
+		},
+		DataStreams: []*DataStream{
+			{
+				Path:  "logs",
+				Type:  "logs",
+				Title: "Logs",
+				Streams: []Stream{
+					{Input: "logfile", Vars: []Variable{{Name: "tags", Type: "text", Default: []interface{}{"foo", "bar"}}}},
+				},
+				Elasticsearch: &DataStreamElasticsearch{
+					Privileges: &DataStreamElasticsearchPrivileges{Indices: []string{"auto_configure"}},
+				},
+			},
+			{Path: "events", Type: "logs"},
+		},
+		Assets: []string{
+			"/package/foo/2.0.0/manifest.yml",
+			"/package/foo/2.0.0/data_stream/logs/manifest.yml",
+			"/package/foo/2.0.0/data_stream/events/manifest.yml",
+		},
+	}
+
+	diff := Diff(from, to)
+
+	assert.Equal(t, "foo", diff.Name)
+	assert.Equal(t, "1.0.0", diff.From)
+	assert.Equal(t, "2.0.0", diff.To)
+	assert.Equal(t, ElementsDiff{
+		Added:   []string{"events"},
+		Removed: []string{"metrics"},
+		Changed: []ElementChange{},
+	}, diff.DataStreams)
+	assert.Equal(t, ElementsDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []ElementChange{},
+	}, diff.PolicyTemplates)
+	assert.Equal(t, ElementsDiff{
+		Added:   []string{"foo.cel"},
+		Removed: []string{"foo.httpjson"},
+		Changed: []ElementChange{
+			{Name: "foo.logfile", Changes: []ValueChange{{Field: "title", From: "Logs", To: "Log files"}}},
+		},
+	}, diff.Inputs)
+	assert.Equal(t, ElementsDiff{
+		Added:   []string{"vars.proxy"},
+		Removed: []string{"vars.timeout"},
+		Changed: []ElementChange{
+			{
+				Name:    "data_streams.logs.streams.logfile.vars.tags",
+				Changes: []ValueChange{{Field: "default", From: []interface{}{"foo"}, To: []interface{}{"foo", "bar"}}},
+			},
+			{
+				Name:    "policy_templates.foo.inputs.logfile.vars.paths",
+				Changes: []ValueChange{{Field: "required", From: false, To: true}},
+			},
+			{
+				Name:    "vars.hosts",
+				Changes: []ValueChange{{Field: "type", From: "text", To: "url"}},
+			},
+		},
+	}, diff.Vars)
+	assert.Equal(t, []ValueChange{
+		{Field: "kibana.version", From: "^8.0.0", To: "^8.10.0"},
+		{Field: "agent.version", From: "", To: "^8.10.0"},
+	}, diff.Conditions)
+	assert.Equal(t, []ValueChange{
+		{Field: "cluster", From: []string{"monitor"}, To: []string{}},
+		{Field: "data_streams.logs.indices", From: []string{}, To: []string{"auto_configure"}},
+	}, diff.ElasticsearchPrivileges)
+	assert.Equal(t, ElementsDiff{
+		Added:   []string{"data_stream/events/manifest.yml"},
+		Removed: []string{"data_stream/metrics/manifest.yml"},
+		Changed: []ElementChange{},
+	}, diff.Assets)
+}
@@ -0,0 +1,107 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	yamlv2 "gopkg.in/yaml.v2"
+)
+
+// Field is the definition of a field of a data stream, with its flattened name.
+type Field struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	// External is the schema where the field is defined, as "ecs" for ECS fields.
+	External   string `json:"external,omitempty"`
+	Unit       string `json:"unit,omitempty"`
+	MetricType string `json:"metric_type,omitempty"`
+}
+
+// fieldDefinition is a field as defined in the fields files, where group fields contain
+// other fields.
+type fieldDefinition struct {
+	Name        string            `yaml:"name"`
+	Type        string            `yaml:"type"`
+	Description string            `yaml:"description"`
+	External    string            `yaml:"external"`
+	Unit        string            `yaml:"unit"`
+	MetricType  string            `yaml:"metric_type"`
+	Fields      []fieldDefinition `yaml:"fields"`
+}
+
+// ParseFields parses the content of a fields file and returns the definitions of its fields,
+// with the names of the fields in groups prefixed by the names of the groups.
+func ParseFields(d []byte) ([]Field, error) {
+	var definitions []fieldDefinition
+	err := yamlv2.Unmarshal(d, &definitions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fields: %w", err)
+	}
+	return flattenFields("", definitions), nil
+}
+
+func flattenFields(prefix string, definitions []fieldDefinition) []Field {
+	var fields []Field
+	for _, definition := range definitions {
+		name := definition.Name
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		// Groups only contain other fields, other fields with subfields, like nested or
+		// object fields, are also fields by themselves.
+		if len(definition.Fields) == 0 || (definition.Type != "" && definition.Type != "group") {
+			fields = append(fields, Field{
+				Name:        name,
+				Type:        definition.Type,
+				Description: strings.TrimSpace(definition.Description),
+				External:    definition.External,
+				Unit:        definition.Unit,
+				MetricType:  definition.MetricType,
+			})
+		}
+		fields = append(fields, flattenFields(name, definition.Fields)...)
+	}
+	return fields
+}
+
+// ReadDataStreamFields reads the definitions of the fields of a data stream from the files
+// in its fields directory. Files are found in the assets of the package, so the package must
+// be loaded with its full data. Files of remote packages are obtained with their remote
+// resolver, redirections are followed with the given client.
+func ReadDataStreamFields(ctx context.Context, client *http.Client, p *Package, ds *DataStream) ([]Field, error) {
+	fieldsDir := path.Join("data_stream", ds.Path, "fields")
+	var files []string
+	for _, asset := range packageAssets(p) {
+		if path.Dir(asset) != fieldsDir {
+			continue
+		}
+		if ext := path.Ext(asset); ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+		files = append(files, asset)
+	}
+	slices.Sort(files)
+
+	fields := []Field{}
+	for _, file := range files {
+		d, err := ReadPackageResource(ctx, client, p, file)
+		if err != nil {
+			return nil, fmt.Errorf("reading fields file failed (path: %s): %w", file, err)
+		}
+		f, err := ParseFields(d)
+		if err != nil {
+			return nil, fmt.Errorf("parsing fields file failed (path: %s): %w", file, err)
+		}
+		fields = append(fields, f...)
+	}
+	return fields, nil
+}
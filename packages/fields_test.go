@@ -0,0 +1,88 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testFields = `
+- name: '@timestamp'
+  external: ecs
+- name: host
+  type: group
+  fields:
+    - name: cpu.usage
+      type: scaled_float
+      unit: percent
+      metric_type: gauge
+      description: |
+        Percent CPU used.
+- name: process
+  type: nested
+  fields:
+    - name: pid
+      type: long
+- name: labels
+  fields:
+    - name: env
+      type: keyword
+`
+
+func TestParseFields(t *testing.T) {
+	fields, err := ParseFields([]byte(testFields))
+	require.NoError(t, err)
+	assert.Equal(t, []Field{
+		{Name: "@timestamp", External: "ecs"},
+		{Name: "host.cpu.usage", Type: "scaled_float", Unit: "percent", MetricType: "gauge", Description: "Percent CPU used."},
+		{Name: "process", Type: "nested"},
+		{Name: "process.pid", Type: "long"},
+		{Name: "labels.env", Type: "keyword"},
+	}, fields)
+
+	_, err = ParseFields([]byte("name: foo"))
+	assert.Error(t, err)
+}
+
+func TestReadRemoteDataStreamFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/package/foo/1.0.0/data_stream/logs/fields/base-fields.yml":
+			w.Write([]byte("- name: '@timestamp'\n  type: date\n"))
+		case "/package/foo/1.0.0/data_stream/logs/fields/fields.yml":
+			w.Write([]byte(testFields))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	p := &Package{
+		BasePackage: BasePackage{Name: "foo", Version: "1.0.0"},
+		Assets: []string{
+			"/package/foo/1.0.0/manifest.yml",
+			"/package/foo/1.0.0/data_stream/logs/manifest.yml",
+			"/package/foo/1.0.0/data_stream/logs/fields/fields.yml",
+			"/package/foo/1.0.0/data_stream/logs/fields/base-fields.yml",
+			"/package/foo/1.0.0/data_stream/metrics/fields/fields.yml",
+		},
+	}
+	p.SetRemoteResolver(redirectResolver{url: server.URL})
+
+	fields, err := ReadDataStreamFields(t.Context(), server.Client(), p, &DataStream{Path: "logs"})
+	require.NoError(t, err)
+	require.Len(t, fields, 6)
+	assert.Equal(t, Field{Name: "@timestamp", Type: "date"}, fields[0])
+	assert.Equal(t, "labels.env", fields[5].Name)
+
+	fields, err = ReadDataStreamFields(t.Context(), server.Client(), p, &DataStream{Path: "traces"})
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+}
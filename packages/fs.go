@@ -112,6 +112,11 @@ func NewZipPackageFileSystem(p *Package) (*ZipPackageFileSystem, error) {
 	}, nil
 }
 
+// Root returns the directory of the zip file that contains the package.
+func (fs *ZipPackageFileSystem) Root() string {
+	return fs.root
+}
+
 func (fs *ZipPackageFileSystem) Stat(name string) (os.FileInfo, error) {
 	path := path.Join(fs.root, name)
 	f, err := fs.reader.Open(path)
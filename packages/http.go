@@ -5,8 +5,15 @@
 package packages
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
 	"net/http"
 	"os"
+	"path"
 
 	"go.elastic.co/apm/v2"
 	"go.uber.org/zap"
@@ -14,6 +21,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/elastic/package-registry/archiver"
+	"github.com/elastic/package-registry/internal/util"
 	"github.com/elastic/package-registry/metrics"
 )
 
@@ -138,8 +146,162 @@ func ServePackageResource(logger *zap.Logger, w http.ResponseWriter, r *http.Req
 	}
 	defer f.Close()
 
-	http.ServeContent(w, r, packageFilePath, stat.ModTime(), f)
+	var content io.ReadSeeker = f
+	contentType := mime.TypeByExtension(path.Ext(packageFilePath))
+	if util.CompressibleContentType(contentType) && stat.Size() >= util.CompressionMinSize {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := util.NegotiateEncoding(r.Header.Get("Accept-Encoding"))
+		// Range requests are served uncompressed, so ranges refer to the original content.
+		if encoding != "" && r.Header.Get("Range") == "" {
+			compressed, err := compressResource(f, encoding)
+			if err != nil {
+				logger.Error("failed to compress file", zap.String("encoding", encoding), zap.Error(err))
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Encoding", encoding)
+			content = bytes.NewReader(compressed)
+		}
+	}
+
+	http.ServeContent(w, r, packageFilePath, stat.ModTime(), content)
 	metrics.StorageRequestsTotal.With(
 		prometheus.Labels{"location": localLocationPrometheusLabel, "component": staticComponentPrometheusLabel},
 	).Inc()
 }
+
+func compressResource(r io.Reader, encoding string) ([]byte, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return util.Compress(encoding, content)
+}
+
+// maxPackageResourceSize is the maximum size of the package files read by ReadPackageResource.
+const maxPackageResourceSize = 10 << 20
+
+// ReadPackageResource returns the content of a file of the package. Files of remote packages
+// are obtained with their remote resolver, redirections are followed with the given client.
+// An error wrapping fs.ErrNotExist is returned if the file doesn't exist.
+func ReadPackageResource(ctx context.Context, client *http.Client, p *Package, packageFilePath string) ([]byte, error) {
+	span, ctx := apm.StartSpan(ctx, "ReadPackageResource", "app")
+	span.Context.SetLabel("file.name", packageFilePath)
+	defer span.End()
+
+	if p.RemoteResolver() != nil {
+		metrics.StorageRequestsTotal.With(
+			prometheus.Labels{"location": remoteLocationPrometheusLabel, "component": staticComponentPrometheusLabel},
+		).Inc()
+		return readRemotePackageResource(ctx, client, p, packageFilePath)
+	}
+
+	packageFS, err := p.fs()
+	if err != nil {
+		return nil, err
+	}
+	defer packageFS.Close()
+
+	f, err := packageFS.Open(packageFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	metrics.StorageRequestsTotal.With(
+		prometheus.Labels{"location": localLocationPrometheusLabel, "component": staticComponentPrometheusLabel},
+	).Inc()
+	return readLimited(f)
+}
+
+func readRemotePackageResource(ctx context.Context, client *http.Client, p *Package, packageFilePath string) ([]byte, error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, path.Join("/package", p.Name, p.Version, packageFilePath), nil)
+	if err != nil {
+		return nil, err
+	}
+	var response resourceResponse
+	p.RemoteResolver().StaticHandler(&response, r, p, packageFilePath)
+
+	switch response.statusCode() {
+	case http.StatusOK:
+		if response.err != nil {
+			return nil, response.err
+		}
+		return response.body.Bytes(), nil
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("resource %s not found: %w", packageFilePath, fs.ErrNotExist)
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		location, err := r.URL.Parse(response.Header().Get("Location"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid redirection: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return readLimited(resp.Body)
+		case http.StatusNotFound:
+			return nil, fmt.Errorf("resource %s not found: %w", packageFilePath, fs.ErrNotExist)
+		default:
+			return nil, fmt.Errorf("unexpected status code %d getting %s", resp.StatusCode, location)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected status code %d getting resource %s", response.statusCode(), packageFilePath)
+	}
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	d, err := io.ReadAll(io.LimitReader(r, maxPackageResourceSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(d) > maxPackageResourceSize {
+		return nil, fmt.Errorf("resource larger than %d bytes", maxPackageResourceSize)
+	}
+	return d, nil
+}
+
+// resourceResponse is a response writer that keeps the response in memory.
+type resourceResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	err    error
+}
+
+func (rr *resourceResponse) Header() http.Header {
+	if rr.header == nil {
+		rr.header = make(http.Header)
+	}
+	return rr.header
+}
+
+func (rr *resourceResponse) WriteHeader(statusCode int) {
+	if rr.status == 0 {
+		rr.status = statusCode
+	}
+}
+
+func (rr *resourceResponse) Write(d []byte) (int, error) {
+	rr.WriteHeader(http.StatusOK)
+	if rr.body.Len()+len(d) > maxPackageResourceSize {
+		rr.err = fmt.Errorf("resource larger than %d bytes", maxPackageResourceSize)
+		return 0, rr.err
+	}
+	return rr.body.Write(d)
+}
+
+func (rr *resourceResponse) statusCode() int {
+	if rr.status == 0 {
+		return http.StatusOK
+	}
+	return rr.status
+}
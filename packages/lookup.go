@@ -0,0 +1,200 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// fieldNamesReaderWorkers is the number of packages whose fields are read concurrently
+// by FieldNamesReader.Preload.
+const fieldNamesReaderWorkers = 8
+
+// PackageDatasets returns the datasets of the data streams of the package.
+func PackageDatasets(p *Package) []string {
+	var datasets []string
+	for _, ds := range p.DataStreams {
+		datasets = append(datasets, ds.Dataset)
+	}
+	if len(p.DataStreams) == 0 {
+		for _, ds := range p.BaseDataStreams {
+			datasets = append(datasets, ds.Dataset)
+		}
+	}
+	slices.Sort(datasets)
+	return slices.Compact(datasets)
+}
+
+// PackageFieldNames returns the names of the fields defined in the data streams of the
+// package. Files of remote packages are obtained with their remote resolver, redirections
+// are followed with the given client.
+func PackageFieldNames(ctx context.Context, client *http.Client, p *Package) ([]string, error) {
+	var names []string
+	for _, ds := range p.DataStreams {
+		fields, err := ReadDataStreamFields(ctx, client, p, ds)
+		if err != nil {
+			return nil, fmt.Errorf("reading fields of data stream %s of package %s-%s: %w", ds.Path, p.Name, p.Version, err)
+		}
+		for _, field := range fields {
+			names = append(names, field.Name)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// LoadedFieldNames returns the names of the fields of packages read from the file system,
+// as they were read when the package was loaded.
+func LoadedFieldNames(_ context.Context, p *Package) ([]string, error) {
+	var names []string
+	for _, ds := range p.DataStreams {
+		if ds.fieldNamesErr != nil {
+			return nil, fmt.Errorf("reading fields of data stream %s of package %s-%s: %w", ds.Path, p.Name, p.Version, ds.fieldNamesErr)
+		}
+		names = append(names, ds.fieldNames...)
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// FieldNamesReader reads the names of the fields of remote packages, and keeps them so
+// they are not read again in later updates of the index. The contents of a package version
+// don't change, and packages are rarely removed, so names are never evicted.
+type FieldNamesReader struct {
+	client *http.Client
+
+	mu    sync.Mutex
+	names map[PackageKey][]string
+}
+
+// NewFieldNamesReader creates a reader that follows the redirections of the remote
+// resolvers with the given client.
+func NewFieldNamesReader(client *http.Client) *FieldNamesReader {
+	return &FieldNamesReader{
+		client: client,
+		names:  make(map[PackageKey][]string),
+	}
+}
+
+// FieldNames returns the names of the fields of the package.
+func (r *FieldNamesReader) FieldNames(ctx context.Context, p *Package) ([]string, error) {
+	key := PackageKey{Name: p.Name, Version: p.Version}
+	r.mu.Lock()
+	names, found := r.names[key]
+	r.mu.Unlock()
+	if found {
+		return names, nil
+	}
+
+	names, err := PackageFieldNames(ctx, r.client, p)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.names[key] = names
+	r.mu.Unlock()
+	return names, nil
+}
+
+// Loaded returns the names of the fields of the package if they have been already read,
+// without reading them otherwise.
+func (r *FieldNamesReader) Loaded(_ context.Context, p *Package) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.names[PackageKey{Name: p.Name, Version: p.Version}], nil
+}
+
+// Preload reads concurrently the names of the fields of the packages not read yet. Errors
+// reading some package don't prevent reading the others, all of them are returned.
+func (r *FieldNamesReader) Preload(ctx context.Context, packages Packages) error {
+	var mu sync.Mutex
+	var errs []error
+	var g errgroup.Group
+	g.SetLimit(fieldNamesReaderWorkers)
+	for _, p := range packages {
+		g.Go(func() error {
+			_, err := r.FieldNames(ctx, p)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	g.Wait()
+	return errors.Join(errs...)
+}
+
+// LookupIndex is a reverse index of the datasets and the fields of a list of packages,
+// used to resolve the Dataset and Field filters.
+type LookupIndex struct {
+	datasets map[string]map[*Package]struct{}
+	fields   map[string]map[*Package]struct{}
+}
+
+// NewLookupIndex builds the lookup index for the given packages. fieldNames obtains the
+// names of the fields of each package, fields are not indexed if it is nil.
+func NewLookupIndex(ctx context.Context, packages Packages, fieldNames func(context.Context, *Package) ([]string, error)) (*LookupIndex, error) {
+	index := LookupIndex{
+		datasets: make(map[string]map[*Package]struct{}),
+		fields:   make(map[string]map[*Package]struct{}),
+	}
+	add := func(entries map[string]map[*Package]struct{}, key string, p *Package) {
+		if entries[key] == nil {
+			entries[key] = make(map[*Package]struct{})
+		}
+		entries[key][p] = struct{}{}
+	}
+	for _, p := range packages {
+		for _, dataset := range PackageDatasets(p) {
+			add(index.datasets, dataset, p)
+		}
+		if fieldNames == nil {
+			continue
+		}
+		names, err := fieldNames(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			add(index.fields, name, p)
+		}
+	}
+	return &index, nil
+}
+
+// Select returns the packages of the list that match the Dataset and Field filters.
+// The list is returned as is if none of them is set.
+func (idx *LookupIndex) Select(packages Packages, filter *Filter) Packages {
+	if filter == nil || (filter.Dataset == "" && filter.Field == "") {
+		return packages
+	}
+	if idx == nil {
+		return nil
+	}
+	var result Packages
+	for _, p := range packages {
+		if filter.Dataset != "" {
+			if _, found := idx.datasets[filter.Dataset][p]; !found {
+				continue
+			}
+		}
+		if filter.Field != "" {
+			if _, found := idx.fields[filter.Field][p]; !found {
+				continue
+			}
+		}
+		result = append(result, p)
+	}
+	return result
+}
@@ -0,0 +1,144 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/elastic/package-registry/internal/util"
+)
+
+func TestPackageDatasets(t *testing.T) {
+	p := &Package{
+		DataStreams: []*DataStream{
+			{Dataset: "foo.logs"},
+			{Dataset: "foo.events"},
+			{Dataset: "foo.logs"},
+		},
+	}
+	assert.Equal(t, []string{"foo.events", "foo.logs"}, PackageDatasets(p))
+
+	p = &Package{
+		BasePackage: BasePackage{
+			BaseDataStreams: []*BaseDataStream{{Dataset: "foo.metrics"}},
+		},
+	}
+	assert.Equal(t, []string{"foo.metrics"}, PackageDatasets(p))
+}
+
+func TestLookupIndex(t *testing.T) {
+	nginx := &Package{
+		BasePackage: BasePackage{Name: "nginx", Version: "1.0.0"},
+		DataStreams: []*DataStream{{Dataset: "nginx.access"}, {Dataset: "nginx.error"}},
+	}
+	apache := &Package{
+		BasePackage: BasePackage{Name: "apache", Version: "1.0.0"},
+		DataStreams: []*DataStream{{Dataset: "apache.access"}},
+	}
+	fields := map[*Package][]string{
+		nginx:  {"nginx.access.remote_ip_list", "source.ip"},
+		apache: {"apache.access.ssl.protocol", "source.ip"},
+	}
+	pkgs := Packages{nginx, apache}
+
+	index, err := NewLookupIndex(t.Context(), pkgs, func(_ context.Context, p *Package) ([]string, error) {
+		return fields[p], nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, pkgs, index.Select(pkgs, &Filter{}))
+	assert.Equal(t, Packages{nginx}, index.Select(pkgs, &Filter{Dataset: "nginx.error"}))
+	assert.Equal(t, pkgs, index.Select(pkgs, &Filter{Field: "source.ip"}))
+	assert.Equal(t, Packages{apache}, index.Select(pkgs, &Filter{Dataset: "apache.access", Field: "source.ip"}))
+	assert.Empty(t, index.Select(pkgs, &Filter{Dataset: "apache.access", Field: "nginx.access.remote_ip_list"}))
+	assert.Empty(t, index.Select(pkgs, &Filter{Dataset: "redis.info"}))
+
+	// Only selected packages of the given list are returned.
+	assert.Equal(t, Packages{apache}, index.Select(Packages{apache}, &Filter{Field: "source.ip"}))
+
+	// Fields are not indexed without a function to read them.
+	index, err = NewLookupIndex(t.Context(), pkgs, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Packages{nginx}, index.Select(pkgs, &Filter{Dataset: "nginx.access"}))
+	assert.Empty(t, index.Select(pkgs, &Filter{Field: "source.ip"}))
+}
+
+func TestFieldNamesReader(t *testing.T) {
+	fsBuilder := func(p *Package) (PackageFileSystem, error) {
+		return NewExtractedPackageFileSystem(p)
+	}
+	logger := util.NewTestLoggerLevel(zapcore.FatalLevel)
+	p, err := NewPackage(logger, "../testdata/package/datastream_without_release/0.1.0", fsBuilder)
+	require.NoError(t, err)
+
+	reader := NewFieldNamesReader(nil)
+	names, err := reader.Loaded(t.Context(), p)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	require.NoError(t, reader.Preload(t.Context(), Packages{p}))
+	names, err = reader.Loaded(t.Context(), p)
+	require.NoError(t, err)
+	assert.Contains(t, names, "apache_spark.nodes.main.applications.count")
+	assert.Contains(t, names, "data_stream.dataset")
+	assert.IsIncreasing(t, names)
+}
+
+func TestLoadedFieldNames(t *testing.T) {
+	fsBuilder := func(p *Package) (PackageFileSystem, error) {
+		return NewExtractedPackageFileSystem(p)
+	}
+	logger := util.NewTestLoggerLevel(zapcore.FatalLevel)
+	p, err := NewPackage(logger, "../testdata/package/datastream_without_release/0.1.0", fsBuilder)
+	require.NoError(t, err)
+
+	names, err := LoadedFieldNames(t.Context(), p)
+	require.NoError(t, err)
+	assert.Contains(t, names, "apache_spark.nodes.main.applications.count")
+	assert.Contains(t, names, "data_stream.dataset")
+	assert.IsIncreasing(t, names)
+
+	expected, err := PackageFieldNames(t.Context(), nil, p)
+	require.NoError(t, err)
+	assert.Equal(t, expected, names)
+}
+
+func TestFileSystemIndexerFieldsLookup(t *testing.T) {
+	packagesPath := t.TempDir()
+	copyTestPackage := func(name, version string) string {
+		dest := filepath.Join(packagesPath, name, version)
+		require.NoError(t, os.CopyFS(dest, os.DirFS(filepath.Join("..", "testdata", "package", name, version))))
+		return dest
+	}
+	copyTestPackage("datastream_without_release", "0.1.0")
+	dest := copyTestPackage("example", "1.0.0")
+
+	// Fields file that is valid YAML, but not a valid definition of fields.
+	brokenFields := "- name: broken\n  fields: not a list\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "data_stream", "foo", "fields", "broken.yml"), []byte(brokenFields), 0644))
+
+	indexer := NewFileSystemIndexer(FSIndexerOptions{
+		Logger: util.NewTestLoggerLevel(zapcore.FatalLevel),
+	}, packagesPath)
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+	require.NoError(t, indexer.Init(t.Context()))
+
+	pkgs, err := indexer.Get(t.Context(), &GetOptions{Filter: &Filter{Field: "data_stream.dataset"}})
+	require.NoError(t, err)
+	require.Len(t, pkgs, 1)
+	assert.Equal(t, "datastream_without_release", pkgs[0].Name)
+
+	// The package with invalid fields is still indexed, without fields.
+	pkgs, err = indexer.Get(t.Context(), &GetOptions{Filter: &Filter{PackageName: "example"}})
+	require.NoError(t, err)
+	require.Len(t, pkgs, 1)
+}
@@ -49,6 +49,10 @@ type Package struct {
 	Vars            []Variable            `config:"vars" json:"vars,omitempty" yaml:"vars,omitempty"`
 	Elasticsearch   *PackageElasticsearch `config:"elasticsearch,omitempty" json:"elasticsearch,omitempty" yaml:"elasticsearch,omitempty"`
 	Agent           *PackageAgent         `config:"agent,omitempty" json:"agent,omitempty" yaml:"agent,omitempty"`
+	// SignatureVerification is the result of verifying the signature of the package, if
+	// signatures are verified by the indexer.
+	SignatureVerification *SignatureVerification `json:"signature_verification,omitempty" yaml:"signature_verification,omitempty"`
+
 	// Local path to the package dir
 	BasePath string `json:"-" yaml:"-"`
 
@@ -97,6 +101,7 @@ type BasePackage struct {
 	Deprecated              *Deprecated          `config:"deprecated,omitempty" json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
 	Requires                *PackageRequirements `config:"requires,omitempty" json:"requires,omitempty" yaml:"requires,omitempty"`
 	Group                   string               `config:"group,omitempty" json:"group,omitempty" yaml:"group,omitempty"`
+	Visibility              string               `config:"visibility,omitempty" json:"visibility,omitempty" yaml:"visibility,omitempty"`
 }
 
 // BasePolicyTemplate is used for the package policy templates in the /search endpoint
@@ -700,6 +705,16 @@ func (p *Package) LoadAssets() (err error) {
 	return nil
 }
 
+// packageAssets returns the paths of the assets relative to the root of the package.
+func packageAssets(p *Package) []string {
+	prefix := path.Join(packagePathPrefix, p.GetPath()) + "/"
+	assets := make([]string, 0, len(p.Assets))
+	for _, a := range p.Assets {
+		assets = append(assets, strings.TrimPrefix(a, prefix))
+	}
+	return assets
+}
+
 func collectAssets(fs PackageFileSystem, pattern string) ([]string, error) {
 	assets, err := fs.Glob(pattern)
 	if err != nil {
@@ -14,6 +14,7 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
@@ -106,12 +107,33 @@ type GetOptions struct {
 
 	FullData        bool
 	SkipPackageData bool
+
+	// After and Limit select a page of packages sorted by name and version. If After
+	// is set, only packages sorted after it are returned. If Limit is set, at most this
+	// number of packages are returned.
+	After *PackageKey
+	Limit int
+}
+
+// Paginated returns true if the options select a page of packages.
+func (o *GetOptions) Paginated() bool {
+	return o != nil && (o.After != nil || o.Limit > 0)
+}
+
+// SelectPage returns the page of the packages selected by the options, if they are paginated.
+func (o *GetOptions) SelectPage(packages Packages) Packages {
+	if !o.Paginated() {
+		return packages
+	}
+	return SelectPage(packages, o.After, o.Limit)
 }
 
 // FileSystemIndexer indexes packages from the filesystem.
 type FileSystemIndexer struct {
 	paths       []string
 	packageList Packages
+	textIndex   *TextIndex
+	lookupIndex *LookupIndex
 
 	deprecatedPackages DeprecatedPackages
 
@@ -134,9 +156,31 @@ type FileSystemIndexer struct {
 	// requireSignatures enforces that all indexed packages must have a signature file.
 	requireSignatures bool
 
+	// signatureVerifier verifies the signatures of the packages, if set.
+	signatureVerifier *SignatureVerifier
+
+	// rejectInvalidSignatures leaves packages with invalid signatures out of the index,
+	// instead of flagging them in their signature verification.
+	rejectInvalidSignatures bool
+
+	// indexChangesHook is called with the packages changed in each update of the index,
+	// found comparing the fingerprints of the packages of the previous update.
+	indexChangesHook IndexChangesHook
+	fingerprints     PackageFingerprints
+
+	// fingerprintsPath is the file where the fingerprints are persisted, if set, so the
+	// changes made while the registry is stopped are notified on the initial load.
+	fingerprintsPath string
+
+	// updateMu serializes the updates of the index, so their changes are notified and
+	// persisted in order.
+	updateMu sync.Mutex
+
 	m sync.RWMutex
 
 	apmTracer *apm.Tracer
+
+	status IndexerStatusTracker
 }
 
 type FSIndexerOptions struct {
@@ -150,6 +194,24 @@ type FSIndexerOptions struct {
 	// RequireSignatures enforces that all packages must have a signature file.
 	// Should be disabled for self-hosted registries with custom unsigned packages.
 	RequireSignatures bool
+
+	// SignatureVerifier is used to verify the signatures of zipped packages, if set.
+	SignatureVerifier *SignatureVerifier
+
+	// RejectInvalidSignatures leaves packages with invalid signatures out of the index, they
+	// are logged and counted in the status of the indexer, but they don't make indexing fail.
+	// Otherwise these packages are indexed, with the result of the verification.
+	RejectInvalidSignatures bool
+
+	// IndexChangesHook, if set, is called with the packages changed by each update of the
+	// index. It is not called for the initial load of the packages, unless FingerprintsDir
+	// is set.
+	IndexChangesHook IndexChangesHook
+
+	// FingerprintsDir, if set, is the directory where the fingerprints of the packages are
+	// persisted, so the initial load is compared with the packages indexed before stopping,
+	// and the changes made meanwhile are also passed to IndexChangesHook.
+	FingerprintsDir string
 }
 
 // NewFileSystemIndexer creates a new FileSystemIndexer for the given paths.
@@ -198,6 +260,8 @@ func NewFileSystemIndexer(options FSIndexerOptions, paths ...string) *FileSystem
 		pathsWorkers:       pathWorkers,
 		requireSignatures:  options.RequireSignatures,
 		deprecatedPackages: make(DeprecatedPackages),
+		indexChangesHook:   options.IndexChangesHook,
+		fingerprintsPath:   FingerprintsPath(options.FingerprintsDir, fileSystemIndexerName, paths...),
 	}
 }
 
@@ -243,6 +307,12 @@ func NewZipFileSystemIndexer(options FSIndexerOptions, paths ...string) *FileSys
 		pathsWorkers:       pathWorkers,
 		requireSignatures:  options.RequireSignatures,
 		deprecatedPackages: make(DeprecatedPackages),
+		indexChangesHook:   options.IndexChangesHook,
+		fingerprintsPath:   FingerprintsPath(options.FingerprintsDir, zipFileSystemIndexerName, paths...),
+
+		// Signatures are created for the zipped packages, so only these can be verified.
+		signatureVerifier:       options.SignatureVerifier,
+		rejectInvalidSignatures: options.RejectInvalidSignatures,
 	}
 }
 
@@ -341,19 +411,104 @@ func (i *FileSystemIndexer) watchPackageFileSystem(ctx context.Context) {
 	}
 }
 
+// Refresh reloads the packages from the file system, so changes are available without
+// waiting for the paths watcher.
+func (i *FileSystemIndexer) Refresh(ctx context.Context) error {
+	return i.updatePackageFileSystemIndex(ctx)
+}
+
 func (i *FileSystemIndexer) updatePackageFileSystemIndex(ctx context.Context) error {
+	i.updateMu.Lock()
+	defer i.updateMu.Unlock()
+
+	changes, fingerprints, err := i.updatePackageList(ctx)
+	if err != nil {
+		return err
+	}
+	// The hook is called without holding the lock, so it can use the indexer.
+	if !changes.Empty() {
+		i.indexChangesHook(ctx, changes)
+	}
+	// Fingerprints are persisted once the changes have been notified, so they are notified
+	// again if the registry stops before.
+	if fingerprints != nil && i.fingerprintsPath != "" {
+		err := fingerprints.WriteFile(i.fingerprintsPath)
+		if err != nil {
+			i.logger.Error("failed to persist fingerprints of the packages", zap.String("indexer", i.label), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// updatePackageList reads the packages from the file system, and returns the changes
+// since the previous update if there is an index changes hook. The fingerprints of the
+// packages are also returned if they need to be persisted.
+func (i *FileSystemIndexer) updatePackageList(ctx context.Context) (IndexChanges, PackageFingerprints, error) {
 	i.m.Lock()
 	defer i.m.Unlock()
 
-	newPackageList, err := i.getPackagesFromFileSystem(ctx)
+	newPackageList, rejected, err := i.getPackagesFromFileSystem(ctx)
 	if err != nil {
-		return err
+		i.status.UpdateFailed(err)
+		return IndexChanges{}, nil, err
+	}
+	lookupIndex, err := NewLookupIndex(ctx, newPackageList, i.fieldNames)
+	if err != nil {
+		i.status.UpdateFailed(err)
+		return IndexChanges{}, nil, err
 	}
 	i.packageList = newPackageList
+	i.textIndex = NewTextIndex(i.packageList)
+	i.lookupIndex = lookupIndex
+	i.status.UpdateSucceeded("", len(i.packageList))
+	i.status.SetRejectedPackages(rejected)
 	// set the deprecated notice information once the package list is updated
 	UpdateLatestDeprecatedPackagesMapByName(i.packageList, i.deprecatedPackages)
 	PropagateLatestDeprecatedInfoToPackageList(i.packageList, i.deprecatedPackages)
-	return nil
+
+	if i.indexChangesHook == nil {
+		return IndexChanges{}, nil, nil
+	}
+	fingerprints, err := NewPackageFingerprints(i.packageList)
+	if err != nil {
+		i.logger.Error("failed to obtain changes in the index", zap.String("indexer", i.label), zap.Error(err))
+		return IndexChanges{}, nil, nil
+	}
+	initialLoad := i.fingerprints == nil
+	previous := i.fingerprints
+	if initialLoad && i.fingerprintsPath != "" {
+		previous, err = ReadPackageFingerprints(i.fingerprintsPath)
+		if err != nil {
+			i.logger.Error("failed to read persisted fingerprints of the packages, changes since the last run are not notified",
+				zap.String("indexer", i.label), zap.Error(err))
+		}
+	}
+	var changes IndexChanges
+	if previous != nil {
+		changes = previous.Changes(fingerprints)
+		changes.Indexer = i.label
+	}
+	i.fingerprints = fingerprints
+	if !initialLoad && changes.Empty() {
+		return changes, nil, nil
+	}
+	return changes, fingerprints, nil
+}
+
+// fieldNames returns the names of the fields of the package read when it was loaded. Packages
+// whose fields couldn't be read are indexed without fields, so they can still be found with
+// other filters.
+func (i *FileSystemIndexer) fieldNames(ctx context.Context, p *Package) ([]string, error) {
+	names, err := LoadedFieldNames(ctx, p)
+	if err != nil {
+		i.logger.Warn("package indexed without fields, they couldn't be read",
+			zap.String("package.name", p.Name),
+			zap.String("package.version", p.Version),
+			zap.String("indexer", i.label),
+			zap.Error(err))
+		return nil, nil
+	}
+	return names, nil
 }
 
 // Get returns a slice with packages.
@@ -379,17 +534,35 @@ func (i *FileSystemIndexer) Get(ctx context.Context, opts *GetOptions) (Packages
 	}
 
 	if opts.Filter != nil {
-		return opts.Filter.Apply(ctx, i.packageList)
+		packageList := i.packageList
+		if opts.Filter.Query != "" {
+			packageList = i.textIndex.Search(opts.Filter.Query)
+		}
+		packageList = i.lookupIndex.Select(packageList, opts.Filter)
+		packageList, err := opts.Filter.Apply(ctx, packageList)
+		if err != nil {
+			return nil, err
+		}
+		return opts.SelectPage(packageList), nil
 	}
 
-	return i.packageList, nil
+	return opts.SelectPage(i.packageList), nil
 }
 
 func (i *FileSystemIndexer) Close(ctx context.Context) error {
 	return nil
 }
 
-func (i *FileSystemIndexer) getPackagesFromFileSystem(ctx context.Context) (Packages, error) {
+// Status returns the status of the last updates of the index.
+func (i *FileSystemIndexer) Status(ctx context.Context) IndexerStatus {
+	status := i.status.Status()
+	status.Name = i.label
+	return status
+}
+
+// getPackagesFromFileSystem reads the packages in the paths of the indexer. It also returns
+// the number of packages rejected because of their invalid signatures.
+func (i *FileSystemIndexer) getPackagesFromFileSystem(ctx context.Context) (Packages, int, error) {
 	span, _ := apm.StartSpan(ctx, "GetFromFileSystem", "app")
 	span.Context.SetLabel("indexer", i.label)
 	defer span.End()
@@ -403,11 +576,12 @@ func (i *FileSystemIndexer) getPackagesFromFileSystem(ctx context.Context) (Pack
 	for _, basePath := range i.paths {
 		packagePaths, err := i.getPackagePaths(basePath)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		allPackagePaths = append(allPackagePaths, packagePaths...)
 	}
 	pList := make(Packages, len(allPackagePaths))
+	var rejected atomic.Int64
 
 	taskPool := workers.NewTaskPool(i.pathsWorkers)
 
@@ -421,6 +595,26 @@ func (i *FileSystemIndexer) getPackagesFromFileSystem(ctx context.Context) (Pack
 			if i.requireSignatures && p.SignaturePath == "" {
 				return fmt.Errorf("package %s-%s is missing a required signature file", p.Name, p.Version)
 			}
+			if i.signatureVerifier != nil && p.SignaturePath != "" {
+				verification := i.signatureVerifier.Verify(path, path+".sig")
+				if !verification.Verified {
+					if i.rejectInvalidSignatures {
+						// Only this package is left out, the rest of the index is still updated.
+						i.logger.Error("package rejected because of its invalid signature",
+							zap.String("package.name", p.Name),
+							zap.String("package.version", p.Version),
+							zap.String("package.path", path),
+							zap.String("error.message", verification.Error))
+						rejected.Add(1)
+						return nil
+					}
+					i.logger.Warn("package has an invalid signature",
+						zap.String("package.name", p.Name),
+						zap.String("package.version", p.Version),
+						zap.String("error.message", verification.Error))
+				}
+				p.SignatureVerification = &verification
+			}
 
 			pList[position] = p
 
@@ -434,7 +628,7 @@ func (i *FileSystemIndexer) getPackagesFromFileSystem(ctx context.Context) (Pack
 	}
 
 	if err := taskPool.Wait(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Remove duplicates while preserving the package discovery order in the paths set in the configuration.
@@ -445,6 +639,10 @@ func (i *FileSystemIndexer) getPackagesFromFileSystem(ctx context.Context) (Pack
 	current := 0
 	packagesFound := make(map[packageKey]struct{})
 	for _, p := range pList {
+		if p == nil {
+			// Rejected package.
+			continue
+		}
 		key := packageKey{name: p.Name, version: p.Version}
 		if _, found := packagesFound[key]; found {
 			i.logger.Debug("duplicated package",
@@ -462,7 +660,7 @@ func (i *FileSystemIndexer) getPackagesFromFileSystem(ctx context.Context) (Pack
 
 	i.logger.Info("Searching packages in filesystem done", zap.String("indexer", i.label), zap.Int("packages.size", len(pList)))
 
-	return pList, nil
+	return pList, int(rejected.Load()), nil
 }
 
 // getPackagePaths returns list of available packages, one for each version.
@@ -512,6 +710,19 @@ type Filter struct {
 	Discovery      discoveryFilters
 	AgentVersion   *semver.Version
 
+	// Query is a free-text query. It is not evaluated by Apply, indexers resolve
+	// it with their text indexes before applying the rest of the filter.
+	Query string
+
+	// Dataset and Field select the packages with a data stream with the given dataset, or
+	// defining the given field. They are not evaluated by Apply either, indexers resolve
+	// them with their lookup indexes.
+	Dataset string
+	Field   string
+
+	// Restrictions are the access rules the caller is not entitled to.
+	Restrictions AccessRestrictions
+
 	// Deprecated, release tags to be removed.
 	Experimental bool
 }
@@ -638,6 +849,11 @@ func (f *Filter) Apply(ctx context.Context, packages Packages) (Packages, error)
 		latestIdx = make(map[string]int, len(packages))
 	}
 	for _, p := range packages {
+		// Skip packages the caller is not entitled to, before selecting the latest versions.
+		if !f.Restrictions.Allows(p) {
+			continue
+		}
+
 		// Skip experimental packages if flag is not specified.
 		if p.Release == ReleaseExperimental && !f.Prerelease {
 			continue
@@ -725,6 +941,10 @@ func (f *Filter) legacyApply(ctx context.Context, packages Packages) Packages {
 		latestIdx = make(map[string]int, len(packages))
 	}
 	for _, p := range packages {
+		if !f.Restrictions.Allows(p) {
+			continue
+		}
+
 		// Skip experimental packages if flag is not specified.
 		if p.Release == ReleaseExperimental && !f.Experimental {
 			continue
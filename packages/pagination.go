@@ -0,0 +1,61 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// PackageKey identifies the position of a package in a list sorted by name and version.
+type PackageKey struct {
+	Name    string
+	Version string
+}
+
+// CompareVersions compares two package versions following semantic versioning.
+// Versions that cannot be parsed are compared as strings.
+func CompareVersions(a, b string) int {
+	aSemVer, aErr := semver.NewVersion(a)
+	bSemVer, bErr := semver.NewVersion(b)
+	if aErr == nil && bErr == nil {
+		if c := aSemVer.Compare(bSemVer); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// Compare compares the key with the name and version of a package, following
+// the same order used to sort Packages.
+func (k PackageKey) Compare(p *Package) int {
+	if c := strings.Compare(k.Name, p.Name); c != 0 {
+		return c
+	}
+	return CompareVersions(k.Version, p.Version)
+}
+
+// SelectPage returns, sorted by name and version, up to limit packages placed after the given key.
+// If after is nil, packages are selected from the beginning, and if limit is zero there is no limit.
+// The original list is not modified.
+func SelectPage(packages Packages, after *PackageKey, limit int) Packages {
+	result := make(Packages, 0, len(packages))
+	for _, p := range packages {
+		if after != nil && after.Compare(p) >= 0 {
+			continue
+		}
+		result = append(result, p)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		key := PackageKey{Name: result[i].Name, Version: result[i].Version}
+		return key.Compare(result[j]) < 0
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
@@ -0,0 +1,47 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, -1, CompareVersions("1.9.0", "1.10.0"))
+	assert.Equal(t, -1, CompareVersions("1.0.0-beta.2", "1.0.0-beta.10"))
+	assert.Equal(t, 1, CompareVersions("1.0.0", "1.0.0-beta.10"))
+	assert.Equal(t, 0, CompareVersions("1.0.0", "1.0.0"))
+	// Same precedence in semver, but still a total order.
+	assert.Equal(t, -1, CompareVersions("1.0.0+build.1", "1.0.0+build.2"))
+	assert.Equal(t, -1, CompareVersions("1.0.0", "invalid"))
+}
+
+func TestSelectPage(t *testing.T) {
+	newPackage := func(name, version string) *Package {
+		return &Package{BasePackage: BasePackage{Name: name, Version: version}}
+	}
+	packageList := Packages{
+		newPackage("nginx", "1.10.0"),
+		newPackage("apache", "1.0.0"),
+		newPackage("nginx", "1.9.0"),
+		newPackage("kubernetes", "1.0.0"),
+	}
+	names := func(packages Packages) (result []string) {
+		for _, p := range packages {
+			result = append(result, p.Name+"-"+p.Version)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"apache-1.0.0", "kubernetes-1.0.0"}, names(SelectPage(packageList, nil, 2)))
+	assert.Equal(t, []string{"nginx-1.9.0", "nginx-1.10.0"}, names(SelectPage(packageList, &PackageKey{Name: "kubernetes", Version: "1.0.0"}, 2)))
+	assert.Equal(t, []string{"nginx-1.10.0"}, names(SelectPage(packageList, &PackageKey{Name: "nginx", Version: "1.9.0"}, 0)))
+	assert.Empty(t, SelectPage(packageList, &PackageKey{Name: "nginx", Version: "1.10.0"}, 2))
+
+	// The original list is not modified.
+	assert.Equal(t, "nginx", packageList[0].Name)
+}
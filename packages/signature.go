@@ -0,0 +1,84 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// SignatureVerification is the result of verifying the signature of a package.
+type SignatureVerification struct {
+	Verified       bool   `json:"verified" yaml:"verified"`
+	KeyFingerprint string `json:"key_fingerprint,omitempty" yaml:"key_fingerprint,omitempty"`
+	Error          string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// SignatureVerifier verifies the detached signatures of package archives with a set of
+// trusted public keys.
+type SignatureVerifier struct {
+	keyRing openpgp.EntityList
+}
+
+// NewSignatureVerifier creates a verifier that trusts the armored public keys in the given files.
+func NewSignatureVerifier(publicKeyPaths ...string) (*SignatureVerifier, error) {
+	if len(publicKeyPaths) == 0 {
+		return nil, errors.New("at least one public key is required")
+	}
+	var keyRing openpgp.EntityList
+	for _, path := range publicKeyPaths {
+		keys, err := readArmoredKeyRing(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key %s: %w", path, err)
+		}
+		keyRing = append(keyRing, keys...)
+	}
+	return &SignatureVerifier{keyRing: keyRing}, nil
+}
+
+func readArmoredKeyRing(path string) (openpgp.EntityList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return openpgp.ReadArmoredKeyRing(f)
+}
+
+// Verify checks the armored detached signature of an archive. Errors reading the files are
+// also reported as a failed verification.
+func (v *SignatureVerifier) Verify(archivePath, signaturePath string) SignatureVerification {
+	signer, err := v.check(archivePath, signaturePath)
+	if err != nil {
+		return SignatureVerification{Error: err.Error()}
+	}
+	return SignatureVerification{
+		Verified:       true,
+		KeyFingerprint: fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint),
+	}
+}
+
+func (v *SignatureVerifier) check(archivePath, signaturePath string) (*openpgp.Entity, error) {
+	signed, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer signed.Close()
+
+	signature, err := os.Open(signaturePath)
+	if err != nil {
+		return nil, err
+	}
+	defer signature.Close()
+
+	signer, err := openpgp.CheckArmoredDetachedSignature(v.keyRing, signed, signature, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	return signer, nil
+}
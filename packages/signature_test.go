@@ -0,0 +1,163 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/package-registry/internal/util"
+)
+
+func TestSignatureVerifier(t *testing.T) {
+	dir := t.TempDir()
+	trusted := newTestSigningKey(t)
+	untrusted := newTestSigningKey(t)
+
+	verifier, err := NewSignatureVerifier(writeTestPublicKey(t, dir, trusted))
+	require.NoError(t, err)
+
+	archive := filepath.Join(dir, "archive.zip")
+	require.NoError(t, os.WriteFile(archive, []byte("content"), 0644))
+
+	writeTestSignature(t, archive, trusted)
+	verification := verifier.Verify(archive, archive+".sig")
+	assert.True(t, verification.Verified)
+	assert.Equal(t, fmt.Sprintf("%X", trusted.PrimaryKey.Fingerprint), verification.KeyFingerprint)
+	assert.Empty(t, verification.Error)
+
+	writeTestSignature(t, archive, untrusted)
+	verification = verifier.Verify(archive, archive+".sig")
+	assert.False(t, verification.Verified)
+	assert.Empty(t, verification.KeyFingerprint)
+	assert.NotEmpty(t, verification.Error)
+
+	writeTestSignature(t, archive, trusted)
+	require.NoError(t, os.WriteFile(archive, []byte("modified content"), 0644))
+	verification = verifier.Verify(archive, archive+".sig")
+	assert.False(t, verification.Verified)
+
+	_, err = NewSignatureVerifier(filepath.Join(dir, "missing.asc"))
+	assert.Error(t, err)
+}
+
+func TestZipFileSystemIndexerSignatureVerification(t *testing.T) {
+	trusted := newTestSigningKey(t)
+	untrusted := newTestSigningKey(t)
+	keysDir := t.TempDir()
+	verifier, err := NewSignatureVerifier(writeTestPublicKey(t, keysDir, trusted))
+	require.NoError(t, err)
+
+	content, err := os.ReadFile("../testdata/local-storage/example-1.0.1.zip")
+	require.NoError(t, err)
+	newPackagesDir := func(t *testing.T, signer *openpgp.Entity) string {
+		dir := t.TempDir()
+		archive := filepath.Join(dir, "example-1.0.1.zip")
+		require.NoError(t, os.WriteFile(archive, content, 0644))
+		writeTestSignature(t, archive, signer)
+		return dir
+	}
+
+	t.Run("valid signature", func(t *testing.T) {
+		indexer := NewZipFileSystemIndexer(FSIndexerOptions{
+			Logger:                  util.NewTestLogger(),
+			SignatureVerifier:       verifier,
+			RejectInvalidSignatures: true,
+		}, newPackagesDir(t, trusted))
+		require.NoError(t, indexer.Init(t.Context()))
+
+		pkgs, err := indexer.Get(t.Context(), nil)
+		require.NoError(t, err)
+		require.Len(t, pkgs, 1)
+		require.NotNil(t, pkgs[0].SignatureVerification)
+		assert.True(t, pkgs[0].SignatureVerification.Verified)
+		assert.Equal(t, fmt.Sprintf("%X", trusted.PrimaryKey.Fingerprint), pkgs[0].SignatureVerification.KeyFingerprint)
+	})
+
+	t.Run("invalid signature rejected", func(t *testing.T) {
+		indexer := NewZipFileSystemIndexer(FSIndexerOptions{
+			Logger:                  util.NewTestLogger(),
+			SignatureVerifier:       verifier,
+			RejectInvalidSignatures: true,
+		}, newPackagesDir(t, untrusted))
+		require.NoError(t, indexer.Init(t.Context()), "invalid signatures must not make indexing fail")
+
+		pkgs, err := indexer.Get(t.Context(), nil)
+		require.NoError(t, err)
+		assert.Empty(t, pkgs)
+		assert.Equal(t, 1, indexer.Status(t.Context()).RejectedPackages)
+	})
+
+	t.Run("invalid signature rejected with other packages", func(t *testing.T) {
+		dir := newPackagesDir(t, untrusted)
+		valid, err := os.ReadFile("../testdata/local-storage/nodirentries-1.0.0.zip")
+		require.NoError(t, err)
+		archive := filepath.Join(dir, "nodirentries-1.0.0.zip")
+		require.NoError(t, os.WriteFile(archive, valid, 0644))
+		writeTestSignature(t, archive, trusted)
+
+		indexer := NewZipFileSystemIndexer(FSIndexerOptions{
+			Logger:                  util.NewTestLogger(),
+			SignatureVerifier:       verifier,
+			RejectInvalidSignatures: true,
+		}, dir)
+		require.NoError(t, indexer.Init(t.Context()))
+
+		pkgs, err := indexer.Get(t.Context(), nil)
+		require.NoError(t, err)
+		require.Len(t, pkgs, 1)
+		assert.Equal(t, "nodirentries", pkgs[0].Name)
+		assert.Equal(t, 1, indexer.Status(t.Context()).RejectedPackages)
+	})
+
+	t.Run("invalid signature flagged", func(t *testing.T) {
+		indexer := NewZipFileSystemIndexer(FSIndexerOptions{
+			Logger:            util.NewTestLogger(),
+			SignatureVerifier: verifier,
+		}, newPackagesDir(t, untrusted))
+		require.NoError(t, indexer.Init(t.Context()))
+
+		pkgs, err := indexer.Get(t.Context(), nil)
+		require.NoError(t, err)
+		require.Len(t, pkgs, 1)
+		require.NotNil(t, pkgs[0].SignatureVerification)
+		assert.False(t, pkgs[0].SignatureVerification.Verified)
+		assert.NotEmpty(t, pkgs[0].SignatureVerification.Error)
+	})
+}
+
+func newTestSigningKey(t *testing.T) *openpgp.Entity {
+	entity, err := openpgp.NewEntity("test", "test", "test@example.com", nil)
+	require.NoError(t, err)
+	return entity
+}
+
+func writeTestPublicKey(t *testing.T, dir string, entity *openpgp.Entity) string {
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	path := filepath.Join(dir, fmt.Sprintf("%X.asc", entity.PrimaryKey.Fingerprint))
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+	return path
+}
+
+func writeTestSignature(t *testing.T, path string, signer *openpgp.Entity) {
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, openpgp.ArmoredDetachSign(&buf, signer, bytes.NewReader(content), nil))
+	require.NoError(t, os.WriteFile(path+".sig", buf.Bytes(), 0644))
+}
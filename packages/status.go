@@ -0,0 +1,93 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"sync"
+	"time"
+)
+
+// IndexerStatus describes the state of an indexer, it is used to report readiness.
+type IndexerStatus struct {
+	Name                 string     `json:"name"`
+	Cursor               string     `json:"cursor,omitempty"`
+	PackagesCount        int        `json:"packages_count"`
+	LastSuccessfulUpdate *time.Time `json:"last_successful_update,omitempty"`
+	LastFailedUpdate     *time.Time `json:"last_failed_update,omitempty"`
+	LastError            string     `json:"last_error,omitempty"`
+	// RejectedPackages is the number of packages left out of the index in the last update,
+	// as packages with invalid signatures.
+	RejectedPackages int             `json:"rejected_packages,omitempty"`
+	Database         *DatabaseStatus `json:"database,omitempty"`
+
+	// Periodic is set for indexers that are expected to refresh their index
+	// periodically, only these indexers can become stale.
+	Periodic bool `json:"periodic"`
+}
+
+// DatabaseStatus describes the state of the database used by an indexer.
+type DatabaseStatus struct {
+	Path      string `json:"path,omitempty"`
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+}
+
+// IndexerStatusTracker keeps track of the results of the index updates of an indexer.
+// The zero value is ready to be used.
+type IndexerStatusTracker struct {
+	m      sync.RWMutex
+	status IndexerStatus
+}
+
+// UpdateSucceeded records a successful index update.
+func (t *IndexerStatusTracker) UpdateSucceeded(cursor string, packagesCount int) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	now := time.Now()
+	t.status.LastSuccessfulUpdate = &now
+	t.status.Cursor = cursor
+	t.status.PackagesCount = packagesCount
+}
+
+// SetRejectedPackages records the number of packages left out of the index in the last update.
+func (t *IndexerStatusTracker) SetRejectedPackages(count int) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	t.status.RejectedPackages = count
+}
+
+// UpdateFailed records a failed index update.
+func (t *IndexerStatusTracker) UpdateFailed(err error) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	now := time.Now()
+	t.status.LastFailedUpdate = &now
+	if err != nil {
+		t.status.LastError = err.Error()
+	}
+}
+
+// Status returns a copy of the current status.
+func (t *IndexerStatusTracker) Status() IndexerStatus {
+	t.m.RLock()
+	defer t.m.RUnlock()
+
+	return t.status
+}
+
+// Stale returns true if the last successful update happened longer than maxAge ago.
+// Indexers that are not periodically updated are never considered stale.
+func (s IndexerStatus) Stale(now time.Time, maxAge time.Duration) bool {
+	if !s.Periodic || maxAge <= 0 {
+		return false
+	}
+	if s.LastSuccessfulUpdate == nil {
+		return true
+	}
+	return now.Sub(*s.LastSuccessfulUpdate) > maxAge
+}
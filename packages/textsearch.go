@@ -0,0 +1,235 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Weights of the fields used for free-text search. Matches in more
+// specific fields contribute more to the relevance of a package.
+const (
+	textWeightName           = 10.0
+	textWeightTitle          = 6.0
+	textWeightPolicyTemplate = 3.0
+	textWeightDataStream     = 2.0
+	textWeightDescription    = 1.0
+
+	// textPrefixMatchFactor is applied to the weight of a term when it only
+	// matches as prefix of a token.
+	textPrefixMatchFactor = 0.5
+)
+
+// TokenizeText splits a text in lowercase tokens, using any character
+// that is not a letter or a digit as separator.
+func TokenizeText(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// SearchableText contains the text of a package that is used for free-text search.
+type SearchableText struct {
+	Name                  string
+	Title                 string
+	Description           string
+	PolicyTemplatesTitles string
+	DataStreamsTitles     string
+}
+
+// NewSearchableText collects the text of the package fields that can be searched.
+func NewSearchableText(p *Package) SearchableText {
+	text := SearchableText{
+		Name:        p.Name,
+		Description: p.Description,
+	}
+	if p.Title != nil {
+		text.Title = *p.Title
+	}
+
+	var titles []string
+	for _, pt := range p.PolicyTemplates {
+		titles = append(titles, pt.Title)
+	}
+	if len(p.PolicyTemplates) == 0 {
+		for _, pt := range p.BasePolicyTemplates {
+			titles = append(titles, pt.Title)
+		}
+	}
+	text.PolicyTemplatesTitles = strings.Join(titles, "\n")
+
+	titles = titles[:0]
+	for _, ds := range p.DataStreams {
+		titles = append(titles, ds.Title)
+	}
+	if len(p.DataStreams) == 0 {
+		for _, ds := range p.BaseDataStreams {
+			titles = append(titles, ds.Title)
+		}
+	}
+	text.DataStreamsTitles = strings.Join(titles, "\n")
+
+	return text
+}
+
+// tokenWeights returns the tokens of the searchable text, with the weight of the
+// most relevant field where each token appears.
+func (t SearchableText) tokenWeights() map[string]float64 {
+	weights := make(map[string]float64)
+	add := func(text string, weight float64) {
+		for _, token := range TokenizeText(text) {
+			if weights[token] < weight {
+				weights[token] = weight
+			}
+		}
+	}
+	add(t.Name, textWeightName)
+	add(t.Title, textWeightTitle)
+	add(t.PolicyTemplatesTitles, textWeightPolicyTemplate)
+	add(t.DataStreamsTitles, textWeightDataStream)
+	add(t.Description, textWeightDescription)
+	return weights
+}
+
+// TextQuery is a free-text query. A package matches the query if all its terms
+// match, exactly or as prefix, any of the tokens of the searchable fields.
+type TextQuery struct {
+	terms []string
+}
+
+// NewTextQuery parses a free-text query.
+func NewTextQuery(query string) *TextQuery {
+	return &TextQuery{terms: TokenizeText(query)}
+}
+
+// Score returns the relevance of the package for this query, zero if it doesn't match.
+func (q *TextQuery) Score(p *Package) float64 {
+	weights := NewSearchableText(p).tokenWeights()
+	score := 0.0
+	for _, term := range q.terms {
+		best := 0.0
+		for token, weight := range weights {
+			best = max(best, termWeight(term, token, weight))
+		}
+		if best == 0 {
+			return 0
+		}
+		score += best
+	}
+	return score
+}
+
+func termWeight(term, token string, weight float64) float64 {
+	switch {
+	case term == token:
+		return weight
+	case strings.HasPrefix(token, term):
+		return weight * textPrefixMatchFactor
+	default:
+		return 0
+	}
+}
+
+// SortByRelevance sorts the packages from the most to the least relevant for the query.
+// Packages with the same relevance are sorted by name and version.
+func SortByRelevance(packages Packages, query string) {
+	q := NewTextQuery(query)
+	scores := make(map[*Package]float64, len(packages))
+	for _, p := range packages {
+		scores[p] = q.Score(p)
+	}
+	sort.SliceStable(packages, func(i, j int) bool {
+		if si, sj := scores[packages[i]], scores[packages[j]]; si != sj {
+			return si > sj
+		}
+		return packages.Less(i, j)
+	})
+}
+
+type textPosting struct {
+	pkg    *Package
+	weight float64
+}
+
+// TextIndex is an inverted index used to resolve free-text queries over a list of packages.
+type TextIndex struct {
+	packages Packages
+
+	// tokens is sorted, to lookup tokens by prefix.
+	tokens   []string
+	postings map[string][]textPosting
+}
+
+// NewTextIndex builds the text index for the given packages.
+func NewTextIndex(packages Packages) *TextIndex {
+	index := TextIndex{
+		packages: packages,
+		postings: make(map[string][]textPosting),
+	}
+	for _, p := range packages {
+		for token, weight := range NewSearchableText(p).tokenWeights() {
+			index.postings[token] = append(index.postings[token], textPosting{pkg: p, weight: weight})
+		}
+	}
+	index.tokens = make([]string, 0, len(index.postings))
+	for token := range index.postings {
+		index.tokens = append(index.tokens, token)
+	}
+	sort.Strings(index.tokens)
+	return &index
+}
+
+// Search returns the packages matching the query, sorted by relevance.
+// If the query doesn't contain any term, all indexed packages are returned.
+func (idx *TextIndex) Search(query string) Packages {
+	if idx == nil {
+		return nil
+	}
+	q := NewTextQuery(query)
+	if len(q.terms) == 0 {
+		return idx.packages
+	}
+
+	var scores map[*Package]float64
+	for _, term := range q.terms {
+		termScores := make(map[*Package]float64)
+		first := sort.SearchStrings(idx.tokens, term)
+		for _, token := range idx.tokens[first:] {
+			if !strings.HasPrefix(token, term) {
+				break
+			}
+			for _, posting := range idx.postings[token] {
+				termScores[posting.pkg] = max(termScores[posting.pkg], termWeight(term, token, posting.weight))
+			}
+		}
+
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for p, score := range scores {
+			termScore, found := termScores[p]
+			if !found {
+				delete(scores, p)
+				continue
+			}
+			scores[p] = score + termScore
+		}
+	}
+
+	result := make(Packages, 0, len(scores))
+	for p := range scores {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if si, sj := scores[result[i]], scores[result[j]]; si != sj {
+			return si > sj
+		}
+		return result.Less(i, j)
+	})
+	return result
+}
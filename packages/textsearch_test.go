@@ -0,0 +1,78 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package packages
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenizeText(t *testing.T) {
+	assert.Equal(t, []string{"aws", "bedrock", "logs", "v2"}, TokenizeText("AWS_Bedrock logs-v2"))
+	assert.Empty(t, TokenizeText(" -_. "))
+}
+
+func TestTextIndexSearch(t *testing.T) {
+	title := func(s string) *string { return &s }
+	packageList := Packages{
+		&Package{BasePackage: BasePackage{
+			Name:        "nginx",
+			Title:       title("Nginx"),
+			Version:     "1.0.0",
+			Description: "Collect logs and metrics from Nginx HTTP servers.",
+		}},
+		&Package{BasePackage: BasePackage{
+			Name:        "apache",
+			Title:       title("Apache HTTP Server"),
+			Version:     "1.0.0",
+			Description: "Collect logs from Apache servers, compatible with nginx configurations.",
+		}},
+		&Package{
+			BasePackage: BasePackage{
+				Name:        "web_proxy",
+				Title:       title("Web Proxy"),
+				Version:     "2.0.0",
+				Description: "Proxies.",
+			},
+			PolicyTemplates: []PolicyTemplate{{Title: "Proxy access logs"}},
+			DataStreams:     []*DataStream{{Title: "Kubernetes ingress"}},
+		},
+	}
+	index := NewTextIndex(packageList)
+
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		{query: "nginx", expected: []string{"nginx", "apache"}},
+		{query: "NGI", expected: []string{"nginx", "apache"}},
+		{query: "http server", expected: []string{"apache", "nginx"}},
+		{query: "logs", expected: []string{"web_proxy", "apache", "nginx"}},
+		{query: "kubernetes", expected: []string{"web_proxy"}},
+		{query: "nginx kubernetes", expected: nil},
+		{query: "redis", expected: nil},
+		{query: "--", expected: []string{"nginx", "apache", "web_proxy"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			var found []string
+			for _, p := range index.Search(c.query) {
+				found = append(found, p.Name)
+			}
+			assert.Equal(t, c.expected, found)
+
+			// Ranking of the index must be the same as the one used to sort
+			// results coming from different indexers.
+			if len(TokenizeText(c.query)) > 0 {
+				result := index.Search(c.query)
+				sorted := append(Packages{}, result...)
+				SortByRelevance(sorted, c.query)
+				assert.Equal(t, result, sorted)
+			}
+		})
+	}
+}
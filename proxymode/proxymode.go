@@ -6,35 +6,39 @@ package proxymode
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/gorilla/mux"
 	"github.com/hashicorp/go-retryablehttp"
-	"go.elastic.co/apm/module/apmhttp/v2"
 	"go.uber.org/zap"
 
+	"github.com/elastic/package-registry/internal/contentcache"
 	"github.com/elastic/package-registry/packages"
 )
 
 type ProxyMode struct {
 	options ProxyOptions
 
-	httpClient     *retryablehttp.Client
-	destinationURL *url.URL
-	resolver       *proxyResolver
+	upstreams []*upstream
 
 	logger *zap.Logger
 }
 
 type ProxyOptions struct {
 	Enabled bool
+
+	// ProxyTo is the endpoint used as single upstream when no upstreams are configured.
 	ProxyTo string
+
+	// Upstreams are the registries included in the responses, in order of precedence.
+	Upstreams []UpstreamOptions
+
+	// ContentCache, if set, caches the artifacts and static files of the upstreams.
+	ContentCache *contentcache.Cache
 }
 
 func NoProxy(logger *zap.Logger) *ProxyMode {
@@ -54,30 +58,17 @@ func NewProxyMode(logger *zap.Logger, options ProxyOptions) (*ProxyMode, error)
 		return &pm, nil
 	}
 
-	pm.httpClient = &retryablehttp.Client{
-		HTTPClient: &http.Client{
-			Timeout: 10 * time.Second,
-			Transport: apmhttp.WrapRoundTripper(&http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 100,
-				IdleConnTimeout:     90 * time.Second,
-			}),
-		},
-		Logger:       withZapLoggerAdapter(logger),
-		RetryWaitMin: 1 * time.Second,
-		RetryWaitMax: 15 * time.Second,
-		RetryMax:     4,
-		CheckRetry:   proxyRetryPolicy,
-		Backoff:      retryablehttp.DefaultBackoff,
+	upstreams := options.Upstreams
+	if len(upstreams) == 0 {
+		upstreams = []UpstreamOptions{{URL: options.ProxyTo}}
 	}
-
-	var err error
-	pm.destinationURL, err = url.Parse(pm.options.ProxyTo)
-	if err != nil {
-		return nil, fmt.Errorf("can't create proxy destination URL: %w", err)
+	for _, upstreamOptions := range upstreams {
+		u, err := newUpstream(logger, upstreamOptions, options.ContentCache)
+		if err != nil {
+			return nil, err
+		}
+		pm.upstreams = append(pm.upstreams, u)
 	}
-
-	pm.resolver = &proxyResolver{destinationURL: *pm.destinationURL}
 	return &pm, nil
 }
 
@@ -114,63 +105,73 @@ func (pm *ProxyMode) Enabled() bool {
 	return pm.options.Enabled
 }
 
+// Search sends the search request to all the upstreams concurrently, and merges their
+// results in order of precedence. When some upstreams fail, the packages of the rest are
+// returned together with an error describing the failures.
 func (pm *ProxyMode) Search(r *http.Request) (packages.Packages, error) {
+	results := make([]packages.Packages, len(pm.upstreams))
+	errs := pm.fanOut(func(i int, u *upstream) error {
+		pkgs, err := u.search(r)
+		results[i] = pkgs
+		return err
+	})
 
-	proxyURL := *r.URL
-	proxyURL.Host = pm.destinationURL.Host
-	proxyURL.Scheme = pm.destinationURL.Scheme
-	proxyURL.User = pm.destinationURL.User
-
-	proxyRequest, err := retryablehttp.NewRequestWithContext(r.Context(), http.MethodGet, proxyURL.String(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("can't create proxy request: %w", err)
-	}
-
-	pm.logger.Debug("Proxy /search request", zap.String("request.uri", proxyURL.String()))
-	response, err := pm.httpClient.Do(proxyRequest)
-	if err != nil {
-		return nil, fmt.Errorf("can't proxy search request: %w", err)
-	}
-	defer response.Body.Close()
 	var pkgs packages.Packages
-	err = json.NewDecoder(response.Body).Decode(&pkgs)
-	if err != nil {
-		return nil, fmt.Errorf("can't proxy search request: %w", err)
+	for _, result := range results {
+		pkgs = pkgs.Join(result)
 	}
-	for i := 0; i < len(pkgs); i++ {
-		pkgs[i].SetRemoteResolver(pm.resolver)
-	}
-	return pkgs, nil
+	return pkgs, errs
 }
 
+// Categories sends the categories request to all the upstreams concurrently, and merges
+// their results in order of precedence. Counts of the same category are added. When some
+// upstreams fail, the categories of the rest are returned together with an error describing
+// the failures.
 func (pm *ProxyMode) Categories(r *http.Request) ([]packages.Category, error) {
+	results := make([][]packages.Category, len(pm.upstreams))
+	errs := pm.fanOut(func(i int, u *upstream) error {
+		cats, err := u.categories(r)
+		results[i] = cats
+		return err
+	})
 
-	proxyURL := *r.URL
-	proxyURL.Host = pm.destinationURL.Host
-	proxyURL.Scheme = pm.destinationURL.Scheme
-	proxyURL.User = pm.destinationURL.User
-
-	proxyRequest, err := retryablehttp.NewRequestWithContext(r.Context(), http.MethodGet, proxyURL.String(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("can't create proxy request: %w", err)
-	}
-
-	pm.logger.Debug("Proxy /categories request", zap.String("request.uri", proxyURL.String()))
-	response, err := pm.httpClient.Do(proxyRequest)
-	if err != nil {
-		return nil, fmt.Errorf("can't proxy categories request: %w", err)
-	}
-	defer response.Body.Close()
 	var cats []packages.Category
-	err = json.NewDecoder(response.Body).Decode(&cats)
-	if err != nil {
-		return nil, fmt.Errorf("can't proxy categories request: %w", err)
+	index := make(map[string]int)
+	for _, result := range results {
+		for _, category := range result {
+			if i, found := index[category.Id]; found {
+				cats[i].Count += category.Count
+				continue
+			}
+			index[category.Id] = len(cats)
+			cats = append(cats, category)
+		}
 	}
-	return cats, nil
+	return cats, errs
 }
 
-func (pm *ProxyMode) Package(r *http.Request) (*packages.Package, error) {
+// fanOut calls the given function for each upstream concurrently, and returns the joined
+// errors of the failed calls.
+func (pm *ProxyMode) fanOut(fn func(i int, u *upstream) error) error {
+	errs := make([]error, len(pm.upstreams))
+	var wg sync.WaitGroup
+	for i, u := range pm.upstreams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(i, u); err != nil {
+				errs[i] = fmt.Errorf("upstream %s: %w", u, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
 
+// Package looks for the package in the upstreams, in order of precedence, and returns the
+// first one found. Failing upstreams are skipped, an error is only returned if the package
+// is not found in the rest of upstreams.
+func (pm *ProxyMode) Package(r *http.Request) (*packages.Package, error) {
 	vars := mux.Vars(r)
 	packageName, ok := vars["packageName"]
 	if !ok {
@@ -182,35 +183,19 @@ func (pm *ProxyMode) Package(r *http.Request) (*packages.Package, error) {
 		return nil, errors.New("missing package version")
 	}
 
-	urlPath := fmt.Sprintf("/package/%s/%s/", packageName, packageVersion)
-	proxyURL := pm.destinationURL.ResolveReference(&url.URL{Path: urlPath})
-	proxyRequest, err := retryablehttp.NewRequestWithContext(r.Context(), http.MethodGet, proxyURL.String(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("can't create proxy request: %w", err)
-	}
-
-	pm.logger.Debug("Proxy /package request", zap.String("request.uri", proxyURL.String()))
-	response, err := pm.httpClient.Do(proxyRequest)
-	if err != nil {
-		return nil, fmt.Errorf("can't proxy package request: %w", err)
-	}
-	defer response.Body.Close()
-
-	switch response.StatusCode {
-	case http.StatusOK:
-		// Package found, all good.
-	case http.StatusNotFound:
-		// Package doesn't exist, don't try to parse the response, just return an empty package.
-		return nil, nil
-	default:
-		return nil, fmt.Errorf("unexpected status code %d received", response.StatusCode)
-	}
-
-	var pkg packages.Package
-	err = json.NewDecoder(response.Body).Decode(&pkg)
-	if err != nil {
-		return nil, fmt.Errorf("can't proxy package request: %w", err)
+	var errs []error
+	for _, u := range pm.upstreams {
+		pkg, err := u.pkg(r.Context(), packageName, packageVersion)
+		if err != nil {
+			pm.logger.Warn("proxy mode: upstream failed, trying the next one",
+				zap.Stringer("proxy.upstream", u),
+				zap.Error(err))
+			errs = append(errs, fmt.Errorf("upstream %s: %w", u, err))
+			continue
+		}
+		if pkg != nil {
+			return pkg, nil
+		}
 	}
-	pkg.SetRemoteResolver(pm.resolver)
-	return &pkg, nil
+	return nil, errors.Join(errs...)
 }
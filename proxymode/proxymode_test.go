@@ -0,0 +1,158 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package proxymode
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/package-registry/internal/util"
+)
+
+func TestSearchMultipleUpstreams(t *testing.T) {
+	first := newTestUpstream(t, map[string]string{
+		"/search": `[{"name":"foo","title":"Foo first","version":"1.0.0","type":"integration"}]`,
+	})
+	second := newTestUpstream(t, map[string]string{
+		"/search": `[{"name":"foo","title":"Foo second","version":"1.0.0","type":"integration"},{"name":"bar","title":"Bar","version":"2.0.0","type":"integration"}]`,
+	})
+	failing := newTestUpstream(t, nil)
+
+	pm := newTestProxyMode(t, first.URL, failing.URL, second.URL)
+	pkgs, err := pm.Search(httptest.NewRequest(http.MethodGet, "/search?all=true", nil))
+	assert.ErrorContains(t, err, "giving up after 1 attempt")
+	require.Len(t, pkgs, 2)
+	assert.Equal(t, "Foo first", *pkgs[0].Title)
+	assert.Equal(t, "bar", pkgs[1].Name)
+
+	pm = newTestProxyMode(t, failing.URL)
+	pkgs, err = pm.Search(httptest.NewRequest(http.MethodGet, "/search", nil))
+	assert.Error(t, err)
+	assert.Empty(t, pkgs)
+}
+
+func TestCategoriesMultipleUpstreams(t *testing.T) {
+	first := newTestUpstream(t, map[string]string{
+		"/categories": `[{"id":"security","title":"Security","count":2}]`,
+	})
+	second := newTestUpstream(t, map[string]string{
+		"/categories": `[{"id":"web","title":"Web","count":1},{"id":"security","title":"Other title","count":3}]`,
+	})
+	failing := newTestUpstream(t, nil)
+
+	pm := newTestProxyMode(t, failing.URL, first.URL, second.URL)
+	cats, err := pm.Categories(httptest.NewRequest(http.MethodGet, "/categories", nil))
+	assert.Error(t, err)
+	require.Len(t, cats, 2)
+	assert.Equal(t, "security", cats[0].Id)
+	assert.Equal(t, "Security", cats[0].Title)
+	assert.Equal(t, 5, cats[0].Count)
+	assert.Equal(t, "web", cats[1].Id)
+	assert.Equal(t, 1, cats[1].Count)
+}
+
+func TestPackageMultipleUpstreams(t *testing.T) {
+	empty := newTestUpstream(t, map[string]string{})
+	found := newTestUpstream(t, map[string]string{
+		"/package/foo/1.0.0/": `{"name":"foo","title":"Foo","version":"1.0.0","type":"integration"}`,
+	})
+	failing := newTestUpstream(t, nil)
+
+	packageRequest := func(name, version string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/package/%s/%s/", name, version), nil)
+		return mux.SetURLVars(r, map[string]string{"packageName": name, "packageVersion": version})
+	}
+
+	pm := newTestProxyMode(t, empty.URL, failing.URL, found.URL)
+	pkg, err := pm.Package(packageRequest("foo", "1.0.0"))
+	require.NoError(t, err)
+	require.NotNil(t, pkg)
+	assert.Equal(t, "Foo", *pkg.Title)
+
+	pm = newTestProxyMode(t, empty.URL, found.URL)
+	pkg, err = pm.Package(packageRequest("bar", "1.0.0"))
+	assert.NoError(t, err)
+	assert.Nil(t, pkg)
+
+	pm = newTestProxyMode(t, empty.URL, failing.URL)
+	pkg, err = pm.Package(packageRequest("bar", "1.0.0"))
+	assert.ErrorContains(t, err, "giving up after 1 attempt")
+	assert.Nil(t, pkg)
+}
+
+func TestNewProxyModeUpstreamOptions(t *testing.T) {
+	pm, err := NewProxyMode(util.NewTestLogger(), ProxyOptions{Enabled: true, ProxyTo: "https://epr.elastic.co/"})
+	require.NoError(t, err)
+	require.Len(t, pm.upstreams, 1)
+	assert.Equal(t, "epr.elastic.co", pm.upstreams[0].String())
+	assert.Equal(t, defaultUpstreamTimeout, pm.upstreams[0].httpClient.HTTPClient.Timeout)
+	assert.Equal(t, defaultUpstreamRetryMax, pm.upstreams[0].httpClient.RetryMax)
+
+	retryMax := 1
+	pm, err = NewProxyMode(util.NewTestLogger(), ProxyOptions{
+		Enabled: true,
+		ProxyTo: "https://epr.elastic.co/",
+		Upstreams: []UpstreamOptions{
+			{URL: "https://registry.internal/", Timeout: time.Second, Retry: UpstreamRetryOptions{Max: &retryMax}},
+			{URL: "https://epr.elastic.co/"},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, pm.upstreams, 2)
+	assert.Equal(t, "registry.internal", pm.upstreams[0].String())
+	assert.Equal(t, time.Second, pm.upstreams[0].httpClient.HTTPClient.Timeout)
+	assert.Equal(t, 1, pm.upstreams[0].httpClient.RetryMax)
+
+	invalid := map[string]UpstreamOptions{
+		"missing host":            {URL: "/search"},
+		"missing CA":              {URL: "https://epr.elastic.co/", TLS: UpstreamTLSOptions{CertificateAuthorities: []string{"missing.pem"}}},
+		"certificate without key": {URL: "https://epr.elastic.co/", TLS: UpstreamTLSOptions{Certificate: "cert.pem"}},
+		"retry waits":             {URL: "https://epr.elastic.co/", Retry: UpstreamRetryOptions{WaitMin: time.Minute, WaitMax: time.Second}},
+	}
+	for name, options := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewProxyMode(util.NewTestLogger(), ProxyOptions{Enabled: true, Upstreams: []UpstreamOptions{options}})
+			assert.Error(t, err)
+		})
+	}
+}
+
+// newTestUpstream starts a server that responds with the given JSON documents by path, and
+// with not found to other paths. If responses is nil, it fails all requests.
+func newTestUpstream(t *testing.T, responses map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if responses == nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		response, found := responses[r.URL.Path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestProxyMode(t *testing.T, urls ...string) *ProxyMode {
+	noRetries := 0
+	var upstreams []UpstreamOptions
+	for _, url := range urls {
+		upstreams = append(upstreams, UpstreamOptions{URL: url, Retry: UpstreamRetryOptions{Max: &noRetries}})
+	}
+	pm, err := NewProxyMode(util.NewTestLogger(), ProxyOptions{Enabled: true, Upstreams: upstreams})
+	require.NoError(t, err)
+	return pm
+}
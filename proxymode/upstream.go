@@ -0,0 +1,245 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package proxymode
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"go.elastic.co/apm/module/apmhttp/v2"
+	"go.uber.org/zap"
+
+	"github.com/elastic/package-registry/internal/contentcache"
+	"github.com/elastic/package-registry/packages"
+)
+
+const (
+	defaultUpstreamTimeout      = 10 * time.Second
+	defaultUpstreamRetryMax     = 4
+	defaultUpstreamRetryWaitMin = 1 * time.Second
+	defaultUpstreamRetryWaitMax = 15 * time.Second
+)
+
+// UpstreamOptions are the options of a Package Registry used as upstream in proxy mode.
+type UpstreamOptions struct {
+	URL string `config:"url"`
+
+	// Timeout is the timeout of each attempt of a request to the upstream, retries of
+	// failed attempts and the waits between them are not included. Defaults to 10 seconds.
+	Timeout time.Duration `config:"timeout"`
+
+	Retry UpstreamRetryOptions `config:"retry"`
+	TLS   UpstreamTLSOptions   `config:"tls"`
+}
+
+// UpstreamRetryOptions is the retry policy of the requests to an upstream.
+type UpstreamRetryOptions struct {
+	// Max is the maximum number of retries, 4 if not set. Set it to 0 to disable retries.
+	Max *int `config:"max"`
+
+	WaitMin time.Duration `config:"wait_min"`
+	WaitMax time.Duration `config:"wait_max"`
+}
+
+// UpstreamTLSOptions are the TLS settings of the connections to an upstream.
+type UpstreamTLSOptions struct {
+	// CertificateAuthorities are the paths to PEM encoded certificate authorities used to verify
+	// the certificate of the upstream, instead of the ones of the system.
+	CertificateAuthorities []string `config:"certificate_authorities"`
+
+	// Certificate and Key are the paths to the PEM encoded client certificate and key presented to
+	// the upstream.
+	Certificate string `config:"certificate"`
+	Key         string `config:"key"`
+
+	InsecureSkipVerify bool `config:"insecure_skip_verify"`
+}
+
+type upstream struct {
+	httpClient     *retryablehttp.Client
+	destinationURL *url.URL
+	resolver       packages.RemoteResolver
+
+	logger *zap.Logger
+}
+
+func newUpstream(logger *zap.Logger, options UpstreamOptions, contentCache *contentcache.Cache) (*upstream, error) {
+	destinationURL, err := url.Parse(options.URL)
+	if err != nil {
+		return nil, fmt.Errorf("can't create proxy destination URL: %w", err)
+	}
+	if destinationURL.Host == "" {
+		return nil, fmt.Errorf("missing host in proxy destination URL %q", options.URL)
+	}
+
+	tlsConfig, err := options.TLS.tlsConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS settings for %s: %w", destinationURL.Host, err)
+	}
+
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = defaultUpstreamTimeout
+	}
+	retryMax := defaultUpstreamRetryMax
+	if options.Retry.Max != nil {
+		retryMax = *options.Retry.Max
+	}
+	retryWaitMin := options.Retry.WaitMin
+	if retryWaitMin == 0 {
+		retryWaitMin = defaultUpstreamRetryWaitMin
+	}
+	retryWaitMax := options.Retry.WaitMax
+	if retryWaitMax == 0 {
+		retryWaitMax = defaultUpstreamRetryWaitMax
+	}
+	if retryWaitMax < retryWaitMin {
+		return nil, fmt.Errorf("maximum retry wait (%s) lower than the minimum (%s) for %s", retryWaitMax, retryWaitMin, destinationURL.Host)
+	}
+
+	logger = logger.With(zap.String("proxy.upstream", destinationURL.Host))
+	u := upstream{
+		httpClient: &retryablehttp.Client{
+			HTTPClient: &http.Client{
+				Timeout: timeout,
+				Transport: apmhttp.WrapRoundTripper(&http.Transport{
+					TLSClientConfig:     tlsConfig,
+					MaxIdleConns:        100,
+					MaxIdleConnsPerHost: 100,
+					IdleConnTimeout:     90 * time.Second,
+				}),
+			},
+			Logger:       withZapLoggerAdapter(logger),
+			RetryWaitMin: retryWaitMin,
+			RetryWaitMax: retryWaitMax,
+			RetryMax:     retryMax,
+			CheckRetry:   proxyRetryPolicy,
+			Backoff:      retryablehttp.DefaultBackoff,
+		},
+		destinationURL: destinationURL,
+		resolver:       contentCache.Resolver(&proxyResolver{destinationURL: *destinationURL}),
+		logger:         logger,
+	}
+	return &u, nil
+}
+
+func (o UpstreamTLSOptions) tlsConfig() (*tls.Config, error) {
+	config := tls.Config{
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+	if len(o.CertificateAuthorities) > 0 {
+		config.RootCAs = x509.NewCertPool()
+		for _, path := range o.CertificateAuthorities {
+			d, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read certificate authority: %w", err)
+			}
+			if !config.RootCAs.AppendCertsFromPEM(d) {
+				return nil, fmt.Errorf("no certificates found in %s", path)
+			}
+		}
+	}
+	if o.Certificate != "" || o.Key != "" {
+		if o.Certificate == "" || o.Key == "" {
+			return nil, errors.New("client certificate and key must be configured together")
+		}
+		certificate, err := tls.LoadX509KeyPair(o.Certificate, o.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return &config, nil
+}
+
+func (u *upstream) String() string {
+	return u.destinationURL.Host
+}
+
+// get sends a GET request to the given path of the upstream, keeping the query of the
+// original request if any.
+func (u *upstream) get(ctx context.Context, path string, query string) (*http.Response, error) {
+	proxyURL := u.destinationURL.ResolveReference(&url.URL{Path: path, RawQuery: query})
+	proxyRequest, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, proxyURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't create proxy request: %w", err)
+	}
+
+	u.logger.Debug("Proxy "+path+" request", zap.String("request.uri", proxyURL.String()))
+	return u.httpClient.Do(proxyRequest)
+}
+
+func (u *upstream) search(r *http.Request) (packages.Packages, error) {
+	response, err := u.get(r.Context(), "/search", r.URL.RawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("can't proxy search request: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("can't proxy search request: unexpected status code %d received", response.StatusCode)
+	}
+	var pkgs packages.Packages
+	err = json.NewDecoder(response.Body).Decode(&pkgs)
+	if err != nil {
+		return nil, fmt.Errorf("can't proxy search request: %w", err)
+	}
+	for i := 0; i < len(pkgs); i++ {
+		pkgs[i].SetRemoteResolver(u.resolver)
+	}
+	return pkgs, nil
+}
+
+func (u *upstream) categories(r *http.Request) ([]packages.Category, error) {
+	response, err := u.get(r.Context(), "/categories", r.URL.RawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("can't proxy categories request: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("can't proxy categories request: unexpected status code %d received", response.StatusCode)
+	}
+	var cats []packages.Category
+	err = json.NewDecoder(response.Body).Decode(&cats)
+	if err != nil {
+		return nil, fmt.Errorf("can't proxy categories request: %w", err)
+	}
+	return cats, nil
+}
+
+func (u *upstream) pkg(ctx context.Context, packageName, packageVersion string) (*packages.Package, error) {
+	urlPath := fmt.Sprintf("/package/%s/%s/", packageName, packageVersion)
+	response, err := u.get(ctx, urlPath, "")
+	if err != nil {
+		return nil, fmt.Errorf("can't proxy package request: %w", err)
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		// Package found, all good.
+	case http.StatusNotFound:
+		// Package doesn't exist, don't try to parse the response, just return an empty package.
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unexpected status code %d received", response.StatusCode)
+	}
+
+	var pkg packages.Package
+	err = json.NewDecoder(response.Body).Decode(&pkg)
+	if err != nil {
+		return nil, fmt.Errorf("can't proxy package request: %w", err)
+	}
+	pkg.SetRemoteResolver(u.resolver)
+	return &pkg, nil
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -31,7 +32,7 @@ type searchHandler struct {
 	indexer   Indexer
 	cacheTime time.Duration
 
-	cache                       *expirable.LRU[string, []byte]
+	cache                       *expirable.LRU[string, *jsonResponse]
 	proxyMode                   *proxymode.ProxyMode
 	allowUnknownQueryParameters bool
 }
@@ -66,7 +67,7 @@ func searchWithProxy(pm *proxymode.ProxyMode) searchOption {
 	}
 }
 
-func searchWithCache(cache *expirable.LRU[string, []byte]) searchOption {
+func searchWithCache(cache *expirable.LRU[string, *jsonResponse]) searchOption {
 	return func(h *searchHandler) {
 		h.cache = cache
 	}
@@ -78,13 +79,29 @@ func searchWithAllowUnknownQueryParameters(allow bool) searchOption {
 	}
 }
 
+// purgeSearchCacheForPackages removes from the cache the responses that could include any
+// of the given packages. Only responses to requests filtering by other packages are kept.
+func purgeSearchCacheForPackages(cache *expirable.LRU[string, *jsonResponse], packageNames []string) {
+	for _, key := range cache.Keys() {
+		u, err := url.Parse(responseCacheKeyURL(key))
+		if err == nil {
+			name := u.Query().Get("package")
+			if name != "" && !slices.Contains(packageNames, name) {
+				continue
+			}
+		}
+		cache.Remove(key)
+	}
+}
+
 func (h *searchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	logger := h.logger.With(apmzap.TraceContext(r.Context())...)
 
+	cacheKey := responseCacheKey(r)
 	if h.cache != nil {
-		if response, ok := h.cache.Get(r.URL.String()); ok {
+		if response, ok := h.cache.Get(cacheKey); ok {
 			logger.Debug("using as response cached request", zap.String("cache.url", r.URL.String()), zap.Int("cache.size", h.cache.Len()))
-			serveJSONResponse(r.Context(), w, h.cacheTime, response)
+			serveJSONResponse(w, r, h.cacheTime, response)
 			return
 		}
 	}
@@ -94,9 +111,23 @@ func (h *searchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		badRequest(w, err.Error())
 		return
 	}
+	page, err := newSearchPageFromQuery(r.URL.Query(), func() string {
+		return searchIndexVersion(r.Context(), h.indexer)
+	})
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+
+	filter.Restrictions = packages.AccessRestrictionsFromContext(r.Context())
 	opts := packages.GetOptions{
 		Filter: filter,
 	}
+	if page != nil && filter.Query == "" {
+		// Request one more package to know if there is a next page.
+		opts.After = page.after()
+		opts.Limit = page.limit + 1
+	}
 
 	packages, err := h.indexer.Get(r.Context(), &opts)
 	if err != nil {
@@ -104,26 +135,45 @@ func (h *searchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Responses are not cached if any upstream failed, so they are complete again once the
+	// upstreams recover.
+	partialResponse := false
 	if h.proxyMode.Enabled() {
-		proxiedPackages, err := h.proxyMode.Search(r)
+		proxiedPackages, err := h.proxyMode.Search(withoutPaginationParameters(r))
 		if err != nil {
-			logger.Error("proxy mode: search failed", zap.Error(err))
-			http.Error(w, "internal server error", http.StatusInternalServerError)
-			return
+			logger.Warn("proxy mode: search failed in some upstreams, response includes only the available ones", zap.Error(err))
+			partialResponse = true
 		}
+		proxiedPackages = opts.Filter.Restrictions.Filter(proxiedPackages)
 		packages = packages.Join(proxiedPackages)
 		if !opts.Filter.AllVersions {
 			packages = latestPackagesVersion(packages)
 		}
 	}
 
-	data, err := getSearchOutput(r.Context(), packages)
+	var nextPage *searchPageToken
+	if page != nil {
+		packages, nextPage = page.selectPage(packages, filter.Query)
+	}
+
+	data, err := getSearchOutput(r.Context(), packages, filter.Query)
 	if err != nil {
 		notFoundError(w, err)
 		return
 	}
 
-	serveJSONResponse(r.Context(), w, h.cacheTime, data)
+	if nextPage != nil {
+		token, err := nextPage.encode()
+		if err != nil {
+			logger.Error("failed to encode next page token", zap.Error(err))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Link", nextPageLink(r.URL, token))
+	}
+
+	response := newJSONResponse(data)
+	serveJSONResponse(w, r, h.cacheTime, response)
 
 	if h.cache != nil {
 		switch {
@@ -135,8 +185,16 @@ func (h *searchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			// - `/search?package=foo` request is not added to the cache
 			// - `/search?package=foo&all=true` request is is added
 			logger.Debug("skipped add to cache for search request with package query parameter", zap.String("cache.url", r.URL.String()), zap.Int("cache.size", h.cache.Len()))
+		case page != nil:
+			// Pages are only valid for a given state of the index, and responses include links to the next page.
+			logger.Debug("skipped add to cache for paginated search request", zap.String("cache.url", r.URL.String()), zap.Int("cache.size", h.cache.Len()))
+		case partialResponse:
+			logger.Debug("skipped add to cache for partial search response", zap.String("cache.url", r.URL.String()), zap.Int("cache.size", h.cache.Len()))
+		case filter.Query != "":
+			// Free-text queries are typed by users, so most of them are unique and they would only cause evictions.
+			logger.Debug("skipped add to cache for search request with free-text query", zap.String("cache.url", r.URL.String()), zap.Int("cache.size", h.cache.Len()))
 		default:
-			val := h.cache.Add(r.URL.String(), data)
+			val := h.cache.Add(cacheKey, response)
 			logger.Debug("added to cache request", zap.String("cache.url", r.URL.String()), zap.Int("cache.size", h.cache.Len()), zap.Bool("cache.eviction", val))
 		}
 	}
@@ -172,6 +230,10 @@ func newSearchFilterFromQuery(query url.Values, allowUnknownQueryParameters bool
 			if v != "" {
 				filter.PackageName = v
 			}
+		case "q":
+			if v != "" {
+				filter.Query = v
+			}
 		case "type":
 			if v != "" {
 				filter.PackageType = v
@@ -224,6 +286,8 @@ func newSearchFilterFromQuery(query url.Values, allowUnknownQueryParameters bool
 				}
 				filter.Discovery = append(filter.Discovery, discovery)
 			}
+		case searchLimitParameter, searchPageTokenParameter:
+			// Pagination parameters, parsed by newSearchPageFromQuery.
 		case "internal":
 			// Parameter removed in https://github.com/elastic/package-registry/pull/765
 			// Keep it here to avoid breaking existing clients.
@@ -256,12 +320,17 @@ func getSpecVersion(version string) (*semver.Version, error) {
 	return specVersion, nil
 }
 
-func getSearchOutput(ctx context.Context, packageList packages.Packages) ([]byte, error) {
+func getSearchOutput(ctx context.Context, packageList packages.Packages, query string) ([]byte, error) {
 	span, _ := apm.StartSpan(ctx, "GetPackageOutput", "app")
 	defer span.End()
 
-	// Packages need to be sorted to be always outputted in the same order
-	sort.Sort(packageList)
+	// Packages need to be sorted to be always outputted in the same order,
+	// the most relevant ones first when searching with a free-text query.
+	if query != "" {
+		packages.SortByRelevance(packageList, query)
+	} else {
+		sort.Sort(packageList)
+	}
 
 	var output []packages.BasePackage
 	for _, p := range packageList {
@@ -0,0 +1,213 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/elastic/package-registry/packages"
+)
+
+const (
+	searchLimitParameter     = "limit"
+	searchPageTokenParameter = "page_token"
+
+	// maxSearchLimit is the maximum number of packages that can be requested in a page.
+	maxSearchLimit = 10000
+)
+
+var (
+	errMalformedSearchPageToken = errors.New("malformed token")
+	errSearchPageTokenExpired   = errors.New("page token has expired, the index has been updated since the first page was requested")
+)
+
+// searchPageToken is the continuation token used to request the next page of search results.
+// It is opaque for clients, it is encoded as base64 JSON.
+type searchPageToken struct {
+	// Index is the version of the index used to obtain the previous page.
+	Index string `json:"i"`
+	// Query is a hash of the query parameters used to obtain the previous page.
+	Query string `json:"q"`
+
+	// Name and Version of the last package of the previous page, used when
+	// results are sorted by name and version.
+	Name    string `json:"n,omitempty"`
+	Version string `json:"v,omitempty"`
+
+	// Offset is the number of packages already returned, used when results are
+	// sorted by relevance.
+	Offset int `json:"o,omitempty"`
+}
+
+func (t searchPageToken) encode() (string, error) {
+	d, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(d), nil
+}
+
+func decodeSearchPageToken(s string) (*searchPageToken, error) {
+	d, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errMalformedSearchPageToken
+	}
+	var token searchPageToken
+	err = json.Unmarshal(d, &token)
+	if err != nil || token.Offset < 0 {
+		return nil, errMalformedSearchPageToken
+	}
+	return &token, nil
+}
+
+// searchPage is a page of search results requested with the pagination query parameters.
+type searchPage struct {
+	limit int
+	index string
+	query string
+	token *searchPageToken
+}
+
+// newSearchPageFromQuery parses the pagination query parameters. It returns nil if the
+// request is not paginated. Tokens are only valid for the same index version and the same
+// query parameters, excluding the limit, that were used to obtain the previous page.
+func newSearchPageFromQuery(query url.Values, indexVersion func() string) (*searchPage, error) {
+	if !query.Has(searchLimitParameter) && !query.Has(searchPageTokenParameter) {
+		return nil, nil
+	}
+
+	v := query.Get(searchLimitParameter)
+	if v == "" {
+		return nil, fmt.Errorf("'%s' is required when using '%s'", searchLimitParameter, searchPageTokenParameter)
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 || limit > maxSearchLimit {
+		return nil, fmt.Errorf("invalid '%s' query param: '%s', it must be a number between 1 and %d", searchLimitParameter, v, maxSearchLimit)
+	}
+
+	page := searchPage{
+		limit: limit,
+		index: indexVersion(),
+		query: searchQueryHash(query),
+	}
+
+	if v := query.Get(searchPageTokenParameter); v != "" {
+		page.token, err = decodeSearchPageToken(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid '%s' query param: %w", searchPageTokenParameter, err)
+		}
+		if page.token.Query != page.query {
+			return nil, fmt.Errorf("invalid '%s' query param: it was obtained with different query parameters", searchPageTokenParameter)
+		}
+		if page.token.Index != page.index {
+			return nil, errSearchPageTokenExpired
+		}
+	}
+
+	return &page, nil
+}
+
+// after returns the key of the last package returned in the previous page, if any.
+func (p *searchPage) after() *packages.PackageKey {
+	if p.token == nil || p.token.Name == "" {
+		return nil
+	}
+	return &packages.PackageKey{Name: p.token.Name, Version: p.token.Version}
+}
+
+// selectPage selects the packages of this page from the search results, and returns the
+// token for the next page, or nil if this is the last one. Results of free-text queries are
+// sorted by relevance, so they are paginated by offset.
+func (p *searchPage) selectPage(packageList packages.Packages, textQuery string) (packages.Packages, *searchPageToken) {
+	next := searchPageToken{
+		Index: p.index,
+		Query: p.query,
+	}
+
+	if textQuery != "" {
+		offset := 0
+		if p.token != nil {
+			offset = p.token.Offset
+		}
+		sorted := append(packages.Packages{}, packageList...)
+		packages.SortByRelevance(sorted, textQuery)
+		sorted = sorted[min(offset, len(sorted)):]
+		if len(sorted) <= p.limit {
+			return sorted, nil
+		}
+		next.Offset = offset + p.limit
+		return sorted[:p.limit], &next
+	}
+
+	// Request one more package to know if there is a next page.
+	page := packages.SelectPage(packageList, p.after(), p.limit+1)
+	if len(page) <= p.limit {
+		return page, nil
+	}
+	page = page[:p.limit]
+	last := page[len(page)-1]
+	next.Name = last.Name
+	next.Version = last.Version
+	return page, &next
+}
+
+// searchQueryHash returns a hash of the query parameters, excluding the pagination ones.
+func searchQueryHash(query url.Values) string {
+	values := make(url.Values, len(query))
+	for k, v := range query {
+		if k == searchLimitParameter || k == searchPageTokenParameter {
+			continue
+		}
+		values[k] = v
+	}
+	sum := sha256.Sum256([]byte(values.Encode()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// searchIndexVersion returns an identifier of the current state of the indexers, based on
+// their cursors or on the time of their last updates.
+func searchIndexVersion(ctx context.Context, indexer Indexer) string {
+	h := sha256.New()
+	for _, status := range indexersStatus(ctx, indexer) {
+		fmt.Fprintf(h, "%s:%s", status.Name, status.Cursor)
+		if status.Cursor == "" && status.LastSuccessfulUpdate != nil {
+			fmt.Fprintf(h, ":%d", status.LastSuccessfulUpdate.UnixNano())
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// withoutPaginationParameters returns a copy of the request without the pagination query
+// parameters, so it can be forwarded to services that don't know about our page tokens.
+func withoutPaginationParameters(r *http.Request) *http.Request {
+	query := r.URL.Query()
+	if !query.Has(searchLimitParameter) && !query.Has(searchPageTokenParameter) {
+		return r
+	}
+	query.Del(searchLimitParameter)
+	query.Del(searchPageTokenParameter)
+
+	proxied := r.Clone(r.Context())
+	proxied.URL.RawQuery = query.Encode()
+	return proxied
+}
+
+// nextPageLink returns the value of the Link header pointing to the next page.
+func nextPageLink(u *url.URL, token string) string {
+	query := u.Query()
+	query.Set(searchPageTokenParameter, token)
+	next := url.URL{Path: u.Path, RawQuery: query.Encode()}
+	return fmt.Sprintf("<%s>; rel=\"next\"", next.String())
+}
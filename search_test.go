@@ -5,11 +5,16 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/package-registry/packages"
@@ -137,3 +142,139 @@ func TestSearchWithProxyMode(t *testing.T) {
 		})
 	}
 }
+
+func TestSearchWithFailingProxyUpstream(t *testing.T) {
+	webServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}))
+	defer webServer.Close()
+
+	indexer := packages.NewZipFileSystemIndexer(packages.FSIndexerOptions{Logger: testLogger}, "./testdata/local-storage")
+	defer indexer.Close(t.Context())
+	require.NoError(t, indexer.Init(t.Context()))
+
+	noRetries := 0
+	proxyMode, err := proxymode.NewProxyMode(testLogger, proxymode.ProxyOptions{
+		Enabled: true,
+		Upstreams: []proxymode.UpstreamOptions{
+			{URL: webServer.URL, Retry: proxymode.UpstreamRetryOptions{Max: &noRetries}},
+		},
+	})
+	require.NoError(t, err)
+
+	cache := expirable.NewLRU[string, *jsonResponse](10, nil, time.Minute)
+	searchHandler, err := newSearchHandler(testLogger, indexer, testCacheTime,
+		searchWithProxy(proxyMode),
+		searchWithCache(cache),
+	)
+	require.NoError(t, err)
+
+	recorder := recordRequest(t, "/search", "/search", searchHandler)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var result []map[string]any
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	assert.NotEmpty(t, result)
+
+	// Partial responses are not cached.
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestSearchPagination(t *testing.T) {
+	fsOpts := packages.FSIndexerOptions{
+		Logger: testLogger,
+	}
+
+	packagesBasePaths := []string{"./testdata/second_package_path", "./testdata/package"}
+	indexer := NewCombinedIndexer(
+		packages.NewZipFileSystemIndexer(fsOpts, "./testdata/local-storage"),
+		packages.NewFileSystemIndexer(fsOpts, packagesBasePaths...),
+	)
+	defer indexer.Close(t.Context())
+
+	err := indexer.Init(t.Context())
+	require.NoError(t, err)
+
+	searchHandler, err := newSearchHandler(testLogger, indexer, testCacheTime)
+	require.NoError(t, err)
+
+	nextLinkPattern := regexp.MustCompile(`^<(.*)>; rel="next"$`)
+	search := func(t *testing.T, endpoint string) (found []string, next string) {
+		recorder := recordRequest(t, endpoint, "/search", searchHandler)
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+		var result []packages.BasePackage
+		err := json.Unmarshal(recorder.Body.Bytes(), &result)
+		require.NoError(t, err)
+		for _, p := range result {
+			found = append(found, p.Name+"-"+p.Version)
+		}
+
+		if link := recorder.Header().Get("Link"); link != "" {
+			matches := nextLinkPattern.FindStringSubmatch(link)
+			require.Len(t, matches, 2, "unexpected link header %q", link)
+			next = matches[1]
+		}
+		return found, next
+	}
+
+	for _, query := range []string{"", "all=true&prerelease=true", "prerelease=true&category=web", "all=true&q=logs"} {
+		t.Run(query, func(t *testing.T) {
+			expected, next := search(t, "/search?"+query)
+			require.NotEmpty(t, expected)
+			require.Empty(t, next)
+
+			var found []string
+			pages := 0
+			next = "/search?limit=2&" + query
+			for next != "" {
+				var page []string
+				page, next = search(t, next)
+				assert.LessOrEqual(t, len(page), 2)
+				found = append(found, page...)
+				pages++
+			}
+			assert.Equal(t, expected, found)
+			assert.Equal(t, (len(expected)+1)/2, pages)
+		})
+	}
+
+	t.Run("token for other query", func(t *testing.T) {
+		_, next := search(t, "/search?all=true&limit=1")
+		require.NotEmpty(t, next)
+
+		recorder := recordRequest(t, next+"&category=web", "/search", searchHandler)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		_, next := search(t, "/search?all=true&limit=1")
+		require.NotEmpty(t, next)
+
+		// Update the index.
+		err := indexer.Init(t.Context())
+		require.NoError(t, err)
+
+		recorder := recordRequest(t, next, "/search", searchHandler)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "expired")
+	})
+}
+
+func TestPurgeSearchCacheForPackages(t *testing.T) {
+	cache := expirable.NewLRU[string, *jsonResponse](10, nil, time.Minute)
+	for _, key := range []string{
+		" /search?package=foo&all=true",
+		" /search?package=bar&all=true",
+		"0,1 /search?package=bar&all=true",
+		"0,1 /search?package=baz",
+		" /search?category=security",
+		" /search",
+	} {
+		cache.Add(key, newJSONResponse([]byte("[]")))
+	}
+
+	purgeSearchCacheForPackages(cache, []string{"foo", "baz"})
+
+	assert.Equal(t, []string{" /search?package=bar&all=true", "0,1 /search?package=bar&all=true"}, cache.Keys())
+}
@@ -82,6 +82,7 @@ func (h *signaturesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	opts := packages.NameVersionFilter(packageName, packageVersion)
+	opts.Filter.Restrictions = packages.AccessRestrictionsFromContext(r.Context())
 	opts.SkipPackageData = true
 
 	pkgs, err := h.indexer.Get(r.Context(), &opts)
@@ -100,7 +101,7 @@ func (h *signaturesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 			return
 		}
-		if proxiedPackage != nil {
+		if proxiedPackage != nil && opts.Filter.Restrictions.Allows(proxiedPackage) {
 			pkgs = pkgs.Join(packages.Packages{proxiedPackage})
 		}
 	}
@@ -73,6 +73,7 @@ func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	opts := packages.NameVersionFilter(params.packageName, params.packageVersion)
+	opts.Filter.Restrictions = packages.AccessRestrictionsFromContext(r.Context())
 	opts.SkipPackageData = true
 
 	pkgs, err := h.indexer.Get(r.Context(), &opts)
@@ -91,7 +92,7 @@ func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 			return
 		}
-		if proxiedPackage != nil {
+		if proxiedPackage != nil && opts.Filter.Restrictions.Allows(proxiedPackage) {
 			pkgs = pkgs.Join(packages.Packages{proxiedPackage})
 		}
 	}
@@ -8,10 +8,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net"
 	"net/http"
 	"net/url"
-	"strings"
+	"slices"
 	"sync"
 	"time"
 
@@ -23,43 +24,86 @@ import (
 	"github.com/elastic/package-registry/metrics"
 	"github.com/elastic/package-registry/packages"
 
+	"github.com/elastic/package-registry/internal/contentcache"
 	internalStorage "github.com/elastic/package-registry/internal/storage"
 )
 
-const indexerGetDurationPrometheusLabel = "StorageIndexer"
+const (
+	indexerGetDurationPrometheusLabel = "StorageIndexer"
+
+	// fieldsReadTimeout is the maximum time to get each fields file when indexing fields.
+	fieldsReadTimeout = 30 * time.Second
+)
 
 type Indexer struct {
 	options       IndexerOptions
 	storageClient *storage.Client
 
+	store           internalStorage.ObjectStore
+	rootStoragePath string
+
 	cursor             string
 	packageList        packages.Packages
+	textIndex          *packages.TextIndex
+	lookupIndex        *packages.LookupIndex
 	deprecatedPackages packages.DeprecatedPackages
 
 	m sync.RWMutex
 
 	resolver packages.RemoteResolver
 
+	// fieldNames keeps the names of the fields of the packages, nil if fields are not indexed.
+	fieldNames *packages.FieldNamesReader
+
+	// fingerprints of the packages of the last update, to find the changes of the next one.
+	fingerprints packages.PackageFingerprints
+
 	logger *zap.Logger
+
+	status packages.IndexerStatusTracker
 }
 
 type IndexerOptions struct {
 	APMTracer                    *apm.Tracer
 	PackageStorageBucketInternal string
 	PackageStorageEndpoint       string
+	S3                           internalStorage.S3Options
 	WatchInterval                time.Duration
 	IncrementalUpdates           bool
+
+	// ContentCache, if set, caches the artifacts and static files served from the
+	// package storage endpoint.
+	ContentCache *contentcache.Cache
+
+	// IndexFields enables the indexing of the names of the fields of the packages, so they
+	// can be looked up by field. Fields files are read from the package storage endpoint.
+	IndexFields bool
+
+	// IndexChangesHook, if set, is called with the packages changed by each update of the
+	// index. It is not called for the initial load of the index, unless FingerprintsDir is
+	// set.
+	IndexChangesHook packages.IndexChangesHook
+
+	// FingerprintsDir, if set, is the directory where the fingerprints of the packages are
+	// persisted, so the initial load is compared with the packages indexed before stopping,
+	// and the changes made meanwhile are also passed to IndexChangesHook.
+	FingerprintsDir string
 }
 
 func NewIndexer(logger *zap.Logger, storageClient *storage.Client, options IndexerOptions) *Indexer {
 	if options.APMTracer == nil {
 		options.APMTracer = apm.DefaultTracer()
 	}
+	var fieldNames *packages.FieldNamesReader
+	if options.IndexFields {
+		fieldNames = packages.NewFieldNamesReader(&http.Client{Timeout: fieldsReadTimeout})
+	}
 	return &Indexer{
 		storageClient:      storageClient,
 		options:            options,
 		logger:             logger,
 		deprecatedPackages: make(packages.DeprecatedPackages),
+		fieldNames:         fieldNames,
 	}
 }
 
@@ -71,6 +115,11 @@ func (i *Indexer) Init(ctx context.Context) error {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
+	i.store, i.rootStoragePath, err = internalStorage.OpenObjectStore(i.options.PackageStorageBucketInternal, i.storageClient, i.options.S3)
+	if err != nil {
+		return fmt.Errorf("can't open bucket: %w", err)
+	}
+
 	err = i.setupResolver()
 	if err != nil {
 		return fmt.Errorf("can't setup remote resolver: %w", err)
@@ -87,7 +136,7 @@ func (i *Indexer) Init(ctx context.Context) error {
 }
 
 func validateIndexerOptions(options IndexerOptions) error {
-	if !strings.HasPrefix(options.PackageStorageBucketInternal, "gs://") {
+	if !internalStorage.IsSupportedBucketURL(options.PackageStorageBucketInternal) {
 		return errors.New("missing or invalid options.PackageStorageBucketInternal")
 	}
 	_, err := url.Parse(options.PackageStorageEndpoint)
@@ -106,6 +155,11 @@ func (i *Indexer) setupResolver() error {
 		return err
 	}
 
+	if baseURL.Scheme == "file" {
+		i.resolver, err = internalStorage.NewLocalResolver(baseURL)
+		return err
+	}
+
 	httpClient := http.Client{
 		Transport: &http.Transport{
 			DialContext: (&net.Dialer{
@@ -115,7 +169,7 @@ func (i *Indexer) setupResolver() error {
 		},
 	}
 
-	i.resolver = internalStorage.NewStorageResolver(&httpClient, baseURL)
+	i.resolver = i.options.ContentCache.Resolver(internalStorage.NewStorageResolver(&httpClient, baseURL))
 	return nil
 }
 
@@ -152,17 +206,27 @@ func (i *Indexer) watchIndices(ctx context.Context) {
 	}
 }
 
-func (i *Indexer) updateIndex(ctx context.Context) error {
+func (i *Indexer) updateIndex(ctx context.Context) (err error) {
 	span, ctx := apm.StartSpan(ctx, "UpdateIndex", "app")
 	defer span.End()
 
+	defer func() {
+		if err != nil {
+			i.status.UpdateFailed(err)
+			return
+		}
+		i.m.RLock()
+		defer i.m.RUnlock()
+		i.status.UpdateSucceeded(i.cursor, len(i.packageList))
+	}()
+
 	i.logger.Debug("Update indices")
 	start := time.Now()
 	defer func() {
 		metrics.StorageIndexerUpdateIndexDurationSeconds.Observe(time.Since(start).Seconds())
 	}()
 
-	latestCursorValue, err := internalStorage.LoadLatestCursorValue(ctx, i.logger, i.storageClient, i.options.PackageStorageBucketInternal)
+	latestCursorValue, err := internalStorage.LoadLatestCursorValue(ctx, i.logger, i.store, i.rootStoragePath)
 	if err != nil {
 		metrics.StorageIndexerUpdateIndexErrorsTotal.Inc()
 		return fmt.Errorf("can't load latest cursor: %w", err)
@@ -172,13 +236,66 @@ func (i *Indexer) updateIndex(ctx context.Context) error {
 	}
 
 	if i.cursor == "" || !i.options.IncrementalUpdates {
-		return i.fullSync(ctx, latestCursorValue)
+		err = i.fullSync(ctx, latestCursorValue)
+	} else {
+		err = i.incrementalSync(ctx, latestCursorValue)
+	}
+	if err != nil {
+		return err
+	}
+	i.notifyChanges(ctx)
+	return nil
+}
+
+// notifyChanges calls the index changes hook with the packages changed since the previous
+// update, found comparing the fingerprints of the packages. The package list is replaced
+// and not modified in place by the updates, and updates are not run concurrently, so the
+// fingerprints can be obtained without holding the lock. On the initial load, they are
+// compared with the fingerprints persisted in FingerprintsDir, if set.
+func (i *Indexer) notifyChanges(ctx context.Context) {
+	if i.options.IndexChangesHook == nil {
+		return
+	}
+	i.m.RLock()
+	packageList := i.packageList
+	i.m.RUnlock()
+
+	fingerprints, err := packages.NewPackageFingerprints(packageList)
+	if err != nil {
+		i.logger.Error("failed to obtain changes in the index", zap.Error(err))
+		return
+	}
+	fingerprintsPath := packages.FingerprintsPath(i.options.FingerprintsDir, indexerGetDurationPrometheusLabel, i.options.PackageStorageBucketInternal)
+	initialLoad := i.fingerprints == nil
+	previous := i.fingerprints
+	if initialLoad && fingerprintsPath != "" {
+		previous, err = packages.ReadPackageFingerprints(fingerprintsPath)
+		if err != nil {
+			i.logger.Error("failed to read persisted fingerprints of the packages, changes since the last run are not notified", zap.Error(err))
+		}
+	}
+	i.fingerprints = fingerprints
+
+	var changes packages.IndexChanges
+	if previous != nil {
+		changes = previous.Changes(fingerprints)
+	}
+	if !changes.Empty() {
+		changes.Indexer = indexerGetDurationPrometheusLabel
+		i.options.IndexChangesHook(ctx, changes)
+	}
+	// Fingerprints are persisted once the changes have been notified, so they are notified
+	// again if the registry stops before.
+	if fingerprintsPath != "" && (initialLoad || !changes.Empty()) {
+		err := fingerprints.WriteFile(fingerprintsPath)
+		if err != nil {
+			i.logger.Error("failed to persist fingerprints of the packages", zap.Error(err))
+		}
 	}
-	return i.incrementalSync(ctx, latestCursorValue)
 }
 
 func (i *Indexer) fullSync(ctx context.Context, latestCursorValue string) error {
-	anIndex, err := internalStorage.LoadSearchIndexAllForCursor(ctx, i.logger, i.storageClient, i.options.PackageStorageBucketInternal, latestCursorValue)
+	anIndex, err := internalStorage.LoadSearchIndexAllForCursor(ctx, i.logger, i.store, i.rootStoragePath, latestCursorValue)
 	if err != nil {
 		metrics.StorageIndexerUpdateIndexErrorsTotal.Inc()
 		return fmt.Errorf("can't load the search-index-all index content: %w", err)
@@ -190,11 +307,16 @@ func (i *Indexer) fullSync(ctx context.Context, latestCursorValue string) error
 	i.logger.Info("Downloaded new search-index-all index", zap.String("index.packages.size", fmt.Sprintf("%d", len(*anIndex))))
 
 	i.transformSearchIndexAllToPackages(anIndex)
+	textIndex := packages.NewTextIndex(*anIndex)
+	i.preloadFieldNames(ctx, *anIndex)
+	lookupIndex := i.newLookupIndex(ctx, *anIndex)
 
 	i.m.Lock()
 	defer i.m.Unlock()
 	i.cursor = latestCursorValue
 	i.packageList = *anIndex
+	i.textIndex = textIndex
+	i.lookupIndex = lookupIndex
 	metrics.StorageIndexerUpdateIndexSuccessTotal.Inc()
 	metrics.NumberIndexedPackages.Set(float64(len(i.packageList)))
 
@@ -205,7 +327,7 @@ func (i *Indexer) fullSync(ctx context.Context, latestCursorValue string) error
 }
 
 func (i *Indexer) incrementalSync(ctx context.Context, latestCursorValue string) error {
-	cursors, err := internalStorage.ListCursorsBetween(ctx, i.storageClient, i.options.PackageStorageBucketInternal, i.cursor, latestCursorValue)
+	cursors, err := internalStorage.ListCursorsBetween(ctx, i.store, i.rootStoragePath, i.cursor, latestCursorValue)
 	if err != nil {
 		metrics.StorageIndexerUpdateIndexErrorsTotal.Inc()
 		return fmt.Errorf("can't list cursors between %s and %s: %w", i.cursor, latestCursorValue, err)
@@ -220,10 +342,10 @@ func (i *Indexer) incrementalSync(ctx context.Context, latestCursorValue string)
 
 	revisions := make([]revision, 0, len(cursors))
 	for _, cursor := range cursors {
-		delta, err := internalStorage.LoadSearchIndexDelta(ctx, i.logger, i.storageClient, i.options.PackageStorageBucketInternal, cursor)
+		delta, err := internalStorage.LoadSearchIndexDelta(ctx, i.logger, i.store, i.rootStoragePath, cursor)
 		if err != nil {
 			i.logger.Warn("failed to load delta, falling back to full sync for timestamp", zap.String("cursor", cursor), zap.Error(err))
-			anIndex, err := internalStorage.LoadSearchIndexAllForCursor(ctx, i.logger, i.storageClient, i.options.PackageStorageBucketInternal, cursor)
+			anIndex, err := internalStorage.LoadSearchIndexAllForCursor(ctx, i.logger, i.store, i.rootStoragePath, cursor)
 			if err != nil {
 				metrics.StorageIndexerUpdateIndexErrorsTotal.Inc()
 				return fmt.Errorf("can't load search-index-all for cursor %s: %w", cursor, err)
@@ -244,10 +366,13 @@ func (i *Indexer) incrementalSync(ctx context.Context, latestCursorValue string)
 	for j := range revisions {
 		if revisions[j].fullIndex != nil {
 			i.transformSearchIndexAllToPackages(revisions[j].fullIndex)
+			i.preloadFieldNames(ctx, *revisions[j].fullIndex)
 		} else if revisions[j].delta != nil {
 			pd := i.prepareDelta(revisions[j].delta)
 			revisions[j].prepared = &pd
 			revisions[j].delta = nil
+			i.preloadFieldNames(ctx, pd.added)
+			i.preloadFieldNames(ctx, slices.Collect(maps.Values(pd.updateMap)))
 		}
 	}
 
@@ -263,6 +388,8 @@ func (i *Indexer) incrementalSync(ctx context.Context, latestCursorValue string)
 		}
 		i.cursor = r.cursor
 	}
+	i.textIndex = packages.NewTextIndex(i.packageList)
+	i.lookupIndex = i.newLookupIndex(ctx, i.packageList)
 
 	metrics.StorageIndexerUpdateIndexSuccessTotal.Inc()
 	metrics.NumberIndexedPackages.Set(float64(len(i.packageList)))
@@ -349,9 +476,42 @@ func (i *Indexer) Get(ctx context.Context, opts *packages.GetOptions) (packages.
 	defer i.m.RUnlock()
 
 	if opts != nil && opts.Filter != nil {
-		return opts.Filter.Apply(ctx, i.packageList)
+		packageList := i.packageList
+		if opts.Filter.Query != "" {
+			packageList = i.textIndex.Search(opts.Filter.Query)
+		}
+		packageList = i.lookupIndex.Select(packageList, opts.Filter)
+		packageList, err := opts.Filter.Apply(ctx, packageList)
+		if err != nil {
+			return nil, err
+		}
+		return opts.SelectPage(packageList), nil
+	}
+	return opts.SelectPage(i.packageList), nil
+}
+
+// preloadFieldNames reads the names of the fields of the packages, if fields are indexed.
+// Packages whose fields cannot be read are indexed without fields.
+func (i *Indexer) preloadFieldNames(ctx context.Context, pkgs packages.Packages) {
+	if i.fieldNames == nil {
+		return
+	}
+	err := i.fieldNames.Preload(ctx, pkgs)
+	if err != nil {
+		i.logger.Warn("failed to read fields of some packages, they won't be found by their fields", zap.Error(err))
 	}
-	return i.packageList, nil
+}
+
+// newLookupIndex builds the lookup index of the packages, with the names of the fields
+// already read by preloadFieldNames, so it doesn't do any request.
+func (i *Indexer) newLookupIndex(ctx context.Context, pkgs packages.Packages) *packages.LookupIndex {
+	var fieldNames func(context.Context, *packages.Package) ([]string, error)
+	if i.fieldNames != nil {
+		fieldNames = i.fieldNames.Loaded
+	}
+	// Building the index only fails if fieldNames fails, which never happens with Loaded.
+	lookupIndex, _ := packages.NewLookupIndex(ctx, pkgs, fieldNames)
+	return lookupIndex
 }
 
 func (i *Indexer) transformSearchIndexAllToPackages(packages *packages.Packages) {
@@ -364,3 +524,11 @@ func (i *Indexer) transformSearchIndexAllToPackages(packages *packages.Packages)
 func (i *Indexer) Close(ctx context.Context) error {
 	return nil
 }
+
+// Status returns the status of the last updates of the index.
+func (i *Indexer) Status(ctx context.Context) packages.IndexerStatus {
+	status := i.status.Status()
+	status.Name = indexerGetDurationPrometheusLabel
+	status.Periodic = i.options.WatchInterval > 0
+	return status
+}
@@ -34,6 +34,61 @@ func TestInit(t *testing.T) {
 
 	// then
 	require.NoError(t, err)
+
+	status := indexer.Status(t.Context())
+	assert.Equal(t, "1", status.Cursor)
+	assert.NotZero(t, status.PackagesCount)
+	assert.NotNil(t, status.LastSuccessfulUpdate)
+	assert.Nil(t, status.LastFailedUpdate)
+}
+
+func TestInitWithS3(t *testing.T) {
+	// given
+	s3Server := internalStorage.PrepareFakeS3Server(t, "testdata/search-index-all-small.json")
+	defer s3Server.Close()
+
+	options := FakeIndexerOptions
+	options.PackageStorageBucketInternal = "s3://" + internalStorage.FakePackageStorageBucketInternal
+	options.S3 = s3Server.Options()
+
+	indexer := NewIndexer(util.NewTestLogger(), nil, options)
+	defer indexer.Close(t.Context())
+
+	// when
+	err := indexer.Init(t.Context())
+
+	// then
+	require.NoError(t, err)
+
+	foundPackages, err := indexer.Get(t.Context(), &packages.GetOptions{
+		Filter: &packages.Filter{
+			PackageName: "1password",
+			PackageType: "integration",
+			Prerelease:  true,
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, foundPackages, 1)
+	assert.Equal(t, "0.2.0", foundPackages[0].Version)
+}
+
+func TestInitWithLocalBucket(t *testing.T) {
+	// given
+	options := FakeIndexerOptions
+	options.PackageStorageBucketInternal = internalStorage.PrepareLocalBucket(t, "testdata/search-index-all-small.json")
+
+	indexer := NewIndexer(util.NewTestLogger(), nil, options)
+	defer indexer.Close(t.Context())
+
+	// when
+	err := indexer.Init(t.Context())
+
+	// then
+	require.NoError(t, err)
+
+	status := indexer.Status(t.Context())
+	assert.Equal(t, "1", status.Cursor)
+	assert.NotZero(t, status.PackagesCount)
 }
 
 func BenchmarkInit(b *testing.B) {
@@ -72,7 +127,7 @@ func BenchmarkIndexerUpdateIndex(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		b.StopTimer()
 		revision := fmt.Sprintf("%d", i+2)
-		fs, indexer.storageClient = internalStorage.UpdateFakeServer(b, fs, revision, "testdata/search-index-all-full.json")
+		fs, indexer.store = internalStorage.UpdateFakeServer(b, fs, revision, "testdata/search-index-all-full.json")
 		b.StartTimer()
 		err = indexer.updateIndex(b.Context())
 		require.NoError(b, err, "index should be updated successfully")
@@ -111,7 +166,7 @@ func BenchmarkIndexerUpdateIndex_Incremental(b *testing.B) {
 
 	// Set up the delta at revision "2" once — "2" > "1" lexicographically so
 	// ListCursorsBetween will find it on every iteration.
-	fs, indexer.storageClient = internalStorage.UpdateFakeServerWithDelta(b, fs, "2", deltaContent)
+	fs, indexer.store = internalStorage.UpdateFakeServerWithDelta(b, fs, "2", deltaContent)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -156,6 +211,35 @@ func BenchmarkIndexerGet(b *testing.B) {
 	}
 }
 
+func TestGet_LookupDataset(t *testing.T) {
+	fs := internalStorage.PrepareFakeServer(t, "testdata/search-index-all-small.json")
+	t.Cleanup(fs.Stop)
+	indexer := NewIndexer(util.NewTestLogger(), internalStorage.ClientNoAuth(fs), FakeIndexerOptions)
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+
+	err := indexer.Init(t.Context())
+	require.NoError(t, err)
+
+	foundPackages, err := indexer.Get(t.Context(), &packages.GetOptions{
+		Filter: &packages.Filter{AllVersions: true, Prerelease: true, Dataset: "1password.item_usages"},
+	})
+	require.NoError(t, err)
+	assert.Len(t, foundPackages, 2)
+
+	foundPackages, err = indexer.Get(t.Context(), &packages.GetOptions{
+		Filter: &packages.Filter{AllVersions: true, Prerelease: true, Dataset: "1password.audit_events"},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, foundPackages)
+
+	// Fields are not indexed by default.
+	foundPackages, err = indexer.Get(t.Context(), &packages.GetOptions{
+		Filter: &packages.Filter{AllVersions: true, Prerelease: true, Field: "data_stream.dataset"},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, foundPackages)
+}
+
 func TestGet_ListPackages(t *testing.T) {
 	t.Parallel()
 
@@ -388,7 +472,7 @@ func TestGet_IndexUpdated(t *testing.T) {
 
 	// when: index update is performed adding new packages
 	const secondRevision = "2"
-	fs, indexer.storageClient = internalStorage.UpdateFakeServer(t, fs, secondRevision, "testdata/search-index-all-full.json")
+	fs, indexer.store = internalStorage.UpdateFakeServer(t, fs, secondRevision, "testdata/search-index-all-full.json")
 	err = indexer.updateIndex(t.Context())
 	require.NoError(t, err, "index should be updated successfully")
 
@@ -408,7 +492,7 @@ func TestGet_IndexUpdated(t *testing.T) {
 
 	// when: index update is performed removing packages
 	const thirdRevision = "3"
-	fs, indexer.storageClient = internalStorage.UpdateFakeServer(t, fs, thirdRevision, "testdata/search-index-all-small.json")
+	fs, indexer.store = internalStorage.UpdateFakeServer(t, fs, thirdRevision, "testdata/search-index-all-small.json")
 	err = indexer.updateIndex(t.Context())
 	require.NoError(t, err, "index should be updated successfully")
 
@@ -427,7 +511,7 @@ func TestGet_IndexUpdated(t *testing.T) {
 	require.Equal(t, "0.2.0", foundPackages[0].Version)
 
 	// when: index update is performed updating some field of an existing pacakage
-	fs, indexer.storageClient = internalStorage.UpdateFakeServer(t, fs, "4", "testdata/search-index-all-small-updated-fields.json")
+	fs, indexer.store = internalStorage.UpdateFakeServer(t, fs, "4", "testdata/search-index-all-small-updated-fields.json")
 	err = indexer.updateIndex(t.Context())
 	require.NoError(t, err, "index should be updated successfully")
 
@@ -478,7 +562,7 @@ func TestIncrementalUpdate(t *testing.T) {
 		require.NoError(t, err)
 
 		deltaContent := readDeltaFile(t, "testdata/search-index-delta-add.json")
-		_, indexer.storageClient = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", deltaContent)
+		_, indexer.store = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", deltaContent)
 
 		err = indexer.updateIndex(t.Context())
 		require.NoError(t, err)
@@ -497,6 +581,12 @@ func TestIncrementalUpdate(t *testing.T) {
 		assert.True(t, versions["0.3.0"], "new 0.3.0 package must be present")
 		assert.True(t, versions["0.1.1"], "original 0.1.1 package must be present")
 		assert.True(t, versions["0.2.0"], "original 0.2.0 package must be present")
+
+		foundPackages, err = indexer.Get(t.Context(), &packages.GetOptions{
+			Filter: &packages.Filter{AllVersions: true, Prerelease: true, Query: "1pass events"},
+		})
+		require.NoError(t, err)
+		assert.Len(t, foundPackages, 3, "text index must include the added package")
 	})
 
 	t.Run("remove_package", func(t *testing.T) {
@@ -512,7 +602,7 @@ func TestIncrementalUpdate(t *testing.T) {
 		require.NoError(t, err)
 
 		deltaContent := readDeltaFile(t, "testdata/search-index-delta-remove.json")
-		_, indexer.storageClient = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", deltaContent)
+		_, indexer.store = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", deltaContent)
 
 		err = indexer.updateIndex(t.Context())
 		require.NoError(t, err)
@@ -539,7 +629,7 @@ func TestIncrementalUpdate(t *testing.T) {
 		require.NoError(t, err)
 
 		deltaContent := readDeltaFile(t, "testdata/search-index-delta-update.json")
-		_, indexer.storageClient = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", deltaContent)
+		_, indexer.store = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", deltaContent)
 
 		err = indexer.updateIndex(t.Context())
 		require.NoError(t, err)
@@ -568,7 +658,7 @@ func TestIncrementalUpdate(t *testing.T) {
 		require.NoError(t, err)
 
 		deltaContent := readDeltaFile(t, "testdata/search-index-delta-update.json")
-		_, indexer.storageClient = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", deltaContent)
+		_, indexer.store = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", deltaContent)
 
 		err = indexer.updateIndex(t.Context())
 		require.NoError(t, err)
@@ -616,7 +706,7 @@ func TestIncrementalUpdate(t *testing.T) {
 		require.NoError(t, err)
 
 		deltaContent := readDeltaFile(t, "testdata/search-index-delta-update-field-removal.json")
-		_, indexer.storageClient = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", deltaContent)
+		_, indexer.store = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", deltaContent)
 
 		err = indexer.updateIndex(t.Context())
 		require.NoError(t, err)
@@ -652,11 +742,11 @@ func TestIncrementalUpdate(t *testing.T) {
 		require.NoError(t, err)
 
 		// revision "2": add 0.3.0
-		fs, indexer.storageClient = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", readDeltaFile(t, "testdata/search-index-delta-add.json"))
+		fs, indexer.store = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", readDeltaFile(t, "testdata/search-index-delta-add.json"))
 		// revision "3": remove 0.1.1
-		fs, indexer.storageClient = internalStorage.UpdateFakeServerWithDelta(t, fs, "3", readDeltaFile(t, "testdata/search-index-delta-remove.json"))
+		fs, indexer.store = internalStorage.UpdateFakeServerWithDelta(t, fs, "3", readDeltaFile(t, "testdata/search-index-delta-remove.json"))
 		// revision "4": update 0.2.0 title — cursor.json now points to "4"
-		_, indexer.storageClient = internalStorage.UpdateFakeServerWithDelta(t, fs, "4", readDeltaFile(t, "testdata/search-index-delta-update.json"))
+		_, indexer.store = internalStorage.UpdateFakeServerWithDelta(t, fs, "4", readDeltaFile(t, "testdata/search-index-delta-update.json"))
 
 		// single updateIndex call should apply all three deltas
 		err = indexer.updateIndex(t.Context())
@@ -695,7 +785,7 @@ func TestIncrementalUpdate(t *testing.T) {
 		err := indexer.Init(t.Context())
 		require.NoError(t, err)
 
-		_, indexer.storageClient = internalStorage.UpdateFakeServer(t, fs, "2", "testdata/search-index-all-full.json")
+		_, indexer.store = internalStorage.UpdateFakeServer(t, fs, "2", "testdata/search-index-all-full.json")
 		err = indexer.updateIndex(t.Context())
 		require.NoError(t, err)
 
@@ -766,8 +856,8 @@ func TestIncrementalUpdate(t *testing.T) {
 
 		// Publish a valid search-index-all.json at revision "2" first, then overlay a
 		// corrupt delta for the same revision. The full index must survive as the fallback.
-		fs, indexer.storageClient = internalStorage.UpdateFakeServer(t, fs, "2", "testdata/search-index-all-full.json")
-		_, indexer.storageClient = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", []byte(`{invalid json`))
+		fs, indexer.store = internalStorage.UpdateFakeServer(t, fs, "2", "testdata/search-index-all-full.json")
+		_, indexer.store = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", []byte(`{invalid json`))
 
 		err = indexer.updateIndex(t.Context())
 		require.NoError(t, err, "corrupt delta must fall back to full sync without error")
@@ -795,7 +885,7 @@ func TestIncrementalUpdate(t *testing.T) {
 		require.NoError(t, err)
 
 		// Publish only a corrupt delta for revision "2" — no search-index-all.json.
-		_, indexer.storageClient = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", []byte(`{invalid json`))
+		_, indexer.store = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", []byte(`{invalid json`))
 
 		err = indexer.updateIndex(t.Context())
 		require.Error(t, err, "must return an error when both delta and full index are unavailable")
@@ -815,7 +905,7 @@ func TestIncrementalUpdate(t *testing.T) {
 		require.NoError(t, err)
 
 		// revision "2" has a full search-index-all.json but no delta file
-		_, indexer.storageClient = internalStorage.UpdateFakeServer(t, fs, "2", "testdata/search-index-all-full.json")
+		_, indexer.store = internalStorage.UpdateFakeServer(t, fs, "2", "testdata/search-index-all-full.json")
 
 		err = indexer.updateIndex(t.Context())
 		require.NoError(t, err)
@@ -842,7 +932,7 @@ func TestIncrementalUpdate(t *testing.T) {
 		require.NoError(t, err)
 
 		deltaContent := readDeltaFile(t, "testdata/search-index-delta-add.json")
-		_, indexer.storageClient = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", deltaContent)
+		_, indexer.store = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", deltaContent)
 
 		var wg sync.WaitGroup
 		for range 50 {
@@ -879,7 +969,7 @@ func TestIncrementalUpdate(t *testing.T) {
 		// Add 1password 0.3.0; the seeded 0.2.0 pointer is reused by applyDelta and
 		// then written to by PropagateLatestDeprecatedInfoToPackageList.
 		deltaContent := readDeltaFile(t, "testdata/search-index-delta-add.json")
-		_, indexer.storageClient = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", deltaContent)
+		_, indexer.store = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", deltaContent)
 
 		var wg sync.WaitGroup
 		for range 50 {
@@ -896,6 +986,89 @@ func TestIncrementalUpdate(t *testing.T) {
 	})
 }
 
+func TestIndexChangesHook(t *testing.T) {
+	t.Parallel()
+
+	var notified []packages.IndexChanges
+	options := IndexerOptions{
+		PackageStorageBucketInternal: "gs://" + internalStorage.FakePackageStorageBucketInternal,
+		WatchInterval:                0,
+		IncrementalUpdates:           true,
+		IndexChangesHook: func(_ context.Context, changes packages.IndexChanges) {
+			notified = append(notified, changes)
+		},
+	}
+
+	fs := internalStorage.PrepareFakeServer(t, "testdata/search-index-all-small.json")
+	t.Cleanup(fs.Stop)
+
+	indexer := NewIndexer(util.NewTestLogger(), internalStorage.ClientNoAuth(fs), options)
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+
+	err := indexer.Init(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, notified, "initial load is not notified")
+
+	content, err := os.ReadFile("testdata/search-index-delta-remove.json")
+	require.NoError(t, err)
+	fs, _ = internalStorage.UpdateFakeServerWithDelta(t, fs, "2", content)
+	content, err = os.ReadFile("testdata/search-index-delta-update.json")
+	require.NoError(t, err)
+	_, indexer.store = internalStorage.UpdateFakeServerWithDelta(t, fs, "3", content)
+
+	err = indexer.updateIndex(t.Context())
+	require.NoError(t, err)
+
+	require.Len(t, notified, 1)
+	assert.Equal(t, packages.IndexChanges{
+		Indexer: indexerGetDurationPrometheusLabel,
+		Updated: []packages.PackageKey{{Name: "1password", Version: "0.2.0"}},
+		Removed: []packages.PackageKey{{Name: "1password", Version: "0.1.1"}},
+	}, notified[0])
+
+	// Updates without changes are not notified.
+	err = indexer.updateIndex(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, notified, 1)
+}
+
+func TestIndexChangesHookPersistedFingerprints(t *testing.T) {
+	t.Parallel()
+
+	var notified []packages.IndexChanges
+	options := IndexerOptions{
+		PackageStorageBucketInternal: "gs://" + internalStorage.FakePackageStorageBucketInternal,
+		WatchInterval:                0,
+		IndexChangesHook: func(_ context.Context, changes packages.IndexChanges) {
+			notified = append(notified, changes)
+		},
+		FingerprintsDir: t.TempDir(),
+	}
+
+	// Fingerprints persisted in a previous run, with a package not available anymore.
+	fingerprintsPath := packages.FingerprintsPath(options.FingerprintsDir, indexerGetDurationPrometheusLabel, options.PackageStorageBucketInternal)
+	previous := make(packages.PackageFingerprints)
+	previous.Add("removed", "1.0.0", []byte("removed 1.0.0"))
+	require.NoError(t, previous.WriteFile(fingerprintsPath))
+
+	fs := internalStorage.PrepareFakeServer(t, "testdata/search-index-all-small.json")
+	t.Cleanup(fs.Stop)
+
+	indexer := NewIndexer(util.NewTestLogger(), internalStorage.ClientNoAuth(fs), options)
+	t.Cleanup(func() { indexer.Close(context.Background()) })
+
+	err := indexer.Init(t.Context())
+	require.NoError(t, err)
+
+	require.Len(t, notified, 1, "changes since the previous run are notified on the initial load")
+	assert.Equal(t, []packages.PackageKey{{Name: "removed", Version: "1.0.0"}}, notified[0].Removed)
+	assert.Len(t, notified[0].Added, len(indexer.packageList))
+
+	persisted, err := packages.ReadPackageFingerprints(fingerprintsPath)
+	require.NoError(t, err)
+	assert.Equal(t, indexer.fingerprints, persisted)
+}
+
 func TestApplyDelta_AddDuplicateSkipped(t *testing.T) {
 	// If pd.added contains a package whose name+version is already in i.packageList,
 	// applyDelta must not produce a duplicate entry.
@@ -0,0 +1,420 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/gorilla/mux"
+	"go.elastic.co/apm/module/apmzap/v2"
+	"go.uber.org/zap"
+
+	"github.com/elastic/package-registry/internal/auth"
+	"github.com/elastic/package-registry/packages"
+)
+
+const (
+	uploadRouterPath       = "/api/packages"
+	uploadDeleteRouterPath = "/api/packages/{packageName:[a-z0-9_]+}/{packageVersion}"
+
+	defaultUploadMaxSize = 100 << 20 // 100MiB
+
+	// uploadTempPattern is the pattern of the temporary files used while receiving packages.
+	// They don't have the .zip extension so they are ignored by the indexers.
+	uploadTempPattern = ".upload-*.tmp"
+)
+
+var packageNameRegexp = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// UploadConfig is the configuration of the API to upload and delete packages.
+type UploadConfig struct {
+	// Path is the writable directory where uploaded packages are stored. It must not be
+	// one of the package paths.
+	Path string `config:"path"`
+
+	// Scopes are the scopes allowed to upload and delete packages. If empty, any
+	// authenticated user can do it.
+	Scopes []string `config:"scopes"`
+
+	// MaxSize is the maximum size in bytes of the upload requests.
+	MaxSize int64 `config:"max_size"`
+}
+
+// uploadError is the body of the error responses of the upload API.
+type uploadError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type uploadHandler struct {
+	logger  *zap.Logger
+	indexer Indexer
+
+	// uploadIndexer is the indexer of the packages in path, refreshed after each change.
+	uploadIndexer *packages.FileSystemIndexer
+	path          string
+
+	scopes            []string
+	maxSize           int64
+	requireSignatures bool
+	afterChangeHook   func(ctx context.Context)
+
+	signatureVerifier       *packages.SignatureVerifier
+	rejectInvalidSignatures bool
+
+	// m serializes the changes in the upload path.
+	m sync.Mutex
+}
+
+type uploadOption func(*uploadHandler)
+
+func newUploadHandler(logger *zap.Logger, indexer Indexer, uploadIndexer *packages.FileSystemIndexer, path string, opts ...uploadOption) (*uploadHandler, error) {
+	if indexer == nil {
+		return nil, errors.New("indexer is required for upload handler")
+	}
+	if uploadIndexer == nil {
+		return nil, errors.New("upload indexer is required for upload handler")
+	}
+	if path == "" {
+		return nil, errors.New("upload path is required for upload handler")
+	}
+
+	h := &uploadHandler{
+		logger:        logger,
+		indexer:       indexer,
+		uploadIndexer: uploadIndexer,
+		path:          path,
+		maxSize:       defaultUploadMaxSize,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h, nil
+}
+
+func uploadWithScopes(scopes []string) uploadOption {
+	return func(h *uploadHandler) {
+		h.scopes = scopes
+	}
+}
+
+func uploadWithMaxSize(maxSize int64) uploadOption {
+	return func(h *uploadHandler) {
+		if maxSize > 0 {
+			h.maxSize = maxSize
+		}
+	}
+}
+
+func uploadWithRequireSignatures(require bool) uploadOption {
+	return func(h *uploadHandler) {
+		h.requireSignatures = require
+	}
+}
+
+func uploadWithSignatureVerifier(verifier *packages.SignatureVerifier, rejectInvalid bool) uploadOption {
+	return func(h *uploadHandler) {
+		h.signatureVerifier = verifier
+		h.rejectInvalidSignatures = rejectInvalid
+	}
+}
+
+func uploadWithAfterChangeHook(hook func(ctx context.Context)) uploadOption {
+	return func(h *uploadHandler) {
+		h.afterChangeHook = hook
+	}
+}
+
+// uploadPackageHandler receives a multipart form with the package zip in the `package` field, and
+// optionally its signature in the `signature` field.
+func (h *uploadHandler) uploadPackageHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := h.logger.With(apmzap.TraceContext(r.Context())...)
+
+		if !h.allowed(r) {
+			uploadErrorResponse(w, http.StatusForbidden, "forbidden", "not allowed to upload packages")
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxSize)
+		err := r.ParseMultipartForm(32 << 20)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				uploadErrorResponse(w, http.StatusRequestEntityTooLarge, "package_too_large", fmt.Sprintf("request larger than %d bytes", h.maxSize))
+				return
+			}
+			uploadErrorResponse(w, http.StatusBadRequest, "invalid_request", "invalid multipart form: "+err.Error())
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		packageFile, _, err := r.FormFile("package")
+		if err != nil {
+			uploadErrorResponse(w, http.StatusBadRequest, "invalid_request", "missing package file in the 'package' field")
+			return
+		}
+		defer packageFile.Close()
+
+		tmpPath, err := h.receive(packageFile)
+		if err != nil {
+			logger.Error("failed to store uploaded package", zap.Error(err))
+			uploadErrorResponse(w, http.StatusInternalServerError, "internal_error", "failed to store uploaded package")
+			return
+		}
+		defer os.Remove(tmpPath)
+		defer os.Remove(tmpPath + ".sig")
+
+		signatureFile, _, err := r.FormFile("signature")
+		switch {
+		case errors.Is(err, http.ErrMissingFile):
+		case err != nil:
+			uploadErrorResponse(w, http.StatusBadRequest, "invalid_request", "invalid signature file: "+err.Error())
+			return
+		default:
+			defer signatureFile.Close()
+			if err := writeFile(tmpPath+".sig", signatureFile); err != nil {
+				logger.Error("failed to store uploaded signature", zap.Error(err))
+				uploadErrorResponse(w, http.StatusInternalServerError, "internal_error", "failed to store uploaded signature")
+				return
+			}
+		}
+
+		p, code, err := h.loadPackage(tmpPath)
+		if err != nil {
+			uploadErrorResponse(w, http.StatusBadRequest, code, err.Error())
+			return
+		}
+
+		h.m.Lock()
+		defer h.m.Unlock()
+
+		exists, err := h.exists(r.Context(), p.Name, p.Version)
+		if err != nil {
+			logger.Error("failed to check existing packages", zap.Error(err))
+			uploadErrorResponse(w, http.StatusInternalServerError, "internal_error", "failed to check existing packages")
+			return
+		}
+		if exists {
+			uploadErrorResponse(w, http.StatusConflict, "duplicate_package", fmt.Sprintf("package %s-%s already exists", p.Name, p.Version))
+			return
+		}
+
+		// The signature is moved first, so the package is indexed with it.
+		targetPath := h.packagePath(p.Name, p.Version)
+		if p.SignaturePath != "" {
+			if err := os.Rename(tmpPath+".sig", targetPath+".sig"); err != nil {
+				logger.Error("failed to move uploaded signature", zap.Error(err))
+				uploadErrorResponse(w, http.StatusInternalServerError, "internal_error", "failed to store uploaded package")
+				return
+			}
+		}
+		if err := os.Rename(tmpPath, targetPath); err != nil {
+			os.Remove(targetPath + ".sig")
+			logger.Error("failed to move uploaded package", zap.Error(err))
+			uploadErrorResponse(w, http.StatusInternalServerError, "internal_error", "failed to store uploaded package")
+			return
+		}
+		logger.Info("package uploaded",
+			zap.String("package.name", p.Name),
+			zap.String("package.version", p.Version),
+			zap.String("package.path", targetPath))
+
+		if err := h.refresh(r.Context()); err != nil {
+			logger.Error("failed to update index of uploaded packages", zap.Error(err))
+			uploadErrorResponse(w, http.StatusInternalServerError, "internal_error", "package stored, but the index couldn't be updated")
+			return
+		}
+
+		noCacheHeaders(w)
+		jsonHeader(w)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{
+			"name":     p.Name,
+			"version":  p.Version,
+			"download": p.GetDownloadPath(),
+		})
+	})
+}
+
+// deletePackageHandler removes a package previously uploaded. Packages from other sources cannot be deleted.
+func (h *uploadHandler) deletePackageHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := h.logger.With(apmzap.TraceContext(r.Context())...)
+
+		if !h.allowed(r) {
+			uploadErrorResponse(w, http.StatusForbidden, "forbidden", "not allowed to delete packages")
+			return
+		}
+
+		vars := mux.Vars(r)
+		packageName := vars["packageName"]
+		packageVersion := vars["packageVersion"]
+		if _, err := semver.StrictNewVersion(packageVersion); err != nil {
+			uploadErrorResponse(w, http.StatusBadRequest, "invalid_request", "invalid package version")
+			return
+		}
+
+		h.m.Lock()
+		defer h.m.Unlock()
+
+		targetPath := h.packagePath(packageName, packageVersion)
+		err := os.Remove(targetPath)
+		if errors.Is(err, os.ErrNotExist) {
+			uploadErrorResponse(w, http.StatusNotFound, "not_found", fmt.Sprintf("package %s-%s not found in uploaded packages", packageName, packageVersion))
+			return
+		}
+		if err != nil {
+			logger.Error("failed to delete package", zap.Error(err))
+			uploadErrorResponse(w, http.StatusInternalServerError, "internal_error", "failed to delete package")
+			return
+		}
+		if err := os.Remove(targetPath + ".sig"); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Warn("failed to delete package signature", zap.Error(err))
+		}
+		logger.Info("package deleted",
+			zap.String("package.name", packageName),
+			zap.String("package.version", packageVersion))
+
+		if err := h.refresh(r.Context()); err != nil {
+			logger.Error("failed to update index of uploaded packages", zap.Error(err))
+			uploadErrorResponse(w, http.StatusInternalServerError, "internal_error", "package deleted, but the index couldn't be updated")
+			return
+		}
+
+		noCacheHeaders(w)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (h *uploadHandler) allowed(r *http.Request) bool {
+	if len(h.scopes) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(auth.Scopes(r.Context()), func(scope string) bool {
+		return slices.Contains(h.scopes, scope)
+	})
+}
+
+// receive writes the uploaded package to a temporary file in the upload path, so it can
+// be moved atomically to its final location.
+func (h *uploadHandler) receive(src multipart.File) (string, error) {
+	f, err := os.CreateTemp(h.path, uploadTempPattern)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, src); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// loadPackage reads and validates the package as the zip indexer does. It returns the code
+// of the error for the response if the package is not valid.
+func (h *uploadHandler) loadPackage(path string) (*packages.Package, string, error) {
+	p, err := packages.NewPackage(h.logger, path, packages.ZipFileSystemBuilder)
+	if err != nil {
+		return nil, "invalid_package", err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, "invalid_package", fmt.Errorf("invalid package: %w", err)
+	}
+	if !packageNameRegexp.MatchString(p.Name) {
+		return nil, "invalid_package", fmt.Errorf("invalid package name %q", p.Name)
+	}
+
+	fs, err := packages.NewZipPackageFileSystem(p)
+	if err != nil {
+		return nil, "invalid_package", err
+	}
+	defer fs.Close()
+	if expected := p.Name + "-" + p.Version; fs.Root() != expected {
+		return nil, "inconsistent_path", fmt.Errorf("package root directory %q doesn't match the name and version of the manifest (expected %q)", fs.Root(), expected)
+	}
+
+	if h.requireSignatures && p.SignaturePath == "" {
+		return nil, "missing_signature", fmt.Errorf("package %s-%s is missing a required signature file", p.Name, p.Version)
+	}
+	// Packages with invalid signatures would be rejected by the indexer.
+	if h.signatureVerifier != nil && h.rejectInvalidSignatures && p.SignaturePath != "" {
+		verification := h.signatureVerifier.Verify(path, path+".sig")
+		if !verification.Verified {
+			return nil, "invalid_signature", fmt.Errorf("package %s-%s has an invalid signature: %s", p.Name, p.Version, verification.Error)
+		}
+	}
+	return p, "", nil
+}
+
+// exists checks if the package is already served by any indexer, or stored in the upload path.
+func (h *uploadHandler) exists(ctx context.Context, name, version string) (bool, error) {
+	if _, err := os.Stat(h.packagePath(name, version)); err == nil {
+		return true, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+
+	opts := packages.NameVersionFilter(name, version)
+	opts.SkipPackageData = true
+	pkgs, err := h.indexer.Get(ctx, &opts)
+	if err != nil {
+		return false, err
+	}
+	return len(pkgs) > 0, nil
+}
+
+func (h *uploadHandler) refresh(ctx context.Context) error {
+	if err := h.uploadIndexer.Refresh(ctx); err != nil {
+		return err
+	}
+	if h.afterChangeHook != nil {
+		h.afterChangeHook(ctx)
+	}
+	return nil
+}
+
+func (h *uploadHandler) packagePath(name, version string) string {
+	return filepath.Join(h.path, name+"-"+version+".zip")
+}
+
+func writeFile(path string, src io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, src); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func uploadErrorResponse(w http.ResponseWriter, status int, code, message string) {
+	noCacheHeaders(w)
+	jsonHeader(w)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]uploadError{
+		"error": {Code: code, Message: message},
+	})
+}
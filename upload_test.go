@@ -0,0 +1,223 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/package-registry/internal/auth"
+	"github.com/elastic/package-registry/internal/util"
+	"github.com/elastic/package-registry/packages"
+)
+
+func TestUploadPackages(t *testing.T) {
+	logger := util.NewTestLogger()
+	uploadPath := t.TempDir()
+	config := defaultConfig
+	config.Auth = auth.Config{
+		Tokens: []auth.TokenConfig{
+			{Name: "ci", Token: "ci-secret", Scopes: []string{"publish"}},
+			{Name: "reader", Token: "reader-secret"},
+		},
+	}
+	config.Upload = UploadConfig{Path: uploadPath, Scopes: []string{"publish"}}
+
+	options := serverOptions{config: &config}
+	uploadIndexer, err := initUploadIndexer(logger, options)
+	require.NoError(t, err)
+	options.uploadIndexer = uploadIndexer
+	options.indexer = NewCombinedIndexer(uploadIndexer)
+	require.NoError(t, options.indexer.Init(t.Context()))
+	options.authenticator, err = initAuthenticator(logger, &config)
+	require.NoError(t, err)
+
+	router, err := getRouter(logger, options)
+	require.NoError(t, err)
+
+	packageZip, err := os.ReadFile("./testdata/local-storage/example-1.0.1.zip")
+	require.NoError(t, err)
+	signature, err := os.ReadFile("./testdata/local-storage/example-1.0.1.zip.sig")
+	require.NoError(t, err)
+
+	t.Run("forbidden", func(t *testing.T) {
+		recorder := uploadPackage(t, router, "reader-secret", packageZip, signature)
+		assertUploadError(t, recorder, http.StatusForbidden, "forbidden")
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		recorder := uploadPackage(t, router, "ci-secret", packageZip, nil)
+		assertUploadError(t, recorder, http.StatusBadRequest, "missing_signature")
+	})
+
+	t.Run("invalid package", func(t *testing.T) {
+		recorder := uploadPackage(t, router, "ci-secret", []byte("not a zip"), signature)
+		assertUploadError(t, recorder, http.StatusBadRequest, "invalid_package")
+	})
+
+	t.Run("inconsistent path", func(t *testing.T) {
+		renamed := renameZipRoot(t, packageZip, "example-1.0.1", "example-2.0.0")
+		recorder := uploadPackage(t, router, "ci-secret", renamed, signature)
+		assertUploadError(t, recorder, http.StatusBadRequest, "inconsistent_path")
+	})
+
+	t.Run("upload", func(t *testing.T) {
+		recorder := uploadPackage(t, router, "ci-secret", packageZip, signature)
+		require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+		assert.JSONEq(t, `{"name":"example","version":"1.0.1","download":"/epr/example/example-1.0.1.zip"}`, recorder.Body.String())
+
+		assert.FileExists(t, filepath.Join(uploadPath, "example-1.0.1.zip"))
+		assert.FileExists(t, filepath.Join(uploadPath, "example-1.0.1.zip.sig"))
+		assert.Equal(t, http.StatusOK, serveWithToken(router, "/package/example/1.0.1/", "reader-secret").Code)
+		assert.Equal(t, http.StatusOK, serveWithToken(router, "/epr/example/example-1.0.1.zip.sig", "reader-secret").Code)
+
+		// No temporary files are left behind.
+		entries, err := os.ReadDir(uploadPath)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		recorder := uploadPackage(t, router, "ci-secret", packageZip, signature)
+		assertUploadError(t, recorder, http.StatusConflict, "duplicate_package")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		recorder := deletePackage(router, "reader-secret", "/api/packages/example/1.0.1")
+		assertUploadError(t, recorder, http.StatusForbidden, "forbidden")
+
+		recorder = deletePackage(router, "ci-secret", "/api/packages/example/1.0.1")
+		require.Equal(t, http.StatusNoContent, recorder.Code, recorder.Body.String())
+		assert.NoFileExists(t, filepath.Join(uploadPath, "example-1.0.1.zip"))
+		assert.NoFileExists(t, filepath.Join(uploadPath, "example-1.0.1.zip.sig"))
+		assert.Equal(t, http.StatusNotFound, serveWithToken(router, "/package/example/1.0.1/", "reader-secret").Code)
+
+		recorder = deletePackage(router, "ci-secret", "/api/packages/example/1.0.1")
+		assertUploadError(t, recorder, http.StatusNotFound, "not_found")
+
+		recorder = deletePackage(router, "ci-secret", "/api/packages/example/invalid")
+		assertUploadError(t, recorder, http.StatusBadRequest, "invalid_request")
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		recorder := deletePackage(router, "", "/api/packages/example/1.0.1")
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+}
+
+func TestUploadPackageInvalidSignature(t *testing.T) {
+	logger := util.NewTestLogger()
+	uploadPath := t.TempDir()
+	uploadIndexer := packages.NewZipFileSystemIndexer(packages.FSIndexerOptions{Logger: logger}, uploadPath)
+	require.NoError(t, uploadIndexer.Init(t.Context()))
+
+	verifier, err := packages.NewSignatureVerifier("./cmd/distribution/GPG-KEY-elasticsearch")
+	require.NoError(t, err)
+	handler, err := newUploadHandler(logger, NewCombinedIndexer(uploadIndexer), uploadIndexer, uploadPath,
+		uploadWithSignatureVerifier(verifier, true),
+	)
+	require.NoError(t, err)
+
+	packageZip, err := os.ReadFile("./testdata/local-storage/example-1.0.1.zip")
+	require.NoError(t, err)
+	signature, err := os.ReadFile("./testdata/local-storage/example-1.0.1.zip.sig")
+	require.NoError(t, err)
+
+	recorder := uploadPackage(t, handler.uploadPackageHandler(), "", packageZip, signature)
+	assertUploadError(t, recorder, http.StatusBadRequest, "invalid_signature")
+	assert.NoFileExists(t, filepath.Join(uploadPath, "example-1.0.1.zip"))
+}
+
+func TestInitUploadIndexerErrors(t *testing.T) {
+	logger := util.NewTestLogger()
+	uploadPath := t.TempDir()
+	authConfig := auth.Config{Tokens: []auth.TokenConfig{{Name: "ci", Token: "secret"}}}
+
+	cases := map[string]Config{
+		"without authentication": {Upload: UploadConfig{Path: uploadPath}},
+		"missing path":           {Auth: authConfig, Upload: UploadConfig{Path: filepath.Join(uploadPath, "missing")}},
+		"package path":           {Auth: authConfig, Upload: UploadConfig{Path: uploadPath}, PackagePaths: []string{uploadPath + "/"}},
+	}
+	for name, config := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := initUploadIndexer(logger, serverOptions{config: &config})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func uploadPackage(t *testing.T, router http.Handler, token string, packageZip, signature []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("package", "package.zip")
+	require.NoError(t, err)
+	part.Write(packageZip)
+	if signature != nil {
+		part, err := form.CreateFormFile("signature", "package.zip.sig")
+		require.NoError(t, err)
+		part.Write(signature)
+	}
+	require.NoError(t, form.Close())
+
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodPost, "/api/packages", &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	request.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func deletePackage(router http.Handler, token, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodDelete, path, nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func assertUploadError(t *testing.T, recorder *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	require.Equal(t, status, recorder.Code, recorder.Body.String())
+	var response map[string]uploadError
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, code, response["error"].Code)
+}
+
+// renameZipRoot returns a copy of the zip file with the root directory renamed.
+func renameZipRoot(t *testing.T, content []byte, root, newRoot string) []byte {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+
+	var result bytes.Buffer
+	writer := zip.NewWriter(&result)
+	for _, f := range reader.File {
+		w, err := writer.Create(newRoot + strings.TrimPrefix(f.Name, root))
+		require.NoError(t, err)
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		r, err := f.Open()
+		require.NoError(t, err)
+		_, err = io.Copy(w, r)
+		r.Close()
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return result.Bytes()
+}